@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// promptArchive asks for a ref to export, prefilled with ref, and then
+// for where to write the archive.
+func (m *model) promptArchive(ref string) tea.Cmd {
+	return m.openPrompt("Archive ref: ", ref, func(m *model, ref string) (tea.Cmd, error) {
+		if !RefExists(ref) {
+			return nil, fmt.Errorf("unknown ref %q", ref)
+		}
+		return m.openPrompt("Write archive to: ", snapshotPath(m.dir, ref, ".zip"), func(m *model, path string) (tea.Cmd, error) {
+			return m.archive(ref, path)
+		}), nil
+	})
+}
+
+// archive runs git archive in the background. The format (zip, tar,
+// tar.gz or tgz) follows the file extension.
+func (m *model) archive(ref, path string) (tea.Cmd, error) {
+	path, err := outputPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return m.startOp("Archive to "+path, "Archiving "+ref, func() error {
+		return Archive(ref, path)
+	}), nil
+}
+
+// cutHome returns the rest of a path starting with ~/, or on Windows
+// also ~\, or false if it doesn't.
+func cutHome(path string) (string, bool) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return rest, true
+	}
+	return strings.CutPrefix(path, "~"+string(filepath.Separator))
+}
+
+// expandPath resolves a path typed at a prompt, expanding a leading ~/.
+func expandPath(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("no path given")
+	}
+	if rest, ok := cutHome(path); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	return filepath.Abs(path)
+}
+
+// outputPath is expandPath for a file about to be written, which must not
+// exist yet.
+func outputPath(path string) (string, error) {
+	path, err := expandPath(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("%s already exists", path)
+	}
+	return path, nil
+}
+
+// snapshotPath suggests <repo>-<ref><ext> next to the repository, so the
+// file doesn't show up as untracked.
+func snapshotPath(dir, ref, ext string) string {
+	name := filepath.Base(dir) + "-" + strings.NewReplacer("/", "-", "~", "-", "^", "-", " ", "_").Replace(ref) + ext
+	return filepath.Join(filepath.Dir(dir), name)
+}
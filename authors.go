@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"strings"
+)
+
+// fileAuthors caches the last authors shown in each panel
+type fileAuthors struct {
+	changes authorCache // as of HEAD
+	branch  authorCache // as of the merge base
+}
+
+// authorCache holds the last author of files as of one revision, so the
+// author column only runs git log for files it hasn't seen
+type authorCache struct {
+	rev     string
+	authors map[string]string // file -> author; empty if it has no history
+}
+
+// lookup fills in the authors of files as of rev, starting over when rev
+// has moved.
+func (c *authorCache) lookup(ctx context.Context, rev string, files []string) {
+	if c.authors == nil || c.rev != rev {
+		c.rev, c.authors = rev, make(map[string]string)
+	}
+	var missing []string
+	for _, f := range files {
+		if _, ok := c.authors[f]; !ok {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	found := GetLastAuthors(ctx, rev, historyPaths(missing))
+	for _, f := range missing {
+		c.authors[f] = found[historyPath(f)]
+	}
+}
+
+// clone copies the caches, for a refresh to add to while the UI reads them.
+func (a fileAuthors) clone() fileAuthors {
+	a.changes.authors = maps.Clone(a.changes.authors)
+	a.branch.authors = maps.Clone(a.branch.authors)
+	return a
+}
+
+// loadAuthors updates the author column after it's turned on, dropping
+// any refresh in flight, which started with it off.
+func (m *model) loadAuthors() {
+	m.cancelRefresh()
+	*m.authors = m.newLoadRun().authors()
+}
+
+// authors updates the author column: for Changed Files as of HEAD, and
+// for Branch Files as of the merge base, i.e. whose code the branch changes.
+func (r *loadRun) authors() fileAuthors {
+	authors := r.set.authors
+	if !r.set.showAuthors {
+		return authors
+	}
+	if r.s.hasCommit {
+		var files []string
+		for _, c := range r.s.changes {
+			if c.Staged != '?' && c.Staged != '!' {
+				files = append(files, c.File)
+			}
+		}
+		authors.changes.lookup(r.ctx, r.s.lastCommit.Hash, files)
+	}
+	if len(r.s.branchFiles) > 0 {
+		if base := r.mergeBase(); base != "" {
+			var files []string
+			for _, bf := range r.s.branchFiles {
+				files = append(files, bf.File)
+			}
+			authors.branch.lookup(r.ctx, base, files)
+		}
+	}
+	return authors
+}
+
+// author returns the last author of a file in a panel, if known.
+func (m model) author(panel, file string) string {
+	if !m.showAuthors || m.narrow() {
+		return ""
+	}
+	if panel == panelBranch {
+		return m.authors.branch.authors[file]
+	}
+	return m.authors.changes.authors[file]
+}
+
+// withAuthor appends a panel file's last author to its rendered name.
+func (m model) withAuthor(panel, file, name string) string {
+	if a := m.author(panel, file); a != "" {
+		return name + helpStyle.Render("  "+a)
+	}
+	return name
+}
+
+// historyPath returns the path a file had before a rename, which is where
+// its history is.
+func historyPath(file string) string {
+	if from, _, ok := strings.Cut(file, " -> "); ok {
+		return from
+	}
+	if from, _, ok := strings.Cut(file, "\t"); ok {
+		return from
+	}
+	return file
+}
+
+func historyPaths(files []string) []string {
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = historyPath(f)
+	}
+	return paths
+}
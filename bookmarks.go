@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Bookmark is a repository to jump to from the bookmarks palette
+type Bookmark struct {
+	Name string `json:"name"` // defaults to the directory's name
+	Path string `json:"path"` // may start with ~/
+}
+
+func (b *Bookmark) validate() error {
+	if b.Path == "" {
+		return fmt.Errorf("missing path")
+	}
+	if rest, ok := cutHome(b.Path); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		b.Path = filepath.Join(home, rest)
+	}
+	if b.Name == "" {
+		b.Name = filepath.Base(b.Path)
+	}
+	return nil
+}
+
+// paletteState holds the bookmarks palette: a query narrowing the list
+// as it's typed
+type paletteState struct {
+	input   textinput.Model
+	matches []int // indexes into m.bookmarks, in display order
+}
+
+// openBookmarks switches to the bookmarks palette.
+func (m *model) openBookmarks() tea.Cmd {
+	if len(m.bookmarks) == 0 {
+		m.notify("No bookmarks; add repositories to \"bookmarks\" in the config")
+		return nil
+	}
+	input := textinput.New()
+	input.Prompt = "Jump to: "
+	input.CharLimit = 256
+	m.palette = paletteState{input: input}
+	m.matchBookmarks()
+	m.view = viewBookmarks
+	m.viewport.GotoTop()
+	m.resize()
+	return m.palette.input.Focus()
+}
+
+// matchBookmarks narrows the list to bookmarks whose name or path matches
+// the query.
+func (m *model) matchBookmarks() {
+	query := strings.TrimSpace(m.palette.input.Value())
+	m.palette.matches = m.palette.matches[:0]
+	for i, b := range m.bookmarks {
+		if _, ok := fuzzyMatch(query, b.Name); ok {
+			m.palette.matches = append(m.palette.matches, i)
+		} else if _, ok := fuzzyMatch(query, b.Path); ok {
+			m.palette.matches = append(m.palette.matches, i)
+		}
+	}
+	m.cursor = 0
+}
+
+// updateBookmarks handles key input in the bookmarks palette. Typing
+// narrows the list, and enter switches to the selected repository.
+func (m model) updateBookmarks(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := &m.palette
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		p.input.Blur()
+		m.view = viewFiles
+		m.resize()
+		return m, tea.ClearScreen
+	case "up", "ctrl+p":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "ctrl+n":
+		m.cursor = min(m.cursor+1, max(len(p.matches)-1, 0))
+	case "enter":
+		if m.cursor >= len(p.matches) {
+			return m, nil
+		}
+		b := m.bookmarks[p.matches[m.cursor]]
+		if err := m.switchRepo(b.Path); err != nil {
+			m.notifyErr(fmt.Errorf("%s: %w", b.Name, err))
+			return m, nil
+		}
+		m.outer = nil
+		p.input.Blur()
+		m.view = viewFiles
+		m.resize()
+		m.notify("Switched to " + b.Name)
+		return m, tea.Batch(checkUpstream, m.loadPR(), tea.ClearScreen)
+	default:
+		var cmd tea.Cmd
+		query := p.input.Value()
+		p.input, cmd = p.input.Update(msg)
+		if p.input.Value() != query {
+			m.matchBookmarks()
+		}
+		m.resize()
+		return m, cmd
+	}
+
+	m.resize()
+	m.scrollTo(m.cursor + 1)
+	return m, nil
+}
+
+// switchRepo re-roots vigil in another repository, reloading everything
+// kept per repository.
+func (m *model) switchRepo(dir string) error {
+	restore := clearGitEnv()
+	if err := os.Chdir(dir); err != nil {
+		restore()
+		return err
+	}
+	if !IsGitRepo() {
+		os.Chdir(m.dir)
+		restore()
+		return fmt.Errorf("not a git repository")
+	}
+	state, err := LoadRepoState()
+	if err != nil {
+		os.Chdir(m.dir)
+		restore()
+		return err
+	}
+
+	m.dir, _ = os.Getwd()
+	m.state = state
+	if m.base != "" && !RefExists(m.base) {
+		m.base = "" // back to the default branch
+	}
+	m.upstream, m.ahead, m.behind, m.upstreamErr, m.counted = "", 0, 0, nil, false
+	m.remotes = nil
+	m.readOnly = IsReadOnly()
+	if m.readOnly {
+		m.autoFetch = false
+	}
+	m.pr, m.hasPR = PullRequest{}, false
+	m.lastFetched = time.Time{}
+	m.collapsed = make(map[string]bool)
+	m.marked = make(map[string]bool)
+	m.heat = nil
+	m.focus = ""
+	m.files.cursor = 0
+	m.setFilter("")
+	m.refresh()
+	return nil
+}
+
+func (m model) renderBookmarks() string {
+	var body strings.Builder
+	body.WriteString(m.palette.input.View() + "\n")
+	if len(m.palette.matches) == 0 {
+		body.WriteString(helpStyle.Render("  No bookmarks match"))
+		return body.String()
+	}
+
+	width := 0
+	for _, i := range m.palette.matches {
+		width = max(width, len(m.bookmarks[i].Name))
+	}
+	for row, i := range m.palette.matches {
+		b := m.bookmarks[i]
+		marker := "  "
+		if samePath(b.Path, m.dir) {
+			marker = "* "
+		}
+		line := m.cursorColumn(row == m.cursor) + marker + branchStyle.Render(fmt.Sprintf("%-*s", width, b.Name))
+		if !m.narrow() {
+			line += "  " + pathStyle.Render(b.Path)
+		}
+		body.WriteString(line + "\n")
+	}
+	return body.String()
+}
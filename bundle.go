@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bundleVerifiedMsg reports the refs in a bundle that's about to be imported
+type bundleVerifiedMsg struct {
+	path string
+	refs []string
+	err  error
+}
+
+// promptBundle asks which refs to bundle, defaulting to the current
+// branch, and then where to write the bundle.
+func (m *model) promptBundle() tea.Cmd {
+	ref := m.branch
+	if !RefExists(ref) {
+		ref = "HEAD"
+	}
+	return m.openPrompt("Bundle refs: ", ref, func(m *model, value string) (tea.Cmd, error) {
+		refs := strings.Fields(value)
+		if len(refs) == 0 {
+			return nil, fmt.Errorf("no refs given")
+		}
+		for _, ref := range refs {
+			if !RefExists(ref) {
+				return nil, fmt.Errorf("unknown ref %q", ref)
+			}
+		}
+		return m.openPrompt("Write bundle to: ", snapshotPath(m.dir, strings.Join(refs, "+"), ".bundle"), func(m *model, path string) (tea.Cmd, error) {
+			path, err := outputPath(path)
+			if err != nil {
+				return nil, err
+			}
+			return m.startOp("Bundle to "+path, "Bundling "+strings.Join(refs, " "), func() error {
+				return CreateBundle(path, refs)
+			}), nil
+		}), nil
+	})
+}
+
+// promptImportBundle asks for a bundle to import and verifies it in the
+// background; importing waits for confirmation once the bundle checks out.
+func (m *model) promptImportBundle() tea.Cmd {
+	if m.readOnlyBlocked("import bundles") {
+		return nil
+	}
+	return m.openPrompt("Import bundle: ", "", func(m *model, path string) (tea.Cmd, error) {
+		path, err := expandPath(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+		if m.jobs.busy != "" {
+			return nil, fmt.Errorf("%s in progress", m.jobs.busy)
+		}
+		return tea.Batch(m.jobs.start("Verifying "+filepath.Base(path)), func() tea.Msg {
+			refs, err := VerifyBundle(path)
+			return bundleVerifiedMsg{path: path, refs: refs, err: err}
+		}), nil
+	})
+}
+
+// confirmImportBundle asks before fetching a verified bundle's refs.
+func (m *model) confirmImportBundle(msg bundleVerifiedMsg) {
+	m.jobs.busy = ""
+	if msg.err != nil {
+		m.notifyErr(fmt.Errorf("bundle verify failed: %w", msg.err))
+		return
+	}
+	if len(msg.refs) == 0 {
+		m.notify(filepath.Base(msg.path) + " holds no refs")
+		return
+	}
+	m.confirm = &confirmation{
+		prompt: fmt.Sprintf("%s is valid (%s). Fetch branches into bundle/ and tags?", filepath.Base(msg.path), strings.Join(msg.refs, ", ")),
+		action: func(m *model) tea.Cmd {
+			return m.startOp("Import bundle", "Importing "+filepath.Base(msg.path), func() error {
+				return ImportBundle(msg.path)
+			})
+		},
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pickStep is where the changelog view is in choosing its two tags
+type pickStep int
+
+const (
+	pickFrom pickStep = iota
+	pickTo
+	pickDone
+)
+
+// changelogState holds the tags pane and the tag-to-tag changelog view
+// it leads to
+type changelogState struct {
+	step    pickStep
+	tags    []Tag // newest first
+	from    string
+	to      string
+	commits []Commit
+	files   []BranchFile
+}
+
+// openChangelog switches to the tags pane, where picking a tag starts a
+// changelog from it.
+func (m *model) openChangelog() {
+	m.changelog = changelogState{tags: GetTags()}
+	m.view = viewChangelog
+	m.cursor = 0
+	m.viewport.GotoTop()
+	m.resize()
+}
+
+// pickerOptions returns the refs offered by the current picker step. The
+// end of the range may also be HEAD, for unreleased changes.
+func (c changelogState) pickerOptions() []string {
+	var names []string
+	if c.step == pickTo {
+		names = append(names, "HEAD")
+	}
+	for _, t := range c.tags {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+// updateChangelog handles key input in the changelog view.
+func (m model) updateChangelog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := &m.changelog
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "T":
+		m.view = viewFiles
+		m.resize()
+		return m, tea.ClearScreen
+	case "backspace":
+		if c.step > pickFrom {
+			c.step--
+			m.cursor = 0
+			m.viewport.GotoTop()
+		}
+	case "up", "k":
+		if c.step == pickDone {
+			m.viewport.LineUp(1)
+			return m, nil
+		}
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		if c.step == pickDone {
+			m.viewport.LineDown(1)
+			return m, nil
+		}
+		m.cursor = min(m.cursor+1, max(len(c.pickerOptions())-1, 0))
+	case "E":
+		if options := c.pickerOptions(); c.step != pickDone && m.cursor < len(options) {
+			return m, m.promptArchive(options[m.cursor])
+		}
+		return m, nil
+	case "a":
+		if m.readOnlyBlocked("create tags") {
+			return m, nil
+		}
+		return m, m.openPrompt("Annotated tag at HEAD: ", "", func(m *model, name string) (tea.Cmd, error) {
+			if err := ValidTagName(name); err != nil {
+				return nil, err
+			}
+			return m.openPrompt("Message for "+name+": ", name, func(m *model, message string) (tea.Cmd, error) {
+				return m.createTag(name, message)
+			}), nil
+		})
+	case "d":
+		m.showDiffSinceTag()
+		return m, nil
+	case "Y":
+		if c.step == pickDone {
+			title := c.to
+			if title == "HEAD" {
+				title = "Unreleased"
+			}
+			m.promptDraft(title, c.commits, c.from+".."+c.to)
+		}
+		return m, nil
+	case "enter":
+		options := c.pickerOptions()
+		if c.step == pickDone || m.cursor >= len(options) {
+			return m, nil
+		}
+		if c.step == pickFrom {
+			c.from = options[m.cursor]
+			c.step = pickTo
+		} else {
+			c.to = options[m.cursor]
+			c.commits = GetCommitsBetween(c.from, c.to)
+			c.files = GetDiffFiles(c.from, c.to)
+			c.step = pickDone
+		}
+		m.cursor = 0
+		m.viewport.GotoTop()
+	}
+
+	m.resize()
+	if c.step != pickDone {
+		m.scrollTo(m.cursor + 1)
+	}
+	return m, nil
+}
+
+// createTag creates an annotated tag at HEAD and lists it.
+func (m *model) createTag(name, message string) (tea.Cmd, error) {
+	if message == "" {
+		return nil, fmt.Errorf("an annotated tag needs a message")
+	}
+	if err := CreateTag(name, message); err != nil {
+		return nil, err
+	}
+	m.notify("Tagged HEAD " + name)
+	m.openChangelog()
+	return nil, nil
+}
+
+// showDiffSinceTag shows, in the output pane, what HEAD has changed since
+// the most recent tag it contains: what a release cut now would ship.
+func (m *model) showDiffSinceTag() {
+	release, ok := GetRelease()
+	if !ok {
+		m.notify("No tag is reachable from HEAD")
+		return
+	}
+	if release.Distance == 0 {
+		m.notify("HEAD is tagged " + release.Tag + "; nothing since")
+		return
+	}
+	diff, err := GetDiff(release.Tag, "HEAD")
+	m.output = outputState{
+		title:  fmt.Sprintf("Diff since %s (%s)", release.Tag, plural(release.Distance, "commit")),
+		lines:  diff,
+		err:    err,
+		parent: m.view,
+		diff:   true,
+	}
+	m.view = viewOutput
+	m.viewport.GotoTop()
+	m.resize()
+}
+
+func (m model) renderChangelog() string {
+	c := m.changelog
+	var body strings.Builder
+
+	if c.step != pickDone {
+		if c.step == pickFrom {
+			return m.renderTags()
+		}
+		body.WriteString(fmt.Sprintf("Changelog from %s: pick the ending tag\n", tagStyle.Render(c.from)))
+		for i, option := range c.pickerOptions() {
+			body.WriteString(m.cursorColumn(i == m.cursor) + tagStyle.Render(option) + "\n")
+		}
+		return body.String()
+	}
+
+	body.WriteString(fmt.Sprintf("Changelog %s..%s", tagStyle.Render(c.from), tagStyle.Render(c.to)))
+	body.WriteString(helpStyle.Render(fmt.Sprintf(" (%s, %s)", plural(len(c.commits), "commit"), plural(len(c.files), "file"))))
+	body.WriteString("\n\nCommits:\n")
+	if len(c.commits) == 0 {
+		body.WriteString(helpStyle.Render("  None") + "\n")
+	}
+	for _, commit := range c.commits {
+		line := fmt.Sprintf("  %s %s %s", commitHashStyle.Render(commit.Hash), commit.Subject, helpStyle.Render("("+commit.Author+", "+m.timeAgo(commit.Time)+")"))
+		if m.narrow() {
+			line = truncate(fmt.Sprintf("%s %s", commitHashStyle.Render(commit.Hash), commit.Subject), m.width)
+		}
+		body.WriteString(line + "\n")
+	}
+
+	body.WriteString("\nFiles:\n")
+	if len(c.files) == 0 {
+		body.WriteString(helpStyle.Render("  None") + "\n")
+	}
+	for _, f := range c.files {
+		label := fmt.Sprintf("%-12s", branchFileLabel(f.Status))
+		if m.narrow() {
+			label = f.Status[:1]
+		}
+		body.WriteString(fmt.Sprintf("  %s  %s\n", branchFileStyle(f.Status).Render(label), fileStyle.Render(f.File)))
+	}
+	return body.String()
+}
+
+// renderTags renders the tags pane: each tag, whether it's annotated or
+// lightweight, and what it says.
+func (m model) renderTags() string {
+	c := m.changelog
+	var body strings.Builder
+	body.WriteString("Tags: pick one to start a changelog from\n")
+	if len(c.tags) == 0 {
+		body.WriteString(helpStyle.Render("  No tags found"))
+		return body.String()
+	}
+
+	width := 0
+	for _, t := range c.tags {
+		width = max(width, len(t.Name))
+	}
+	for i, t := range c.tags {
+		kind := "annotated  "
+		if !t.Annotated {
+			kind = "lightweight"
+		}
+		line := fmt.Sprintf("%s %s %s %s %s", tagStyle.Render(fmt.Sprintf("%-*s", width, t.Name)), helpStyle.Render(kind), commitHashStyle.Render(t.Hash), t.Subject, helpStyle.Render("("+m.timeAgo(t.Time)+")"))
+		if m.narrow() {
+			line = truncate(fmt.Sprintf("%s %s %s", helpStyle.Render(kind[:1]), tagStyle.Render(t.Name), t.Subject), m.width-1)
+		}
+		body.WriteString(m.cursorColumn(i == m.cursor) + line + "\n")
+	}
+	return body.String()
+}
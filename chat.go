@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Conditions a chat alert can watch for
+const (
+	whenBehind    = "behind"    // commits behind upstream
+	whenAhead     = "ahead"     // commits ahead of upstream
+	whenConflicts = "conflicts" // conflicted files
+	whenChanges   = "changes"   // files with uncommitted changes
+)
+
+// defaultAlertMessages are used when an alert doesn't set its own message
+var defaultAlertMessages = map[string]string{
+	whenBehind:    "{{.Repo}}: {{.Branch}} is {{.Count}} behind upstream",
+	whenAhead:     "{{.Repo}}: {{.Branch}} has {{.Count}} unpushed commits",
+	whenConflicts: "{{.Repo}}: {{.Count}} conflicted files on {{.Branch}}",
+	whenChanges:   "{{.Repo}}: {{.Count}} uncommitted files on {{.Branch}}",
+}
+
+// ChatAlert posts a message to a Slack or Discord incoming webhook when a
+// count reaches a threshold, e.g. a release branch falling 15 behind
+type ChatAlert struct {
+	Service   string `json:"service"`   // slack or discord
+	URL       string `json:"url"`       // the channel's incoming webhook URL
+	When      string `json:"when"`      // behind, ahead, conflicts or changes
+	Threshold int    `json:"threshold"` // post once the count reaches this; default 1
+	Branch    string `json:"branch"`    // only on branches matching this pattern, e.g. release/*
+	Message   string `json:"message"`   // a text/template over alertVars
+
+	// Interval is the fewest minutes between two posts of this alert, so
+	// a flapping count doesn't flood the channel; default 10
+	Interval int `json:"interval_minutes"`
+}
+
+// alertVars are the template variables available to alert messages
+type alertVars struct {
+	Repo      string // base name of the repository root
+	Dir       string
+	Branch    string
+	Count     int // the count the alert watches
+	Threshold int
+	Ahead     int
+	Behind    int
+}
+
+// alertState tracks whether an alert's condition holds and when it last
+// posted
+type alertState struct {
+	firing   bool
+	lastSent time.Time
+}
+
+func (a ChatAlert) validate() error {
+	switch a.Service {
+	case "slack", "discord":
+	default:
+		return fmt.Errorf("service must be \"slack\" or \"discord\", got %q", a.Service)
+	}
+	if err := (Webhook{URL: a.URL}).validate(); err != nil {
+		return err
+	}
+	if _, ok := defaultAlertMessages[a.When]; !ok {
+		return fmt.Errorf("when must be %q, %q, %q or %q, got %q", whenBehind, whenAhead, whenConflicts, whenChanges, a.When)
+	}
+	if _, err := path.Match(a.Branch, ""); err != nil {
+		return fmt.Errorf("branch: %v", err)
+	}
+	_, err := template.New(a.When).Parse(a.message())
+	return err
+}
+
+func (a ChatAlert) message() string {
+	if a.Message != "" {
+		return a.Message
+	}
+	return defaultAlertMessages[a.When]
+}
+
+func (a ChatAlert) threshold() int {
+	return max(a.Threshold, 1)
+}
+
+func (a ChatAlert) interval() time.Duration {
+	if a.Interval <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(a.Interval) * time.Minute
+}
+
+// alertCount returns the number an alert watches, or false if it's unknown,
+// such as behind without an upstream.
+func (m model) alertCount(when string) (int, bool) {
+	switch when {
+	case whenBehind:
+		return m.behind, m.counted && m.upstreamErr == nil
+	case whenAhead:
+		return m.ahead, m.counted && m.upstreamErr == nil
+	case whenConflicts:
+		return m.summary.Conflicts, true
+	default:
+		return len(m.changes), true
+	}
+}
+
+// checkAlerts posts the chat alerts whose condition has just started to
+// hold. Posting happens in the background like webhooks, and failures
+// are reported the same way.
+func (m *model) checkAlerts() {
+	for i, a := range m.alerts {
+		state := &m.alertStates[i]
+		count, known := m.alertCount(a.When)
+		if !known {
+			continue
+		}
+		matches, _ := path.Match(a.Branch, m.branch) // validated on load
+		firing := count >= a.threshold() && (a.Branch == "" || matches)
+		if firing && !state.firing && time.Since(state.lastSent) >= a.interval() {
+			state.lastSent = time.Now()
+			go postAlert(a, m.alertText(a, count), m.webhookErrs)
+		}
+		state.firing = firing
+	}
+}
+
+func (m model) alertText(a ChatAlert, count int) string {
+	vars := alertVars{
+		Repo:      filepath.Base(m.dir),
+		Dir:       m.dir,
+		Branch:    m.branch,
+		Count:     count,
+		Threshold: a.threshold(),
+		Ahead:     m.ahead,
+		Behind:    m.behind,
+	}
+	tmpl := template.Must(template.New(a.When).Parse(a.message())) // validated on load
+	var text strings.Builder
+	if err := tmpl.Execute(&text, vars); err != nil {
+		return err.Error()
+	}
+	return text.String()
+}
+
+func postAlert(a ChatAlert, text string, errs chan<- error) {
+	body := map[string]string{"text": text}
+	if a.Service == "discord" {
+		body = map[string]string{"content": text}
+	}
+	data, err := json.Marshal(body)
+	if err == nil {
+		err = post(a.URL, data)
+	}
+	if err != nil {
+		select {
+		case errs <- fmt.Errorf("%s alert: %v", a.Service, err):
+		default:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pickState holds the log of another branch's commits, to cherry-pick
+// some onto the current branch
+type pickState struct {
+	branch  string
+	commits []Commit // not on HEAD yet, newest first
+	marked  map[string]bool
+}
+
+// openPick switches to the commits branch has that HEAD doesn't.
+func (m *model) openPick(branch string) {
+	if branch == m.branch {
+		m.notify("Pick commits from another branch")
+		return
+	}
+	m.pick = pickState{branch: branch, commits: GetCommitsToPick(branch), marked: make(map[string]bool)}
+	m.view = viewPick
+	m.cursor = 0
+	m.viewport.GotoTop()
+	m.resize()
+}
+
+// updatePick handles key input in the branch log: space marks commits,
+// and enter cherry-picks the marked ones, or the selected one.
+func (m model) updatePick(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := &m.pick
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.view = viewStack
+		m.cursor = max(slices.IndexFunc(m.stack.branches, func(b stackBranch) bool { return b.name == p.branch }), 0)
+		m.resize()
+		return m, tea.ClearScreen
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(p.commits)-1, 0))
+	case " ":
+		if m.cursor < len(p.commits) {
+			hash := p.commits[m.cursor].Hash
+			p.marked[hash] = !p.marked[hash]
+			m.cursor = min(m.cursor+1, len(p.commits)-1)
+		}
+	case "enter":
+		if m.cursor >= len(p.commits) || m.toolConflict("cherry-pick", "jj duplicate") {
+			return m, nil
+		}
+		m.confirmCherryPick()
+		return m, nil
+	}
+
+	m.resize()
+	m.scrollTo(m.cursor + 1)
+	return m, nil
+}
+
+// confirmCherryPick asks before applying the marked commits, or the
+// selected one, oldest first so each lands on the ones it builds on.
+func (m *model) confirmCherryPick() {
+	p := m.pick
+	var hashes []string
+	for i := len(p.commits) - 1; i >= 0; i-- {
+		if p.marked[p.commits[i].Hash] {
+			hashes = append(hashes, p.commits[i].Hash)
+		}
+	}
+	what := plural(len(hashes), "commit")
+	if len(hashes) == 0 {
+		c := p.commits[m.cursor]
+		hashes = []string{c.Hash}
+		what = c.Hash + " (" + truncate(c.Subject, 40) + ")"
+	}
+	m.confirm = &confirmation{
+		prompt: fmt.Sprintf("Cherry-pick %s from %s onto %s?", what, p.branch, m.branch),
+		action: func(m *model) tea.Cmd {
+			// Conflicts show up in Changed Files
+			m.view = viewFiles
+			m.files.cursor = 0
+			err := CherryPick(hashes...)
+			return m.sequenceDone(sequenceDoneMsg{op: opCherryPick, err: err})
+		},
+	}
+}
+
+func (m model) renderPick() string {
+	p := m.pick
+	var body strings.Builder
+	body.WriteString(fmt.Sprintf("Commits on %s not on %s:\n", branchStyle.Render(p.branch), branchStyle.Render(m.branch)))
+	if len(p.commits) == 0 {
+		body.WriteString(helpStyle.Render("  None; " + m.branch + " has them all"))
+		return body.String()
+	}
+
+	for i, c := range p.commits {
+		mark := "  "
+		if p.marked[c.Hash] {
+			mark = statusAdded.Render(glyphs.Mark) + " "
+		}
+		line := fmt.Sprintf("%s %s %s", commitHashStyle.Render(c.Hash), c.Subject, helpStyle.Render("("+c.Author+", "+m.timeAgo(c.Time)+")"))
+		if m.narrow() {
+			line = truncate(fmt.Sprintf("%s %s", commitHashStyle.Render(c.Hash), c.Subject), m.width-3)
+		}
+		body.WriteString(m.cursorColumn(i == m.cursor) + mark + line + "\n")
+	}
+	return body.String()
+}
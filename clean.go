@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// promptClean starts removing untracked files: the selected one or the
+// whole tree, picked first if an untracked file is selected.
+func (m *model) promptClean() {
+	if m.toolConflict("clean", "jj file untrack or rm") {
+		return
+	}
+	if file, ok := m.selectedFile(); ok {
+		if c, found := m.change(file); found && c.Staged == '?' {
+			m.choice = &choice{
+				prompt:  "Clean untracked:",
+				options: []string{c.File, "whole tree"},
+				action: func(m *model, option string) tea.Cmd {
+					if option == c.File {
+						m.previewClean(c.File)
+					} else {
+						m.previewClean()
+					}
+					return nil
+				},
+			}
+			return
+		}
+	}
+	m.previewClean()
+}
+
+// previewClean lists what git clean would remove in the output pane and
+// asks before removing exactly those paths.
+func (m *model) previewClean(paths ...string) {
+	removals, err := CleanPreview(paths...)
+	if err != nil {
+		m.notifyErr(err)
+		return
+	}
+	if len(removals) == 0 {
+		m.notify("Nothing to clean")
+		return
+	}
+
+	m.output = outputState{title: "$ git clean -nd " + strings.Join(paths, " "), parent: m.view}
+	for _, r := range removals {
+		m.output.lines = append(m.output.lines, "Would remove "+r)
+	}
+	m.view = viewOutput
+	m.viewport.GotoTop()
+	m.resize()
+
+	m.confirm = &confirmation{
+		prompt: fmt.Sprintf("Delete %s? This can't be undone.", plural(len(removals), "untracked path")),
+		action: func(m *model) tea.Cmd {
+			if err := Clean(removals...); err != nil {
+				m.notifyErr(err)
+				return nil
+			}
+			m.view = m.output.parent
+			m.notify("Removed " + plural(len(removals), "untracked path"))
+			m.refresh()
+			return tea.ClearScreen
+		},
+	}
+}
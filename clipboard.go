@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Things the copy menu can copy for the selected file
+const (
+	copyPath     = "path"
+	copyAbsolute = "absolute path"
+	copyDiff     = "diff"
+)
+
+// promptCopy offers to copy the selected file's path, absolute path or
+// diff to the clipboard.
+func (m *model) promptCopy() {
+	row, ok := m.selectedRow()
+	if !ok || row.file == "" {
+		return
+	}
+	m.choice = &choice{
+		prompt:  "Copy:",
+		options: []string{copyPath, copyAbsolute, copyDiff},
+		action: func(m *model, option string) tea.Cmd {
+			m.copyFile(row, option)
+			return nil
+		},
+	}
+}
+
+// copyFile copies one of the copy menu's options for a file row.
+func (m *model) copyFile(row listRow, what string) {
+	file := treePath(row.file)
+	var text, desc string
+	switch what {
+	case copyPath:
+		text, desc = file, file
+	case copyAbsolute:
+		root, err := GetRepoRoot()
+		if err != nil {
+			m.notifyErr(err)
+			return
+		}
+		text = filepath.Join(root, file)
+		desc = text
+	case copyDiff:
+		diff, err := m.rowDiff(row)
+		if err != nil {
+			m.notifyErr(err)
+			return
+		}
+		if len(diff) == 0 {
+			m.notify("No diff for " + file)
+			return
+		}
+		text = strings.Join(diff, "\n") + "\n"
+		desc = "the diff of " + file + " (" + plural(len(diff), "line") + ")"
+	}
+	m.notify(fmt.Sprintf("Copied %s via %s", desc, copyToClipboard(text)))
+}
+
+// rowDiff returns the diff shown for a file row: its uncommitted changes,
+// or for branch files what the branch changed since the merge base.
+func (m model) rowDiff(row listRow) ([]string, error) {
+	if f := m.fileSize(row.panel, row.file); f.skipsDiff() {
+		return nil, fmt.Errorf("%s is %s, so its diff isn't shown", treePath(row.file), f)
+	}
+	if row.panel == panelBranch {
+		base, err := GetMergeBase(m.base)
+		if err != nil {
+			return nil, err
+		}
+		return GetFileDiff(base, "HEAD", row.file)
+	}
+	c, ok := m.change(row.file)
+	if !ok {
+		return nil, fmt.Errorf("%s has no changes", row.file)
+	}
+	return GetWorkingDiff(changePaths(c), c.Staged == '?')
+}
+
+// sshSession reports whether vigil is running over SSH, where the local
+// clipboard and browser belong to another machine.
+func sshSession() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
+
+// clipboardCommands are native clipboard tools, tried in order
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"},
+}
+
+// copyToClipboard puts text on the system clipboard, returning how. A
+// native tool is used if one works; over SSH, or when none does, the
+// terminal is asked to do it with OSC 52, which most modern terminals
+// (and tmux with set-clipboard on) support.
+func copyToClipboard(text string) string {
+	if !sshSession() {
+		for _, args := range clipboardCommands {
+			if args[0] == "pbcopy" && runtime.GOOS != "darwin" {
+				continue
+			}
+			if _, err := exec.LookPath(args[0]); err != nil {
+				continue
+			}
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Stdin = strings.NewReader(text)
+			if cmd.Run() == nil {
+				return args[0]
+			}
+		}
+	}
+	writeTerminal(passthrough("\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\x07"))
+	return "OSC 52"
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Pages the code host menu can open
+const (
+	hostFile    = "file"
+	hostCompare = "compare"
+	hostPR      = "pull request"
+)
+
+// CodeHost is where the origin remote lives on the web
+type CodeHost struct {
+	Host   string // e.g. github.com
+	Base   string // the repository's page, e.g. https://github.com/owner/repo
+	GitLab bool   // GitLab's URL layout rather than GitHub's
+}
+
+// parseRemoteURL works out the repository's web page from a remote URL:
+// scp-like (git@host:owner/repo.git), ssh://, git:// or http(s)://.
+func parseRemoteURL(remote string) (CodeHost, bool) {
+	var host, path string
+	// A one-letter "scheme" is a Windows drive
+	if u, err := url.Parse(remote); err == nil && len(u.Scheme) > 1 {
+		if u.Scheme == "file" {
+			return CodeHost{}, false
+		}
+		host, path = u.Hostname(), u.Path
+	} else if at, rest, ok := strings.Cut(remote, ":"); ok && len(at) > 1 && !strings.Contains(at, "/") {
+		// scp-like: [user@]host:path
+		_, host, _ = strings.Cut(at, "@")
+		if host == "" {
+			host = at
+		}
+		path = rest
+	} else {
+		return CodeHost{}, false
+	}
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || !strings.Contains(path, "/") {
+		return CodeHost{}, false
+	}
+	return CodeHost{
+		Host:   host,
+		Base:   "https://" + host + "/" + path,
+		GitLab: strings.Contains(host, "gitlab"),
+	}, true
+}
+
+// GetCodeHost returns the web location of the origin remote.
+func GetCodeHost() (CodeHost, error) {
+	remote, err := GetRemoteURL("origin")
+	if err != nil {
+		return CodeHost{}, err
+	}
+	host, ok := parseRemoteURL(remote)
+	if !ok {
+		return CodeHost{}, fmt.Errorf("can't tell the web address of %s", remote)
+	}
+	return host, nil
+}
+
+// page joins path onto the repository's URL, with GitLab's /-/ separator
+// where it uses one.
+func (h CodeHost) page(path string) string {
+	if h.GitLab {
+		return h.Base + "/-/" + path
+	}
+	return h.Base + "/" + path
+}
+
+// escapePath escapes each segment of a slash-separated path, keeping
+// the slashes, which branch names may contain too.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// FileURL links to file at ref.
+func (h CodeHost) FileURL(ref, file string) string {
+	return h.page("blob/" + escapePath(ref) + "/" + escapePath(file))
+}
+
+// CompareURL links to what head changes relative to base.
+func (h CodeHost) CompareURL(base, head string) string {
+	return h.page("compare/" + escapePath(base) + "..." + escapePath(head))
+}
+
+// PullRequestsURL links to the pull (merge) requests from branch.
+func (h CodeHost) PullRequestsURL(branch string) string {
+	if h.GitLab {
+		return h.page("merge_requests?scope=all&state=all&source_branch=" + url.QueryEscape(branch))
+	}
+	return h.page("pulls?q=" + url.QueryEscape("is:pr head:"+branch))
+}
+
+// promptCodeHost offers to open the selected file, the branch's compare
+// page or its pull request on the code host.
+func (m *model) promptCodeHost() {
+	host, err := GetCodeHost()
+	if err != nil {
+		m.notifyErr(err)
+		return
+	}
+	ref, err := currentBranch()
+	if err != nil || ref == "" {
+		if !m.hasCommit {
+			m.notify("Nothing to open before the first commit")
+			return
+		}
+		ref = m.lastCommit.Hash // detached
+	}
+
+	var options []string
+	file, hasFile := m.selectedFile()
+	if hasFile {
+		options = append(options, hostFile)
+	}
+	options = append(options, hostCompare, hostPR)
+	m.choice = &choice{
+		prompt:  "Open on " + host.Host + ":",
+		options: options,
+		action: func(m *model, option string) tea.Cmd {
+			var link string
+			switch option {
+			case hostFile:
+				link = host.FileURL(ref, treePath(file))
+			case hostCompare:
+				link = host.CompareURL(strings.TrimPrefix(m.baseName(), "origin/"), ref)
+			case hostPR:
+				link = host.PullRequestsURL(ref)
+				if m.hasPR {
+					link = m.pr.URL
+				}
+			}
+			m.openURL(link)
+			return nil
+		},
+	}
+}
+
+// openURL opens link in the browser. Over SSH, or with no browser to
+// start, the link is copied to the clipboard instead.
+func (m *model) openURL(link string) {
+	if !sshSession() {
+		var cmd *exec.Cmd
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("open", link)
+		case "windows":
+			cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", link)
+		default:
+			cmd = exec.Command("xdg-open", link)
+		}
+		if cmd.Start() == nil {
+			go cmd.Wait()
+			m.notify("Opened " + link)
+			return
+		}
+	}
+	m.notify(fmt.Sprintf("Copied %s via %s", link, copyToClipboard(link)))
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// codeownersPaths are where GitHub and GitLab look for a CODEOWNERS file,
+// in the order they look
+var codeownersPaths = []string{".github/CODEOWNERS", ".gitlab/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// ownersRule is a line of CODEOWNERS: a path pattern and who owns what
+// it matches. A rule with no owners unowns its paths.
+type ownersRule struct {
+	re     *regexp.Regexp
+	owners []string
+}
+
+// ReadCodeowners returns the rules of the repository's CODEOWNERS file,
+// or nil without one.
+func ReadCodeowners() []ownersRule {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil
+	}
+	for _, p := range codeownersPaths {
+		if data, err := os.ReadFile(filepath.Join(root, p)); err == nil {
+			return parseCodeowners(string(data))
+		}
+	}
+	return nil
+}
+
+// parseCodeowners parses CODEOWNERS rules, skipping comments, GitLab
+// section headers and patterns that don't compile.
+func parseCodeowners(data string) []ownersRule {
+	var rules []ownersRule
+	for _, line := range strings.Split(data, "\n") {
+		line, _, _ = strings.Cut(line, " #")
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[") || strings.HasPrefix(fields[0], "^[") {
+			continue
+		}
+		re, err := regexp.Compile(ownersPattern(fields[0]))
+		if err != nil {
+			continue
+		}
+		rules = append(rules, ownersRule{re: re, owners: fields[1:]})
+	}
+	return rules
+}
+
+// ownersPattern translates a CODEOWNERS path pattern, which follows
+// .gitignore's rules, into a regular expression over repository paths.
+func ownersPattern(pattern string) string {
+	dir := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	// A pattern with a slash before its end is relative to the root;
+	// without one it matches at any depth
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			re.WriteString("[^/]*")
+		case pattern[i] == '?':
+			re.WriteString("[^/]")
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	switch {
+	case dir:
+		re.WriteString("/.*")
+	case strings.HasSuffix(pattern, "/*"):
+		// docs/* owns the files in docs, not those in its subdirectories
+	default:
+		re.WriteString("(/.*)?") // a directory owns everything under it
+	}
+	re.WriteString("$")
+	return re.String()
+}
+
+// ownersOf returns who owns a path: the owners of the last rule matching
+// it, as in GitHub.
+func ownersOf(rules []ownersRule, path string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].re.MatchString(path) {
+			return rules[i].owners
+		}
+	}
+	return nil
+}
+
+// owners looks up the owners of the branch files in CODEOWNERS.
+func (r *loadRun) owners() map[string][]string {
+	rules := ReadCodeowners()
+	if rules == nil {
+		return nil
+	}
+	owners := make(map[string][]string)
+	for _, bf := range r.s.branchFiles {
+		path := treePath(bf.File)
+		owners[path] = ownersOf(rules, path)
+	}
+	return owners
+}
+
+// ownersBadge shows after a branch file's name who owns it.
+func (m model) ownersBadge(file, text string) string {
+	owners := m.owners[treePath(file)]
+	if len(owners) == 0 || m.narrow() {
+		return text
+	}
+	return text + " " + ownerStyle.Render(strings.Join(owners, " "))
+}
+
+// reviewers returns the owners of the branch files, most files first, and
+// how many of the files no one owns.
+func (m model) reviewers() (owners []string, files map[string]int, unowned int) {
+	files = make(map[string]int)
+	for _, bf := range m.branchFiles {
+		fileOwners, ok := m.owners[treePath(bf.File)]
+		if !ok {
+			continue
+		}
+		if len(fileOwners) == 0 {
+			unowned++
+		}
+		for _, o := range fileOwners {
+			if files[o] == 0 {
+				owners = append(owners, o)
+			}
+			files[o]++
+		}
+	}
+	sort.SliceStable(owners, func(i, j int) bool { return files[owners[i]] > files[owners[j]] })
+	return owners, files, unowned
+}
+
+// renderOwnersLine sums up whose review the branch will need, from the
+// owners of its files.
+func (m model) renderOwnersLine() string {
+	owners, files, unowned := m.reviewers()
+	var parts []string
+	for _, o := range owners {
+		if m.narrow() {
+			parts = append(parts, ownerStyle.Render(o))
+		} else {
+			parts = append(parts, ownerStyle.Render(o)+helpStyle.Render(fmt.Sprintf(" (%d)", files[o])))
+		}
+	}
+	if unowned > 0 && !m.narrow() {
+		parts = append(parts, helpStyle.Render(plural(unowned, "file")+" unowned"))
+	}
+	line := strings.Join(parts, ", ")
+	if !m.narrow() {
+		line = "Owners: " + line
+	}
+	return truncate(line, m.width)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Command is a user-defined shell command bound to a key
+type Command struct {
+	Key         string `json:"key"`
+	Cmd         string `json:"cmd"`         // shell command, a text/template over commandVars
+	Description string `json:"description"` // shown in help; defaults to the command
+	Output      string `json:"output"`      // pane (default), flash or none
+}
+
+// commandVars are the template variables available to custom commands
+type commandVars struct {
+	File   string // selected file, relative to Root; empty if none
+	Branch string // current branch, or the selected one in the stack view
+	Base   string // comparison base for branch files
+	Commit string // abbreviated hash of HEAD
+	Root   string // top-level directory of the working tree
+}
+
+var commandFuncs = template.FuncMap{"quote": shellQuote}
+
+func (c Command) validate() error {
+	if c.Key == "" {
+		return fmt.Errorf("missing key")
+	}
+	if c.Cmd == "" {
+		return fmt.Errorf("missing cmd")
+	}
+	switch c.Output {
+	case "", "pane", "flash", "none":
+	default:
+		return fmt.Errorf("output must be \"pane\", \"flash\" or \"none\", got %q", c.Output)
+	}
+	_, err := template.New(c.Key).Funcs(commandFuncs).Parse(c.Cmd)
+	return err
+}
+
+func (c Command) description() string {
+	if c.Description != "" {
+		return c.Description
+	}
+	return c.Cmd
+}
+
+// commandDoneMsg reports a finished custom command
+type commandDoneMsg struct {
+	command Command
+	script  string
+	output  string
+	err     error
+	elapsed time.Duration
+}
+
+// outputState holds the pane showing a custom command's output
+type outputState struct {
+	title  string
+	lines  []string
+	err    error
+	parent viewMode // view to return to
+	watch  bool     // showing the watch command's output
+	diff   bool     // lines are a diff, to color
+}
+
+// customCommand returns the command bound to key, if any. Custom commands
+// take precedence over built-in keys.
+func (m model) customCommand(key string) (Command, bool) {
+	for _, c := range m.commands {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return Command{}, false
+}
+
+// runCommand runs a custom command from the repository root in the
+// background.
+func (m *model) runCommand(c Command) tea.Cmd {
+	if m.jobs.busy != "" {
+		m.notify(m.jobs.busy + " already in progress")
+		return nil
+	}
+	root, err := GetRepoRoot()
+	if err != nil {
+		m.notifyErr(err)
+		return nil
+	}
+	vars := commandVars{Branch: m.branch, Base: m.baseName(), Root: root}
+	if file, ok := m.selectedFile(); ok {
+		vars.File = treePath(file)
+	}
+	if m.hasCommit {
+		vars.Commit = m.lastCommit.Hash
+	}
+	if m.view == viewStack && m.cursor < len(m.stack.branches) {
+		vars.Branch = m.stack.branches[m.cursor].name
+	}
+
+	var script strings.Builder
+	tmpl := template.Must(template.New(c.Key).Funcs(commandFuncs).Parse(c.Cmd)) // validated on load
+	if err := tmpl.Execute(&script, vars); err != nil {
+		m.notifyErr(err)
+		return nil
+	}
+
+	return tea.Batch(m.jobs.start("Running "+truncate(script.String(), 40)), func() tea.Msg {
+		start := time.Now()
+		cmd := shellCommand(script.String())
+		cmd.Dir = root
+		output, err := cmd.CombinedOutput()
+		return commandDoneMsg{command: c, script: script.String(), output: string(output), err: err, elapsed: time.Since(start)}
+	})
+}
+
+// commandDone shows a finished command's result as the command asks.
+func (m *model) commandDone(msg commandDoneMsg) {
+	m.jobs.busy = ""
+	m.refresh() // the command may have changed the repository
+
+	output := strings.TrimRight(msg.output, "\n")
+	switch msg.command.Output {
+	case "none", "flash":
+		last := ""
+		if msg.command.Output == "flash" {
+			last = output[strings.LastIndex(output, "\n")+1:]
+		}
+		if msg.err != nil && last != "" {
+			m.notifyErr(fmt.Errorf("%s: %w: %s", msg.script, msg.err, last))
+		} else if msg.err != nil {
+			m.notifyErr(fmt.Errorf("%s: %w", msg.script, msg.err))
+		} else if last != "" {
+			m.notify(last)
+		} else {
+			m.notify(msg.script + " done")
+		}
+	default:
+		m.output = outputState{
+			title:  fmt.Sprintf("$ %s (%s)", msg.script, msg.elapsed.Round(time.Millisecond*100)),
+			err:    msg.err,
+			parent: m.view,
+		}
+		if output != "" {
+			m.output.lines = strings.Split(output, "\n")
+		}
+		m.view = viewOutput
+		m.viewport.GotoTop()
+		m.resize()
+	}
+}
+
+// updateOutput handles key input in the command output pane.
+func (m model) updateOutput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.view = m.output.parent
+		m.resize()
+		return m, tea.ClearScreen
+	case "up", "k":
+		m.viewport.LineUp(1)
+	case "down", "j":
+		m.viewport.LineDown(1)
+	case "pgup":
+		m.viewport.HalfViewUp()
+	case "pgdown":
+		m.viewport.HalfViewDown()
+	case "home", "g":
+		m.viewport.GotoTop()
+	case "end", "G":
+		m.viewport.GotoBottom()
+	case "v":
+		if m.output.diff {
+			m.toggleSplit()
+			m.resize()
+		}
+	}
+	return m, nil
+}
+
+func (m model) renderOutput() string {
+	var body strings.Builder
+	body.WriteString(m.output.title)
+	if m.output.err != nil {
+		body.WriteString("  " + errorStyle.Render(m.output.err.Error()))
+	} else {
+		body.WriteString("  " + statusAdded.Render("ok"))
+	}
+	body.WriteString("\n")
+	if len(m.output.lines) == 0 {
+		body.WriteString(helpStyle.Render("  No output"))
+	}
+	if m.output.diff && m.renderPaged(&body, m.output.lines, m.width) {
+		return body.String()
+	}
+	if m.output.diff {
+		body.WriteString(m.fitDiff(diffView{lines: m.output.lines}).View())
+		return body.String()
+	}
+	for _, line := range m.output.lines {
+		line = strings.ReplaceAll(line, "\t", "    ")
+		if m.narrow() {
+			line = truncate(line, m.width)
+		}
+		body.WriteString(line + "\n")
+	}
+	return body.String()
+}
+
+// shellQuote quotes s for use as a single word in the shell: sh, or
+// cmd.exe where that's all there is.
+func shellQuote(s string) string {
+	if shell()[0] == "cmd" {
+		return cmdQuote(s)
+	}
+	return shQuote(s)
+}
+
+// shQuote quotes s for sh, in single quotes.
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// cmdQuote quotes s for cmd.exe, in double quotes with any inside doubled.
+// cmd.exe expands %VAR% even inside quotes, so each % is escaped as ^%,
+// which only works outside them: 100%.txt becomes "100"^%".txt".
+func cmdQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, `""`)
+	return `"` + strings.ReplaceAll(s, "%", `"^%"`) + `"`
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// conventionalCommits is the commit_subjects value that checks subjects
+// against the Conventional Commits format, as commitlint's
+// config-conventional does
+const conventionalCommits = "conventional"
+
+// conventionalTypes are the types config-conventional allows
+var conventionalTypes = []string{"build", "chore", "ci", "docs", "feat", "fix", "perf", "refactor", "revert", "style", "test"}
+
+// conventionalSubject is "type(scope)!: description", capturing the type,
+// scope, breaking mark and description
+var conventionalSubject = regexp.MustCompile(`^(` + strings.Join(conventionalTypes, "|") + `)(?:\(([^()]+)\))?(!)?: (\S.*)`)
+
+// conventionalCommit is a subject parsed as a Conventional Commit
+type conventionalCommit struct {
+	kind        string // feat, fix, ...
+	scope       string
+	breaking    bool
+	description string
+}
+
+// parseSubject parses a conventional subject, reporting whether it is one.
+func parseSubject(subject string) (conventionalCommit, bool) {
+	match := conventionalSubject.FindStringSubmatch(subject)
+	if match == nil {
+		return conventionalCommit{}, false
+	}
+	return conventionalCommit{kind: match[1], scope: match[2], breaking: match[3] != "", description: match[4]}, true
+}
+
+// lintExempt are subjects git writes itself, which commitlint skips too
+var lintExempt = regexp.MustCompile(`^(Merge |Revert "|(fixup|squash|amend)! )`)
+
+// subjectPattern returns the regular expression commit subjects must
+// match for a commit_subjects value, or nil when it's empty.
+func subjectPattern(value string) (*regexp.Regexp, error) {
+	switch value {
+	case "":
+		return nil, nil
+	case conventionalCommits:
+		return conventionalSubject, nil
+	}
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return nil, fmt.Errorf("commit_subjects: %v", err)
+	}
+	return re, nil
+}
+
+// badSubject reports whether a branch commit's subject fails the check.
+func (m model) badSubject(c Commit) bool {
+	if m.subjects == nil || lintExempt.MatchString(c.Subject) {
+		return false
+	}
+	return !m.subjects.MatchString(c.Subject)
+}
+
+// badSubjects counts the branch commits whose subjects fail the check.
+func (m model) badSubjects() int {
+	n := 0
+	for _, c := range m.commits {
+		if m.badSubject(c) {
+			n++
+		}
+	}
+	return n
+}
+
+// subjectBadge flags a commit whose subject fails the check.
+func (m model) subjectBadge(c Commit, text string) string {
+	if !m.badSubject(c) {
+		return text
+	}
+	badge := glyphs.Warn
+	if !m.narrow() {
+		badge += " " + m.subjectRule()
+	}
+	return text + " " + statusConflict.Render(badge)
+}
+
+// subjectRule names what a failing subject doesn't follow.
+func (m model) subjectRule() string {
+	if m.subjects == conventionalSubject {
+		return "not conventional"
+	}
+	return "subject doesn't match"
+}
+
+// subjectsTitle notes in the Branch Commits title how many subjects fail.
+func (m model) subjectsTitle() string {
+	n := m.badSubjects()
+	if n == 0 {
+		return ""
+	}
+	if m.narrow() {
+		return fmt.Sprintf(" %s%d", glyphs.Warn, n)
+	}
+	return fmt.Sprintf(" (%s to fix)", plural(n, "subject"))
+}
+
+// subjectHelp says what a failing subject should look like.
+func (m model) subjectHelp() string {
+	if m.subjects == conventionalSubject {
+		return "Subject should be type(scope): description, type one of " + strings.Join(conventionalTypes, ", ")
+	}
+	return "Subject should match " + m.subjects.String()
+}
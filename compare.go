@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// compareState holds the view comparing two arbitrary refs
+type compareState struct {
+	from  string
+	to    string
+	files []BranchFile
+	stats map[string]DiffStat // keyed by new path
+
+	// File whose diff is shown; empty while listing files
+	file string
+	diff diffView
+}
+
+// promptCompare asks for the two refs to compare: the comparison base and
+// HEAD by default.
+func (m *model) promptCompare() tea.Cmd {
+	return m.openPrompt("Compare from: ", m.baseName(), func(m *model, from string) (tea.Cmd, error) {
+		if !RefExists(from) {
+			return nil, fmt.Errorf("unknown ref %q", from)
+		}
+		return m.openPrompt("Compare "+from+" to: ", "HEAD", func(m *model, to string) (tea.Cmd, error) {
+			if !RefExists(to) {
+				return nil, fmt.Errorf("unknown ref %q", to)
+			}
+			m.openCompare(from, to)
+			return tea.ClearScreen, nil
+		}), nil
+	})
+}
+
+// openCompare switches to the compare view listing the files that differ
+// between from and to.
+func (m *model) openCompare(from, to string) {
+	m.compare = compareState{from: from, to: to, files: GetDiffFiles(from, to), stats: GetDiffStats(from, to)}
+	m.view = viewCompare
+	m.cursor = 0
+	m.viewport.GotoTop()
+}
+
+// updateCompare handles key input in the compare view: picking a file
+// from the list, or scrolling through its diff.
+func (m model) updateCompare(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := &m.compare
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "backspace":
+		if c.file != "" {
+			// Back to the file list
+			c.file, c.diff = "", diffView{}
+			m.viewport.GotoTop()
+			break
+		}
+		if msg.String() == "esc" {
+			m.view = viewFiles
+			m.resize()
+			return m, tea.ClearScreen
+		}
+	case "up", "k", "down", "j", "pgup", "pgdown":
+		if c.file != "" {
+			c.diff, _ = m.fitDiff(c.diff).Update(msg)
+			break
+		}
+		switch msg.String() {
+		case "up", "k":
+			m.cursor = max(m.cursor-1, 0)
+		case "down", "j":
+			m.cursor = min(m.cursor+1, max(len(c.files)-1, 0))
+		}
+	case "v":
+		if c.file != "" {
+			m.toggleSplit()
+		}
+	case "o":
+		if c.file != "" && len(c.diff.lines) > 0 {
+			return m, m.openEditor(treePath(c.file), diffLineNumber(c.diff.lines, c.diff.line))
+		}
+	case "enter":
+		if c.file != "" || m.cursor >= len(c.files) {
+			return m, nil
+		}
+		if c.stats[treePath(c.files[m.cursor].File)].Binary {
+			m.notify(treePath(c.files[m.cursor].File) + " is binary, so its diff isn't shown")
+			return m, nil
+		}
+		diff, err := GetFileDiff(c.from, c.to, c.files[m.cursor].File)
+		if err != nil {
+			m.notifyErr(err)
+			return m, nil
+		}
+		c.file = c.files[m.cursor].File
+		c.diff = diffView{file: c.file, lines: diff, selectable: true}
+		m.viewport.GotoTop()
+		m.resize()
+		return m, tea.ClearScreen
+	}
+
+	m.resize()
+	if c.file != "" {
+		m.scrollTo(m.fitDiff(c.diff).row() + 1)
+	} else {
+		m.scrollTo(m.cursor + 1)
+	}
+	return m, nil
+}
+
+func (m model) renderCompare() string {
+	c := m.compare
+	var body strings.Builder
+
+	if c.file != "" {
+		body.WriteString(fmt.Sprintf("%s..%s  %s\n", tagStyle.Render(c.from), tagStyle.Render(c.to), fileStyle.Render(treePath(c.file))))
+		if m.renderPaged(&body, c.diff.lines, m.width) {
+			return body.String()
+		}
+		body.WriteString(m.fitDiff(c.diff).View())
+		return body.String()
+	}
+
+	body.WriteString(fmt.Sprintf("Compare %s..%s", tagStyle.Render(c.from), tagStyle.Render(c.to)))
+	var total DiffStat
+	for _, s := range c.stats {
+		total.Added += s.Added
+		total.Removed += s.Removed
+	}
+	summary := plural(len(c.files), "file")
+	if len(c.files) > 0 {
+		summary += ", " + statusAdded.Render(fmt.Sprintf("+%d", total.Added)) + helpStyle.Render(" ") + statusDeleted.Render(fmt.Sprintf("-%d", total.Removed))
+	}
+	body.WriteString(helpStyle.Render(" (") + summary + helpStyle.Render(")") + "\n")
+	if len(c.files) == 0 {
+		body.WriteString(helpStyle.Render("  No differences"))
+	}
+	for i, f := range c.files {
+		label := fmt.Sprintf("%-12s", branchFileLabel(f.Status))
+		if m.narrow() {
+			label = f.Status[:1]
+		}
+		body.WriteString(fmt.Sprintf("%s%s  %s%s\n", m.cursorColumn(i == m.cursor), branchFileStyle(f.Status).Render(label), fileStyle.Render(f.File), renderDiffStat(c.stats[treePath(f.File)])))
+	}
+	return body.String()
+}
+
+// renderDiffStat shows a file's added and removed line counts, e.g.
+// "  +12 -3", or "  binary".
+func renderDiffStat(s DiffStat) string {
+	if s.Binary {
+		return helpStyle.Render("  binary")
+	}
+	if s.Added == 0 && s.Removed == 0 {
+		return ""
+	}
+	return "  " + statusAdded.Render(fmt.Sprintf("+%d", s.Added)) + " " + statusDeleted.Render(fmt.Sprintf("-%d", s.Removed))
+}
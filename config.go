@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Config is vigil's user configuration, read from config.json in the
+// user config directory (e.g. ~/.config/vigil/config.json).
+type Config struct {
+	Base    string            `json:"base"`    // comparison base for branch files
+	Layout  string            `json:"layout"`  // preset to start in
+	Layouts map[string]Layout `json:"layouts"` // user presets, merged over the built-ins
+
+	// Header sets the header segments, in order, for every layout;
+	// segments left out are hidden. Unset keeps each layout's own
+	Header []string `json:"header"`
+
+	// Pull is how p integrates upstream changes: rebase, merge, or empty
+	// to follow git's pull.rebase setting
+	Pull string `json:"pull"`
+
+	// AutoFetch runs git fetch in the background; turn it off on metered
+	// or VPN connections
+	AutoFetch bool `json:"auto_fetch"`
+
+	// Tree starts with file lists grouped by directory
+	Tree bool `json:"tree"`
+
+	// Authors shows the last author of each file after its name
+	Authors bool `json:"authors"`
+
+	// Syntax highlights code in diffs by language; turn it off if huge
+	// diffs scroll slowly
+	Syntax bool `json:"syntax"`
+
+	// DiffPager is a command diffs are piped through for display, such as
+	// delta, whose colored output is shown in place of vigil's own
+	DiffPager string `json:"diff_pager"`
+
+	// LargeFileKB is the size from which files are labeled with their size
+	// and their diffs aren't generated; 0 turns it off. WarnLargeFiles asks
+	// before staging them
+	LargeFileKB    int  `json:"large_file_kb"`
+	WarnLargeFiles bool `json:"warn_large_files"`
+
+	// Cruft are regular expressions for lines that shouldn't be committed,
+	// checked in uncommitted changes along with conflict markers. Unset,
+	// common debug lines such as fmt.Println are checked for
+	Cruft []string `json:"cruft"`
+
+	// Secrets scans staged changes for credentials; off unless enabled
+	Secrets SecretsConfig `json:"secrets"`
+
+	// CommitSubjects flags branch commits whose subjects don't follow
+	// Conventional Commits ("conventional") or match a regular expression
+	CommitSubjects string `json:"commit_subjects"`
+
+	// Untracked and Ignored include those files in Changed Files. Unset,
+	// Untracked follows git's status.showUntrackedFiles
+	Untracked *bool `json:"untracked"`
+	Ignored   bool  `json:"ignored"`
+
+	// Renames, Prune and PushDefault override git's diff.renames,
+	// fetch.prune and push.default for the git commands vigil runs; unset,
+	// git's own settings apply
+	Renames     *bool  `json:"renames"`
+	Prune       *bool  `json:"prune"`
+	PushDefault string `json:"push_default"`
+
+	// LargeRepo keeps status fast in repositories with a great many files,
+	// using git's file system monitor and untracked cache and running
+	// status without optional locks
+	LargeRepo bool `json:"large_repo"`
+
+	// FollowActivity scrolls to and highlights whichever panel changed most recently
+	FollowActivity bool `json:"follow_activity"`
+
+	// Commands are shell commands bound to keys
+	Commands []Command `json:"commands"`
+
+	// Watch runs a command whenever the working tree changes
+	Watch WatchConfig `json:"watch"`
+
+	// Debug adds the debug segment, with average timings, to every
+	// layout's header
+	Debug bool `json:"debug"`
+
+	// Title keeps the terminal title set to the branch and its state
+	Title bool `json:"title"`
+
+	// Notify is how alerts (conflicts, new upstream commits, finished
+	// operations) are raised: osc9, osc777 or off
+	Notify string `json:"notify"`
+
+	// Webhooks receive a JSON POST on repository events, for dashboards
+	// and chat bots
+	Webhooks []Webhook `json:"webhooks"`
+
+	// Protected are branch patterns on which uncommitted changes are
+	// reported to webhooks, e.g. release/*, and which deleting merged
+	// branches leaves alone
+	Protected []string `json:"protected_branches"`
+
+	// StaleDays is how long after its last commit a branch is marked stale
+	// in the stack view; 0 turns the mark off
+	StaleDays int `json:"stale_days"`
+
+	// Alerts post to Slack or Discord when a count reaches a threshold
+	Alerts []ChatAlert `json:"alerts"`
+
+	// Bookmarks are repositories to switch between with v
+	Bookmarks []Bookmark `json:"bookmarks"`
+
+	// Editor opens files: vscode, intellij, nvim, or a command with {file}
+	// and {line} placeholders. Empty runs $VISUAL or $EDITOR in the terminal
+	Editor string `json:"editor"`
+}
+
+// ConfigPath returns the location of the config file.
+func ConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "vigil", "config.json"), nil
+}
+
+// LoadConfig reads the config file. A missing file is not an error and
+// yields the defaults.
+func LoadConfig() (Config, error) {
+	cfg := Config{Layout: "monitor", AutoFetch: true, Syntax: true, Title: true, Notify: notifyOSC9, Protected: defaultProtected, StaleDays: 90, LargeFileKB: 1024}
+
+	path, err := ConfigPath()
+	if err != nil {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	if cfg.Layout == "" {
+		cfg.Layout = "monitor"
+	}
+	switch cfg.Pull {
+	case "", "rebase", "merge":
+	default:
+		return cfg, fmt.Errorf("%s: pull must be \"rebase\" or \"merge\", got %q", path, cfg.Pull)
+	}
+	if cfg.PushDefault != "" && !slices.Contains(pushDefaults, cfg.PushDefault) {
+		return cfg, fmt.Errorf("%s: push_default must be one of %s, got %q", path, strings.Join(pushDefaults, ", "), cfg.PushDefault)
+	}
+	switch cfg.Notify {
+	case notifyOSC9, notifyOSC777, notifyOff:
+	default:
+		return cfg, fmt.Errorf("%s: notify must be \"osc9\", \"osc777\" or \"off\", got %q", path, cfg.Notify)
+	}
+	if err := validateEditor(cfg.Editor); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	for i, c := range cfg.Commands {
+		if err := c.validate(); err != nil {
+			return cfg, fmt.Errorf("%s: command %d: %v", path, i+1, err)
+		}
+	}
+	if _, err := cruftPatterns(cfg.Cruft); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	if _, err := subjectPattern(cfg.CommitSubjects); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, p := range cfg.Secrets.Allow {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return cfg, fmt.Errorf("%s: secrets allow pattern %q: %v", path, p, err)
+		}
+	}
+	if cfg.LargeFileKB < 0 {
+		return cfg, fmt.Errorf("%s: large_file_kb can't be negative, got %d", path, cfg.LargeFileKB)
+	}
+	if cfg.StaleDays < 0 {
+		return cfg, fmt.Errorf("%s: stale_days can't be negative, got %d", path, cfg.StaleDays)
+	}
+	for _, p := range cfg.Protected {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return cfg, fmt.Errorf("%s: protected branch %q: %v", path, p, err)
+		}
+	}
+	for i := range cfg.Bookmarks {
+		if err := cfg.Bookmarks[i].validate(); err != nil {
+			return cfg, fmt.Errorf("%s: bookmark %d: %v", path, i+1, err)
+		}
+	}
+	if err := cfg.Watch.validate(); err != nil {
+		return cfg, fmt.Errorf("%s: watch: %v", path, err)
+	}
+	for i, w := range cfg.Webhooks {
+		if err := w.validate(); err != nil {
+			return cfg, fmt.Errorf("%s: webhook %d: %v", path, i+1, err)
+		}
+	}
+	for i, a := range cfg.Alerts {
+		if err := a.validate(); err != nil {
+			return cfg, fmt.Errorf("%s: alert %d: %v", path, i+1, err)
+		}
+	}
+	if err := validateHeader(cfg.Header); err != nil {
+		return cfg, fmt.Errorf("%s: header: %v", path, err)
+	}
+	for name, l := range cfg.Layouts {
+		if err := l.validate(); err != nil {
+			return cfg, fmt.Errorf("%s: layout %q: %v", path, name, err)
+		}
+	}
+	return cfg, nil
+}
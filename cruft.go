@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// cruftPattern is something that shouldn't be committed, matched against
+// each added line
+type cruftPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// conflictMarkers finds merge conflict markers left behind after a
+// resolution. They're always checked for.
+var conflictMarkers = cruftPattern{"conflict marker", regexp.MustCompile(`^(<{7}|={7}|>{7})( |$)`)}
+
+// defaultCruft are the debug lines checked for unless the config lists its
+// own patterns
+var defaultCruft = []cruftPattern{
+	{"fmt.Println", regexp.MustCompile(`\bfmt\.Println\(`)},
+	{"console.log", regexp.MustCompile(`\bconsole\.log\(`)},
+	{"debugger", regexp.MustCompile(`^\s*debugger;?\s*$`)},
+	{"breakpoint()", regexp.MustCompile(`^\s*breakpoint\(\)\s*$`)},
+}
+
+// cruftPatterns returns the patterns to check: conflict markers, then the
+// config's patterns, or the default debug lines without any.
+func cruftPatterns(patterns []string) ([]cruftPattern, error) {
+	checks := []cruftPattern{conflictMarkers}
+	if len(patterns) == 0 {
+		return append(checks, defaultCruft...), nil
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("cruft pattern %q: %v", p, err)
+		}
+		checks = append(checks, cruftPattern{p, re})
+	}
+	return checks, nil
+}
+
+// matchCruft returns the name of the first pattern line matches, or "".
+func matchCruft(checks []cruftPattern, line string) string {
+	for _, c := range checks {
+		if c.re.MatchString(line) {
+			return c.name
+		}
+	}
+	return ""
+}
+
+// GetAddedLines returns the lines added since rev in the working tree,
+// keyed by the file's new path.
+func GetAddedLines(ctx context.Context, rev string) map[string][]string {
+	output, err := gitCommandContext(ctx, "diff", "--unified=0", "--no-color", "--no-ext-diff", rev).Output()
+	if err != nil {
+		return nil
+	}
+	added := make(map[string][]string)
+	var file string
+	for _, line := range strings.Split(string(output), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = strings.TrimPrefix(line[4:], "b/")
+		case strings.HasPrefix(line, "+") && file != "":
+			added[file] = append(added[file], line[1:])
+		case strings.HasPrefix(line, "diff --git "):
+			file = ""
+		}
+	}
+	return added
+}
+
+// cruft scans the lines each uncommitted change adds, and the whole of
+// new files, for cruft. Conflicted files are left out, as their markers
+// are expected, and so are those too large or binary to diff.
+func (r *loadRun) cruft(sizes fileSizes) map[string][]string {
+	if len(r.s.changes) == 0 {
+		return nil
+	}
+	var added map[string][]string
+	if r.s.hasCommit {
+		added = GetAddedLines(r.ctx, "HEAD")
+	}
+	root := r.root()
+
+	found := make(map[string][]string)
+	for _, c := range r.s.changes {
+		path := treePath(c.File)
+		if c.IsConflict() || c.Repo != "" || c.Staged == '!' || sizes.of(panelChanges, c.File).skipsDiff() {
+			continue
+		}
+		lines, ok := added[path]
+		if !ok && (c.Staged == '?' || !r.s.hasCommit) {
+			lines = readLines(filepath.Join(root, path))
+		}
+		for _, line := range lines {
+			if name := matchCruft(r.set.cruftChecks, line); name != "" {
+				found[path] = append(found[path], name)
+			}
+		}
+	}
+	return found
+}
+
+// readLines returns the lines of a file, or nil if it can't be read.
+func readLines(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
+
+// cruftBadge warns after a file's name of the cruft found in it, e.g.
+// "⚠ fmt.Println +2".
+func (m model) cruftBadge(file, text string) string {
+	found := m.cruft[treePath(file)]
+	if len(found) == 0 {
+		return text
+	}
+	badge := glyphs.Warn + " " + found[0]
+	if len(found) > 1 {
+		badge += fmt.Sprintf(" +%d", len(found)-1)
+	}
+	return text + " " + statusConflict.Render(badge)
+}
+
+// cruftTitle notes in the Changed Files title how many files have cruft.
+func (m model) cruftTitle() string {
+	if len(m.cruft) == 0 {
+		return ""
+	}
+	if m.narrow() {
+		return fmt.Sprintf(" %s%d", glyphs.Warn, len(m.cruft))
+	}
+	return fmt.Sprintf(" (%s with cruft)", plural(len(m.cruft), "file"))
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// daemonRequest is one line sent to the daemon's socket
+type daemonRequest struct {
+	Cmd string `json:"cmd"` // status, refresh or fetch
+}
+
+// daemonResponse is the daemon's one-line answer to a request
+type daemonResponse struct {
+	OK     bool        `json:"ok"`
+	Error  string      `json:"error,omitempty"`
+	Status *statusFile `json:"status,omitempty"`
+}
+
+// daemon polls one repository and serves its status to clients
+type daemon struct {
+	mu      sync.Mutex
+	m       model
+	updated time.Time
+}
+
+// socketPath returns where the current repository's daemon listens. Each
+// worktree has its own git dir, and so its own daemon.
+func socketPath() (string, error) {
+	dir, err := GetGitDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "vigil.sock"), nil
+}
+
+// runDaemon implements "vigil daemon": keep git polling and fetching
+// running for the repository and serve its status over a Unix socket.
+func runDaemon(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	dir := fs.String("C", "", "serve the repository in `dir`")
+	noFetch := fs.Bool("no-fetch", false, "don't run git fetch in the background")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: vigil daemon [flags]\n\nServe the repository's status on .git/vigil.sock, one JSON request and\nresponse per line, e.g. {\"cmd\":\"status\"}.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *dir != "" {
+		if err := os.Chdir(*dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if !IsGitRepo() {
+		fmt.Fprintln(os.Stderr, "Error: Not a git repository")
+		return 1
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+	if *noFetch {
+		cfg.AutoFetch = false
+	}
+	cfg.applyGitOverrides()
+
+	path, err := socketPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if DaemonRunning() {
+		fmt.Fprintf(os.Stderr, "Error: a vigil daemon is already serving %s\n", path)
+		return 1
+	}
+	os.Remove(path) // left behind by a daemon that didn't shut down cleanly
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	d := startDaemon(cfg)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		ln.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "vigil daemon serving %s\n", path)
+
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return 0 // the listener removes the socket file on close
+		}
+		if err != nil {
+			continue
+		}
+		go d.serve(conn)
+	}
+}
+
+// startDaemon starts polling, and fetching if enabled, the repository in
+// the current directory.
+func startDaemon(cfg Config) *daemon {
+	// The daemon reuses the TUI's model for its git polling, without the
+	// terminal side effects
+	m := initialModel(cfg, RepoState{})
+	m.dir, _ = os.Getwd()
+	m.setTitle = false
+	m.notifyMode = notifyOff
+	d := &daemon{m: m, updated: time.Now()}
+	d.m.setUpstream(countUpstream().(fetchTickMsg))
+
+	go d.poll()
+	if cfg.AutoFetch {
+		go d.fetchLoop()
+	}
+	return d
+}
+
+// poll refreshes the status on the same schedule as the TUI.
+func (d *daemon) poll() {
+	for range time.Tick(3 * time.Second) {
+		d.refresh()
+	}
+}
+
+// fetchLoop fetches on the same schedule as the TUI, starting right away.
+func (d *daemon) fetchLoop() {
+	for {
+		d.fetch()
+		d.mu.Lock()
+		delay := d.m.fetchDelay()
+		d.mu.Unlock()
+		time.Sleep(delay)
+	}
+}
+
+func (d *daemon) refresh() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.m.refresh()
+	d.updated = time.Now()
+	if d.m.flashIsErr {
+		fmt.Fprintln(os.Stderr, d.m.flash) // e.g. a webhook that failed
+		d.m.flash, d.m.flashIsErr = "", false
+	}
+}
+
+// fetch runs git fetch outside the lock, so status requests are answered
+// while it's in flight.
+func (d *daemon) fetch() {
+	msg := fetchUpstream().(fetchTickMsg)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.m.setUpstream(msg)
+}
+
+// serve answers requests on one connection until the client hangs up.
+func (d *daemon) serve(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req daemonRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			encoder.Encode(daemonResponse{Error: "bad request: " + err.Error()})
+			continue
+		}
+		encoder.Encode(d.handle(req))
+	}
+}
+
+func (d *daemon) handle(req daemonRequest) daemonResponse {
+	switch req.Cmd {
+	case "status":
+	case "refresh":
+		d.refresh()
+	case "fetch":
+		d.fetch()
+	default:
+		return daemonResponse{Error: fmt.Sprintf("unknown cmd %q (want status, refresh or fetch)", req.Cmd)}
+	}
+	s := d.status()
+	return daemonResponse{OK: true, Status: &s}
+}
+
+// status returns the latest status, stamped with when it was read.
+func (d *daemon) status() statusFile {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.m.snapshot()
+	s.Updated = d.updated
+	return s
+}
+
+// QueryDaemon sends one request to the current repository's daemon.
+func QueryDaemon(cmd string) (statusFile, error) {
+	path, err := socketPath()
+	if err != nil {
+		return statusFile{}, err
+	}
+	conn, err := net.DialTimeout("unix", path, 200*time.Millisecond)
+	if err != nil {
+		return statusFile{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second)) // a fetch can be slow
+
+	if err := json.NewEncoder(conn).Encode(daemonRequest{Cmd: cmd}); err != nil {
+		return statusFile{}, err
+	}
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return statusFile{}, err
+	}
+	if !resp.OK || resp.Status == nil {
+		return statusFile{}, errors.New(resp.Error)
+	}
+	return *resp.Status, nil
+}
+
+// DaemonRunning reports whether a daemon is serving the current repository.
+func DaemonRunning() bool {
+	path, err := socketPath()
+	if err != nil {
+		return false
+	}
+	conn, err := net.DialTimeout("unix", path, 200*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// daemonLink is the TUI's tie to a daemon serving its repository. While
+// it's up the TUI asks it for the status instead of polling git, and
+// leaves fetching, webhooks and alerts to it.
+type daemonLink struct {
+	key string // fingerprint of the last status it served
+
+	// The TUI's own settings, taken back if the daemon goes away
+	autoFetch bool
+	webhooks  []Webhook
+	alerts    []ChatAlert
+}
+
+// linkDaemon hands fetching, webhooks and alerts over to the daemon
+// serving the current repository, if one is, so they don't go out twice.
+func linkDaemon(cfg *Config) *daemonLink {
+	if !DaemonRunning() {
+		return nil
+	}
+	link := &daemonLink{autoFetch: cfg.AutoFetch, webhooks: cfg.Webhooks, alerts: cfg.Alerts}
+	cfg.AutoFetch = false
+	cfg.Webhooks = nil
+	cfg.Alerts = nil
+	return link
+}
+
+// daemonStatusMsg is the daemon's answer to a status request
+type daemonStatusMsg struct {
+	status statusFile
+	key    string
+	err    error
+}
+
+// queryDaemonStatus asks the daemon for the status in place of a refresh.
+func queryDaemonStatus() tea.Msg {
+	s, err := QueryDaemon("status")
+	if err != nil {
+		return daemonStatusMsg{err: err}
+	}
+	return daemonStatusMsg{status: s, key: s.fingerprint()}
+}
+
+// fingerprint returns a value that changes whenever the status or the
+// contents of a changed file do, like WorkingTreeFingerprint but from a
+// status already read, so it runs no git.
+func (s statusFile) fingerprint() string {
+	s.Updated = time.Time{}
+	data, _ := json.Marshal(s)
+	h := fnv.New64a()
+	h.Write(data)
+	root, _ := GetRepoRoot()
+	for _, c := range s.Changes {
+		if info, err := os.Stat(filepath.Join(root, c.Path)); err == nil {
+			fmt.Fprintf(h, "%d %d", info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// daemonStatus takes in the daemon's status. The counts are used as they
+// are, since the daemon is what fetches; the rest is only read from git
+// again when it has changed. If the daemon has gone away the TUI goes back
+// to polling git and takes fetching, webhooks and alerts back.
+func (m *model) daemonStatus(msg daemonStatusMsg) tea.Cmd {
+	link := m.daemon
+	if msg.err != nil {
+		debugLog.Debug("daemon gone", "err", msg.err)
+		m.daemon = nil
+		m.autoFetch = link.autoFetch && !m.readOnly
+		m.webhooks = link.webhooks
+		m.webhookSent = make([]map[string]time.Time, len(link.webhooks))
+		for i := range m.webhookSent {
+			m.webhookSent[i] = make(map[string]time.Time)
+		}
+		m.alerts = link.alerts
+		m.alertStates = make([]alertState, len(link.alerts))
+		m.notify("vigil daemon stopped, polling git again")
+		return m.refreshInBackground()
+	}
+	if msg.status.Upstream {
+		m.ahead, m.behind, m.upstreamErr = msg.status.Ahead, msg.status.Behind, nil
+	}
+	if msg.key == link.key {
+		return tick()
+	}
+	link.key = msg.key
+	return m.refreshInBackground()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"vigil/internal/fixture"
+)
+
+func TestTUIFollowsDaemon(t *testing.T) {
+	repo, err := fixture.Detached(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+	path, err := socketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("no Unix sockets:", err)
+	}
+	defer ln.Close()
+	d := startDaemon(Config{})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+
+	cfg := Config{AutoFetch: true, Alerts: []ChatAlert{{When: "changes"}}}
+	link := linkDaemon(&cfg)
+	if link == nil {
+		t.Fatal("linkDaemon found no daemon")
+	}
+	m := initialModel(cfg, RepoState{})
+	m.daemon = link
+	if m.autoFetch || len(m.alerts) > 0 {
+		t.Error("the TUI fetches or alerts alongside the daemon")
+	}
+
+	// A new status is read from git, an unchanged one isn't
+	msg, ok := queryDaemonStatus().(daemonStatusMsg)
+	if !ok || msg.err != nil {
+		t.Fatalf("queryDaemonStatus = %+v", msg)
+	}
+	if _, ok := m.daemonStatus(msg)().(refreshedMsg); !ok {
+		t.Error("a new daemon status didn't refresh")
+	}
+	if again := queryDaemonStatus().(daemonStatusMsg); again.key != m.daemon.key {
+		t.Errorf("status fingerprint changed with nothing changed: %s, then %s", m.daemon.key, again.key)
+	}
+
+	// Editing a file that's already modified leaves the status as it was
+	// but still counts as a change
+	if err := os.WriteFile(fixture.Modified, []byte("edited again\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if again := queryDaemonStatus().(daemonStatusMsg); again.key == m.daemon.key {
+		t.Error("status fingerprint didn't change when a changed file did")
+	}
+
+	// Without the daemon, the TUI polls git and takes its settings back
+	ln.Close()
+	msg = queryDaemonStatus().(daemonStatusMsg)
+	if msg.err == nil {
+		t.Fatal("queryDaemonStatus worked with the daemon gone")
+	}
+	if _, ok := m.daemonStatus(msg)().(refreshedMsg); !ok {
+		t.Error("the TUI didn't refresh itself once the daemon was gone")
+	}
+	if m.daemon != nil || !m.autoFetch || len(m.alerts) != 1 || len(m.alertStates) != 1 {
+		t.Errorf("daemon %v, autoFetch %v, %d alerts: want the TUI's own settings back", m.daemon, m.autoFetch, len(m.alerts))
+	}
+}
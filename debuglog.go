@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// debugLog is vigil's structured log of what it runs and how long things
+// take: git commands, refreshes, fetches and the watch poll. It discards
+// everything unless --debug turns it on.
+var debugLog = slog.New(slog.DiscardHandler)
+
+// debugFlag is --debug, which takes an optional file to log to
+type debugFlag struct {
+	on   bool
+	file string // "" for the default
+}
+
+func (f *debugFlag) String() string {
+	if f.file != "" {
+		return f.file
+	}
+	return fmt.Sprint(f.on)
+}
+
+func (f *debugFlag) Set(value string) error {
+	switch value {
+	case "true":
+		f.on, f.file = true, ""
+	case "false":
+		f.on, f.file = false, ""
+	default:
+		f.on, f.file = true, value
+	}
+	return nil
+}
+
+// IsBoolFlag lets --debug be given without a file.
+func (f *debugFlag) IsBoolFlag() bool { return true }
+
+// openDebugLog starts logging as JSON lines to path, or to debug.log in
+// vigil's cache directory when path is "", appending to what's there. It
+// returns the path, and the file to close when vigil exits.
+func openDebugLog(path string) (string, *os.File, error) {
+	if path == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return "", nil, err
+		}
+		dir = filepath.Join(dir, "vigil")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", nil, err
+		}
+		path = filepath.Join(dir, "debug.log")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return "", nil, err
+	}
+	debugLog = slog.New(slog.NewJSONHandler(f, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			// "1.2ms" rather than nanoseconds
+			if a.Value.Kind() == slog.KindDuration {
+				return slog.String(a.Key, a.Value.Duration().String())
+			}
+			return a
+		},
+	}))
+	cwd, _ := os.Getwd()
+	debugLog.Info("start", "args", os.Args[1:], "dir", cwd, "git", gitVersion().String())
+	return path, f, nil
+}
+
+// debugging reports whether the debug log is on, to skip gathering what
+// it would be told otherwise.
+func debugging() bool {
+	return debugLog.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// gitCmd is a git command that logs each run to the debug log, with its
+// arguments, duration and exit status, and counts it for the diagnostics
+// overlay
+type gitCmd struct {
+	*exec.Cmd
+	start time.Time
+}
+
+// gitCommand is exec.Command for git.
+func gitCommand(args ...string) *gitCmd {
+	return &gitCmd{Cmd: exec.Command("git", gitArgs(args)...)}
+}
+
+// gitCommandContext is exec.CommandContext for git.
+func gitCommandContext(ctx context.Context, args ...string) *gitCmd {
+	return &gitCmd{Cmd: exec.CommandContext(ctx, "git", gitArgs(args)...)}
+}
+
+// gitArgs adds the options every git command vigil runs takes.
+func gitArgs(args []string) []string {
+	if noOptionalLocks {
+		return append([]string{"--no-optional-locks"}, args...)
+	}
+	return args
+}
+
+// begin starts timing a run, and adds vigil's git environment to the
+// command's, which is the process's unless the caller set one.
+func (c *gitCmd) begin() {
+	if len(gitEnv) > 0 {
+		if c.Env == nil {
+			c.Env = os.Environ()
+		}
+		c.Env = append(c.Env, gitEnv...)
+	}
+	c.start = time.Now()
+}
+
+func (c *gitCmd) Run() error {
+	c.begin()
+	err := c.Cmd.Run()
+	c.log(err)
+	return err
+}
+
+func (c *gitCmd) Output() ([]byte, error) {
+	c.begin()
+	output, err := c.Cmd.Output()
+	c.log(err)
+	return output, err
+}
+
+func (c *gitCmd) CombinedOutput() ([]byte, error) {
+	c.begin()
+	output, err := c.Cmd.CombinedOutput()
+	c.log(err)
+	return output, err
+}
+
+func (c *gitCmd) Start() error {
+	c.begin()
+	err := c.Cmd.Start()
+	if err != nil {
+		c.log(err)
+	}
+	return err
+}
+
+func (c *gitCmd) Wait() error {
+	err := c.Cmd.Wait()
+	c.log(err)
+	return err
+}
+
+// log records a finished run: exit 0, git's exit code, or why it didn't
+// run or was killed.
+func (c *gitCmd) log(err error) {
+	took := time.Since(c.start)
+	recordGit(c.Args[1:], took, err != nil)
+	if !debugging() {
+		return
+	}
+	attrs := []any{"args", c.Args[1:], "duration", took}
+	if c.Dir != "" {
+		attrs = append(attrs, "dir", c.Dir)
+	}
+	var exit *exec.ExitError
+	switch {
+	case err == nil:
+		attrs = append(attrs, "exit", 0)
+	case errors.As(err, &exit) && exit.ExitCode() >= 0:
+		attrs = append(attrs, "exit", exit.ExitCode())
+	default:
+		attrs = append(attrs, "error", err.Error())
+	}
+	debugLog.Debug("git", attrs...)
+}
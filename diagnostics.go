@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diagnosticsKey toggles the diagnostics overlay in every view
+const diagnosticsKey = "`"
+
+// gitStat is how often one git subcommand has run and how long it took
+type gitStat struct {
+	name   string
+	count  int
+	failed int
+	total  time.Duration
+	last   time.Duration
+	max    time.Duration
+}
+
+// gitStats are the git commands run so far, by subcommand, for the
+// diagnostics overlay. Commands run in the background too, hence the lock.
+var gitStats = struct {
+	sync.Mutex
+	bySub map[string]*gitStat
+}{bySub: make(map[string]*gitStat)}
+
+// recordGit adds a finished git command to gitStats.
+func recordGit(args []string, took time.Duration, failed bool) {
+	name := gitSubcommand(args)
+	gitStats.Lock()
+	defer gitStats.Unlock()
+	s := gitStats.bySub[name]
+	if s == nil {
+		s = &gitStat{name: name}
+		gitStats.bySub[name] = s
+	}
+	s.count++
+	s.total += took
+	s.last = took
+	s.max = max(s.max, took)
+	if failed {
+		s.failed++
+	}
+}
+
+// gitSubcommand returns the subcommand of git's arguments, past global
+// options such as -c key=value.
+func gitSubcommand(args []string) string {
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "-c" || args[i] == "-C":
+			i++
+		case !strings.HasPrefix(args[i], "-"):
+			return args[i]
+		}
+	}
+	return "git"
+}
+
+// slowestGit returns the git subcommands that took longest altogether,
+// at most n of them, and how many commands ran in all.
+func slowestGit(n int) ([]gitStat, int) {
+	gitStats.Lock()
+	defer gitStats.Unlock()
+	var stats []gitStat
+	total := 0
+	for _, s := range gitStats.bySub {
+		stats = append(stats, *s)
+		total += s.count
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].total > stats[j].total })
+	return stats[:min(n, len(stats))], total
+}
+
+// diagnosticsRows is how many git subcommands the overlay lists
+const diagnosticsRows = 6
+
+// renderDiagnostics renders the diagnostics overlay shown under the
+// header: the last refresh and its parts, the git subcommands that took
+// longest, the watch poll's events and vigil's memory use.
+func (m model) renderDiagnostics() string {
+	mt := m.metrics
+	ms := func(d time.Duration) string {
+		if d == 0 {
+			return "-"
+		}
+		return d.Round(100 * time.Microsecond).String()
+	}
+	var lines []string
+	lines = append(lines, "Diagnostics "+helpStyle.Render("("+diagnosticsKey+" to close)"))
+	lines = append(lines, fmt.Sprintf("Last refresh %s: status %s, branch diff %s %s %d changes/min",
+		ms(mt.refresh.last()), ms(mt.status.last()), ms(mt.diff.last()), glyphs.Dot, mt.changeRate()))
+
+	stats, total := slowestGit(diagnosticsRows)
+	lines = append(lines, fmt.Sprintf("git: %s", plural(total, "command")))
+	for _, s := range stats {
+		line := fmt.Sprintf("  %-14s %5d× avg %-8s max %-8s last %s",
+			s.name, s.count, ms(s.total/time.Duration(s.count)), ms(s.max), ms(s.last))
+		if s.failed > 0 {
+			line += fmt.Sprintf(" (%d failed)", s.failed)
+		}
+		lines = append(lines, helpStyle.Render(line))
+	}
+
+	if m.watch.enabled() {
+		lines = append(lines, fmt.Sprintf("Watch: poll %s, %s, %s, %s", ms(mt.scan.last()),
+			plural(mt.treeChanges, "working tree change"), plural(mt.pathChanges, "watch path change"), plural(mt.watchRuns, "run")))
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	lines = append(lines, fmt.Sprintf("Memory: heap %s, from the OS %s, %s, %s",
+		formatSize(int64(mem.HeapAlloc)), formatSize(int64(mem.Sys)), plural(int(mem.NumGC), "GC"), plural(runtime.NumGoroutine(), "goroutine")))
+
+	for i, line := range lines {
+		lines[i] = truncate(line, m.width)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// diffView is a diff as the compare, history and output views show it:
+// unified or side by side, colored by the painter. A selectable diff has
+// a selected line, which the navigation keys move; otherwise the view
+// showing it just scrolls.
+type diffView struct {
+	file       string   // path the diff is colored as; empty for a commit
+	lines      []string // the unified diff
+	line       int      // selected line, if selectable
+	selectable bool
+
+	// How it's drawn, set from the model's settings by fitDiff
+	split  bool // side by side
+	syntax bool
+	narrow bool
+	width  int
+	page   int // rows pgup and pgdown move by
+}
+
+// fitDiff sets how d is drawn from the model's settings and size.
+func (m model) fitDiff(d diffView) diffView {
+	d.split = m.showSplit()
+	d.syntax = m.syntax
+	d.narrow = m.narrow()
+	d.width = m.width
+	d.page = m.viewport.Height
+	return d
+}
+
+// Update moves the selected line on the navigation keys.
+func (d diffView) Update(msg tea.Msg) (diffView, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok || !d.selectable {
+		return d, nil
+	}
+	switch key.String() {
+	case "up", "k":
+		d.line = d.step(-1)
+	case "down", "j":
+		d.line = d.step(1)
+	case "pgup":
+		d.line = d.step(-d.page / 2)
+	case "pgdown":
+		d.line = d.step(d.page / 2)
+	}
+	return d, nil
+}
+
+// View draws the diff, one row per line, with the cursor column when it's
+// selectable.
+func (d diffView) View() string {
+	var body strings.Builder
+	width := d.width
+	if d.selectable {
+		width-- // for the cursor column
+	}
+	cursor := func(selected bool) string {
+		if !d.selectable {
+			return ""
+		}
+		return cursorColumn(selected, d.narrow)
+	}
+
+	painter := &diffPainter{syntax: d.syntax, lang: languageFor(d.file)}
+	if d.split {
+		selected := splitRowOf(splitDiff(d.lines), d.line)
+		for i, row := range renderSplit(d.lines, painter, width) {
+			body.WriteString(cursor(i == selected) + row + "\n")
+		}
+		return body.String()
+	}
+	for i, line := range d.lines {
+		line = strings.ReplaceAll(line, "\t", "    ")
+		if d.narrow {
+			line = truncate(line, width)
+		}
+		body.WriteString(cursor(i == d.line) + painter.render(line) + "\n")
+	}
+	return body.String()
+}
+
+// row returns the body row showing the selected line, which differs from
+// the line's index when the diff is side by side.
+func (d diffView) row() int {
+	if !d.split {
+		return d.line
+	}
+	return splitRowOf(splitDiff(d.lines), d.line)
+}
+
+// step returns the selected line moved by n rows as the diff is shown.
+func (d diffView) step(n int) int {
+	if !d.split {
+		return max(min(d.line+n, len(d.lines)-1), 0)
+	}
+	rows := splitDiff(d.lines)
+	if len(rows) == 0 {
+		return 0
+	}
+	r := max(min(splitRowOf(rows, d.line)+n, len(rows)-1), 0)
+	return rows[r].first()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// draftSections are the headings a changelog draft groups conventional
+// commits under, in order
+var draftSections = []struct {
+	heading string
+	kinds   []string
+}{
+	{"Features", []string{"feat"}},
+	{"Fixes", []string{"fix"}},
+	{"Performance", []string{"perf"}},
+	{"Reverts", []string{"revert"}},
+	{"Refactoring", []string{"refactor"}},
+	{"Documentation", []string{"docs"}},
+	{"Tests", []string{"test"}},
+	{"Build and CI", []string{"build", "ci"}},
+	{"Chores", []string{"chore", "style"}},
+}
+
+// draftSkipped are commits left out of a draft: merges, and fixups that
+// are yet to be squashed
+var draftSkipped = regexp.MustCompile(`^(Merge |(fixup|squash|amend)! )`)
+
+// draftChangelog writes commits, newest first, as a markdown changelog
+// under title: breaking changes first, then one section per type, then
+// the commits that aren't conventional.
+func draftChangelog(title string, commits []Commit) string {
+	sections := make(map[string][]string)
+	var breaking, other []string
+	for _, c := range commits {
+		if draftSkipped.MatchString(c.Subject) {
+			continue
+		}
+		cc, ok := parseSubject(c.Subject)
+		if !ok {
+			other = append(other, fmt.Sprintf("- %s (%s)", c.Subject, c.Hash))
+			continue
+		}
+		entry := cc.description
+		if cc.scope != "" {
+			entry = fmt.Sprintf("**%s:** %s", cc.scope, entry)
+		}
+		entry = fmt.Sprintf("- %s (%s)", entry, c.Hash)
+		if cc.breaking {
+			breaking = append(breaking, entry)
+		} else {
+			sections[cc.kind] = append(sections[cc.kind], entry)
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("## " + title + "\n")
+	write := func(heading string, entries []string) {
+		if len(entries) > 0 {
+			out.WriteString("\n### " + heading + "\n\n" + strings.Join(entries, "\n") + "\n")
+		}
+	}
+	write("Breaking changes", breaking)
+	for _, s := range draftSections {
+		var entries []string
+		for _, kind := range s.kinds {
+			entries = append(entries, sections[kind]...)
+		}
+		write(s.heading, entries)
+	}
+	write("Other changes", other)
+	return out.String()
+}
+
+// Changelog draft destinations offered by promptDraft
+const (
+	draftCopy = "copy"
+	draftSave = "save to a file"
+)
+
+// promptDraft offers to copy a changelog draft of commits to the
+// clipboard or write it to a file, suggesting one named after ref.
+func (m *model) promptDraft(title string, commits []Commit, ref string) {
+	if len(commits) == 0 {
+		m.notify("No commits to draft a changelog from")
+		return
+	}
+	draft := draftChangelog(title, commits)
+	what := "changelog draft of " + plural(len(commits), "commit")
+	m.choice = &choice{
+		prompt:  "Changelog draft:",
+		options: []string{draftCopy, draftSave},
+		action: func(m *model, option string) tea.Cmd {
+			if option == draftCopy {
+				m.notify(fmt.Sprintf("Copied %s via %s", what, copyToClipboard(draft)))
+				return nil
+			}
+			return m.openPrompt("Write "+what+" to: ", snapshotPath(m.dir, ref, "-changelog.md"), func(m *model, path string) (tea.Cmd, error) {
+				path, err := outputPath(path)
+				if err != nil {
+					return nil, err
+				}
+				if err := os.WriteFile(path, []byte(draft), 0o644); err != nil {
+					return nil, err
+				}
+				m.notify("Wrote " + what + " to " + path)
+				return nil, nil
+			})
+		},
+	}
+}
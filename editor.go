@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editorPresets are built-in values for the editor setting. Each opens
+// the file in an editor that's already running, so vigil keeps going.
+var editorPresets = map[string]string{
+	"vscode":   "code --goto {file}:{line}",
+	"intellij": "idea --line {line} {file}",
+	// Neovim started with --listen, or vigil running in a Neovim terminal
+	"nvim": `nvim --server "$NVIM" --remote {file} && nvim --server "$NVIM" --remote-send '<C-\><C-N>:{line}<CR>'`,
+}
+
+// editorDoneMsg reports that the editor was opened, or exited when it ran
+// in the terminal
+type editorDoneMsg struct {
+	err error
+}
+
+func validateEditor(editor string) error {
+	if _, ok := editorPresets[editor]; ok || editor == "" || strings.Contains(editor, "{file}") {
+		return nil
+	}
+	return fmt.Errorf("editor must be vscode, intellij, nvim or a command containing {file}, got %q", editor)
+}
+
+// openEditor opens file, relative to the repository root, at line. With
+// no editor configured, $VISUAL or $EDITOR takes over the terminal until
+// it exits.
+func (m *model) openEditor(file string, line int) tea.Cmd {
+	root, err := GetRepoRoot()
+	if err != nil {
+		m.notifyErr(err)
+		return nil
+	}
+	path := filepath.Join(root, file)
+	if _, err := os.Stat(path); err != nil {
+		m.notifyErr(fmt.Errorf("%s isn't in the working tree", file))
+		return nil
+	}
+	line = max(line, 1)
+
+	if m.editor == "" {
+		editor := os.Getenv("VISUAL")
+		if editor == "" {
+			editor = os.Getenv("EDITOR")
+		}
+		if editor == "" {
+			editor = "vi"
+		}
+		cmd := shellCommand(fmt.Sprintf("%s +%d %s", editor, line, shellQuote(path)))
+		cmd.Dir = root
+		return tea.ExecProcess(cmd, func(err error) tea.Msg {
+			return editorDoneMsg{err: err}
+		})
+	}
+
+	script := m.editor
+	if preset, ok := editorPresets[script]; ok {
+		script = preset
+	}
+	script = strings.NewReplacer("{file}", shellQuote(path), "{line}", strconv.Itoa(line)).Replace(script)
+	m.notify("Opening " + file)
+	return func() tea.Msg {
+		cmd := shellCommand(script)
+		cmd.Dir = root
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			if out := strings.TrimSpace(string(output)); out != "" {
+				err = errors.New(out)
+			}
+			return editorDoneMsg{err: fmt.Errorf("%s: %w", script, err)}
+		}
+		return editorDoneMsg{}
+	}
+}
+
+// editorDone reports a failed editor and picks up any changes made in it.
+func (m *model) editorDone(msg editorDoneMsg) {
+	m.refresh()
+	if msg.err != nil {
+		m.notifyErr(msg.err)
+	}
+}
+
+// openSelectedFile opens the selected file in the editor at its first
+// change: against HEAD in Changed Files, against the merge base in Branch
+// Files.
+func (m *model) openSelectedFile() tea.Cmd {
+	row, ok := m.selectedRow()
+	if !ok || row.file == "" {
+		return nil
+	}
+	file := treePath(row.file)
+	if row.line > 0 {
+		return m.openEditor(file, row.line)
+	}
+	from := "HEAD"
+	if row.panel == panelBranch {
+		base, err := GetMergeBase(m.base)
+		if err != nil {
+			m.notifyErr(err)
+			return nil
+		}
+		from = base
+	}
+	return m.openEditor(file, FirstChangedLine(from, file))
+}
+
+// diffLineNumber returns the line in the new version of the file that
+// diff line i belongs to. Removed lines map to where they were removed.
+// Lines before the first hunk map to 0.
+func diffLineNumber(diff []string, i int) int {
+	line := 0
+	for _, l := range diff[:i+1] {
+		switch {
+		case strings.HasPrefix(l, "@@"):
+			line = hunkStart(l)
+		case line == 0:
+			// file header
+		case strings.HasPrefix(l, "+"), strings.HasPrefix(l, " "):
+			line++
+		}
+	}
+	// A context or added line counts itself; step back to it
+	if l := diff[i]; line > 0 && (strings.HasPrefix(l, "+") || strings.HasPrefix(l, " ")) {
+		line--
+	}
+	return line
+}
+
+// hunkStart returns the first new-side line of a hunk header such as
+// "@@ -12,7 +12,9 @@".
+func hunkStart(header string) int {
+	_, after, ok := strings.Cut(header, " +")
+	if !ok {
+		return 0
+	}
+	start, _, _ := strings.Cut(after, " ")
+	start, _, _ = strings.Cut(start, ",")
+	n, _ := strconv.Atoi(start)
+	return max(n, 1)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+)
+
+// branchSummary is what vigil export writes about the branch
+type branchSummary struct {
+	branch  string
+	base    string
+	commits []Commit // newest first
+	files   []exportedFile
+}
+
+// exportedFile is a changed file with its line counts and, when diffs are
+// exported, its diff
+type exportedFile struct {
+	file   BranchFile
+	stat   DiffStat
+	diff   []string
+	reason string // why the diff was left out, if it was
+}
+
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "markdown", "output format: markdown or html")
+	base := fs.String("base", "", "ref to compare against (default: the config's base, or the default branch)")
+	diffs := fs.Bool("diffs", false, "include each file's diff")
+	out := fs.String("o", "", "write to `file` instead of standard output")
+	dir := fs.String("C", "", "export the repository in `dir`")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: vigil export [flags]\n\nSummarize the branch since it diverged from its base: its commits and\nchanged files with line counts, and optionally their diffs, ready to\npaste into a pull request or attach to a ticket.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *format != "markdown" && *format != "html" {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (want markdown or html)\n", *format)
+		return 1
+	}
+	if *dir != "" {
+		if err := os.Chdir(*dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if !IsGitRepo() {
+		fmt.Fprintln(os.Stderr, "Error: Not a git repository")
+		return 1
+	}
+	if *base == "" {
+		cfg, err := LoadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			return 1
+		}
+		*base = cfg.Base
+	}
+
+	s, err := summarizeBranch(*base, *diffs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	text := s.markdown()
+	if *format == "html" {
+		text = s.html()
+	}
+	if *out == "" {
+		fmt.Print(text)
+		return 0
+	}
+	if err := os.WriteFile(*out, []byte(text), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// summarizeBranch gathers what the branch has done since its merge base
+// with base.
+func summarizeBranch(base string, diffs bool) (branchSummary, error) {
+	if base == "" {
+		base = GetDefaultBranch()
+	}
+	mergeBase, err := GetMergeBase(base)
+	if err != nil {
+		return branchSummary{}, fmt.Errorf("no merge base with %s", base)
+	}
+	s := branchSummary{
+		branch:  GetCurrentBranch(),
+		base:    base,
+		commits: GetCommitsBetween(mergeBase, "HEAD"),
+	}
+	stats := GetDiffStats(mergeBase, "HEAD")
+	for _, f := range GetDiffFiles(mergeBase, "HEAD") {
+		e := exportedFile{file: f, stat: stats[treePath(f.File)]}
+		if diffs {
+			if e.stat.Binary {
+				e.reason = "binary"
+			} else if e.diff, err = GetFileDiff(mergeBase, "HEAD", f.File); err != nil {
+				e.reason = err.Error()
+			}
+		}
+		s.files = append(s.files, e)
+	}
+	return s, nil
+}
+
+// title is the summary's heading, e.g. "feature vs main".
+func (s branchSummary) title() string {
+	return fmt.Sprintf("%s vs %s", s.branch, s.base)
+}
+
+// totals returns the lines added and removed across the files.
+func (s branchSummary) totals() (added, removed int) {
+	for _, f := range s.files {
+		added += f.stat.Added
+		removed += f.stat.Removed
+	}
+	return added, removed
+}
+
+// counts describes a file's changed lines, e.g. "+12 -3".
+func (f exportedFile) counts() string {
+	if f.stat.Binary {
+		return "binary"
+	}
+	return fmt.Sprintf("+%d -%d", f.stat.Added, f.stat.Removed)
+}
+
+// path is how the file is named, with renames shown as old → new.
+func (f exportedFile) path() string {
+	return strings.Replace(f.file.File, "\t", " → ", 1)
+}
+
+func (s branchSummary) markdown() string {
+	var b strings.Builder
+	added, removed := s.totals()
+	fmt.Fprintf(&b, "## %s\n\n", s.title())
+	fmt.Fprintf(&b, "%s, %s, +%d -%d\n", plural(len(s.commits), "commit"), plural(len(s.files), "file"), added, removed)
+
+	b.WriteString("\n### Commits\n\n")
+	for _, c := range s.commits {
+		fmt.Fprintf(&b, "- `%s` %s (%s)\n", c.Hash, c.Subject, c.Author)
+	}
+
+	b.WriteString("\n### Files\n\n| File | Status | Lines |\n| --- | --- | --- |\n")
+	for _, f := range s.files {
+		fmt.Fprintf(&b, "| `%s` | %s | %s |\n", f.path(), branchFileLabel(f.file.Status), f.counts())
+	}
+
+	for _, f := range s.files {
+		if f.diff == nil && f.reason == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n<details>\n<summary><code>%s</code> %s</summary>\n\n", html.EscapeString(f.path()), f.counts())
+		if f.reason != "" {
+			fmt.Fprintf(&b, "Diff not shown: %s\n", f.reason)
+		} else {
+			// A fence longer than any backtick run in the diff
+			fence := "```"
+			for strings.Contains(strings.Join(f.diff, "\n"), fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(&b, "%sdiff\n%s\n%s\n", fence, strings.Join(f.diff, "\n"), fence)
+		}
+		b.WriteString("\n</details>\n")
+	}
+	return b.String()
+}
+
+// exportStyle is the stylesheet of the HTML export, which is one
+// self-contained page
+const exportStyle = `body { font-family: -apple-system, sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; }
+code, pre { font-family: ui-monospace, monospace; font-size: 0.9em; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.2em 1em 0.2em 0; }
+pre { background: #f6f8fa; padding: 0.5em; overflow-x: auto; }
+.add { color: #1a7f37; } .del { color: #cf222e; } .hunk { color: #8250df; } .meta { color: #57606a; }`
+
+func (s branchSummary) html() string {
+	var b strings.Builder
+	esc := html.EscapeString
+	added, removed := s.totals()
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n", esc(s.title()), exportStyle)
+	fmt.Fprintf(&b, "<h2>%s</h2>\n", esc(s.title()))
+	fmt.Fprintf(&b, "<p>%s, %s, +%d -%d</p>\n", plural(len(s.commits), "commit"), plural(len(s.files), "file"), added, removed)
+
+	b.WriteString("<h3>Commits</h3>\n<ul>\n")
+	for _, c := range s.commits {
+		fmt.Fprintf(&b, "<li><code>%s</code> %s <span class=\"meta\">(%s)</span></li>\n", esc(c.Hash), esc(c.Subject), esc(c.Author))
+	}
+	b.WriteString("</ul>\n")
+
+	b.WriteString("<h3>Files</h3>\n<table>\n<tr><th>File</th><th>Status</th><th>Lines</th></tr>\n")
+	for _, f := range s.files {
+		fmt.Fprintf(&b, "<tr><td><code>%s</code></td><td>%s</td><td>%s</td></tr>\n", esc(f.path()), branchFileLabel(f.file.Status), f.counts())
+	}
+	b.WriteString("</table>\n")
+
+	for _, f := range s.files {
+		if f.diff == nil && f.reason == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "<details>\n<summary><code>%s</code> %s</summary>\n", esc(f.path()), f.counts())
+		if f.reason != "" {
+			fmt.Fprintf(&b, "<p>Diff not shown: %s</p>\n", esc(f.reason))
+		} else {
+			b.WriteString("<pre>")
+			for _, line := range f.diff {
+				class := ""
+				switch {
+				case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"), strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "index "):
+					class = "meta"
+				case strings.HasPrefix(line, "+"):
+					class = "add"
+				case strings.HasPrefix(line, "-"):
+					class = "del"
+				case strings.HasPrefix(line, "@@"):
+					class = "hunk"
+				}
+				if class != "" {
+					fmt.Fprintf(&b, "<span class=\"%s\">%s</span>\n", class, esc(line))
+				} else {
+					b.WriteString(esc(line) + "\n")
+				}
+			}
+			b.WriteString("</pre>\n")
+		}
+		b.WriteString("</details>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
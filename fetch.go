@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fetchDueMsg is sent when the next background fetch is due
+type fetchDueMsg struct{}
+
+// fetchTickMsg carries ahead/behind counts after a fetch
+type fetchTickMsg struct {
+	upstream string
+	ahead    int
+	behind   int
+	err      error
+	fetched  bool  // false when only recounted from local refs
+	fetchErr error // why the fetch failed, if it did
+	remotes  []RemoteDivergence
+	elapsed  time.Duration // how long the fetch took
+}
+
+// Background fetches run every fetchInterval. Each one in a row that fails
+// doubles the wait, up to maxFetchInterval, so vigil isn't stuck retrying
+// a remote it can't reach.
+const (
+	fetchInterval    = 2 * time.Minute
+	maxFetchInterval = 30 * time.Minute
+)
+
+// fetchTimeout is how long a fetch may take before it's given up as
+// offline; a dead SSH connection can otherwise hang for many minutes
+const fetchTimeout = time.Minute
+
+// upstreamMsg is a one-off ahead/behind update that doesn't reschedule fetching
+type upstreamMsg fetchTickMsg
+
+func fetchUpstream() tea.Msg {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	fetchErr := FetchContext(ctx)
+	if ctx.Err() != nil {
+		fetchErr = fmt.Errorf("%w: no answer from the remote in %s", errOffline, fetchTimeout)
+	}
+	elapsed := time.Since(start)
+	ahead, behind, err := GetCommitsAheadBehind()
+	upstream, _ := GetUpstream()
+	return fetchTickMsg{upstream: upstream, ahead: ahead, behind: behind, err: err, fetched: true, fetchErr: fetchErr, remotes: GetRemoteDivergence(upstream), elapsed: elapsed}
+}
+
+// countUpstream updates ahead/behind from the remote-tracking refs as they
+// are, without fetching.
+func countUpstream() tea.Msg {
+	ahead, behind, err := GetCommitsAheadBehind()
+	upstream, _ := GetUpstream()
+	return fetchTickMsg{upstream: upstream, ahead: ahead, behind: behind, err: err, remotes: GetRemoteDivergence(upstream)}
+}
+
+// checkUpstream is a one-off countUpstream.
+func checkUpstream() tea.Msg {
+	return upstreamMsg(countUpstream().(fetchTickMsg))
+}
+
+// fetchNow is a one-off fetchUpstream.
+func fetchNow() tea.Msg {
+	return upstreamMsg(fetchUpstream().(fetchTickMsg))
+}
+
+// scheduleFetch schedules the next background update.
+func (m model) scheduleFetch() tea.Cmd {
+	return tea.Tick(m.fetchDelay(), func(t time.Time) tea.Msg {
+		return fetchDueMsg{}
+	})
+}
+
+// fetchDelay is how long until the next background fetch, backing off
+// while fetches fail.
+func (m model) fetchDelay() time.Duration {
+	return min(fetchInterval<<min(m.fetchFails, 5), maxFetchInterval)
+}
+
+// syncStatus describes failing fetches, e.g. "offline — last synced 12m
+// ago", or is "" while they work.
+func (m model) syncStatus() string {
+	if m.fetchErr == nil {
+		return ""
+	}
+	s := "fetch failing"
+	if errors.Is(m.fetchErr, errOffline) {
+		s = "offline"
+	}
+	if !m.lastFetched.IsZero() {
+		s += " — last synced " + m.timeAgo(m.lastFetched)
+	}
+	return s
+}
+
+// startFetch runs fetch in the background, with a spinner in the header
+// until it's done. With auto-fetch off, ahead/behind is only recounted in
+// case the user fetched by hand.
+func (m *model) startFetch() tea.Cmd {
+	if !m.autoFetch || m.readOnly {
+		return countUpstream
+	}
+	m.jobs.fetching = true
+	return tea.Batch(fetchUpstream, m.jobs.spinner.Tick)
+}
+
+// setUpstream records an ahead/behind result.
+func (m *model) setUpstream(msg fetchTickMsg) {
+	if msg.fetched && msg.err == nil && msg.behind > m.behind {
+		m.alert("New upstream commits", fmt.Sprintf("%s is %d behind", m.branch, msg.behind))
+	}
+	if msg.fetched {
+		for _, r := range msg.remotes {
+			if prev, ok := m.remoteDivergence(r.Ref); ok && r.Behind > prev.Behind {
+				m.alert("New commits on "+r.Ref, fmt.Sprintf("%s is %d behind %s", m.branch, r.Behind, r.Ref))
+			}
+		}
+	}
+	becameBehind := m.counted && msg.err == nil && msg.behind > 0 && (m.behind == 0 || m.upstreamErr != nil)
+	m.upstream = msg.upstream
+	m.ahead = msg.ahead
+	m.behind = msg.behind
+	m.upstreamErr = msg.err
+	m.remotes = msg.remotes
+	m.counted = true
+	if becameBehind {
+		m.emit(eventBehind, fmt.Sprintf("%s is %d behind", m.branch, msg.behind))
+	}
+	if msg.fetched {
+		m.jobs.fetching = false
+		m.fetchErr = msg.fetchErr
+		if msg.fetchErr != nil {
+			m.fetchFails++
+		} else {
+			m.fetchFails = 0
+			m.lastFetched = m.now()
+		}
+		m.metrics.fetch.add(msg.elapsed)
+		debugLog.Debug("fetch", "duration", msg.elapsed, "error", msg.fetchErr, "fails", m.fetchFails, "next", m.fetchDelay())
+	}
+	m.publish()
+}
+
+// remoteDivergence returns the last count against another remote's ref.
+func (m model) remoteDivergence(ref string) (RemoteDivergence, bool) {
+	for _, r := range m.remotes {
+		if r.Ref == ref {
+			return r, true
+		}
+	}
+	return RemoteDivergence{}, false
+}
+
+// updateFetch handles the background fetch cycle: starting a fetch when
+// one is due, and recording its result.
+func (m model) updateFetch(msg tea.Msg) (model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case fetchDueMsg:
+		return m, m.startFetch()
+
+	case fetchTickMsg:
+		m.setUpstream(msg)
+		m.resize()
+		if msg.fetched {
+			return m, tea.Batch(m.scheduleFetch(), m.loadPR())
+		}
+		return m, m.scheduleFetch()
+
+	case upstreamMsg:
+		m.setUpstream(fetchTickMsg(msg))
+		m.resize()
+		if msg.fetchErr != nil {
+			m.notifyErr(msg.fetchErr)
+		}
+		if msg.fetched {
+			return m, m.loadPR()
+		}
+	}
+	return m, nil
+}
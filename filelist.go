@@ -0,0 +1,741 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// updateFiles handles key input in the files view.
+func (m model) updateFiles(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		if m.clearMarked() {
+			return m, nil
+		}
+		if m.filter == "" {
+			if cmd, ok := m.leaveNestedRepo(); ok {
+				return m, cmd
+			}
+			return m, tea.Quit
+		}
+		m.setFilter("")
+	case "/":
+		return m, m.openFilter()
+	case "up", "k", "down", "j", "pgup", "pgdown", "home", "g", "end", "G":
+		m.syncFiles()
+		m.files, _ = m.files.Update(msg)
+		m.showSelection()
+	case "*":
+		m.togglePin()
+	case "x":
+		m.toggleReviewed()
+	case "s":
+		m.cycleSort()
+	case "u":
+		m.statusOpts.Untracked = !m.statusOpts.Untracked
+		m.notify("Untracked files " + shownHidden(m.statusOpts.Untracked))
+		m.refresh()
+	case "i":
+		m.statusOpts.Ignored = !m.statusOpts.Ignored
+		m.notify("Ignored files " + shownHidden(m.statusOpts.Ignored))
+		m.refresh()
+	case "t":
+		m.tree = !m.tree
+		m.moveSelection(0)
+	case ".":
+		m.toggleHideWhitespace()
+	case "O":
+		m.showAuthors = !m.showAuthors
+		m.notify("Last authors " + shownHidden(m.showAuthors))
+		m.loadAuthors()
+		m.resize()
+	case "enter":
+		if c, ok := m.selectedRepo(); ok {
+			return m, m.openNestedRepo(c)
+		}
+		if row, ok := m.selectedRow(); ok && row.commit != "" {
+			m.showCommit(row.commit)
+			return m, nil
+		}
+		if row, ok := m.selectedRow(); ok && row.line > 0 {
+			return m, m.openSelectedFile()
+		}
+		m.toggleCollapsed()
+	case " ":
+		m.toggleMarked()
+	case "+":
+		m.stageChanges(m.targetChanges())
+	case "-":
+		m.unstageChanges(m.targetChanges())
+	case "d":
+		m.confirmDiscard(m.targetChanges())
+	case "z":
+		m.stashChanges(m.targetChanges())
+	case "r":
+		m.refresh()
+		return m, tea.ClearScreen
+	case "l":
+		m.cycleLayout(1)
+		return m, tea.ClearScreen
+	case "L":
+		m.cycleLayout(-1)
+		return m, tea.ClearScreen
+	case "a":
+		m.toggleMarkedAll()
+	case "Z":
+		m.follow = !m.follow
+		m.focus = ""
+		m.notify("Follow activity " + onOff(m.follow))
+		m.resize()
+		return m, nil
+	case "f":
+		if m.jobs.fetching || m.readOnlyBlocked("fetch") {
+			return m, nil
+		}
+		m.jobs.fetching = true
+		return m, tea.Batch(fetchNow, m.jobs.spinner.Tick)
+	case "A":
+		if m.readOnlyBlocked("fetch") {
+			return m, nil
+		}
+		m.autoFetch = !m.autoFetch
+		m.notify("Automatic fetch " + onOff(m.autoFetch))
+		return m, nil
+	case "?":
+		m.view = viewHelp
+		m.viewport.GotoTop()
+		m.resize()
+		return m, tea.ClearScreen
+	case "P":
+		return m, m.push(false)
+	case "p":
+		return m, m.pull()
+	case "F":
+		m.confirmForcePush()
+		return m, nil
+	case "w":
+		m.openWorktrees()
+		return m, tea.ClearScreen
+	case "T":
+		m.openChangelog()
+		return m, tea.ClearScreen
+	case "c":
+		return m, m.promptCompare()
+	case "S":
+		return m, m.openStack()
+	case "R":
+		if m.stoppedOp() != "" {
+			m.promptStopped()
+			return m, nil
+		}
+		if row, ok := m.selectedRow(); ok && row.commit != "" {
+			return m, m.startInteractiveRebase(row.commit)
+		}
+		m.notify("Select a commit in Branch Commits to rebase from")
+		return m, nil
+	case "J":
+		m.openToolLog()
+		return m, tea.ClearScreen
+	case "W":
+		m.showWatchOutput()
+		return m, tea.ClearScreen
+	case "b":
+		return m, m.openPrompt("Base ref: ", m.base, (*model).setBase)
+	case "N":
+		return m, m.openUpstream()
+	case "E":
+		return m, m.promptArchive("HEAD")
+	case "B":
+		return m, m.promptBundle()
+	case "M":
+		return m, m.promptFormatPatch()
+	case "D":
+		m.promptPatch()
+		return m, nil
+	case "Y":
+		m.promptDraft(m.branch, m.commits, m.branch)
+		return m, nil
+	case "I":
+		return m, m.promptImportBundle()
+	case "n":
+		if !m.hasCommit || m.readOnlyBlocked("edit notes") {
+			return m, nil
+		}
+		return m, m.openPrompt("Note on "+m.lastCommit.Hash+": ", m.lastCommit.Note, (*model).setNote)
+	case "o":
+		return m, m.openSelectedFile()
+	case "y":
+		m.promptCopy()
+		return m, nil
+	case "V":
+		m.promptCodeHost()
+		return m, nil
+	case "m":
+		m.openMenu()
+		return m, tea.ClearScreen
+	case "h":
+		m.openHistory()
+		return m, tea.ClearScreen
+	case "H":
+		m.openReflog()
+		return m, tea.ClearScreen
+	case "U":
+		m.confirmUndoCommit()
+		return m, nil
+	case "e":
+		m.ignoreSelected()
+		return m, nil
+	case "v":
+		return m, m.openBookmarks()
+	case "C":
+		m.promptClean()
+		return m, nil
+	}
+	return m, nil
+}
+
+// renderFiles renders the layout's panels, in order.
+func (m model) renderFiles() string {
+	sections := m.files.sections
+	if len(sections) == 0 && m.filter != "" {
+		return helpStyle.Render(fmt.Sprintf("No files match %q", m.filter))
+	}
+	if len(sections) == 0 && m.hideSpace && (len(m.changes) > 0 || len(m.branchFiles) > 0) {
+		return helpStyle.Render("Only whitespace changes, hidden (. shows them)")
+	}
+	if len(sections) == 0 {
+		if m.narrow() {
+			return helpStyle.Render("No changes")
+		}
+		return helpStyle.Render("No changes detected")
+	}
+
+	return m.files.View()
+}
+
+// cursorColumn renders the leading column that marks the selected row.
+func (m model) cursorColumn(selected bool) string {
+	return cursorColumn(selected, m.narrow())
+}
+
+func cursorColumn(selected, narrow bool) string {
+	pad := " "
+	if !narrow {
+		pad = "  "
+	}
+	if selected {
+		return glyphs.Cursor + pad[1:]
+	}
+	return pad
+}
+
+// listRow is one selectable line of a panel
+type listRow struct {
+	panel  string
+	file   string
+	dir    string // set instead of file for directory rows in tree mode
+	commit string // set instead of file for rows of the commits panel
+	text   string // rendered, without the cursor column
+
+	generated bool // set for the row generated branch files are grouped under
+	todos     bool // set for the row TODO comments are grouped under
+	line      int  // line of the file a TODO comment row points to
+}
+
+// panelSection is a rendered, non-empty panel
+type panelSection struct {
+	panel  string
+	title  string
+	rows   []listRow
+	hidden int // rows cut off by the layout's size for the panel
+}
+
+// fileList is the files view's panels and the cursor over their rows.
+// The model renders the panels from the repository state and hands them
+// over with syncFiles; the list owns which row is selected, moves it on
+// the navigation keys and draws the cursor column.
+type fileList struct {
+	sections []panelSection
+	cursor   int
+	page     int    // rows pgup and pgdown move by
+	narrow   bool   // condensed cursor column
+	focus    string // panel whose title is highlighted, if any
+}
+
+// Update moves the cursor on the navigation keys.
+func (f fileList) Update(msg tea.Msg) (fileList, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return f, nil
+	}
+	switch key.String() {
+	case "up", "k":
+		f.move(-1)
+	case "down", "j":
+		f.move(1)
+	case "pgup":
+		f.move(-max(f.page/2, 1))
+	case "pgdown":
+		f.move(max(f.page/2, 1))
+	case "home", "g":
+		f.move(-len(f.rows()))
+	case "end", "G":
+		f.move(len(f.rows()))
+	}
+	return f, nil
+}
+
+// View renders the panels with their titles and the cursor column.
+func (f fileList) View() string {
+	var body strings.Builder
+	row := 0
+	for i, section := range f.sections {
+		if i > 0 {
+			body.WriteString("\n")
+		}
+		title := section.title
+		if f.focus == section.panel {
+			title = focusStyle.Render(title)
+		}
+		body.WriteString(title + "\n")
+		for _, r := range section.rows {
+			body.WriteString(cursorColumn(row == f.cursor, f.narrow) + r.text + "\n")
+			row++
+		}
+		if section.hidden > 0 {
+			body.WriteString(helpStyle.Render(fmt.Sprintf("  ... %d more", section.hidden)) + "\n")
+		}
+	}
+	return body.String()
+}
+
+// rows returns the selectable rows of all panels, in display order.
+func (f fileList) rows() []listRow {
+	var rows []listRow
+	for _, section := range f.sections {
+		rows = append(rows, section.rows...)
+	}
+	return rows
+}
+
+// selected returns the row under the cursor, if any.
+func (f fileList) selected() (listRow, bool) {
+	rows := f.rows()
+	if f.cursor >= len(rows) {
+		return listRow{}, false
+	}
+	return rows[f.cursor], true
+}
+
+// move moves the cursor by delta rows, stopping at either end.
+func (f *fileList) move(delta int) {
+	f.cursor = max(min(f.cursor+delta, len(f.rows())-1), 0)
+}
+
+// line returns the body line a selectable row is rendered on.
+func (f fileList) line(index int) (int, bool) {
+	line, row := 0, 0
+	for i, section := range f.sections {
+		if i > 0 {
+			line++ // blank separator
+		}
+		line++ // title
+		if index < row+len(section.rows) {
+			return line + index - row, true
+		}
+		line += len(section.rows)
+		row += len(section.rows)
+		if section.hidden > 0 {
+			line++
+		}
+	}
+	return 0, false
+}
+
+// panelLine returns the body line a panel's title is rendered on.
+func (f fileList) panelLine(panel string) (int, bool) {
+	line := 0
+	for _, section := range f.sections {
+		if section.panel == panel {
+			return line, true
+		}
+		line += len(section.rows) + 2 // title and blank separator
+		if section.hidden > 0 {
+			line++
+		}
+	}
+	return 0, false
+}
+
+// syncFiles hands the file list the panels as the repository state
+// renders them now.
+func (m *model) syncFiles() {
+	m.files.sections = m.renderPanels()
+	m.files.page = m.viewport.Height
+	m.files.narrow = m.narrow()
+	m.files.focus = ""
+	if m.follow {
+		m.files.focus = m.focus
+	}
+}
+
+// renderPanels renders the layout's non-empty panels in order.
+func (m model) renderPanels() []panelSection {
+	var sections []panelSection
+	for _, panel := range m.layout.Panels {
+		var section panelSection
+		switch panel {
+		case panelChanges:
+			section = m.renderChanges()
+		case panelBranch:
+			section = m.renderBranchFiles()
+		case panelCommits:
+			section = m.renderBranchCommits()
+		}
+		if len(section.rows) == 0 {
+			continue
+		}
+		section.panel = panel
+		for i := range section.rows {
+			section.rows[i].panel = panel
+		}
+		if size := m.layout.Sizes[panel]; size > 0 && len(section.rows) > size {
+			section.hidden = len(section.rows) - size
+			section.rows = section.rows[:size]
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// visibleRows returns the selectable rows of all panels, in display order.
+func (m model) visibleRows() []listRow {
+	return m.files.rows()
+}
+
+// selectedRow returns the selected row, if any.
+func (m model) selectedRow() (listRow, bool) {
+	if m.view != viewFiles {
+		return listRow{}, false
+	}
+	return m.files.selected()
+}
+
+// selectedFile returns the path of the selected row, if it's a file.
+func (m model) selectedFile() (string, bool) {
+	row, ok := m.selectedRow()
+	return row.file, ok && row.file != ""
+}
+
+// moveSelection moves the cursor by delta rows and scrolls it into view.
+func (m *model) moveSelection(delta int) {
+	m.syncFiles()
+	m.files.move(delta)
+	m.showSelection()
+}
+
+// showSelection re-renders the list and scrolls the cursor into view.
+func (m *model) showSelection() {
+	m.resize()
+	if line, ok := m.files.line(m.files.cursor); ok {
+		m.scrollTo(line)
+	}
+}
+
+// sameRow reports whether two rows stand for the same thing, however
+// they're rendered.
+func sameRow(a, b listRow) bool {
+	a.text, b.text = "", ""
+	return a == b
+}
+
+// selectionAnchor is the selected row and where it is on screen, kept
+// across a refresh
+type selectionAnchor struct {
+	row    listRow
+	ok     bool
+	offset int // lines below the top of the viewport, or -1 if out of view
+}
+
+// anchorSelection notes the selected row and where it's shown.
+func (m model) anchorSelection() selectionAnchor {
+	row, ok := m.files.selected()
+	if !ok {
+		return selectionAnchor{}
+	}
+	a := selectionAnchor{row: row, ok: true, offset: -1}
+	if line, ok := m.files.line(m.files.cursor); ok && m.view == viewFiles && line >= m.viewport.YOffset && line < m.viewport.YOffset+m.viewport.Height {
+		a.offset = line - m.viewport.YOffset
+	}
+	return a
+}
+
+// restoreSelection selects the anchored row again wherever the refresh
+// moved it, scrolling to keep it on the same screen line, so rows coming
+// and going above it don't make the list jump. If the row is gone the
+// cursor stays at the same index.
+func (m *model) restoreSelection(a selectionAnchor) {
+	m.syncFiles()
+	rows := m.files.rows()
+	if i := slices.IndexFunc(rows, func(r listRow) bool { return sameRow(r, a.row) }); a.ok && i >= 0 {
+		m.files.cursor = i
+	}
+	m.files.move(0)
+	m.resize()
+	if line, ok := m.files.line(m.files.cursor); ok && a.offset >= 0 && m.view == viewFiles {
+		m.viewport.SetYOffset(max(line-a.offset, 0))
+	}
+}
+
+// selectFile moves the cursor to the first row for file, if it's shown.
+func (m *model) selectFile(file string) {
+	m.resize()
+	for i, r := range m.files.rows() {
+		if r.file == file {
+			m.moveSelection(i - m.files.cursor)
+			return
+		}
+	}
+}
+
+func (m model) renderChanges() panelSection {
+	// Pinned files go first, then conflicts so they're visible as soon as the panel is
+	changes := slices.Clone(m.changes)
+	rank := func(c FileChange) int {
+		switch {
+		case m.isPinned(c.File):
+			return 0
+		case c.IsConflict():
+			return 1
+		}
+		return 2
+	}
+	slices.SortStableFunc(changes, func(a, b FileChange) int {
+		if r := rank(a) - rank(b); r != 0 {
+			return r
+		}
+		return m.compareFiles(panelChanges, a.File, b.File, a.Label, b.Label)
+	})
+
+	var entries []fileEntry
+	hidden := 0
+	for _, change := range changes {
+		if m.hidesWhitespace(panelChanges, change.File) && !m.isPinned(change.File) {
+			hidden++
+			continue
+		}
+		whitespace := m.isWhitespaceOnly(panelChanges, change.File)
+		pinned := m.isPinned(change.File)
+		status := formatLabel(change)
+		if m.narrow() {
+			// Porcelain status letters, no padding columns
+			status = changeStyle(change).Render(string([]byte{change.Staged, change.Unstaged}))
+		}
+		marked := m.marked[change.File]
+		entries = append(entries, fileEntry{
+			file:   change.File,
+			status: status,
+			render: func(name styledName) string {
+				text := m.withAuthor(panelChanges, change.File, m.withHeat(change.File, name(fileStyle)))
+				if change.Repo != "" {
+					text += helpStyle.Render(" [" + change.Repo + "]")
+				}
+				if whitespace {
+					text = whitespaceBadge(text)
+				}
+				text = m.cruftBadge(change.File, m.sizeBadge(panelChanges, change.File, text))
+				text = m.secretsBadge(change.File, text)
+				if pinned {
+					text = pinStyle.Render(glyphs.Pin) + " " + text
+				}
+				if marked {
+					text = markStyle.Render(glyphs.Mark) + " " + text
+				}
+				return text
+			},
+		})
+	}
+
+	title := "Changed Files"
+	if m.narrow() {
+		title = "Changed"
+	}
+	title += m.sortTitle() + m.cruftTitle()
+	if n := len(m.marked); n > 0 {
+		title += fmt.Sprintf(" (%d selected)", n)
+	}
+	title += whitespaceHidden(hidden) + ":"
+	return panelSection{title: title, rows: m.fileRows(panelChanges, entries)}
+}
+
+func (m model) renderBranchFiles() panelSection {
+	branchFiles := slices.Clone(m.branchFiles)
+	slices.SortStableFunc(branchFiles, func(a, b BranchFile) int {
+		return m.compareFiles(panelBranch, a.File, b.File, branchFileLabel(a.Status), branchFileLabel(b.Status))
+	})
+
+	// Generated files are grouped last, except while filtering
+	var entries, generated []fileEntry
+	hidden := 0
+	for _, bf := range branchFiles {
+		if m.hidesWhitespace(panelBranch, bf.File) {
+			hidden++
+			continue
+		}
+		whitespace := m.isWhitespaceOnly(panelBranch, bf.File)
+		isGenerated := m.isGenerated(bf.File)
+		nameStyle := fileStyle
+		if isGenerated {
+			nameStyle = generatedStyle
+		}
+		reviewed := m.isReviewed(bf.File)
+		status := branchFileStyle(bf.Status).Render(fmt.Sprintf("%-12s", branchFileLabel(bf.Status)))
+		if m.narrow() {
+			status = branchFileStyle(bf.Status).Render(bf.Status[:1])
+		}
+		entry := fileEntry{
+			file:   bf.File,
+			status: status,
+			render: func(name styledName) string {
+				var text string
+				if reviewed {
+					text = statusAdded.Render(glyphs.Check) + " " + m.withAuthor(panelBranch, bf.File, name(reviewedStyle))
+				} else {
+					text = m.withAuthor(panelBranch, bf.File, name(nameStyle))
+				}
+				if whitespace {
+					text = whitespaceBadge(text)
+				}
+				return m.ownersBadge(bf.File, m.sizeBadge(panelBranch, bf.File, text))
+			},
+		}
+		if isGenerated && m.filter == "" {
+			generated = append(generated, entry)
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+
+	title := fmt.Sprintf("Branch Files vs %s", m.baseName())
+	if m.narrow() {
+		title = fmt.Sprintf("vs %s", m.baseName())
+	}
+	title += m.sortTitle()
+	if n := m.reviewProgress(); n > 0 {
+		title += fmt.Sprintf(" (%d/%d reviewed)", n, len(m.branchFiles))
+	}
+	title += whitespaceHidden(hidden) + ":"
+	rows := m.fileRows(panelBranch, entries)
+	if len(generated) > 0 {
+		rows = append(rows, m.generatedRow(len(generated)))
+		if m.showGen {
+			rows = append(rows, m.fileRows(panelBranch, generated)...)
+		}
+	}
+	rows = append(rows, m.todoRows()...)
+	return panelSection{title: title, rows: rows}
+}
+
+// renderBranchCommits lists the commits behind Branch Files, so it's clear
+// which commit changed what. The filter matches paths, so it hides them.
+func (m model) renderBranchCommits() panelSection {
+	if m.filter != "" {
+		return panelSection{}
+	}
+	var rows []listRow
+	for _, c := range m.commits {
+		text := m.subjectBadge(c, fmt.Sprintf("%s %s %s", commitHashStyle.Render(c.Hash), c.Subject, helpStyle.Render("("+m.timeAgo(c.Time)+")")))
+		if m.narrow() {
+			width := m.width - 1
+			if m.badSubject(c) {
+				width -= 2 // for the badge
+			}
+			text = m.subjectBadge(c, truncate(fmt.Sprintf("%s %s", commitHashStyle.Render(c.Hash), c.Subject), width))
+		}
+		rows = append(rows, listRow{commit: c.Hash, text: text})
+	}
+
+	title := fmt.Sprintf("Branch Commits since %s (%d)", m.baseName(), len(m.commits))
+	if m.narrow() {
+		title = fmt.Sprintf("commits (%d)", len(m.commits))
+	}
+	title += m.subjectsTitle() + ":"
+	return panelSection{title: title, rows: rows}
+}
+
+// showCommit shows a commit's message and diff in the output pane. A
+// branch commit whose subject fails the check says what it should be.
+func (m *model) showCommit(hash string) {
+	lines, err := GetCommit(hash)
+	m.output = outputState{title: "$ git show " + hash, lines: lines, err: err, parent: viewFiles, diff: true}
+	m.view = viewOutput
+	m.viewport.GotoTop()
+	m.resize()
+	if i := slices.IndexFunc(m.commits, func(c Commit) bool { return c.Hash == hash }); i >= 0 && m.badSubject(m.commits[i]) {
+		m.notify(m.subjectHelp())
+	}
+}
+
+func formatLabel(c FileChange) string {
+	return changeStyle(c).Render(fmt.Sprintf("%-12s", c.Label))
+}
+
+func changeStyle(c FileChange) lipgloss.Style {
+	if c.IsConflict() {
+		return statusConflict
+	}
+	if c.Staged == '?' || c.Staged == '!' {
+		return statusUntracked
+	}
+	if c.Staged == 'D' || c.Unstaged == 'D' {
+		return statusDeleted
+	}
+	if c.Staged == 'A' {
+		return statusAdded
+	}
+	if c.Staged == 'R' {
+		return statusRenamed
+	}
+	if c.Staged != ' ' && c.Staged != 0 {
+		return statusAdded // staged changes in green
+	}
+	return statusModified
+}
+
+func branchFileStyle(status string) lipgloss.Style {
+	switch {
+	case status == "A":
+		return statusAdded
+	case status == "D":
+		return statusDeleted
+	case strings.HasPrefix(status, "R"):
+		return statusRenamed
+	default:
+		return statusModified
+	}
+}
+
+func branchFileLabel(status string) string {
+	switch {
+	case status == "A":
+		return "added"
+	case status == "D":
+		return "deleted"
+	case status == "M":
+		return "modified"
+	case status == "T":
+		return "type changed"
+	case strings.HasPrefix(status, "R"):
+		return "renamed"
+	case strings.HasPrefix(status, "C"):
+		return "copied"
+	default:
+		return "changed"
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"vigil/internal/fixture"
+)
+
+// renderFixture renders the repository in the current directory at a
+// fixed size, without colors.
+func renderFixture(t *testing.T) string {
+	t.Helper()
+	untracked := true
+	m := initialModel(Config{Layout: "monitor", Untracked: &untracked, Ignored: true}, RepoState{})
+	mm, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 60})
+	return ansi.Strip(mm.View())
+}
+
+// fileRow is one line of a file panel: the status label, then the file
+var fileRow = regexp.MustCompile(`^\s*(?:▸\s+)?(\S.*?)\s{2,}(\S.*?)\s*$`)
+
+// changedFiles returns the files in the Changed Files panel of a rendered
+// view, in order, and the label shown for each.
+func changedFiles(view string) (files []string, labels map[string]string) {
+	labels = make(map[string]string)
+	_, panel, _ := strings.Cut(view, "Changed Files")
+	lines := strings.Split(panel, "\n")[1:]
+	for _, line := range lines {
+		match := fileRow.FindStringSubmatch(line)
+		if match == nil {
+			break
+		}
+		files = append(files, match[2])
+		labels[match[2]] = match[1]
+	}
+	return files, labels
+}
+
+func TestFileListFixture(t *testing.T) {
+	repo, err := fixture.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+	view := renderFixture(t)
+
+	files, labels := changedFiles(view)
+	want := map[string]string{
+		fixture.Modified:       "modified",
+		fixture.Staged:         "modified (staged)",
+		fixture.StagedModified: "modified (staged), modified",
+		fixture.Added:          "added (staged)",
+		fixture.AddedModified:  "added (staged), modified",
+		fixture.Deleted:        "deleted",
+		fixture.StagedDeleted:  "deleted (staged)",
+		fixture.RenamedFrom + " -> " + fixture.RenamedTo: "renamed (staged)",
+		fixture.TypeChanged:   "type changed",
+		fixture.Untracked:     "untracked",
+		"build/":              "ignored",
+		fixture.BothModified:  "conflict",
+		fixture.BothAdded:     "conflict",
+		fixture.DeletedByUs:   "conflict",
+		fixture.DeletedByThem: "conflict",
+	}
+	if len(files) != len(want) {
+		t.Errorf("file list shows %d files, want %d:\n%s", len(files), len(want), view)
+	}
+	for file, label := range want {
+		if labels[file] != label {
+			t.Errorf("%s: shown as %q, want %q", file, labels[file], label)
+		}
+	}
+	// Conflicts lead, so they're seen first
+	for i, file := range files {
+		if conflict := labels[file] == "conflict"; conflict != (i < 4) {
+			t.Errorf("row %d is %s, %q: want the 4 conflicts first", i, file, labels[file])
+		}
+	}
+
+	for _, s := range []string{
+		"Branch: " + fixture.Branch,
+		"staged 6 · modified 5 · untracked 1 · stashes 1 · conflicts 4 · ignored 1",
+		"✎ Needs review",
+		"Release: " + fixture.Tag,
+	} {
+		if !strings.Contains(view, s) {
+			t.Errorf("header doesn't show %q:\n%s", s, view)
+		}
+	}
+}
+
+func TestFileListDetached(t *testing.T) {
+	repo, err := fixture.Detached(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+	view := renderFixture(t)
+
+	if !strings.Contains(view, "Branch: (detached) ") {
+		t.Errorf("header doesn't show HEAD detached:\n%s", view)
+	}
+	files, labels := changedFiles(view)
+	if len(files) != 1 || labels[fixture.Modified] != "modified" {
+		t.Errorf("file list %v, want only %s modified", labels, fixture.Modified)
+	}
+}
+
+func TestFileListEmpty(t *testing.T) {
+	repo, err := fixture.Empty(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+	view := renderFixture(t)
+
+	files, labels := changedFiles(view)
+	if len(files) != 2 || labels[fixture.Added] != "added (staged)" || labels[fixture.Untracked] != "untracked" {
+		t.Errorf("file list %v, want %s added and %s untracked", labels, fixture.Added, fixture.Untracked)
+	}
+}
+
+func TestFileListKeys(t *testing.T) {
+	f := fileList{page: 4, sections: []panelSection{
+		{panel: panelChanges, title: "Changed Files:", rows: []listRow{{file: "a", text: "a"}, {file: "b", text: "b"}}},
+		{panel: panelBranch, title: "Branch Files:", rows: []listRow{{file: "c", text: "c"}}, hidden: 2},
+	}}
+	for _, step := range []struct {
+		key  string
+		want string
+		line int
+	}{
+		{"j", "b", 2},
+		{"j", "c", 5},
+		{"j", "c", 5}, // stays on the last row
+		{"g", "a", 1},
+		{"G", "c", 5},
+		{"k", "b", 2},
+	} {
+		f, _ = f.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(step.key)})
+		row, ok := f.selected()
+		if !ok || row.file != step.want {
+			t.Fatalf("after %s selected %q, want %q", step.key, row.file, step.want)
+		}
+		if line, _ := f.line(f.cursor); line != step.line {
+			t.Errorf("after %s the row is on line %d, want %d", step.key, line, step.line)
+		}
+	}
+
+	lines := strings.Split(ansi.Strip(f.View()), "\n")
+	if want := glyphs.Cursor + " b"; lines[2] != want {
+		t.Errorf("selected row rendered as %q, want %q", lines[2], want)
+	}
+	if !strings.Contains(lines[6], "2 more") {
+		t.Errorf("hidden rows rendered as %q", lines[6])
+	}
+}
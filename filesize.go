@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// binarySniffLen is how much of a file is checked for NUL bytes to tell
+// whether it's binary, as git does
+const binarySniffLen = 8000
+
+// fileSizes holds the binary and large files in each panel, by path
+type fileSizes struct {
+	changes map[string]fileSize // in the working tree
+	branch  map[string]fileSize // at HEAD
+}
+
+// fileSize is what a file panel notes about a file's content. bytes is
+// only set for files at least the large file size.
+type fileSize struct {
+	bytes  int64
+	binary bool
+}
+
+// sizes finds the binary and large files in Changed Files and Branch
+// Files, keeping the branch's unless it has moved.
+func (r *loadRun) sizes() fileSizes {
+	sizes := fileSizes{branch: r.set.sizes.branch}
+	root := r.root()
+	if root == "" {
+		return sizes
+	}
+
+	if len(r.s.changes) > 0 {
+		stats := r.headStats()
+		sizes.changes = make(map[string]fileSize)
+		for _, c := range r.s.changes {
+			path := treePath(c.File)
+			info, err := os.Stat(filepath.Join(root, path))
+			if c.Repo != "" || err != nil || info.IsDir() {
+				continue // deleted files have no content to speak of
+			}
+			var f fileSize
+			if r.set.largeSize > 0 && info.Size() >= r.set.largeSize {
+				f.bytes = info.Size()
+			}
+			if s, ok := stats[path]; ok {
+				f.binary = s.Binary
+			} else {
+				f.binary = isBinaryFile(filepath.Join(root, path)) // untracked or new
+			}
+			if f != (fileSize{}) {
+				sizes.changes[path] = f
+			}
+		}
+	}
+
+	if !r.s.branchMoved {
+		return sizes // the branch files are as they were
+	}
+	sizes.branch = nil
+	if len(r.s.branchFiles) > 0 {
+		if r.mergeBase() == "" {
+			return sizes
+		}
+		var paths []string
+		for _, bf := range r.s.branchFiles {
+			paths = append(paths, treePath(bf.File))
+		}
+		stats := r.branchStats()
+		var blobs map[string]int64
+		if r.set.largeSize > 0 {
+			blobs = GetBlobSizes(r.ctx, "HEAD", paths)
+		}
+		sizes.branch = make(map[string]fileSize)
+		for _, path := range paths {
+			var f fileSize
+			if n := blobs[path]; r.set.largeSize > 0 && n >= r.set.largeSize {
+				f.bytes = n
+			}
+			f.binary = stats[path].Binary
+			if f != (fileSize{}) {
+				sizes.branch[path] = f
+			}
+		}
+	}
+	return sizes
+}
+
+// isBinaryFile reports whether a file looks binary: a NUL byte near the
+// start.
+func isBinaryFile(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	head := make([]byte, binarySniffLen)
+	n, _ := io.ReadFull(file, head)
+	return bytes.IndexByte(head[:n], 0) >= 0
+}
+
+// fileSize returns what's known of a file's content in a panel.
+func (m model) fileSize(panel, file string) fileSize {
+	return m.sizes.of(panel, file)
+}
+
+// of returns what's known of a file's content in a panel.
+func (s fileSizes) of(panel, file string) fileSize {
+	if panel == panelBranch {
+		return s.branch[treePath(file)]
+	}
+	return s.changes[treePath(file)]
+}
+
+// skipsDiff reports whether a file's diff isn't worth generating.
+func (f fileSize) skipsDiff() bool {
+	return f.binary || f.bytes > 0
+}
+
+// String describes the file, e.g. "binary" or "2.4 MB".
+func (f fileSize) String() string {
+	switch {
+	case f.binary && f.bytes > 0:
+		return "binary, " + formatSize(f.bytes)
+	case f.binary:
+		return "binary"
+	case f.bytes > 0:
+		return formatSize(f.bytes)
+	}
+	return ""
+}
+
+// sizeBadge notes after a file's name that it's binary or large.
+func (m model) sizeBadge(panel, file, text string) string {
+	f := m.fileSize(panel, file)
+	if f == (fileSize{}) {
+		return text
+	}
+	style := helpStyle
+	if f.bytes > 0 {
+		style = statusModified
+	}
+	return text + style.Render(" "+f.String())
+}
+
+// formatSize formats a number of bytes for people, e.g. "2.4 MB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	size, prefix := float64(n)/unit, 0
+	for size >= unit && prefix < 3 {
+		size /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%.1f %cB", size, "KMGT"[prefix])
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var matchStyle = lipgloss.NewStyle().
+	Bold(true).
+	Underline(true).
+	Foreground(lipgloss.Color("220"))
+
+// openFilter starts typing a filter for the file lists.
+func (m *model) openFilter() tea.Cmd {
+	m.filtering = true
+	m.filterInput.SetValue(m.filter)
+	m.filterInput.CursorEnd()
+	return m.filterInput.Focus()
+}
+
+// setFilter narrows the file lists to paths matching filter, or shows
+// everything again when it's empty.
+func (m *model) setFilter(filter string) {
+	m.filter = filter
+	m.files.cursor = 0
+	m.viewport.GotoTop()
+	m.resize()
+}
+
+// updateFilter handles key input while the filter is being typed. The
+// lists narrow as you type; enter keeps the filter and esc clears it.
+func (m model) updateFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.filtering = false
+		m.filterInput.Blur()
+		m.setFilter("")
+		return m, nil
+	case "enter":
+		m.filtering = false
+		m.filterInput.Blur()
+		return m, nil
+	case "up":
+		m.moveSelection(-1)
+		return m, nil
+	case "down":
+		m.moveSelection(1)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.filterInput, cmd = m.filterInput.Update(msg)
+	if value := strings.TrimSpace(m.filterInput.Value()); value != m.filter {
+		m.setFilter(value)
+	}
+	return m, cmd
+}
+
+func newFilterInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "/"
+	input.CharLimit = 256
+	return input
+}
+
+// fuzzyMatch reports whether pattern matches text, ignoring case, and
+// returns the rune positions in text to highlight. A contiguous match is
+// preferred, the last one so it lands on the file name rather than a
+// directory; otherwise pattern's characters must appear in order.
+func fuzzyMatch(pattern, text string) ([]int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(text)
+	for i := range t {
+		t[i] = unicode.ToLower(t[i])
+	}
+	if len(p) == 0 {
+		return nil, true
+	}
+
+	for start := len(t) - len(p); start >= 0; start-- {
+		if string(t[start:start+len(p)]) == string(p) {
+			positions := make([]int, len(p))
+			for i := range positions {
+				positions[i] = start + i
+			}
+			return positions, true
+		}
+	}
+
+	var positions []int
+	for i := 0; i < len(t) && len(positions) < len(p); i++ {
+		if t[i] == p[len(positions)] {
+			positions = append(positions, i)
+		}
+	}
+	return positions, len(positions) == len(p)
+}
+
+// highlight renders text in style, with the runes at positions picked out
+// in matchStyle.
+func highlight(text string, positions []int, style lipgloss.Style) string {
+	if len(positions) == 0 {
+		return style.Render(text)
+	}
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+
+	var out strings.Builder
+	var run []rune
+	inMatch := false
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if inMatch {
+			out.WriteString(matchStyle.Inherit(style).Render(string(run)))
+		} else {
+			out.WriteString(style.Render(string(run)))
+		}
+		run = run[:0]
+	}
+	for i, r := range []rune(text) {
+		if matched[i] != inMatch {
+			flush()
+			inMatch = matched[i]
+		}
+		run = append(run, r)
+	}
+	flush()
+	return out.String()
+}
+
+// filterHint describes the active filter for the footer.
+func (m model) filterHint() string {
+	if m.narrow() {
+		return fmt.Sprintf("/%s esc:clear", m.filter)
+	}
+	return fmt.Sprintf("Filter: %s  /: edit  esc: clear  ?: help  q: quit", m.filter)
+}
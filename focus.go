@@ -0,0 +1,46 @@
+package main
+
+import "slices"
+
+// followActivity moves focus to the panel whose contents changed since the
+// previous refresh and scrolls it into view. Uncommitted changes win when
+// both changed, since that's where new conflicts show up.
+func (m *model) followActivity(prevChanges []FileChange, prevBranchFiles []BranchFile) {
+	var panel string
+	switch {
+	case !slices.Equal(prevChanges, m.changes):
+		panel = panelChanges
+	case !slices.Equal(prevBranchFiles, m.branchFiles):
+		panel = panelBranch
+	default:
+		return
+	}
+
+	m.focus = panel
+	m.resize()
+	if line, ok := m.panelLine(panel); ok {
+		m.viewport.SetYOffset(line)
+	}
+}
+
+// panelLine returns the body line a panel's title is rendered on.
+func (m model) panelLine(panel string) (int, bool) {
+	if m.view != viewFiles {
+		return 0, false
+	}
+	return m.files.panelLine(panel)
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func shownHidden(b bool) string {
+	if b {
+		return "shown"
+	}
+	return "hidden"
+}
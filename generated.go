@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Attributes that mark a file as generated: GitHub's, and vigil's own,
+// which takes precedence when set or unset
+const (
+	attrLinguistGenerated = "linguist-generated"
+	attrVigilGenerated    = "vigil-generated"
+)
+
+// GetGeneratedFiles returns which of paths .gitattributes marks as
+// generated, such as lockfiles and protobuf output.
+func GetGeneratedFiles(ctx context.Context, paths []string) map[string]bool {
+	if len(paths) == 0 {
+		return nil
+	}
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil
+	}
+	cmd := gitCommandContext(ctx, "check-attr", "-z", "--stdin", attrLinguistGenerated, attrVigilGenerated)
+	cmd.Dir = root // paths are relative to it
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	// Each answer is "<path>\0<attribute>\0<value>\0"
+	values := make(map[string]map[string]string)
+	fields := strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		path, attr, value := fields[i], fields[i+1], fields[i+2]
+		if values[path] == nil {
+			values[path] = make(map[string]string)
+		}
+		values[path][attr] = value
+	}
+
+	generated := make(map[string]bool)
+	for path, attrs := range values {
+		value := attrs[attrVigilGenerated]
+		if value == "unspecified" {
+			value = attrs[attrLinguistGenerated]
+		}
+		if value == "set" || value == "true" {
+			generated[path] = true
+		}
+	}
+	return generated
+}
+
+// generated finds the generated files among the branch files.
+func (r *loadRun) generated() map[string]bool {
+	var paths []string
+	for _, bf := range r.s.branchFiles {
+		paths = append(paths, treePath(bf.File))
+	}
+	return GetGeneratedFiles(r.ctx, paths)
+}
+
+// isGenerated reports whether .gitattributes marks a file as generated.
+func (m model) isGenerated(file string) bool {
+	return m.generated[treePath(file)]
+}
+
+// generatedRow is the row Branch Files groups generated files under,
+// collapsed until enter expands it.
+func (m model) generatedRow(n int) listRow {
+	glyph := glyphs.TreeClosed
+	if m.showGen {
+		glyph = glyphs.TreeOpen
+	}
+	text := generatedStyle.Render(fmt.Sprintf("%s %s", glyph, plural(n, "generated file")))
+	return listRow{generated: true, text: text}
+}
@@ -1,9 +1,21 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"vigil/internal/porcelain"
 )
 
 // FileChange represents a changed file in git status
@@ -12,29 +24,96 @@ type FileChange struct {
 	Unstaged byte // second column: unstaged status
 	Label    string
 	File     string
+	Repo     string // repoSubmodule or repoNested when the entry is another repository
+}
+
+// Kinds of repository git status can report as a single entry, without
+// listing the files inside
+const (
+	repoSubmodule = "submodule"
+	repoNested    = "nested repo" // an untracked repository inside the working tree
+)
+
+// IsConflict reports whether the file has unresolved merge conflicts
+func (c FileChange) IsConflict() bool {
+	switch string([]byte{c.Staged, c.Unstaged}) {
+	case "DD", "AU", "UD", "UA", "DU", "AA", "UU":
+		return true
+	}
+	return false
 }
 
-// IsGitRepo checks if the current directory is inside a git repository
+// IsGitRepo checks if the current directory is inside a git repository's
+// working tree. Inside the .git directory, git answers false.
 func IsGitRepo() bool {
-	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
-	err := cmd.Run()
-	return err == nil
+	output, err := gitCommand("rev-parse", "--is-inside-work-tree").Output()
+	return err == nil && strings.TrimSpace(string(output)) == "true"
+}
+
+// InsideGitDir reports whether the current directory is inside a
+// repository's .git directory, returning the working tree it belongs to
+// if there is one.
+func InsideGitDir() (string, bool) {
+	output, err := gitCommand("rev-parse", "--is-inside-git-dir", "--git-dir").Output()
+	if err != nil {
+		return "", false
+	}
+	inside, gitDir, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	if inside != "true" {
+		return "", false
+	}
+	gitDir, err = filepath.Abs(gitDir)
+	if err != nil {
+		return "", true
+	}
+	if filepath.Base(gitDir) == ".git" {
+		return filepath.Dir(gitDir), true
+	}
+	// A linked worktree's git dir records where the worktree's .git file is
+	if data, err := os.ReadFile(filepath.Join(gitDir, "gitdir")); err == nil {
+		return filepath.Dir(filepath.FromSlash(strings.TrimSpace(string(data)))), true
+	}
+	return "", true // bare, or a separate git dir
+}
+
+// GetGitDir returns the absolute path of the current worktree's git dir,
+// which for a linked worktree is under the common dir's worktrees/.
+func GetGitDir() (string, error) {
+	output, err := gitCommand("rev-parse", "--git-dir").Output()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(filepath.FromSlash(strings.TrimSpace(string(output))))
+}
+
+// GetGitCommonDir returns the absolute path of the repository's git dir
+// shared by all worktrees.
+func GetGitCommonDir() (string, error) {
+	if !gitAtLeast(2, 31) {
+		// No --path-format; the path may be relative to the current directory
+		output, err := gitCommand("rev-parse", "--git-common-dir").Output()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Abs(strings.TrimSpace(string(output)))
+	}
+	cmd := gitCommand("rev-parse", "--path-format=absolute", "--git-common-dir")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return filepath.FromSlash(strings.TrimSpace(string(output))), nil
 }
 
 // GetCurrentBranch returns the current git branch name
 func GetCurrentBranch() string {
-	cmd := exec.Command("git", "branch", "--show-current")
-	output, err := cmd.Output()
-	if err == nil {
-		branch := strings.TrimSpace(string(output))
-		if branch != "" {
-			return branch
-		}
+	if branch, err := currentBranch(); err == nil && branch != "" {
+		return branch
 	}
 
 	// Try symbolic-ref for repos with no commits yet
-	cmd = exec.Command("git", "symbolic-ref", "--short", "HEAD")
-	output, err = cmd.Output()
+	cmd := gitCommand("symbolic-ref", "--short", "HEAD")
+	output, err := cmd.Output()
 	if err == nil {
 		branch := strings.TrimSpace(string(output))
 		if branch != "" {
@@ -43,7 +122,7 @@ func GetCurrentBranch() string {
 	}
 
 	// Might be in detached HEAD state
-	cmd = exec.Command("git", "rev-parse", "--short", "HEAD")
+	cmd = gitCommand("rev-parse", "--short", "HEAD")
 	output, err = cmd.Output()
 	if err == nil {
 		return "(detached) " + strings.TrimSpace(string(output))
@@ -52,47 +131,324 @@ func GetCurrentBranch() string {
 	return "unknown"
 }
 
-// GetGitStatus returns a list of changed files from git status
-func GetGitStatus() []FileChange {
-	cmd := exec.Command("git", "status", "--porcelain", "-uall")
+// currentBranch returns the checked out branch's name, or "" with a
+// detached HEAD.
+func currentBranch() (string, error) {
+	cmd := gitCommand("branch", "--show-current")
+	if !gitAtLeast(2, 22) {
+		cmd = gitCommand("symbolic-ref", "--short", "--quiet", "HEAD")
+	}
 	output, err := cmd.Output()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 {
+		return "", nil // symbolic-ref: detached
+	}
 	if err != nil {
-		return nil
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// StatusSummary holds overall dirtiness counts for the working tree
+type StatusSummary struct {
+	Staged    int
+	Modified  int
+	Untracked int
+	Stashes   int
+	Conflicts int
+	Ignored   int
+}
+
+// Dirty reports uncommitted changes of any kind, untracked files included.
+func (s StatusSummary) Dirty() bool {
+	return s.Staged+s.Modified+s.Untracked+s.Conflicts > 0
+}
+
+// StatusOptions picks which files beyond tracked changes GetGitStatus reports
+type StatusOptions struct {
+	Untracked     bool // untracked files, listed individually
+	UntrackedDirs bool // with Untracked, list a wholly untracked directory once
+	Ignored       bool // ignored files; a wholly ignored directory is listed once
+}
+
+// GetGitStatus returns a list of changed files from git status, along with
+// summary counts gathered in the same pass.
+func GetGitStatus(opts StatusOptions) ([]FileChange, StatusSummary) {
+	return GetGitStatusContext(context.Background(), opts)
+}
+
+// GetGitStatusContext is GetGitStatus, killing git status if ctx is
+// canceled first.
+func GetGitStatusContext(ctx context.Context, opts StatusOptions) ([]FileChange, StatusSummary) {
+	var summary StatusSummary
+	format, parse := statusFormat()
+	args := []string{"status", format, "-z", "-uno"}
+	if opts.Ignored {
+		// git only lists ignored files alongside untracked ones; those are
+		// dropped below if they weren't asked for
+		args[3] = "-uall"
+		if gitAtLeast(2, 16) {
+			args = append(args, "--ignored=matching")
+		} else {
+			args = append(args, "--ignored")
+		}
+	} else if opts.Untracked && opts.UntrackedDirs {
+		args[3] = "-unormal"
+	} else if opts.Untracked {
+		args[3] = "-uall"
+	}
+	// --show-stash is accepted from 2.14, but porcelain v2 only prints the
+	// "# stash" header it asks for from 2.35
+	stashHeader := gitAtLeast(2, 35)
+	if stashHeader {
+		args = append(args, "--show-stash")
+	}
+	cmd := gitCommandContext(ctx, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, summary
 	}
 
+	status := parse(output)
+	summary.Stashes = status.Stashes
+	if !stashHeader {
+		summary.Stashes = countStashes()
+	}
 	var changes []FileChange
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if len(line) < 4 {
-			continue
+	var root string
+	v1 := !gitAtLeast(2, 11)
+	for _, e := range status.Entries {
+		change := fileChange(e)
+		untracked := change.Staged == '?' || change.Staged == '!'
+		if untracked && strings.HasSuffix(e.Path, "/") || v1 && !untracked {
+			// Untracked directories, and with git too old for porcelain
+			// v2's submodule column any tracked entry, may be repositories
+			if root == "" {
+				root, _ = GetRepoRoot()
+			}
+			change.Repo = repoAt(root, e)
 		}
-		staged := line[0]
-		unstaged := line[1]
-		file := line[3:]
+		switch {
+		case change.IsConflict():
+			summary.Conflicts++
+		case change.Staged == '?':
+			if !opts.Untracked {
+				continue
+			}
+			summary.Untracked++
+		case change.Staged == '!':
+			summary.Ignored++
+		default:
+			if change.Staged != ' ' {
+				summary.Staged++
+			}
+			if change.Unstaged != ' ' {
+				summary.Modified++
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, summary
+}
 
-		label := statusLabel(staged, unstaged)
-		changes = append(changes, FileChange{
-			Staged:   staged,
-			Unstaged: unstaged,
-			Label:    label,
-			File:     file,
-		})
+// statusFormat returns the git status porcelain flag to use and its
+// parser: v2, or v1 on git older than 2.11.
+func statusFormat() (string, func([]byte) porcelain.Status) {
+	if !gitAtLeast(2, 11) {
+		return "--porcelain", porcelain.ParseStatusV1
 	}
-	return changes
+	return "--porcelain=v2", porcelain.ParseStatus
 }
 
-// GetCommitsAheadBehind fetches from remote and returns how many commits
-// the current branch is ahead and behind its upstream tracking branch.
-func GetCommitsAheadBehind() (ahead int, behind int, err error) {
-	// Fetch latest remote refs
-	fetch := exec.Command("git", "fetch", "--quiet")
-	fetch.Run() // ignore fetch errors (e.g. offline)
+// countStashes counts stash entries, for git too old to report them in
+// git status.
+func countStashes() int {
+	output, err := gitCommand("stash", "list").Output()
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(output), "\n")
+}
 
-	cmd := exec.Command("git", "rev-list", "--count", "--left-right", "HEAD...@{upstream}")
-	output, err := cmd.Output()
+// fileChange converts a porcelain status entry. Renames are reported as
+// "old -> new", like the v1 format.
+func fileChange(e porcelain.Entry) FileChange {
+	// v2 marks unchanged columns with '.', v1 with a space
+	staged, unstaged := e.X, e.Y
+	if staged == '.' {
+		staged = ' '
+	}
+	if unstaged == '.' {
+		unstaged = ' '
+	}
+	file := e.Path
+	if e.OrigPath != "" {
+		file = e.OrigPath + " -> " + e.Path
+	}
+	c := FileChange{
+		Staged:   staged,
+		Unstaged: unstaged,
+		Label:    statusLabel(staged, unstaged),
+		File:     file,
+	}
+	if e.IsSubmodule() {
+		c.Repo = repoSubmodule
+	}
+	return c
+}
+
+// repoAt reports whether a status entry is a repository of its own: one
+// with a .git directory, or for a submodule a .git file, at its top.
+func repoAt(root string, e porcelain.Entry) string {
+	if root == "" {
+		return ""
+	}
+	if _, err := os.Stat(filepath.Join(root, e.Path, ".git")); err != nil {
+		return ""
+	}
+	if e.X == '?' || e.X == '!' {
+		return repoNested
+	}
+	return repoSubmodule
+}
+
+// WorkingTreeFingerprint returns a value that changes whenever HEAD, the
+// index or the contents of a changed file do, cheaply enough to poll.
+func WorkingTreeFingerprint() string {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return ""
+	}
+	format, parse := statusFormat()
+	status, err := gitCommand("status", format, "-z", "-uall").Output()
+	if err != nil {
+		return ""
+	}
+	head, _ := gitCommand("rev-parse", "HEAD").Output()
+
+	h := fnv.New64a()
+	h.Write(head)
+	h.Write(status)
+	for _, e := range parse(status).Entries {
+		if info, err := os.Stat(filepath.Join(root, e.Path)); err == nil {
+			fmt.Fprintf(h, "%d %d", info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// Fetch updates remote-tracking refs from the current branch's remote.
+// With more than one remote, as in a fork with origin and upstream, it
+// fetches them all.
+func Fetch() error {
+	return FetchContext(context.Background())
+}
+
+// FetchContext is Fetch, killing git fetch if ctx is done first. When the
+// remote couldn't be reached at all the error wraps errOffline.
+func FetchContext(ctx context.Context) error {
+	args := []string{"fetch", "--quiet"}
+	if remotes, err := GetRemotes(); err == nil && len(remotes) > 1 {
+		args = append(args, "--all")
+	}
+	cmd := gitCommandContext(ctx, args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	// ssh, started by git, keeps the output open after git is killed
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if unreachable.Match(output) {
+		return fmt.Errorf("%w: %v", errOffline, gitError(output, err))
+	}
+	return gitError(output, err)
+}
+
+// errOffline means a fetch couldn't reach the remote: the network, rather
+// than the remote, is the problem
+var errOffline = errors.New("offline")
+
+// unreachable matches what ssh, curl and git say when a remote's host
+// can't be resolved or connected to
+var unreachable = regexp.MustCompile(`(?i)could not resolve|name resolution|network is unreachable|no route to host|timed out|connection refused`)
+
+// GetCommitsAheadBehind returns how many commits the current branch is
+// ahead and behind its upstream tracking branch.
+func GetCommitsAheadBehind() (ahead int, behind int, err error) {
+	ahead, behind, err = countAheadBehind("@{upstream}")
 	if err != nil {
+		if _, gone := GoneUpstream(); gone {
+			return 0, 0, errUpstreamGone
+		}
 		return 0, 0, fmt.Errorf("no upstream")
 	}
+	return ahead, behind, nil
+}
+
+// errUpstreamGone means the branch tracks a remote branch that no longer
+// exists, typically one deleted after its pull request was merged
+var errUpstreamGone = errors.New("upstream gone")
+
+// GoneUpstream returns the upstream the current branch is set to track
+// when that remote branch has been deleted.
+func GoneUpstream() (string, bool) {
+	branch, err := currentBranch()
+	if err != nil || branch == "" {
+		return "", false
+	}
+	output, err := gitCommand("for-each-ref", "--format=%(upstream:short) %(upstream:track)", "refs/heads/"+branch).Output()
+	if err != nil {
+		return "", false
+	}
+	upstream, track, _ := strings.Cut(strings.TrimSpace(string(output)), " ")
+	return upstream, track == "[gone]"
+}
+
+// RemoteDivergence is how far HEAD is from the main branch of a remote
+// other than the upstream's, e.g. the original repository of a fork
+type RemoteDivergence struct {
+	Ref    string // e.g. upstream/main
+	Ahead  int
+	Behind int
+}
+
+// GetRemoteDivergence compares HEAD with the main branch of each remote
+// besides the upstream's, when there's more than one remote. A remote's
+// main branch is the one its HEAD points to, or else its branch named
+// like the default branch.
+func GetRemoteDivergence(upstream string) []RemoteDivergence {
+	remotes, err := GetRemotes()
+	if err != nil || len(remotes) < 2 {
+		return nil
+	}
+	var divergence []RemoteDivergence
+	for _, r := range remotes {
+		if strings.HasPrefix(upstream, r+"/") {
+			continue
+		}
+		ref := r + "/" + GetDefaultBranch()
+		if output, err := gitCommand("symbolic-ref", "--short", "refs/remotes/"+r+"/HEAD").Output(); err == nil {
+			ref = strings.TrimSpace(string(output))
+		} else if !RefExists("refs/remotes/" + ref) {
+			continue
+		}
+		ahead, behind, err := countAheadBehind("refs/remotes/" + ref)
+		if err != nil {
+			continue
+		}
+		divergence = append(divergence, RemoteDivergence{Ref: ref, Ahead: ahead, Behind: behind})
+	}
+	return divergence
+}
+
+// countAheadBehind counts the commits HEAD has that ref doesn't, and the
+// other way round.
+func countAheadBehind(ref string) (ahead int, behind int, err error) {
+	output, err := gitCommand("rev-list", "--count", "--left-right", "HEAD..."+ref).Output()
+	if err != nil {
+		return 0, 0, err
+	}
 	parts := strings.Fields(strings.TrimSpace(string(output)))
 	if len(parts) != 2 {
 		return 0, 0, fmt.Errorf("unexpected output")
@@ -102,14 +458,104 @@ func GetCommitsAheadBehind() (ahead int, behind int, err error) {
 	return ahead, behind, nil
 }
 
+// Commit holds summary information about a single commit
+type Commit struct {
+	Hash    string    `json:"hash"` // abbreviated
+	Subject string    `json:"subject"`
+	Author  string    `json:"author"`
+	Time    time.Time `json:"time"`
+	Note    string    `json:"note,omitempty"` // from git notes, if any
+}
+
+// GetLastCommit returns the commit at HEAD, or false if there are no commits yet.
+func GetLastCommit() (Commit, bool) {
+	cmd := gitCommand("log", "-1", "--format=%h%x00%s%x00%an%x00%ct%x00%N")
+	output, err := cmd.Output()
+	if err != nil {
+		return Commit{}, false
+	}
+	parts := strings.Split(strings.TrimSpace(string(output)), "\x00")
+	if len(parts) != 5 {
+		return Commit{}, false
+	}
+	var unix int64
+	fmt.Sscanf(parts[3], "%d", &unix)
+	return Commit{
+		Hash:    parts[0],
+		Subject: parts[1],
+		Author:  parts[2],
+		Time:    time.Unix(unix, 0),
+		Note:    strings.TrimSpace(parts[4]),
+	}, true
+}
+
+// SetNote replaces the git note on a commit, or removes it when text is empty.
+func SetNote(commit, text string) error {
+	var cmd *gitCmd
+	if text == "" {
+		cmd = gitCommand("notes", "remove", "--ignore-missing", commit)
+	} else {
+		cmd = gitCommand("notes", "add", "--force", "--message", text, commit)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// Release describes HEAD relative to the most recent reachable tag
+type Release struct {
+	Describe string `json:"describe"` // e.g. v1.4.2-17-gabc123, or just v1.4.2 at the tag
+	Tag      string `json:"tag"`
+	Distance int    `json:"distance"` // commits since Tag
+}
+
+// GetRelease runs git describe --tags, or returns false if no tag is reachable.
+func GetRelease() (Release, bool) {
+	cmd := gitCommand("describe", "--tags", "--long")
+	output, err := cmd.Output()
+	if err != nil {
+		return Release{}, false
+	}
+	long := strings.TrimSpace(string(output))
+
+	// tag-N-gHASH; the tag itself may contain dashes
+	rest, hash, ok1 := cutLast(long, "-")
+	tag, n, ok2 := cutLast(rest, "-")
+	if !ok1 || !ok2 || !strings.HasPrefix(hash, "g") {
+		return Release{}, false
+	}
+	var distance int
+	fmt.Sscanf(n, "%d", &distance)
+
+	describe := long
+	if distance == 0 {
+		describe = tag
+	}
+	return Release{Describe: describe, Tag: tag, Distance: distance}, true
+}
+
+// cutLast slices s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
 var cachedDefaultBranch string
 
-// GetDefaultBranch returns the default branch name (main or master), cached after first call.
+// GetDefaultBranch returns the default branch name (main or master),
+// cached until origin/HEAD or a main branch appears or moves.
 func GetDefaultBranch() string {
-	if cachedDefaultBranch != "" {
+	key := refs.fingerprint("refs/heads/main")
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if cachedDefaultBranch != "" && key != "" && key == defaultBranchKey {
 		return cachedDefaultBranch
 	}
-	cmd := exec.Command("git", "symbolic-ref", "refs/remotes/origin/HEAD")
+	defaultBranchKey = key
+	cmd := gitCommand("symbolic-ref", "refs/remotes/origin/HEAD")
 	output, err := cmd.Output()
 	if err == nil {
 		ref := strings.TrimSpace(string(output))
@@ -119,7 +565,7 @@ func GetDefaultBranch() string {
 			return cachedDefaultBranch
 		}
 	}
-	if exec.Command("git", "rev-parse", "--verify", "refs/heads/main").Run() == nil {
+	if gitCommand("rev-parse", "--verify", "refs/heads/main").Run() == nil {
 		cachedDefaultBranch = "main"
 	} else {
 		cachedDefaultBranch = "master"
@@ -133,57 +579,1322 @@ type BranchFile struct {
 	File   string
 }
 
+// RefExists reports whether ref resolves to a commit.
+func RefExists(ref string) bool {
+	return gitCommand("rev-parse", "--verify", "--quiet", ref+"^{commit}").Run() == nil
+}
+
 // GetBranchDiffFiles returns files changed in commits on this branch
-// since it diverged from the default branch.
-func GetBranchDiffFiles() []BranchFile {
-	defaultBranch := GetDefaultBranch()
+// since it diverged from base. An empty base means the default branch.
+func GetBranchDiffFiles(base string) []BranchFile {
+	return GetBranchDiffFilesContext(context.Background(), base)
+}
+
+// GetBranchDiffFilesContext is GetBranchDiffFiles, killing git diff if ctx
+// is canceled first.
+func GetBranchDiffFilesContext(ctx context.Context, base string) []BranchFile {
+	mergeBase, ok := branchMergeBase(base)
+	if !ok {
+		return nil
+	}
+	return diffFiles(ctx, mergeBase, "HEAD")
+}
+
+// GetBranchCommits returns the commits on this branch since it diverged
+// from base, newest first. An empty base means the default branch.
+func GetBranchCommits(base string) []Commit {
+	return GetBranchCommitsContext(context.Background(), base)
+}
+
+// GetBranchCommitsContext is GetBranchCommits, killing git log if ctx is
+// canceled first.
+func GetBranchCommitsContext(ctx context.Context, base string) []Commit {
+	mergeBase, ok := branchMergeBase(base)
+	if !ok {
+		return nil
+	}
+	return logCommitsContext(ctx, mergeBase+"..HEAD")
+}
+
+// branchMergeBase returns where HEAD diverged from base, or false when
+// HEAD is base itself and there's no branch to speak of.
+func branchMergeBase(base string) (string, bool) {
+	if base == "" {
+		base = GetDefaultBranch()
+	}
+
+	// Check if HEAD is the same ref as the base (handles detached HEAD too)
+	headRev, err := gitCommand("rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", false
+	}
+	baseRev, err := gitCommand("rev-parse", base+"^{commit}").Output()
+	if err != nil {
+		return "", false
+	}
+	if strings.TrimSpace(string(headRev)) == strings.TrimSpace(string(baseRev)) {
+		return "", false
+	}
+
+	mergeBase, err := GetMergeBase(base)
+	if err != nil {
+		return "", false
+	}
+	return mergeBase, true
+}
+
+// GetMergeBase returns the commit HEAD branched off base at, or the
+// default branch if base is empty. It's cached until HEAD or base moves.
+func GetMergeBase(base string) (string, error) {
+	if base == "" {
+		base = GetDefaultBranch()
+	}
+	key := refs.fingerprint(base)
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if c, ok := mergeBases[base]; ok && key != "" && c.key == key {
+		return c.base, nil
+	}
+	mergeBase, err := MergeBase(base, "HEAD")
+	if err != nil {
+		delete(mergeBases, base)
+		return "", err
+	}
+	mergeBases[base] = cachedMergeBase{key: key, base: mergeBase}
+	return mergeBase, nil
+}
+
+// MergeBase returns the best common ancestor of two refs.
+func MergeBase(a, b string) (string, error) {
+	output, err := gitCommand("merge-base", a, b).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ForkPoint returns where branch forked from parent. It uses parent's
+// reflog, so a fork point is still found after parent was amended or
+// rebased, and falls back to the merge base.
+func ForkPoint(parent, branch string) (string, error) {
+	output, err := gitCommand("merge-base", "--fork-point", parent, branch).Output()
+	if err == nil {
+		return strings.TrimSpace(string(output)), nil
+	}
+	return MergeBase(parent, branch)
+}
+
+// LocalBranch is a local branch, its tip and when that was committed
+type LocalBranch struct {
+	Name string
+	Hash string
+	Time time.Time
+}
 
-	// Check if HEAD is the same ref as the default branch (handles detached HEAD too)
-	headRev, err := exec.Command("git", "rev-parse", "HEAD").Output()
+// GetMergedBranches returns the local branches whose commits are all in
+// into, other than into itself. Branches still at into's tip are left
+// out too, as they're more likely just created than done with.
+func GetMergedBranches(into string) map[string]bool {
+	tip, err := gitCommand("rev-parse", into+"^{commit}").Output()
 	if err != nil {
 		return nil
 	}
-	defaultRev, err := exec.Command("git", "rev-parse", defaultBranch).Output()
+	output, err := gitCommand("for-each-ref", "--merged="+into, "--format=%(refname:short) %(objectname)", "refs/heads").Output()
 	if err != nil {
 		return nil
 	}
-	if strings.TrimSpace(string(headRev)) == strings.TrimSpace(string(defaultRev)) {
+	merged := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		name, hash, ok := cutLast(line, " ")
+		if ok && name != into && hash != strings.TrimSpace(string(tip)) {
+			merged[name] = true
+		}
+	}
+	return merged
+}
+
+// GetLocalBranches returns all local branches.
+func GetLocalBranches() []LocalBranch {
+	output, err := gitCommand("for-each-ref", "--format=%(refname:short) %(objectname) %(committerdate:unix)", "refs/heads").Output()
+	if err != nil {
 		return nil
 	}
+	var branches []LocalBranch
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		rest, unix, ok := cutLast(line, " ")
+		if !ok {
+			continue
+		}
+		name, hash, ok := cutLast(rest, " ")
+		if !ok {
+			continue
+		}
+		var secs int64
+		fmt.Sscanf(unix, "%d", &secs)
+		branches = append(branches, LocalBranch{Name: name, Hash: hash, Time: time.Unix(secs, 0)})
+	}
+	return branches
+}
+
+// BranchCounts is how far a branch has diverged from a base
+type BranchCounts struct {
+	Ahead  int // commits on the branch but not the base
+	Behind int // commits on the base but not the branch
+}
+
+// GetBranchCounts returns how far each of branches has diverged from base.
+// git 2.41 and later count every branch in one pass; older versions take a
+// rev-list per branch.
+func GetBranchCounts(base string, branches []LocalBranch) map[string]BranchCounts {
+	counts := make(map[string]BranchCounts, len(branches))
+	if gitAtLeast(2, 41) {
+		output, err := gitCommand("for-each-ref", "--format=%(refname:short) %(ahead-behind:"+base+")", "refs/heads").Output()
+		if err == nil {
+			for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+				var c BranchCounts
+				fields := strings.Fields(line)
+				if len(fields) == 3 {
+					fmt.Sscanf(fields[1]+" "+fields[2], "%d %d", &c.Ahead, &c.Behind)
+					counts[fields[0]] = c
+				}
+			}
+			return counts
+		}
+	}
+	for _, b := range branches {
+		output, err := gitCommand("rev-list", "--left-right", "--count", base+"..."+b.Name).Output()
+		var c BranchCounts
+		if err == nil {
+			fmt.Sscanf(string(output), "%d %d", &c.Behind, &c.Ahead)
+			counts[b.Name] = c
+		}
+	}
+	return counts
+}
 
-	cmd := exec.Command("git", "merge-base", defaultBranch, "HEAD")
+// GetCommitGraph returns the parents of every commit reachable from tips
+// but not from base, keyed by commit hash.
+func GetCommitGraph(base string, tips []string) map[string][]string {
+	cmd := gitCommand("rev-list", "--parents", "--stdin")
+	cmd.Stdin = strings.NewReader(strings.Join(tips, "\n") + "\n^" + base + "\n")
 	output, err := cmd.Output()
 	if err != nil {
 		return nil
 	}
-	mergeBase := strings.TrimSpace(string(output))
+	parents := make(map[string][]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			parents[fields[0]] = fields[1:]
+		}
+	}
+	return parents
+}
 
-	cmd = exec.Command("git", "diff", "--name-status", mergeBase, "HEAD")
-	output, err = cmd.Output()
+// GetBranchReflogs returns every commit each of the named local branches
+// has pointed at, newest first. Branches without a reflog are left out.
+func GetBranchReflogs(names []string) map[string][]string {
+	args := []string{"log", "--walk-reflogs", "--format=%gD %H"}
+	for _, name := range names {
+		args = append(args, "refs/heads/"+name)
+	}
+	output, err := gitCommand(args...).Output()
 	if err != nil {
 		return nil
 	}
-
-	var files []BranchFile
+	reflogs := make(map[string][]string)
 	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
-		if line == "" {
+		entry, hash, ok := cutLast(line, " ")
+		if !ok {
 			continue
 		}
-		parts := strings.SplitN(line, "\t", 2)
-		if len(parts) != 2 {
-			continue
+		if ref, _, ok := cutLast(entry, "@{"); ok {
+			name := strings.TrimPrefix(ref, "refs/heads/")
+			reflogs[name] = append(reflogs[name], hash)
 		}
-		files = append(files, BranchFile{Status: parts[0], File: parts[1]})
 	}
-	return files
+	return reflogs
 }
 
-func statusLabel(staged, unstaged byte) string {
-	if staged == '?' && unstaged == '?' {
-		return "untracked"
+// CountCommits returns the number of commits reachable from to but not from.
+func CountCommits(from, to string) (int, error) {
+	output, err := gitCommand("rev-list", "--count", from+".."+to).Output()
+	if err != nil {
+		return 0, err
 	}
-	if staged == '!' && unstaged == '!' {
-		return "ignored"
+	var n int
+	_, err = fmt.Sscanf(string(output), "%d", &n)
+	return n, err
+}
+
+// Checkout switches to a local branch.
+func Checkout(branch string) error {
+	cmd := gitCommand("switch", "--quiet", branch)
+	if !gitAtLeast(2, 23) {
+		cmd = gitCommand("checkout", "--quiet", branch)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// UndoCommit removes the last commit, leaving its changes staged.
+func UndoCommit() error {
+	output, err := gitCommand("reset", "--quiet", "--soft", "HEAD~1").CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// CleanPreview returns the untracked files and directories git clean -fd
+// would remove, relative to the top of the working tree: everywhere, or
+// only within paths. Ignored files are left alone.
+func CleanPreview(paths ...string) ([]string, error) {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"-c", "core.quotePath=false", "--literal-pathspecs", "clean", "-nd", "--"}, paths...)
+	cmd := gitCommand(args...)
+	cmd.Dir = root
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, gitError(output, err)
+	}
+
+	var removals []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if path, ok := strings.CutPrefix(line, "Would remove "); ok {
+			removals = append(removals, path)
+		}
+	}
+	return removals, nil
+}
+
+// Clean removes the given untracked files and directories, as listed by
+// CleanPreview. Only those are removed, even if more have appeared since.
+func Clean(paths ...string) error {
+	if len(paths) == 0 {
+		return nil // without paths git clean would remove everything
+	}
+	root, err := GetRepoRoot()
+	if err != nil {
+		return err
+	}
+	args := append([]string{"--literal-pathspecs", "clean", "-fd", "--"}, paths...)
+	cmd := gitCommand(args...)
+	cmd.Dir = root
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// runGitAtRoot runs a git command from the top of the working tree with
+// literal pathspecs, for commands given paths relative to it.
+func runGitAtRoot(args ...string) error {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return err
+	}
+	cmd := gitCommand(append([]string{"--literal-pathspecs"}, args...)...)
+	cmd.Dir = root
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// Stage adds the given paths to the index, deletions included.
+func Stage(paths ...string) error {
+	return runGitAtRoot(append([]string{"add", "--all", "--"}, paths...)...)
+}
+
+// Unstage resets the given paths in the index to HEAD, keeping their
+// working tree changes.
+func Unstage(paths ...string) error {
+	return runGitAtRoot(append([]string{"reset", "--quiet", "--"}, paths...)...)
+}
+
+// Discard throws away uncommitted changes: restore paths go back to HEAD
+// in both the index and the working tree, and remove paths, which HEAD
+// doesn't have, are unstaged and deleted.
+func Discard(restore, remove []string) error {
+	if err := Unstage(append(slices.Clone(restore), remove...)...); err != nil {
+		return err
+	}
+	if len(restore) > 0 {
+		if err := runGitAtRoot(append([]string{"checkout", "--quiet", "--"}, restore...)...); err != nil {
+			return err
+		}
+	}
+	return Clean(remove...)
+}
+
+// StashPaths stashes the changes to the given paths, untracked files
+// included, leaving the rest of the working tree alone.
+func StashPaths(paths ...string) error {
+	return runGitAtRoot(append([]string{"stash", "push", "--include-untracked", "--quiet", "--"}, paths...)...)
+}
+
+// ReflogEntry is one movement of HEAD
+type ReflogEntry struct {
+	Selector string // e.g. HEAD@{2}
+	Hash     string // abbreviated
+	Subject  string // what moved HEAD, e.g. "checkout: moving from main to fix"
+	Time     time.Time
+}
+
+// GetReflog returns HEAD's most recent movements, newest first.
+func GetReflog(limit int) ([]ReflogEntry, error) {
+	// With --date=raw the selector carries the time, HEAD@{1712345678 +0200}
+	output, err := gitCommand("log", "--walk-reflogs", "--date=raw", fmt.Sprintf("-n%d", limit), "--format=%h%x00%gd%x00%gs", "HEAD").CombinedOutput()
+	if err != nil {
+		return nil, gitError(output, err)
+	}
+
+	var entries []ReflogEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Split(line, "\x00")
+		if len(parts) != 3 {
+			continue
+		}
+		var unix int64
+		fmt.Sscanf(parts[1], "HEAD@{%d}", &unix)
+		entries = append(entries, ReflogEntry{
+			Selector: fmt.Sprintf("HEAD@{%d}", len(entries)),
+			Hash:     parts[0],
+			Subject:  parts[2],
+			Time:     time.Unix(unix, 0),
+		})
+	}
+	return entries, nil
+}
+
+// CheckoutDetached checks out rev with a detached HEAD.
+func CheckoutDetached(rev string) error {
+	cmd := gitCommand("switch", "--quiet", "--detach", rev)
+	if !gitAtLeast(2, 23) {
+		cmd = gitCommand("checkout", "--quiet", "--detach", rev)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// ValidBranchName reports whether name can be used for a new branch.
+func ValidBranchName(name string) error {
+	if gitCommand("check-ref-format", "--branch", name).Run() != nil || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("%q isn't a valid branch name", name)
+	}
+	if RefExists("refs/heads/" + name) {
+		return fmt.Errorf("%s already exists", name)
+	}
+	return nil
+}
+
+// CreateBranch creates a branch at HEAD and switches to it.
+func CreateBranch(name string) error {
+	cmd := gitCommand("switch", "--quiet", "--create", name)
+	if !gitAtLeast(2, 23) {
+		cmd = gitCommand("checkout", "--quiet", "-b", name)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// RenameBranch renames a local branch, current or not.
+func RenameBranch(from, to string) error {
+	output, err := gitCommand("branch", "--move", from, to).CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// errUnmerged is returned by DeleteBranch for a branch whose commits
+// aren't all merged
+var errUnmerged = errors.New("not fully merged")
+
+// DeleteBranch deletes a local branch. Unless force is set, a branch with
+// commits that aren't in its upstream (or HEAD, without one) is kept and
+// errUnmerged returned.
+func DeleteBranch(name string, force bool) error {
+	flag := "-d"
+	if force {
+		flag = "-D"
+	}
+	output, err := gitCommand("branch", flag, name).CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(output), "not fully merged") {
+			return errUnmerged
+		}
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// ResetHard moves the current branch to rev, discarding uncommitted
+// changes to tracked files.
+func ResetHard(rev string) error {
+	output, err := gitCommand("reset", "--quiet", "--hard", rev).CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// RebaseState is a rebase stopped partway, at a conflict, a commit to
+// edit or a failed exec
+type RebaseState struct {
+	Branch string // the branch being rebased, empty if HEAD was detached
+	Step   int    // the commit it stopped at, counting from 1
+	Total  int
+}
+
+// GetRebaseState returns the rebase in progress, if any.
+func GetRebaseState() (RebaseState, bool) {
+	// Interactive and merge rebases keep their state in rebase-merge,
+	// apply-based ones in rebase-apply, with different file names
+	for _, layout := range [][3]string{{"rebase-merge", "msgnum", "end"}, {"rebase-apply", "next", "last"}} {
+		output, err := gitCommand("rev-parse", "--git-path", layout[0]).Output()
+		if err != nil {
+			return RebaseState{}, false
+		}
+		dir := strings.TrimSpace(string(output))
+		head, err := os.ReadFile(filepath.Join(dir, "head-name"))
+		if err != nil {
+			continue
+		}
+		var r RebaseState
+		r.Branch = strings.TrimPrefix(strings.TrimSpace(string(head)), "refs/heads/")
+		if r.Branch == "detached HEAD" {
+			r.Branch = ""
+		}
+		if step, err := os.ReadFile(filepath.Join(dir, layout[1])); err == nil {
+			fmt.Sscanf(string(step), "%d", &r.Step)
+		}
+		if total, err := os.ReadFile(filepath.Join(dir, layout[2])); err == nil {
+			fmt.Sscanf(string(total), "%d", &r.Total)
+		}
+		return r, true
+	}
+	return RebaseState{}, false
+}
+
+// Restack rebases the commits branch has since it forked from parent onto
+// parent's current tip, then returns to the branch that was checked out.
+// On conflicts the rebase is left in progress to be resolved.
+func Restack(branch, parent string) error {
+	forkPoint, err := ForkPoint(parent, branch)
+	if err != nil {
+		return fmt.Errorf("%s and %s have no common history", branch, parent)
+	}
+	prev, _ := currentBranch()
+	output, err := gitCommand("rebase", "--onto", parent, forkPoint, branch).CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	if prev != "" && prev != branch {
+		return Checkout(prev)
+	}
+	return nil
+}
+
+// DiffStat is the lines added and removed in one file
+type DiffStat struct {
+	Added   int
+	Removed int
+	Binary  bool
+}
+
+// diffSizes returns the number of lines added plus removed per file in
+// stats. Binary files count as zero.
+func diffSizes(stats map[string]DiffStat) map[string]int {
+	sizes := make(map[string]int, len(stats))
+	for path, s := range stats {
+		sizes[path] = s.Added + s.Removed
+	}
+	return sizes
+}
+
+// GetDiffStats returns the lines added and removed per file in git diff
+// with the given revisions, keyed by the file's new path.
+func GetDiffStats(revs ...string) map[string]DiffStat {
+	return GetDiffStatsContext(context.Background(), revs...)
+}
+
+// GetDiffStatsContext is GetDiffStats, killing git if ctx is canceled.
+func GetDiffStatsContext(ctx context.Context, revs ...string) map[string]DiffStat {
+	args := append([]string{"diff", "--numstat", "-z"}, revs...)
+	output, err := gitCommandContext(ctx, args...).Output()
+	if err != nil {
+		return nil
+	}
+
+	stats := make(map[string]DiffStat)
+	for _, s := range porcelain.ParseNumstat(output) {
+		stats[s.Path] = DiffStat{Added: s.Added, Removed: s.Removed, Binary: s.Binary}
+	}
+	return stats
+}
+
+// whitespaceOnlyFiles returns the files whose changes are all whitespace:
+// they differ in stats, but not in ignoring, the same diff with
+// --ignore-all-space. Files are keyed by their new path.
+func whitespaceOnlyFiles(stats, ignoring map[string]DiffStat) map[string]bool {
+	if len(stats) == 0 || ignoring == nil {
+		return nil
+	}
+	only := make(map[string]bool)
+	for path, s := range stats {
+		if s.Binary || s.Added+s.Removed == 0 {
+			continue // binary, or only the mode changed
+		}
+		if w := ignoring[path]; w.Added+w.Removed == 0 {
+			only[path] = true
+		}
+	}
+	return only
+}
+
+// GetBlobSizes returns the size in bytes of files as of rev, keyed by
+// path. Files rev doesn't have are left out.
+func GetBlobSizes(ctx context.Context, rev string, paths []string) map[string]int64 {
+	if len(paths) == 0 {
+		return nil
+	}
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil
+	}
+	// From the root, as paths are relative to it
+	cmd := gitCommandContext(ctx, append([]string{"ls-tree", "-l", "-z", rev, "--"}, paths...)...)
+	cmd.Dir = root
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	// Each entry is "<mode> <type> <object> <size>\t<path>", with the size
+	// padded and "-" for submodules
+	sizes := make(map[string]int64)
+	for _, entry := range strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00") {
+		info, path, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 4 {
+			continue
+		}
+		if n, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+			sizes[path] = n
+		}
+	}
+	return sizes
+}
+
+// GetRepoRoot returns the top-level directory of the working tree.
+func GetRepoRoot() (string, error) {
+	output, err := gitCommand("rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", err
+	}
+	// git writes C:/repo on Windows
+	return filepath.FromSlash(strings.TrimSpace(string(output))), nil
+}
+
+// GetDiffFiles returns the files that differ between two commits.
+func GetDiffFiles(from, to string) []BranchFile {
+	return diffFiles(context.Background(), from, to)
+}
+
+func diffFiles(ctx context.Context, from, to string) []BranchFile {
+	output, err := gitCommandContext(ctx, "diff", "--name-status", "-z", from, to).Output()
+	if err != nil {
+		return nil
+	}
+
+	// Renames and copies are given as "old<tab>new"
+	var files []BranchFile
+	for _, f := range porcelain.ParseNameStatus(output) {
+		file := f.Path
+		if f.OrigPath != "" {
+			file = f.OrigPath + "\t" + f.Path
+		}
+		files = append(files, BranchFile{Status: f.Status, File: file})
+	}
+	return files
+}
+
+// GetFileDiff returns the diff of one file between two commits, as lines.
+// Renames are given as "old<tab>new", as GetDiffFiles reports them.
+func GetFileDiff(from, to, file string) ([]string, error) {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"diff", "--no-color", from, to, "--"}, strings.Split(file, "\t")...)
+	cmd := gitCommand(args...)
+	cmd.Dir = root // the paths are relative to it
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, gitError(output, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"), nil
+}
+
+// emptyTree is the hash of git's empty tree, to diff against before the
+// first commit
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// GetWorkingDiff returns the uncommitted changes to paths, staged and
+// unstaged together, as lines. Paths are relative to the repository root;
+// an untracked file's diff adds its whole contents.
+func GetWorkingDiff(paths []string, untracked bool) ([]string, error) {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"diff", "--no-color"}
+	if untracked {
+		args = append(args, "--no-index", "--", os.DevNull, paths[0])
+	} else {
+		from := "HEAD"
+		if !RefExists("HEAD") {
+			from = emptyTree
+		}
+		args = append(append(args, from, "--"), paths...)
+	}
+	cmd := gitCommand(args...)
+	cmd.Dir = root
+	output, err := cmd.CombinedOutput()
+	// --no-index exits 1 when the files differ, which they always do
+	var exitErr *exec.ExitError
+	if err != nil && !(untracked && errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		return nil, gitError(output, err)
+	}
+	if len(output) == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"), nil
+}
+
+// GetBlame returns git blame output for file, relative to the repository
+// root, as lines. Uncommitted lines are attributed to "Not Committed Yet".
+func GetBlame(file string) ([]string, error) {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	cmd := gitCommand("blame", "--date=short", "--", file)
+	cmd.Dir = root
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, gitError(output, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"), nil
+}
+
+// FirstChangedLine returns the first line of file that differs from rev,
+// or 1 if nothing does (or the file is untracked).
+func FirstChangedLine(rev, file string) int {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return 1
+	}
+	cmd := gitCommand("diff", "--no-color", "--unified=0", rev, "--", file)
+	cmd.Dir = root // file is relative to it
+	output, err := cmd.Output()
+	if err != nil {
+		return 1
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "@@") {
+			return hunkStart(line)
+		}
+	}
+	return 1
+}
+
+// GetLastAuthors returns the author of the latest commit up to rev that
+// touched each of files, which are relative to the repository root. Files
+// with no history are left out. It reads one git log for all the files and
+// stops it once every file is accounted for.
+func GetLastAuthors(ctx context.Context, rev string, files []string) map[string]string {
+	authors := make(map[string]string)
+	root, err := GetRepoRoot()
+	if err != nil {
+		return authors
+	}
+	for len(files) > 0 {
+		batch := files[:min(len(files), 200)] // keep the command line short
+		files = files[len(batch):]
+
+		wanted := make(map[string]bool)
+		for _, f := range batch {
+			wanted[f] = true
+		}
+		args := append([]string{"-c", "core.quotePath=false", "log", "--format=%x00%an", "--name-only", "--no-renames", rev, "--"}, batch...)
+		cmd := gitCommandContext(ctx, args...)
+		cmd.Dir = root
+		stdout, err := cmd.StdoutPipe()
+		if err != nil || cmd.Start() != nil {
+			return authors
+		}
+		scanner := bufio.NewScanner(stdout)
+		author := ""
+		for len(wanted) > 0 && scanner.Scan() {
+			line := scanner.Text()
+			if name, ok := strings.CutPrefix(line, "\x00"); ok {
+				author = name
+			} else if wanted[line] {
+				authors[line] = author
+				delete(wanted, line)
+			}
+		}
+		cmd.Process.Kill() // the rest of history isn't needed
+		cmd.Wait()
+	}
+	return authors
+}
+
+// Tag is a tag as listed in the tags pane
+type Tag struct {
+	Name      string
+	Annotated bool      // lightweight tags are just a ref, with no message or tagger
+	Hash      string    // the tagged commit, abbreviated
+	Subject   string    // the tag message's first line, or the commit's for a lightweight tag
+	Time      time.Time // when an annotated tag was made, or its commit for a lightweight one
+}
+
+// GetTags returns all tags, most recently created first.
+func GetTags() []Tag {
+	// %(*objectname) is the commit an annotated tag points to; it's empty
+	// for lightweight tags, which point at the commit directly
+	cmd := gitCommand("for-each-ref", "--sort=-creatordate",
+		"--format=%(refname:short)%00%(objecttype)%00%(objectname:short)%00%(*objectname:short)%00%(creatordate:unix)%00%(subject)", "refs/tags")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var tags []Tag
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Split(line, "\x00")
+		if len(parts) != 6 {
+			continue
+		}
+		t := Tag{Name: parts[0], Annotated: parts[1] == "tag", Hash: parts[2], Subject: parts[5]}
+		if parts[3] != "" {
+			t.Hash = parts[3]
+		}
+		var unix int64
+		fmt.Sscanf(parts[4], "%d", &unix)
+		t.Time = time.Unix(unix, 0)
+		tags = append(tags, t)
+	}
+	return tags
+}
+
+// ValidTagName reports whether name can be used for a new tag.
+func ValidTagName(name string) error {
+	if gitCommand("check-ref-format", "refs/tags/"+name).Run() != nil || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("%q isn't a valid tag name", name)
+	}
+	if RefExists("refs/tags/" + name) {
+		return fmt.Errorf("%s already exists", name)
+	}
+	return nil
+}
+
+// CreateTag creates an annotated tag at HEAD.
+func CreateTag(name, message string) error {
+	output, err := gitCommand("tag", "--annotate", "--message", message, name, "HEAD").CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// GetDiff returns the diff between two revisions.
+func GetDiff(from, to string) ([]string, error) {
+	output, err := gitCommand("diff", "--no-color", from, to).CombinedOutput()
+	if err != nil {
+		return nil, gitError(output, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"), nil
+}
+
+// GetCommitsBetween returns the commits reachable from to but not from,
+// newest first.
+func GetCommitsBetween(from, to string) []Commit {
+	return logCommits(from + ".." + to)
+}
+
+// GetCommitsToPick returns the commits on branch that HEAD doesn't have,
+// newest first. Merges, and commits whose changes HEAD already has (as
+// after an earlier cherry-pick), are left out.
+func GetCommitsToPick(branch string) []Commit {
+	return logCommits("--cherry-pick", "--right-only", "--no-merges", "HEAD..."+branch)
+}
+
+// logCommits runs git log with args, returning the commits it lists.
+func logCommits(args ...string) []Commit {
+	return logCommitsContext(context.Background(), args...)
+}
+
+func logCommitsContext(ctx context.Context, args ...string) []Commit {
+	args = append([]string{"log", "--format=%h%x00%s%x00%an%x00%ct"}, args...)
+	output, err := gitCommandContext(ctx, args...).Output()
+	if err != nil {
+		return nil
+	}
+
+	var commits []Commit
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Split(line, "\x00")
+		if len(parts) != 4 {
+			continue
+		}
+		var unix int64
+		fmt.Sscanf(parts[3], "%d", &unix)
+		commits = append(commits, Commit{
+			Hash:    parts[0],
+			Subject: parts[1],
+			Author:  parts[2],
+			Time:    time.Unix(unix, 0),
+		})
+	}
+	return commits
+}
+
+// CherryPick applies commits to HEAD in the order given. On conflicts the
+// cherry-pick is left in progress to be resolved.
+func CherryPick(hashes ...string) error {
+	args := append([]string{"cherry-pick"}, hashes...)
+	output, err := gitCommand(args...).CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// CherryPickInProgress reports whether a cherry-pick stopped partway.
+func CherryPickInProgress() bool {
+	return gitCommand("rev-parse", "--verify", "--quiet", "CHERRY_PICK_HEAD").Run() == nil
+}
+
+// FileCommit is a commit in a file's history, with the path the file had
+// there, which changes across renames
+type FileCommit struct {
+	Commit
+	Path string
+}
+
+// GetFileHistory returns the commits that changed file, relative to the
+// repository root, newest first and following renames.
+func GetFileHistory(file string) ([]FileCommit, error) {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	cmd := gitCommand("-c", "core.quotePath=false", "log", "--follow", "--format=%x00%h%x00%s%x00%an%x00%ct", "--name-only", "--", file)
+	cmd.Dir = root
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, gitError(output, err)
+	}
+
+	var commits []FileCommit
+	for _, line := range strings.Split(string(output), "\n") {
+		if fields, ok := strings.CutPrefix(line, "\x00"); ok {
+			parts := strings.Split(fields, "\x00")
+			if len(parts) != 4 {
+				continue
+			}
+			var unix int64
+			fmt.Sscanf(parts[3], "%d", &unix)
+			commits = append(commits, FileCommit{Commit: Commit{Hash: parts[0], Subject: parts[1], Author: parts[2], Time: time.Unix(unix, 0)}})
+		} else if line != "" && len(commits) > 0 {
+			commits[len(commits)-1].Path = line
+		}
+	}
+	return commits, nil
+}
+
+// GetCommitFileDiff returns the diff a commit made to the given paths,
+// relative to the repository root; pass a file's old and new path to see
+// a rename as one.
+func GetCommitFileDiff(hash string, paths ...string) ([]string, error) {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"show", "--no-color", "--format=", "--find-renames", hash, "--"}, paths...)
+	cmd := gitCommand(args...)
+	cmd.Dir = root
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, gitError(output, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"), nil
+}
+
+// GetCommit returns a commit's message and diff, as git show prints them.
+func GetCommit(hash string) ([]string, error) {
+	output, err := gitCommand("show", "--no-color", hash).CombinedOutput()
+	if err != nil {
+		return nil, gitError(output, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"), nil
+}
+
+// Worktree represents an entry from git worktree list
+type Worktree struct {
+	Path     string
+	Head     string
+	Branch   string // short branch name; empty when detached or bare
+	Bare     bool
+	Detached bool
+	Dirty    bool
+}
+
+// GetWorktrees returns all worktrees of the repository, with their dirty state.
+func GetWorktrees() []Worktree {
+	cmd := gitCommand("worktree", "list", "--porcelain")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var worktrees []Worktree
+	var wt *Worktree
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "worktree":
+			worktrees = append(worktrees, Worktree{Path: filepath.FromSlash(value)})
+			wt = &worktrees[len(worktrees)-1]
+		case "HEAD":
+			if wt != nil {
+				wt.Head = value
+			}
+		case "branch":
+			if wt != nil {
+				wt.Branch = strings.TrimPrefix(value, "refs/heads/")
+			}
+		case "bare":
+			if wt != nil {
+				wt.Bare = true
+			}
+		case "detached":
+			if wt != nil {
+				wt.Detached = true
+			}
+		}
+	}
+
+	for i := range worktrees {
+		if worktrees[i].Bare {
+			continue
+		}
+		out, err := gitCommand("-C", worktrees[i].Path, "status", "--porcelain").Output()
+		worktrees[i].Dirty = err == nil && len(strings.TrimSpace(string(out))) > 0
+	}
+	return worktrees
+}
+
+// gitError turns a failed git command's output into an error, preferring
+// git's own message over the exit status.
+func gitError(output []byte, err error) error {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		line = strings.TrimPrefix(line, "fatal: ")
+		line = strings.TrimPrefix(line, "error: ")
+		if line != "" && !strings.HasPrefix(line, "hint:") {
+			return errors.New(line)
+		}
+	}
+	return err
+}
+
+// runGitRemote runs a git command that talks to a remote. Credential
+// prompts are disabled since there's no terminal to answer them on.
+func runGitRemote(args ...string) error {
+	cmd := gitCommand(args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// HasUpstream reports whether the current branch tracks an upstream branch.
+func HasUpstream() bool {
+	return gitCommand("rev-parse", "--abbrev-ref", "@{upstream}").Run() == nil
+}
+
+// GetUpstream returns the name of the current branch's upstream, e.g.
+// origin/main. An upstream that's gone is named, with errUpstreamGone.
+func GetUpstream() (string, error) {
+	output, err := gitCommand("rev-parse", "--abbrev-ref", "@{upstream}").Output()
+	if err != nil {
+		if upstream, gone := GoneUpstream(); gone {
+			return upstream, errUpstreamGone
+		}
+		return "", fmt.Errorf("no upstream")
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetRemoteURL returns the URL of a remote, with url.<base>.insteadOf
+// rewrites applied.
+func GetRemoteURL(remote string) (string, error) {
+	output, err := gitCommand("ls-remote", "--get-url", remote).CombinedOutput()
+	if err != nil {
+		return "", gitError(output, err)
+	}
+	url := strings.TrimSpace(string(output))
+	if url == remote {
+		// ls-remote echoes names it doesn't know
+		return "", fmt.Errorf("no %s remote", remote)
+	}
+	return url, nil
+}
+
+// GetPushRemote returns the remote a new branch should be pushed to:
+// remote.pushDefault if set, else origin, else the first remote.
+func GetPushRemote() (string, error) {
+	if output, err := gitCommand("config", "remote.pushDefault").Output(); err == nil {
+		if remote := strings.TrimSpace(string(output)); remote != "" {
+			return remote, nil
+		}
+	}
+	output, err := gitCommand("remote").Output()
+	if err != nil {
+		return "", err
+	}
+	remotes := strings.Fields(string(output))
+	if len(remotes) == 0 {
+		return "", errors.New("no remotes configured")
+	}
+	if slices.Contains(remotes, "origin") {
+		return "origin", nil
+	}
+	return remotes[0], nil
+}
+
+// Push pushes the current branch, setting its upstream if it has none
+// unless push.default is nothing. With force, it uses --force-with-lease.
+func Push(force bool) error {
+	args := []string{"push"}
+	if force {
+		args = append(args, "--force-with-lease")
+	}
+	if !HasUpstream() {
+		branch, err := currentBranch()
+		if err != nil || branch == "" {
+			return errors.New("not on a branch")
+		}
+		if GitConfig("push.default") == "nothing" {
+			// git wouldn't push without being told where to either
+			return errors.New("push.default is nothing; N picks where to push")
+		}
+		remote, err := GetPushRemote()
+		if err != nil {
+			return err
+		}
+		args = append(args, "--set-upstream", remote, branch)
+	}
+	return runGitRemote(args...)
+}
+
+// PushTo pushes branch to remote and sets it as the branch's upstream.
+func PushTo(remote, branch string) error {
+	return runGitRemote("push", "--set-upstream", remote, branch)
+}
+
+// GetRemotes returns the configured remotes' names.
+func GetRemotes() ([]string, error) {
+	output, err := gitCommand("remote").Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// GetRemoteBranches returns the remote-tracking branches, e.g.
+// origin/main, leaving out each remote's HEAD.
+func GetRemoteBranches() ([]string, error) {
+	output, err := gitCommand("for-each-ref", "--format=%(refname:short)", "refs/remotes").Output()
+	if err != nil {
+		return nil, err
+	}
+	var branches []string
+	for _, ref := range strings.Fields(string(output)) {
+		if !strings.HasSuffix(ref, "/HEAD") && strings.Contains(ref, "/") {
+			branches = append(branches, ref)
+		}
+	}
+	return branches, nil
+}
+
+// SetUpstream makes the current branch track upstream, e.g. origin/main.
+func SetUpstream(upstream string) error {
+	output, err := gitCommand("branch", "--set-upstream-to="+upstream).CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// UnsetUpstream stops the current branch tracking its upstream.
+func UnsetUpstream() error {
+	output, err := gitCommand("branch", "--unset-upstream").CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// Pull pulls the current branch's upstream. mode is "rebase", "merge", or
+// empty to follow git's pull.rebase setting.
+func Pull(mode string) error {
+	args := []string{"pull"}
+	switch mode {
+	case "rebase":
+		args = append(args, "--rebase")
+	case "merge":
+		args = append(args, "--no-rebase")
+	}
+	return runGitRemote(args...)
+}
+
+// Archive writes a snapshot of ref to path with git archive. The format
+// is inferred from the file extension.
+func Archive(ref, path string) error {
+	output, err := gitCommand("archive", "--output", path, ref).CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// CreateBundle writes refs, with their full history, to a bundle file at path.
+func CreateBundle(path string, refs []string) error {
+	args := append([]string{"bundle", "create", path}, refs...)
+	output, err := gitCommand(args...).CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// VerifyBundle checks that the bundle at path is valid and that this
+// repository has the commits it builds on, and returns the refs it holds.
+func VerifyBundle(path string) ([]string, error) {
+	output, err := gitCommand("bundle", "verify", "--quiet", path).CombinedOutput()
+	if err != nil {
+		return nil, gitError(output, err)
+	}
+	output, err = gitCommand("bundle", "list-heads", path).Output()
+	if err != nil {
+		return nil, err
+	}
+	var refs []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if _, ref, ok := strings.Cut(line, " "); ok {
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// ImportBundle fetches the branches in the bundle at path into
+// refs/remotes/bundle/, so they can be inspected before merging, along
+// with any tags it holds.
+func ImportBundle(path string) error {
+	output, err := gitCommand("fetch", path, "+refs/heads/*:refs/remotes/bundle/*", "refs/tags/*:refs/tags/*").CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// FormatPatch writes the commits after from as a numbered patch series
+// with a cover letter template into dir.
+func FormatPatch(from, dir string) error {
+	output, err := gitCommand("format-patch", "--numbered", "--cover-letter", "--output-directory", dir, from+"..HEAD").CombinedOutput()
+	if err != nil {
+		return gitError(output, err)
+	}
+	return nil
+}
+
+// GetPatch returns a diff git apply can take, binary files included:
+// uncommitted changes against HEAD when to is empty, otherwise from..to.
+// Untracked files aren't part of it.
+func GetPatch(from, to string) ([]byte, error) {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	if to == "" && from == "HEAD" && !RefExists("HEAD") {
+		from = emptyTree
+	}
+	args := []string{"diff", "--no-color", "--no-ext-diff", "--binary", from}
+	if to != "" {
+		args = append(args, to)
+	}
+	cmd := gitCommand(args...)
+	cmd.Dir = root
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, gitError(exitErr.Stderr, err)
+	} else if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// ApplyPatch applies the patch file at path to the working tree and index,
+// falling back to a three-way merge where it doesn't apply cleanly, which
+// leaves conflicts to resolve. It returns git's output either way.
+func ApplyPatch(path string) ([]string, error) {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	cmd := gitCommand("apply", "--3way", "--verbose", path)
+	cmd.Dir = root
+	output, err := cmd.CombinedOutput()
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	if err != nil {
+		return lines, gitError(output, err)
+	}
+	return lines, nil
+}
+
+func statusLabel(staged, unstaged byte) string {
+	if staged == '?' && unstaged == '?' {
+		return "untracked"
+	}
+	if staged == '!' && unstaged == '!' {
+		return "ignored"
+	}
+	if (FileChange{Staged: staged, Unstaged: unstaged}).IsConflict() {
+		return "conflict"
 	}
 
 	parts := []string{}
@@ -199,6 +1910,8 @@ func statusLabel(staged, unstaged byte) string {
 		parts = append(parts, "renamed (staged)")
 	case 'C':
 		parts = append(parts, "copied (staged)")
+	case 'T':
+		parts = append(parts, "type changed (staged)")
 	}
 
 	switch unstaged {
@@ -206,6 +1919,8 @@ func statusLabel(staged, unstaged byte) string {
 		parts = append(parts, "modified")
 	case 'D':
 		parts = append(parts, "deleted")
+	case 'T':
+		parts = append(parts, "type changed")
 	}
 
 	if len(parts) == 0 {
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"vigil/internal/fixture"
+)
+
+// statusCodes maps each file git status reported to its two status
+// letters.
+func statusCodes(changes []FileChange) map[string]string {
+	codes := make(map[string]string, len(changes))
+	for _, c := range changes {
+		codes[c.File] = string([]byte{c.Staged, c.Unstaged})
+	}
+	return codes
+}
+
+func TestGetGitStatus(t *testing.T) {
+	repo, err := fixture.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+
+	changes, summary := GetGitStatus(StatusOptions{Untracked: true, Ignored: true})
+	want := map[string]string{
+		fixture.Modified:       " M",
+		fixture.Staged:         "M ",
+		fixture.StagedModified: "MM",
+		fixture.Added:          "A ",
+		fixture.AddedModified:  "AM",
+		fixture.Deleted:        " D",
+		fixture.StagedDeleted:  "D ",
+		fixture.RenamedFrom + " -> " + fixture.RenamedTo: "R ",
+		fixture.TypeChanged:   " T",
+		fixture.Untracked:     "??",
+		"build/":              "!!", // fixture.Ignored's directory, wholly ignored
+		fixture.BothModified:  "UU",
+		fixture.BothAdded:     "AA",
+		fixture.DeletedByUs:   "DU",
+		fixture.DeletedByThem: "UD",
+	}
+	got := statusCodes(changes)
+	for file, code := range want {
+		if got[file] != code {
+			t.Errorf("%s: status %q, want %q", file, got[file], code)
+		}
+	}
+	for file, code := range got {
+		if _, ok := want[file]; !ok {
+			t.Errorf("unexpected %q %s", code, file)
+		}
+	}
+
+	wantSummary := StatusSummary{Staged: 6, Modified: 5, Untracked: 1, Stashes: 1, Conflicts: 4, Ignored: 1}
+	if summary != wantSummary {
+		t.Errorf("summary %+v, want %+v", summary, wantSummary)
+	}
+	for _, c := range changes {
+		if c.IsConflict() != (c.Label == "conflict") {
+			t.Errorf("%s: labeled %q, conflict %v", c.File, c.Label, c.IsConflict())
+		}
+	}
+
+	// Untracked and ignored files are only listed when asked for
+	changes, summary = GetGitStatus(StatusOptions{})
+	got = statusCodes(changes)
+	if _, ok := got[fixture.Untracked]; ok || summary.Untracked != 0 {
+		t.Error("untracked files listed without being asked for")
+	}
+	if _, ok := got["build/"]; ok || summary.Ignored != 0 {
+		t.Error("ignored files listed without being asked for")
+	}
+	if got[fixture.Modified] != " M" {
+		t.Errorf("%s: status %q without untracked files, want \" M\"", fixture.Modified, got[fixture.Modified])
+	}
+
+	if branch := GetCurrentBranch(); branch != fixture.Branch {
+		t.Errorf("branch %q, want %q", branch, fixture.Branch)
+	}
+	ahead, behind, err := GetCommitsAheadBehind()
+	if err != nil || ahead != 1 || behind != 1 {
+		t.Errorf("ahead %d, behind %d, err %v; want 1 each", ahead, behind, err)
+	}
+}
+
+func TestGetGitStatusDetached(t *testing.T) {
+	repo, err := fixture.Detached(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+
+	changes, _ := GetGitStatus(StatusOptions{Untracked: true})
+	if got := statusCodes(changes); len(got) != 1 || got[fixture.Modified] != " M" {
+		t.Errorf("status %v, want only %s modified", got, fixture.Modified)
+	}
+	commit, ok := GetLastCommit()
+	if !ok {
+		t.Fatal("no last commit while detached")
+	}
+	if want := "(detached) " + commit.Hash; GetCurrentBranch() != want {
+		t.Errorf("branch %q, want %q", GetCurrentBranch(), want)
+	}
+	if commit.Subject != "First commit" {
+		t.Errorf("HEAD is %q, want the first commit", commit.Subject)
+	}
+}
+
+func TestGetGitStatusEmpty(t *testing.T) {
+	repo, err := fixture.Empty(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+
+	changes, summary := GetGitStatus(StatusOptions{Untracked: true})
+	got := statusCodes(changes)
+	if len(got) != 2 || got[fixture.Added] != "A " || got[fixture.Untracked] != "??" {
+		t.Errorf("status %v, want %s added and %s untracked", got, fixture.Added, fixture.Untracked)
+	}
+	if summary != (StatusSummary{Staged: 1, Untracked: 1}) {
+		t.Errorf("summary %+v, want one staged and one untracked", summary)
+	}
+	if _, ok := GetLastCommit(); ok {
+		t.Error("a last commit in a repository with none")
+	}
+	if branch := GetCurrentBranch(); branch != fixture.Base {
+		t.Errorf("branch %q, want %q", branch, fixture.Base)
+	}
+}
+
+func TestGetGitStatusStashesBeforeHeader(t *testing.T) {
+	repo, err := fixture.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+
+	// Before 2.35 porcelain v2 has no stash header, so they're counted
+	// with git stash list
+	version := gitVersion
+	gitVersion = func() GitVersion { return GitVersion{2, 34, 0} }
+	t.Cleanup(func() { gitVersion = version })
+	if _, summary := GetGitStatus(StatusOptions{}); summary.Stashes != 1 {
+		t.Errorf("%d stashes, want 1", summary.Stashes)
+	}
+}
+
+func TestGetFileDiffFromSubdirectory(t *testing.T) {
+	repo, err := fixture.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(repo, filepath.Dir(fixture.BranchAdded)))
+
+	diff, err := GetFileDiff(fixture.Base, fixture.Branch, fixture.BranchModified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(diff, "+With a feature.") {
+		t.Errorf("diff of %s from a subdirectory:\n%s", fixture.BranchModified, strings.Join(diff, "\n"))
+	}
+}
+
+func TestFirstChangedLineFromSubdirectory(t *testing.T) {
+	repo, err := fixture.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(repo, filepath.Dir(fixture.BranchAdded)))
+
+	// The branch adds a blank line and a sentence after the heading
+	if line := FirstChangedLine(fixture.Base, fixture.BranchModified); line != 2 {
+		t.Errorf("first changed line of %s from a subdirectory is %d, want 2", fixture.BranchModified, line)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// pushDefaults are the values git accepts for push.default
+var pushDefaults = []string{"nothing", "current", "upstream", "tracking", "simple", "matching"}
+
+// GitConfig returns a git config value as git sees it in this repository,
+// or "" if it isn't set.
+func GitConfig(key string) string {
+	output, err := gitCommand("config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// statusOptions returns which untracked and ignored files to list. Unless
+// the config says, untracked files follow status.showUntrackedFiles: none
+// for "no", and a wholly untracked directory listed once for "normal".
+// With it unset they're listed individually, as with "all".
+func (c Config) statusOptions() StatusOptions {
+	opts := StatusOptions{Untracked: true, Ignored: c.Ignored}
+	if c.Untracked != nil {
+		opts.Untracked = *c.Untracked
+		return opts
+	}
+	switch strings.ToLower(GitConfig("status.showUntrackedFiles")) {
+	case "no", "false", "off", "0":
+		opts.Untracked = false
+	case "normal", "true", "yes", "on", "1":
+		opts.UntrackedDirs = true
+	}
+	return opts
+}
+
+// gitOverrides returns the git settings the config overrides, as key and
+// value pairs.
+func (c Config) gitOverrides() [][2]string {
+	var overrides [][2]string
+	if c.Renames != nil {
+		// status.renames falls back to diff.renames, but may be set on its own
+		v := strconv.FormatBool(*c.Renames)
+		overrides = append(overrides, [2]string{"diff.renames", v}, [2]string{"status.renames", v})
+	}
+	if c.Prune != nil {
+		overrides = append(overrides, [2]string{"fetch.prune", strconv.FormatBool(*c.Prune)})
+	}
+	if c.PushDefault != "" {
+		overrides = append(overrides, [2]string{"push.default", c.PushDefault})
+	}
+	if c.LargeRepo {
+		overrides = append(overrides, largeRepoOverrides()...)
+	}
+	return overrides
+}
+
+// applyGitOverrides makes every git command vigil runs see the config's
+// overrides, as though each were run with git -c, so views and actions
+// match git's behavior under those settings.
+func (c Config) applyGitOverrides() {
+	for _, kv := range c.gitOverrides() {
+		setGitConfigEnv(kv[0], kv[1])
+	}
+	if c.LargeRepo {
+		startLargeRepo()
+	}
+}
+
+// gitEnv is added to the environment of the git commands vigil runs, and
+// only theirs: custom commands, the watch command, editors and pagers see
+// git configured as you have it.
+var gitEnv []string
+
+// setGitConfigEnv adds a setting to the environment git reads config
+// from, after any already there.
+func setGitConfigEnv(key, value string) {
+	if !gitAtLeast(2, 31) {
+		// Older git only reads GIT_CONFIG_PARAMETERS, which is how git -c
+		// passes settings on to the commands it runs
+		params := strings.TrimSpace(gitEnvValue("GIT_CONFIG_PARAMETERS") + " '" + key + "=" + value + "'")
+		gitEnv = append(gitEnv, "GIT_CONFIG_PARAMETERS="+params)
+		return
+	}
+	n, _ := strconv.Atoi(gitEnvValue("GIT_CONFIG_COUNT"))
+	gitEnv = append(gitEnv,
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", n, key),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n, value),
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", n+1))
+}
+
+// gitEnvValue returns an environment variable as the git commands vigil
+// runs see it. Later entries win, as they do for exec.Cmd.
+func gitEnvValue(name string) string {
+	for _, kv := range slices.Backward(gitEnv) {
+		if value, ok := strings.CutPrefix(kv, name+"="); ok {
+			return value
+		}
+	}
+	return os.Getenv(name)
+}
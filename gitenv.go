@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// gitEnvPaths are the environment variables that point git at a
+// repository other than the one the current directory is in, as with
+// GIT_DIR=~/.dotfiles GIT_WORK_TREE=~ for a bare dotfiles repository.
+var gitEnvPaths = []string{"GIT_DIR", "GIT_WORK_TREE", "GIT_COMMON_DIR", "GIT_INDEX_FILE"}
+
+// absGitEnv makes relative paths in the git environment absolute, so they
+// keep naming the same repository after vigil changes directory for -C,
+// a bookmark or a worktree.
+func absGitEnv() {
+	for _, name := range gitEnvPaths {
+		path := os.Getenv(name)
+		if path == "" || filepath.IsAbs(path) {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			os.Setenv(name, abs)
+		}
+	}
+}
+
+// clearGitEnv unsets the git environment before switching to another
+// repository by directory, which it would otherwise override, returning a
+// func that puts it back if the switch fails.
+func clearGitEnv() (restore func()) {
+	saved := map[string]string{}
+	for _, name := range gitEnvPaths {
+		if path, ok := os.LookupEnv(name); ok {
+			saved[name] = path
+			os.Unsetenv(name)
+		}
+	}
+	return func() {
+		for name, path := range saved {
+			os.Setenv(name, path)
+		}
+	}
+}
+
+// gitDirs returns the absolute git dir and common dir of the current
+// repository, which are inside the working tree only for a plain .git
+// directory.
+func gitDirs() []string {
+	var dirs []string
+	if dir, err := GetGitDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	if dir, err := GetGitCommonDir(); err == nil && (len(dirs) == 0 || dir != dirs[0]) {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
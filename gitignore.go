@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ignoreSelected offers patterns to add to .gitignore for the selected
+// untracked file: the file itself, its extension, or its directory.
+func (m *model) ignoreSelected() {
+	row, ok := m.selectedRow()
+	if !ok || row.panel != panelChanges || row.file == "" {
+		return
+	}
+	change, found := m.change(row.file)
+	if !found || change.Staged != '?' {
+		m.notify("Only untracked files can be ignored")
+		return
+	}
+	if m.readOnlyBlocked("edit .gitignore") {
+		return
+	}
+
+	m.choice = &choice{
+		prompt:  "Add to .gitignore:",
+		options: ignoreOptions(change.File),
+		action: func(m *model, pattern string) tea.Cmd {
+			if err := AppendGitignore(pattern); err != nil {
+				m.notifyErr(err)
+				return nil
+			}
+			m.notify("Added " + pattern + " to .gitignore")
+			m.refresh()
+			return nil
+		},
+	}
+}
+
+// change returns the uncommitted change to file.
+func (m model) change(file string) (FileChange, bool) {
+	for _, c := range m.changes {
+		if c.File == file {
+			return c, true
+		}
+	}
+	return FileChange{}, false
+}
+
+// ignoreOptions returns the patterns that would ignore file, most specific
+// first: the file, every file with its extension, and its directory. file
+// is relative to the repository root.
+func ignoreOptions(file string) []string {
+	file = strings.TrimSuffix(file, "/") // an untracked directory
+	options := []string{"/" + escapeIgnore(file)}
+	if ext := path.Ext(file); ext != "" && ext != path.Base(file) {
+		options = append(options, "*"+escapeIgnore(ext))
+	}
+	if dir := path.Dir(file); dir != "." {
+		options = append(options, "/"+escapeIgnore(dir)+"/")
+	}
+	return options
+}
+
+// escapeIgnore escapes the characters gitignore would otherwise read as
+// patterns or comments.
+func escapeIgnore(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case strings.ContainsRune(`\*?[`, r),
+			i == 0 && (r == '#' || r == '!'):
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	// Trailing spaces are dropped unless escaped
+	s := b.String()
+	if trimmed := strings.TrimRight(s, " "); len(trimmed) < len(s) {
+		s = trimmed + strings.Repeat(`\ `, len(s)-len(trimmed))
+	}
+	return s
+}
+
+// AppendGitignore adds a pattern to the .gitignore at the top of the
+// working tree, creating it if needed.
+func AppendGitignore(pattern string) error {
+	root, err := GetRepoRoot()
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(root, ".gitignore")
+	existing, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	line := pattern + "\n"
+	if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+		line = "\n" + line
+	}
+	if _, err := f.WriteString(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
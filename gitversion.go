@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// GitVersion is the installed git's version
+type GitVersion struct {
+	Major, Minor, Patch int
+}
+
+func (v GitVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// known reports whether the version could be read at all
+func (v GitVersion) known() bool {
+	return v != GitVersion{}
+}
+
+// atLeast reports whether v is major.minor or newer. An unknown version is
+// assumed to be new.
+func (v GitVersion) atLeast(major, minor int) bool {
+	if !v.known() {
+		return true
+	}
+	return v.Major > major || v.Major == major && v.Minor >= minor
+}
+
+// GetGitVersion returns the installed git's version, or the zero version
+// if it can't be parsed.
+func GetGitVersion() GitVersion {
+	output, err := gitCommand("version").Output()
+	if err != nil {
+		return GitVersion{}
+	}
+	var v GitVersion
+	rest, ok := strings.CutPrefix(strings.TrimSpace(string(output)), "git version ")
+	if !ok {
+		return GitVersion{}
+	}
+	if n, _ := fmt.Sscanf(rest, "%d.%d.%d", &v.Major, &v.Minor, &v.Patch); n < 2 {
+		return GitVersion{}
+	}
+	return v
+}
+
+// gitVersion is read once, on first use
+var gitVersion = sync.OnceValue(GetGitVersion)
+
+// gitAtLeast reports whether the installed git is major.minor or newer.
+func gitAtLeast(major, minor int) bool {
+	return gitVersion().atLeast(major, minor)
+}
+
+// gitLimit is something vigil can't do, or does worse, on git older than
+// a version. Where vigil has an equivalent fallback (porcelain v1 status,
+// symbolic-ref for branch --show-current, checkout for switch) nothing
+// visible changes, so it isn't listed.
+type gitLimit struct {
+	major, minor int
+	what         string
+}
+
+var gitLimits = []gitLimit{
+	{2, 7, "the worktree list (w) is unavailable"},
+	{2, 13, "stashing selected files (z) is unavailable"},
+	{2, 16, "ignored directories are listed file by file rather than once"},
+}
+
+// GitLimitations returns what's disabled or degraded on the installed git,
+// empty on a recent one.
+func GitLimitations() []string {
+	var limits []string
+	for _, l := range gitLimits {
+		if !gitAtLeast(l.major, l.minor) {
+			limits = append(limits, fmt.Sprintf("%s (needs git %d.%d)", l.what, l.major, l.minor))
+		}
+	}
+	return limits
+}
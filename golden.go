@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// screenSize is a terminal size to render at
+type screenSize struct {
+	width, height int
+}
+
+func (s screenSize) String() string {
+	return fmt.Sprintf("%dx%d", s.width, s.height)
+}
+
+// parseSizes parses a comma-separated list of sizes such as "100x30,40x20".
+func parseSizes(list string) ([]screenSize, error) {
+	var sizes []screenSize
+	for _, s := range strings.Split(list, ",") {
+		var size screenSize
+		if _, err := fmt.Sscanf(s, "%dx%d", &size.width, &size.height); err != nil || size.width <= 0 || size.height <= 0 {
+			return nil, fmt.Errorf("bad size %q (want WIDTHxHEIGHT, e.g. 100x30)", s)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// screen is one rendered frame at one size
+type screen struct {
+	name string // golden file name, e.g. 100x30-003.txt
+	view string
+}
+
+// renderScreens renders every frame of m's replay at each size, with the
+// model's clock pinned to when the frame was recorded so relative times
+// don't drift.
+func renderScreens(m model, sizes []screenSize) []screen {
+	var screens []screen
+	for _, size := range sizes {
+		for i, f := range m.replay.frames {
+			if !f.Updated.IsZero() {
+				updated := f.Updated
+				m.now = func() time.Time { return updated }
+			}
+			m.replay.index = i
+			frame := m // each frame starts from a fresh model
+			frame.refresh()
+			var tm tea.Model = frame
+			tm, _ = tm.Update(tea.WindowSizeMsg{Width: size.width, Height: size.height})
+
+			lines := strings.Split(tm.View(), "\n")
+			for j, line := range lines {
+				lines[j] = strings.TrimRight(line, " ")
+			}
+			screens = append(screens, screen{
+				name: fmt.Sprintf("%s-%03d.txt", size, i+1),
+				view: strings.Join(lines, "\n") + "\n",
+			})
+		}
+	}
+	return screens
+}
+
+// checkGolden compares screens with the golden files in dir, or with
+// update rewrites them. It reports every screen that differs.
+func checkGolden(dir string, screens []screen, update bool) error {
+	if update {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		for _, s := range screens {
+			if err := os.WriteFile(filepath.Join(dir, s.name), []byte(s.view), 0o644); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var failures []string
+	for _, s := range screens {
+		want, err := os.ReadFile(filepath.Join(dir, s.name))
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+		if line, ok := firstDifference(string(want), s.view); ok {
+			failures = append(failures, fmt.Sprintf("%s: line %d differs:\n  want %q\n  got  %q", s.name, line.number, line.want, line.got))
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "\n"))
+	}
+	return nil
+}
+
+type lineDiff struct {
+	number    int
+	want, got string
+}
+
+// firstDifference returns the first line where want and got differ.
+func firstDifference(want, got string) (lineDiff, bool) {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := range max(len(wantLines), len(gotLines)) {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return lineDiff{number: i + 1, want: w, got: g}, true
+		}
+	}
+	return lineDiff{}, false
+}
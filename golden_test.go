@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+
+	"vigil/internal/fixture"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenDir is testdata, found before any test changes directory
+var goldenDir, _ = filepath.Abs("testdata")
+
+// demoStart is when the demo session is made to start, so its relative
+// times render the same on every run
+var demoStart = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// demoModel returns a model replaying the built-in demo, in an empty
+// repository so nothing of the checkout running the tests shows through.
+func demoModel(t *testing.T) model {
+	t.Helper()
+	repo, err := fixture.Empty(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.Ascii)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+
+	m := initialModel(Config{Layout: "monitor", Syntax: true}, RepoState{})
+	m.setTitle = false
+	m.notifyMode = notifyOff
+	m.now = func() time.Time { return demoStart }
+	m.replay = &replayer{frames: demoFrames(demoStart), speed: 1}
+	return m
+}
+
+// checkScreens compares screens with testdata/<prefix>-<name>.golden, or
+// with -update rewrites them.
+func checkScreens(t *testing.T, prefix string, screens []screen) {
+	t.Helper()
+	for i := range screens {
+		screens[i].name = prefix + "-" + strings.TrimSuffix(screens[i].name, ".txt") + ".golden"
+	}
+	if err := checkGolden(goldenDir, screens, *update); err != nil {
+		t.Errorf("%v\n(run go test -update to accept the new screens)", err)
+	}
+}
+
+func TestGoldenDemo(t *testing.T) {
+	m := demoModel(t)
+	checkScreens(t, "demo", renderScreens(m, []screenSize{{100, 30}, {60, 20}}))
+}
+
+// TestGoldenKeys drives the model with keys, as a user would, and
+// compares the screens it ends on.
+func TestGoldenKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+	}{
+		{"help", "?"},
+		{"layout", "l"},
+		{"select", "jj"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := demoModel(t)
+			m.replay.index = 6 // mid-merge, with a conflict
+			m.refresh()
+			var tm tea.Model = m
+			tm, _ = tm.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+			for _, r := range tt.keys {
+				tm, _ = tm.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+			}
+			lines := strings.Split(tm.View(), "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimRight(line, " ")
+			}
+			checkScreens(t, "keys", []screen{{name: tt.name + ".txt", view: strings.Join(lines, "\n") + "\n"}})
+		})
+	}
+}
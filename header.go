@@ -0,0 +1,457 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// header is the block above the viewport: the layout's segments and,
+// toggled with the diagnostics key, the diagnostics overlay. The model
+// hands it what it shows with fillHeader before each frame; the header
+// owns how that's laid out and whether diagnostics are shown.
+type header struct {
+	diagnostics bool
+
+	layout Layout
+	width  int
+	now    time.Time
+
+	dir         string
+	branch      string
+	tool        vcsTool
+	readOnly    bool
+	rebasing    bool
+	rebase      RebaseState
+	picking     bool
+	upstream    string
+	upstreamErr error
+	ahead       int
+	behind      int
+	remotes     []RemoteDivergence
+	fetching    bool
+	spinner     string
+	fetchErr    error
+	syncStatus  string
+	lastFetched time.Time
+	summary     StatusSummary
+	release     Release
+	hasRelease  bool
+	lastCommit  Commit
+	hasCommit   bool
+
+	// Lines other features render, empty when there's nothing to show
+	secrets string
+	watch   string
+	pr      string
+	ci      string
+	owners  string
+	debug   string
+	overlay string // diagnostics
+}
+
+// fillHeader returns the header with what it shows taken from the model.
+func (m model) fillHeader() header {
+	h := m.header
+	h.layout, h.width, h.now = m.layout, m.width, m.now()
+	h.dir, h.branch, h.tool = m.dir, m.branch, m.tool
+	h.readOnly, h.rebasing, h.rebase, h.picking = m.readOnly, m.rebasing, m.rebase, m.picking
+	h.upstream, h.upstreamErr, h.ahead, h.behind, h.remotes = m.upstream, m.upstreamErr, m.ahead, m.behind, m.remotes
+	h.fetching, h.spinner = m.jobs.fetching, m.jobs.spin()
+	h.fetchErr, h.syncStatus, h.lastFetched = m.fetchErr, m.syncStatus(), m.lastFetched
+	h.summary = m.summary
+	h.release, h.hasRelease = m.release, m.hasRelease
+	h.lastCommit, h.hasCommit = m.lastCommit, m.hasCommit
+
+	h.secrets, h.watch, h.pr, h.ci, h.owners, h.debug, h.overlay = "", "", "", "", "", "", ""
+	if len(m.secrets) > 0 {
+		h.secrets = m.renderSecretsLine()
+	}
+	if m.watch.cmd != "" {
+		h.watch = m.renderWatchLine()
+	}
+	if m.hasPR {
+		h.pr = m.renderPRLine()
+		if len(m.pr.Checks) > 0 {
+			h.ci = m.renderCILine()
+		}
+	}
+	if m.owners != nil && len(m.branchFiles) > 0 {
+		h.owners = m.renderOwnersLine()
+	}
+	if m.layout.hasSegment(segmentDebug) {
+		h.debug = m.renderDebugLine()
+	}
+	if h.diagnostics {
+		h.overlay = m.renderDiagnostics()
+	}
+	return h
+}
+
+// Update toggles the diagnostics overlay on its key.
+func (h header) Update(msg tea.Msg) (header, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok && key.String() == diagnosticsKey {
+		h.diagnostics = !h.diagnostics
+		return h, tea.ClearScreen
+	}
+	return h, nil
+}
+
+// View renders the segments, then the diagnostics overlay when it's on.
+func (h header) View() string {
+	if h.diagnostics {
+		return h.renderSegments() + h.overlay
+	}
+	return h.renderSegments()
+}
+
+// narrow reports whether the condensed layout should be used.
+func (h header) narrow() bool {
+	return h.width > 0 && h.width < narrowWidth
+}
+
+func (h header) timeAgo(t time.Time) string {
+	return timeAgo(h.now, t)
+}
+
+// renderSegments renders the layout's header segments above the viewport.
+func (h header) renderSegments() string {
+	if h.narrow() {
+		return h.renderNarrowHeader()
+	}
+
+	var header strings.Builder
+	grouped := false // in a run of blockSegments
+	for _, segment := range h.layout.Header {
+		if grouped && !slices.Contains(blockSegments, segment) {
+			header.WriteString("\n")
+			grouped = false
+		}
+		switch segment {
+		case segmentBanner:
+			header.WriteString(asciiStyle.Render(glyphs.Art))
+			header.WriteString("\n")
+		case segmentPath:
+			header.WriteString(pathStyle.Render(h.dir))
+			header.WriteString("\n\n")
+		case segmentBranch:
+			header.WriteString(h.renderBranchLine())
+			header.WriteString("\n")
+			if h.secrets != "" {
+				header.WriteString(h.secrets)
+				header.WriteString("\n")
+			}
+			grouped = true
+		case segmentRelease:
+			if h.hasRelease {
+				header.WriteString(h.renderReleaseLine())
+				header.WriteString("\n")
+				grouped = true
+			}
+		case segmentCommit:
+			if h.hasCommit {
+				header.WriteString(h.renderCommitLine())
+				header.WriteString("\n")
+				grouped = true
+			}
+		case segmentWatch:
+			if h.watch != "" {
+				header.WriteString(h.watch)
+				header.WriteString("\n")
+				grouped = true
+			}
+		case segmentUpstream:
+			header.WriteString("Upstream: " + h.renderUpstream())
+			header.WriteString("\n")
+			grouped = true
+		case segmentStashes:
+			if h.summary.Stashes > 0 {
+				header.WriteString("Stashes: " + helpStyle.Render(fmt.Sprint(h.summary.Stashes)))
+				header.WriteString("\n")
+				grouped = true
+			}
+		case segmentPR:
+			if h.pr != "" {
+				header.WriteString(h.pr)
+				header.WriteString("\n")
+				grouped = true
+			}
+		case segmentCI:
+			if h.ci != "" {
+				header.WriteString(h.ci)
+				header.WriteString("\n")
+				grouped = true
+			}
+		case segmentOwners:
+			if h.owners != "" {
+				header.WriteString(h.owners)
+				header.WriteString("\n")
+				grouped = true
+			}
+		case segmentDebug:
+			header.WriteString(h.debug)
+			header.WriteString("\n")
+			grouped = true
+		case segmentAge:
+			if h.hasCommit {
+				header.WriteString("Last commit: " + helpStyle.Render(h.timeAgo(h.lastCommit.Time)))
+				header.WriteString("\n")
+				grouped = true
+			}
+		}
+	}
+	if h.secrets != "" && !h.layout.hasSegment(segmentBranch) {
+		// Too important to leave to the layout
+		header.WriteString(h.secrets + "\n")
+		grouped = true
+	}
+	if grouped {
+		header.WriteString("\n")
+	}
+	return header.String()
+}
+
+// renderReleaseLine renders git describe output and how far HEAD is from the tag.
+func (h header) renderReleaseLine() string {
+	r := h.release
+	distance := "at tag"
+	switch {
+	case r.Distance == 1:
+		distance = "1 commit since"
+	case r.Distance > 1:
+		distance = fmt.Sprintf("%d commits since", r.Distance)
+	}
+	if h.narrow() {
+		return tagStyle.Render(r.Tag) + helpStyle.Render(fmt.Sprintf(" +%d", r.Distance))
+	}
+	return "Release: " + tagStyle.Render(r.Describe) + helpStyle.Render(" ("+distance+" "+r.Tag+")")
+}
+
+// renderCommitLine renders HEAD's short SHA, subject, author and age.
+func (h header) renderCommitLine() string {
+	c := h.lastCommit
+	meta := fmt.Sprintf("%s, %s", c.Author, h.timeAgo(c.Time))
+	if h.narrow() {
+		meta = h.timeAgo(c.Time)
+	}
+	line := commitHashStyle.Render(c.Hash) + " " + c.Subject + " " + helpStyle.Render("("+meta+")")
+	if h.narrow() {
+		line = truncate(line, h.width)
+	}
+	if c.Note != "" {
+		note := noteStyle.Render(glyphs.Note + " " + strings.ReplaceAll(c.Note, "\n", " "))
+		if h.narrow() {
+			note = truncate(note, h.width)
+		}
+		line += "\n" + note
+	}
+	return line
+}
+
+// renderBranchLine renders the branch name followed by the upstream state
+// and working tree counts, unless those have segments of their own.
+func (h header) renderBranchLine() string {
+	var line strings.Builder
+	line.WriteString("Branch: ")
+	line.WriteString(branchStyle.Render(h.branch))
+	if h.tool != toolNone {
+		line.WriteString(" " + tagStyle.Render("["+string(h.tool)+"]"))
+	}
+	if h.readOnly {
+		line.WriteString(" " + statusModified.Render("[read-only filesystem]"))
+	}
+	if h.rebasing {
+		rebasing := "[rebasing]"
+		if h.rebase.Total > 0 {
+			rebasing = fmt.Sprintf("[rebasing %d/%d]", h.rebase.Step, h.rebase.Total)
+		}
+		line.WriteString(" " + statusModified.Render(rebasing))
+	}
+	if h.picking {
+		line.WriteString(" " + statusModified.Render("[cherry-picking]"))
+	}
+	if !h.layout.hasSegment(segmentUpstream) {
+		line.WriteString(" " + h.renderUpstream())
+	}
+	if summary := h.renderSummary(); summary != "" {
+		line.WriteString("  " + summary)
+	}
+	return line.String()
+}
+
+// renderUpstream renders ahead/behind, the same against other remotes'
+// main branches, and when the upstream was last fetched, e.g.
+// "origin/main (2 ahead) · upstream/main (5 behind) · fetched 3m ago".
+// The upstream's name is left out on the branch line.
+func (h header) renderUpstream() string {
+	var line strings.Builder
+	if h.layout.hasSegment(segmentUpstream) && h.upstream != "" && h.upstreamErr == nil {
+		line.WriteString(branchStyle.Render(h.upstream) + " ")
+	}
+	if errors.Is(h.upstreamErr, errUpstreamGone) {
+		line.WriteString(statusDeleted.Render("(" + h.upstream + " gone; N to clean up)"))
+	} else if h.upstreamErr != nil {
+		line.WriteString(helpStyle.Render("(no upstream; N to set)"))
+	} else {
+		line.WriteString(helpStyle.Render("(" + divergence(h.ahead, h.behind) + ")"))
+	}
+	for _, r := range h.remotes {
+		line.WriteString(helpStyle.Render(" "+glyphs.Dot+" ") + branchStyle.Render(r.Ref) + " " + helpStyle.Render("("+divergence(r.Ahead, r.Behind)+")"))
+	}
+	if h.fetching {
+		line.WriteString(" " + h.spinner)
+	} else if h.fetchErr != nil {
+		line.WriteString(helpStyle.Render(" "+glyphs.Dot+" ") + statusConflict.Render(h.syncStatus))
+	} else if !h.lastFetched.IsZero() {
+		line.WriteString(helpStyle.Render(" " + glyphs.Dot + " fetched " + h.timeAgo(h.lastFetched)))
+	}
+	return line.String()
+}
+
+// divergence describes ahead/behind counts, e.g. "2 behind, 1 ahead".
+func divergence(ahead, behind int) string {
+	if ahead == 0 && behind == 0 {
+		return "up to date"
+	}
+	var parts []string
+	if behind > 0 {
+		parts = append(parts, fmt.Sprintf("%d behind", behind))
+	}
+	if ahead > 0 {
+		parts = append(parts, fmt.Sprintf("%d ahead", ahead))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// renderSummary renders the non-zero working tree counts, e.g.
+// "staged 2 · modified 1 · stashes 1". Stashes are left out when they
+// have a segment of their own.
+func (h header) renderSummary() string {
+	s := h.summary
+	counts := []struct {
+		n      int
+		label  string
+		symbol string // starship-style, for the narrow layout
+		style  lipgloss.Style
+	}{
+		{s.Staged, "staged", "+", statusAdded},
+		{s.Modified, "modified", "!", statusModified},
+		{s.Untracked, "untracked", "?", statusUntracked},
+		{s.Stashes, "stashes", "$", helpStyle},
+		{s.Conflicts, "conflicts", "=", statusConflict},
+		{s.Ignored, "ignored", "i", helpStyle},
+	}
+
+	var parts []string
+	for _, c := range counts {
+		if c.n == 0 || c.label == "stashes" && h.layout.hasSegment(segmentStashes) {
+			continue
+		}
+		if h.narrow() {
+			parts = append(parts, c.style.Render(fmt.Sprintf("%s%d", c.symbol, c.n)))
+		} else {
+			parts = append(parts, c.style.Render(fmt.Sprintf("%s %d", c.label, c.n)))
+		}
+	}
+	sep := helpStyle.Render(" " + glyphs.Dot + " ")
+	if h.narrow() {
+		sep = " "
+	}
+	return strings.Join(parts, sep)
+}
+
+// renderNarrowHeader drops the banner and full path, and abbreviates the
+// upstream state to +ahead/-behind.
+func (h header) renderNarrowHeader() string {
+	var header strings.Builder
+	if h.layout.hasSegment(segmentPath) {
+		header.WriteString(pathStyle.Render(filepath.Base(h.dir)))
+		header.WriteString("\n")
+	}
+	if h.layout.hasSegment(segmentBranch) {
+		header.WriteString(branchStyle.Render(h.branch))
+		if h.readOnly {
+			header.WriteString(" " + statusModified.Render("[ro]"))
+		}
+		if h.rebasing {
+			header.WriteString(" " + statusModified.Render("[rebasing]"))
+		}
+		if h.picking {
+			header.WriteString(" " + statusModified.Render("[picking]"))
+		}
+		if !h.layout.hasSegment(segmentUpstream) {
+			header.WriteString(h.renderNarrowUpstream())
+		}
+		header.WriteString("\n")
+		if h.secrets != "" {
+			header.WriteString(h.secrets + "\n")
+		}
+		if summary := h.renderSummary(); summary != "" {
+			header.WriteString(summary + "\n")
+		}
+	}
+	if h.layout.hasSegment(segmentUpstream) {
+		if h.upstream != "" && h.upstreamErr == nil {
+			header.WriteString(branchStyle.Render(h.upstream))
+		}
+		header.WriteString(h.renderNarrowUpstream() + "\n")
+	}
+	if h.layout.hasSegment(segmentStashes) && h.summary.Stashes > 0 {
+		header.WriteString(helpStyle.Render(fmt.Sprintf("$%d", h.summary.Stashes)) + "\n")
+	}
+	if h.layout.hasSegment(segmentPR) && h.pr != "" {
+		header.WriteString(h.pr + "\n")
+	}
+	if h.layout.hasSegment(segmentCI) && h.ci != "" {
+		header.WriteString(h.ci + "\n")
+	}
+	if h.layout.hasSegment(segmentAge) && h.hasCommit {
+		header.WriteString(helpStyle.Render(h.timeAgo(h.lastCommit.Time)) + "\n")
+	}
+	if h.layout.hasSegment(segmentRelease) && h.hasRelease {
+		header.WriteString(h.renderReleaseLine() + "\n")
+	}
+	if h.layout.hasSegment(segmentCommit) && h.hasCommit {
+		header.WriteString(h.renderCommitLine() + "\n")
+	}
+	if h.layout.hasSegment(segmentWatch) && h.watch != "" {
+		header.WriteString(h.watch + "\n")
+	}
+	if h.layout.hasSegment(segmentOwners) && h.owners != "" {
+		header.WriteString(h.owners + "\n")
+	}
+	if header.Len() > 0 {
+		header.WriteString("\n")
+	}
+	return header.String()
+}
+
+// renderNarrowUpstream abbreviates the upstream state to +ahead/-behind.
+func (h header) renderNarrowUpstream() string {
+	var s string
+	if h.upstreamErr == nil && (h.ahead > 0 || h.behind > 0) {
+		s = helpStyle.Render(fmt.Sprintf(" +%d -%d", h.ahead, h.behind))
+	}
+	if errors.Is(h.upstreamErr, errUpstreamGone) {
+		s = statusDeleted.Render(" gone")
+	}
+	for _, r := range h.remotes {
+		if r.Ahead > 0 || r.Behind > 0 {
+			s += helpStyle.Render(fmt.Sprintf(" %s +%d -%d", strings.SplitN(r.Ref, "/", 2)[0], r.Ahead, r.Behind))
+		}
+	}
+	if h.fetching {
+		s += " " + h.spinner
+	} else if errors.Is(h.fetchErr, errOffline) {
+		s += statusConflict.Render(" offline")
+	} else if h.fetchErr != nil {
+		s += statusConflict.Render(" " + glyphs.Warn)
+	}
+	return s
+}
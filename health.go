@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// healthProblem is a git command vigil depends on failing in a way that
+// would otherwise leave an empty, misleading screen
+type healthProblem struct {
+	command string // as typed at a shell, e.g. "git status --porcelain"
+	stderr  string
+	fixes   []string
+}
+
+// healthChecks are the commands checked before vigil trusts what git
+// reports: status reads the index, HEAD and the objects it points to.
+var healthChecks = [][]string{
+	{"status", "--porcelain"},
+}
+
+// CheckHealth runs the health checks, returning the first failure.
+func CheckHealth() (healthProblem, bool) {
+	for _, args := range healthChecks {
+		if p, failed := runHealthCheck(args...); failed {
+			return p, true
+		}
+	}
+	return healthProblem{}, false
+}
+
+// DiagnoseNotRepo explains why the current directory isn't seen as a
+// working tree, when it's something other than not being in a repository
+// at all: ownership git won't trust, or a .git directory git can't read.
+func DiagnoseNotRepo() (healthProblem, bool) {
+	p, failed := runHealthCheck("rev-parse", "--is-inside-work-tree")
+	if !failed || !strings.Contains(p.stderr, "not a git repository") {
+		return p, failed
+	}
+	gitDir, ok := findGitDir()
+	if !ok {
+		return healthProblem{}, false
+	}
+	if _, err := os.Stat(gitDir); err != nil {
+		cwd, _ := os.Getwd()
+		p.fixes = []string{
+			fmt.Sprintf("%s is missing; if the repository moved, point this worktree back at it: git -C <repository> worktree repair %s", gitDir, shellQuote(cwd)),
+		}
+		return p, true
+	}
+	p.fixes = []string{
+		fmt.Sprintf("%s exists but git doesn't recognize it; check that HEAD is there and names a branch: cat %s", gitDir, filepath.Join(gitDir, "HEAD")),
+		fmt.Sprintf("If HEAD is missing or garbled: echo 'ref: refs/heads/main' > %s", filepath.Join(gitDir, "HEAD")),
+	}
+	return p, true
+}
+
+// findGitDir looks for the git dir the way git does: $GIT_DIR if set,
+// otherwise a .git directory, or a .git file pointing at one as in a linked
+// worktree or submodule, in the current directory or above it.
+func findGitDir() (string, bool) {
+	if dir := os.Getenv("GIT_DIR"); dir != "" {
+		return dir, true
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	for {
+		gitDir := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
+			return gitDir, true
+		}
+		if data, err := os.ReadFile(gitDir); err == nil {
+			if target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: "); ok {
+				target = filepath.FromSlash(target)
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(dir, target)
+				}
+				return target, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// runHealthCheck runs one git command, describing its failure.
+func runHealthCheck(args ...string) (healthProblem, bool) {
+	cmd := gitCommand(args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		return healthProblem{}, false
+	}
+	p := healthProblem{command: "git " + strings.Join(args, " "), stderr: strings.TrimSpace(stderr.String())}
+	var exitErr *exec.ExitError
+	if p.stderr == "" && !errors.As(err, &exitErr) {
+		p.stderr = err.Error() // git itself couldn't be run
+	}
+	p.fixes = suggestFixes(p.stderr)
+	return p, true
+}
+
+// suggestFixes recognizes common causes of git failing from its error
+// output.
+func suggestFixes(stderr string) []string {
+	gitDir := ".git"
+	if output, err := gitCommand("rev-parse", "--git-dir").Output(); err == nil {
+		if dir, err := filepath.Abs(strings.TrimSpace(string(output))); err == nil {
+			gitDir = dir
+		}
+	}
+	index := filepath.Join(gitDir, "index")
+
+	var fixes []string
+	switch {
+	case strings.Contains(stderr, "dubious ownership"):
+		dir, _ := os.Getwd()
+		fixes = append(fixes,
+			"The repository belongs to another user. If you trust it: git config --global --add safe.directory "+dir,
+			"Or take ownership of it: sudo chown -R $(id -u) "+dir)
+	case strings.Contains(stderr, "index file"), strings.Contains(stderr, "bad signature"):
+		fixes = append(fixes,
+			"The index is corrupt. Rebuild it from HEAD (anything staged becomes unstaged): rm "+index+" && git reset")
+	case strings.Contains(stderr, "Permission denied"):
+		fixes = append(fixes,
+			"git can't read or write part of the repository. Check who owns it: ls -la "+gitDir,
+			"Then take ownership of it: sudo chown -R $(id -u) "+gitDir)
+	case strings.Contains(stderr, "bad object"), strings.Contains(stderr, "corrupt"), strings.Contains(stderr, "unable to read"):
+		fixes = append(fixes,
+			"Objects are missing or corrupt. Find out which: git fsck --full",
+			"If they can't be recovered, fetch them again from a remote or re-clone")
+	case strings.Contains(stderr, "executable file not found"):
+		fixes = append(fixes, "Install git, or add it to your PATH")
+	}
+	return append(fixes, "Run the command yourself to see everything git says")
+}
+
+// String renders the problem for printing before vigil's screen starts.
+func (p healthProblem) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "Error: %s failed\n", p.command)
+	for _, line := range strings.Split(p.stderr, "\n") {
+		s.WriteString("  " + line + "\n")
+	}
+	s.WriteString("\nSuggested fixes:\n")
+	for _, fix := range p.fixes {
+		s.WriteString("  - " + fix + "\n")
+	}
+	return s.String()
+}
+
+// showHealth switches to the health screen for a failed check.
+func (m *model) showHealth(p healthProblem) {
+	m.health = p
+	m.view = viewHealth
+	m.viewport.GotoTop()
+	m.resize()
+}
+
+// recheckHealth reruns the health checks, going back to the file list
+// once they pass, as after fixing things in another terminal.
+func (m *model) recheckHealth() {
+	if p, failed := CheckHealth(); failed {
+		m.health = p
+		return
+	}
+	m.view = viewFiles
+	m.refresh()
+	m.notify("git is working again")
+}
+
+// updateHealth handles key input on the health screen.
+func (m model) updateHealth(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c", "esc":
+		return m, tea.Quit
+	case "r":
+		m.recheckHealth()
+	}
+	m.resize()
+	return m, tea.ClearScreen
+}
+
+func (m model) renderHealth() string {
+	var body strings.Builder
+	body.WriteString(errorStyle.Render("vigil can't read this repository") + "\n\n")
+	body.WriteString(helpStyle.Render("$ ") + m.health.command + "\n")
+	for _, line := range strings.Split(m.health.stderr, "\n") {
+		body.WriteString(errorStyle.Render(line) + "\n")
+	}
+	body.WriteString("\nSuggested fixes:\n")
+	// Fixes are long and hold commands to copy, so they wrap rather than
+	// being cut off
+	wrap := lipgloss.NewStyle().Width(max(m.width-4, 20))
+	for _, fix := range m.health.fixes {
+		for i, line := range strings.Split(wrap.Render(fix), "\n") {
+			prefix := "    "
+			if i == 0 {
+				prefix = "  " + glyphs.Dot + " "
+			}
+			body.WriteString(prefix + strings.TrimRight(line, " ") + "\n")
+		}
+	}
+	return body.String()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// heatWindow is how far back edits count towards a file's heat
+const heatWindow = 30 * time.Minute
+
+// fileHeat tracks how often a changed file has been edited this session
+type fileHeat struct {
+	modTime time.Time   // as of the last refresh; zero if deleted
+	edits   []time.Time // when each edit was noticed, within heatWindow
+}
+
+// trackHeat notes which changed files were edited since the last refresh,
+// going by modification time. Files already changed when vigil starts
+// aren't counted until they're edited again.
+func (m *model) trackHeat() {
+	if m.replay != nil {
+		return
+	}
+	first := m.heat == nil
+	if first {
+		m.heat = make(map[string]fileHeat)
+	}
+	root, err := GetRepoRoot()
+	if err != nil {
+		return
+	}
+	now := m.now()
+	for _, c := range m.changes {
+		file := treePath(c.File)
+		var modTime time.Time
+		if info, err := os.Stat(filepath.Join(root, file)); err == nil {
+			modTime = info.ModTime()
+		}
+		h, seen := m.heat[file]
+		if !first && (!seen || !h.modTime.Equal(modTime)) {
+			h.edits = append(h.edits, now)
+		}
+		h.modTime = modTime
+		m.heat[file] = h
+	}
+	for file, h := range m.heat {
+		for len(h.edits) > 0 && now.Sub(h.edits[0]) > heatWindow {
+			h.edits = h.edits[1:]
+		}
+		m.heat[file] = h
+	}
+}
+
+// heatLevel grades a file's recent edits from 0 (none) to 3.
+func (m model) heatLevel(file string) int {
+	switch n := len(m.heat[treePath(file)].edits); {
+	case n >= 5:
+		return 3
+	case n >= 2:
+		return 2
+	case n == 1:
+		return 1
+	}
+	return 0
+}
+
+// withHeat follows a file's name with its heat indicator, e.g. "••", so
+// the files being worked on stand out.
+func (m model) withHeat(file, name string) string {
+	switch m.heatLevel(file) {
+	case 1:
+		return name + " " + helpStyle.Render(glyphs.HeatLow)
+	case 2:
+		return name + " " + statusModified.Render(strings.Repeat(glyphs.Heat, 2))
+	case 3:
+		return name + " " + statusDeleted.Render(strings.Repeat(glyphs.Heat, 3))
+	}
+	return name
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fileKeys documents the files view key bindings, in display order
+var fileKeys = []struct {
+	key  string
+	desc string
+}{
+	{"j/k", "move selection"},
+	{"m", "menu of everything that can be done with the selected file"},
+	{"g/G", "jump to top/bottom"},
+	{"*", "pin or unpin the selected file"},
+	{"x", "mark the selected branch file reviewed"},
+	{"/", "filter files by path (esc clears)"},
+	{"s", "cycle sort: path, status, mtime, diff size"},
+	{"t", "toggle tree view grouped by directory"},
+	{"u", "show or hide untracked files"},
+	{"i", "show or hide ignored files"},
+	{"C", "preview, then delete untracked files (selected or all)"},
+	{"e", "add the selected untracked file, its extension or directory to .gitignore"},
+	{"O", "show or hide each file's last author"},
+	{".", "show or hide files whose changes are all whitespace"},
+	{"space", "select the changed file for a batch action (on a directory: collapse)"},
+	{"a", "select all changed files, or clear the selection if they all are"},
+	{"+/-", "stage/unstage the selected changes"},
+	{"d", "discard the selected changes, deleting new files"},
+	{"z", "stash the selected changes"},
+	{"enter", "collapse or expand the selected directory, generated files or TODOs, open a TODO in the editor, a submodule or nested repo (esc comes back), or show a branch commit"},
+	{"r", "refresh now"},
+	{"f", "fetch now"},
+	{"A", "toggle automatic background fetch"},
+	{"p", "pull"},
+	{"P", "push (sets upstream on first push)"},
+	{"F", "force push with lease"},
+	{"N", "set or change the upstream branch, or push to create it"},
+	{"o", "open the selected file in the editor at its first change, or a TODO at its line"},
+	{"y", "copy the selected file's path, absolute path or diff to the clipboard"},
+	{"V", "open the selected file, the branch's compare page or its PR on GitHub/GitLab"},
+	{"h", "history of the selected file, with each commit's diff"},
+	{"H", "reflog of HEAD, to check out or reset to an earlier state"},
+	{"n", "add or edit the note on the latest commit"},
+	{"U", "undo the latest commit, keeping its changes staged"},
+	{"R", "rebase -i from the selected branch commit, or continue or abort a rebase or cherry-pick in progress"},
+	{"b", "change the comparison base for branch files"},
+	{"l/L", "next/previous layout preset"},
+	{"Z", "toggle follow activity"},
+	{"w", "worktrees"},
+	{"v", "jump to a bookmarked repository"},
+	{"T", "tags, to create one at HEAD, diff since the latest, or see the changelog between two"},
+	{"c", "compare any two refs, with per-file diffs"},
+	{"S", "branch stacks, to create, rename, delete, switch or restack branches, or cherry-pick from one"},
+	{"W", "output of the watch command"},
+	{"J", "commit graph of all branches, and jj or git-branchless logs when one manages the repo"},
+	{"E", "export HEAD or a ref with git archive"},
+	{"M", "write branch commits as a patch series for mailing"},
+	{"D", "save or copy changes or the branch diff as a patch, or apply one"},
+	{"Y", "draft a markdown changelog of the branch's commits, grouped by type, to copy or save"},
+	{"B", "bundle the branch (or other refs) for offline transfer"},
+	{"I", "verify and import a bundle"},
+	{"?", "this help"},
+	{diagnosticsKey, "diagnostics: refresh and git command timings, watch events, memory (any view)"},
+	{"q", "quit"},
+}
+
+// keyHints returns the footer's key summary for the current view.
+func (m model) keyHints() string {
+	arrows := glyphs.Up + "/" + glyphs.Down + "/j/k"
+	switch m.view {
+	case viewWorktrees:
+		if m.narrow() {
+			return "enter:switch esc:back q:quit"
+		}
+		return "Select: " + arrows + "  enter: switch  r: refresh  esc: back  q: quit"
+	case viewHelp:
+		return "esc: back  q: quit"
+	case viewToolLog:
+		if len(m.tool.logs()) == 1 {
+			if m.narrow() {
+				return "r:redraw esc:back"
+			}
+			return "Scroll: " + arrows + "  r: redraw  esc: back  q: quit"
+		}
+		if m.narrow() {
+			return "tab:next esc:back"
+		}
+		return "Scroll: " + arrows + "  tab: next log  r: refresh  esc: back  q: quit"
+	case viewOutput:
+		if m.narrow() {
+			return "esc:back q:quit"
+		}
+		if m.output.diff {
+			return "Scroll: " + arrows + "  v: " + m.splitHint() + "  esc: back  q: quit"
+		}
+		return "Scroll: " + arrows + "  esc: back  q: quit"
+	case viewStack:
+		if m.narrow() {
+			return "n:new m:mv d:del c:pick R:restack"
+		}
+		return "Select: " + arrows + "  enter: switch  n: new  m: rename  d: delete  D: delete merged  c: cherry-pick  R: restack  r: refresh  esc: back  q: quit"
+	case viewPick:
+		if m.narrow() {
+			return "space:mark enter:pick esc:back"
+		}
+		return "Select: " + arrows + "  space: mark  enter: cherry-pick marked or selected  esc: back  q: quit"
+	case viewBookmarks:
+		if m.narrow() {
+			return "enter:switch esc:back"
+		}
+		return "Type to filter  Select: " + glyphs.Up + "/" + glyphs.Down + "  enter: switch  esc: back"
+	case viewHealth:
+		if m.narrow() {
+			return "r:retry q:quit"
+		}
+		return "r: retry (also checked every few seconds)  q: quit"
+	case viewUpstream:
+		if m.narrow() {
+			return "enter:set esc:back"
+		}
+		return "Type to filter  Select: " + glyphs.Up + "/" + glyphs.Down + "  enter: set upstream  esc: back"
+	case viewMenu:
+		if m.narrow() {
+			return "enter:run esc:back"
+		}
+		return "Select: " + arrows + "  enter or the action's key: run  esc: back  q: quit"
+	case viewReflog:
+		if m.narrow() {
+			return "enter:checkout R:reset"
+		}
+		return "Select: " + arrows + "  enter: check out  R: reset to  r: refresh  esc: back  q: quit"
+	case viewCompare:
+		if m.compare.file != "" {
+			if m.narrow() {
+				return "o:open bksp:files"
+			}
+			return "Select: " + arrows + "  o: open in editor  v: " + m.splitHint() + "  backspace: files  esc: back  q: quit"
+		}
+		if m.narrow() {
+			return "enter:diff esc:back"
+		}
+		return "Select: " + arrows + "  enter: diff  esc: back  q: quit"
+	case viewHistory:
+		if m.history.shown >= 0 {
+			if m.narrow() {
+				return "bksp:log esc:back"
+			}
+			return "Scroll: " + arrows + "  v: " + m.splitHint() + "  backspace: log  esc: back  q: quit"
+		}
+		if m.narrow() {
+			return "enter:diff esc:back"
+		}
+		return "Select: " + arrows + "  enter: diff  esc: back  q: quit"
+	case viewChangelog:
+		if m.changelog.step == pickDone {
+			if m.narrow() {
+				return "Y:draft bksp:repick esc:back"
+			}
+			return "Scroll: " + arrows + "  Y: changelog draft  backspace: pick again  esc: back  q: quit"
+		}
+		if m.changelog.step == pickFrom {
+			if m.narrow() {
+				return "enter:pick a:tag d:diff"
+			}
+			return "Select: " + arrows + "  enter: changelog from  a: tag HEAD  d: diff since latest tag  esc: back  q: quit"
+		}
+		if m.narrow() {
+			return "enter:pick esc:back"
+		}
+		return "Select: " + arrows + "  enter: pick  esc: back  q: quit"
+	}
+	if n := len(m.marked); n > 0 {
+		if m.narrow() {
+			return fmt.Sprintf("%d sel +/-/d/z esc", n)
+		}
+		return fmt.Sprintf("%d selected  +: stage  -: unstage  d: discard  z: stash  esc: clear selection", n)
+	}
+	if m.filter != "" {
+		return m.filterHint()
+	}
+	if m.replay != nil {
+		return fmt.Sprintf("Replaying %d/%d  Select: %s  l: layout (%s)  ?: help  q: quit", m.replay.index+1, len(m.replay.frames), arrows, m.layoutName)
+	}
+	if m.narrow() {
+		return "?:help q:quit"
+	}
+	return fmt.Sprintf("Select: %s  r: refresh  l: layout (%s)  ?: help  q: quit", arrows, m.layoutName)
+}
+
+// updateHelp handles key input in the help view.
+func (m model) updateHelp(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "?":
+		m.view = viewFiles
+		m.resize()
+		return m, tea.ClearScreen
+	case "up", "k":
+		m.viewport.LineUp(1)
+	case "down", "j":
+		m.viewport.LineDown(1)
+	}
+	return m, nil
+}
+
+func (m model) renderHelp() string {
+	var body strings.Builder
+	body.WriteString("Keys:\n")
+	for _, k := range fileKeys {
+		body.WriteString(fmt.Sprintf("  %s  %s\n", branchStyle.Render(fmt.Sprintf("%-5s", k.key)), k.desc))
+	}
+	if limits := GitLimitations(); len(limits) > 0 {
+		body.WriteString(fmt.Sprintf("\nOn git %s:\n", gitVersion()))
+		for _, l := range limits {
+			body.WriteString(helpStyle.Render("  "+l) + "\n")
+		}
+	}
+	if len(m.commands) > 0 {
+		body.WriteString("\nCustom commands:\n")
+		for _, c := range m.commands {
+			body.WriteString(fmt.Sprintf("  %s  %s\n", branchStyle.Render(fmt.Sprintf("%-5s", c.Key)), c.description()))
+		}
+	}
+	return body.String()
+}
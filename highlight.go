@@ -0,0 +1,376 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Token colors for syntax highlighting in diffs
+var (
+	keywordStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("141"))
+	stringStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("179"))
+	numberStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("173"))
+	commentStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("245")).Italic(true)
+
+	// Added and deleted lines are tinted behind the tokens, so the
+	// syntax colors don't hide which side a line is on
+	addedLineStyle   = statusAdded.Background(lipgloss.Color("22"))
+	deletedLineStyle = statusDeleted.Background(lipgloss.Color("52"))
+)
+
+// language is enough of a language's lexical syntax to color keywords,
+// strings, numbers and comments.
+type language struct {
+	keywords     map[string]bool
+	lineComments []string  // e.g. "//" or "#"
+	blockComment [2]string // open and close, if the language has them
+	quotes       string    // characters that start and end a string
+}
+
+func words(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		set[w] = true
+	}
+	return set
+}
+
+var (
+	langGo = &language{
+		keywords:     words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false iota"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	}
+	langJS = &language{
+		keywords:     words("async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof let new of return static super switch this throw try typeof var void while yield null undefined true false interface type enum implements private public protected readonly as"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'`",
+	}
+	langC = &language{
+		keywords:     words("auto break case char const continue default do double else enum extern float for goto if inline int long register return short signed sizeof static struct switch typedef union unsigned void volatile while bool true false nullptr NULL class namespace template typename public private protected virtual override new delete this throw try catch using final import package extends implements interface abstract boolean byte null super synchronized"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"'",
+	}
+	langRust = &language{
+		keywords:     words("as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while Some None Ok Err"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "\"",
+	}
+	langPython = &language{
+		keywords:     words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	langRuby = &language{
+		keywords:     words("alias and begin break case class def defined do else elsif end ensure false for if in module next nil not or redo rescue retry return self super then true undef unless until when while yield require attr_reader attr_accessor"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	langShell = &language{
+		keywords:     words("if then else elif fi for while until do done case esac in function return local export readonly set unset shift exit"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+	langSQL = &language{
+		keywords: words("select from where and or not insert into values update set delete create table drop alter add index primary key foreign references join left right inner outer on group by order having limit as null is in distinct union all case when then else end " +
+			"SELECT FROM WHERE AND OR NOT INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER ADD INDEX PRIMARY KEY FOREIGN REFERENCES JOIN LEFT RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT AS NULL IS IN DISTINCT UNION ALL CASE WHEN THEN ELSE END"),
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       "'\"",
+	}
+	langYAML = &language{
+		keywords:     words("true false null yes no on off"),
+		lineComments: []string{"#"},
+		quotes:       "\"'",
+	}
+)
+
+// languages maps file extensions, and a few whole file names, to their
+// language
+var languages = map[string]*language{
+	".go": langGo,
+	".js": langJS, ".jsx": langJS, ".mjs": langJS, ".cjs": langJS, ".ts": langJS, ".tsx": langJS,
+	".c": langC, ".h": langC, ".cc": langC, ".cpp": langC, ".hpp": langC, ".java": langC, ".cs": langC, ".kt": langC, ".swift": langC,
+	".rs": langRust,
+	".py": langPython,
+	".rb": langRuby,
+	".sh": langShell, ".bash": langShell, ".zsh": langShell,
+	".sql": langSQL,
+	".yml": langYAML, ".yaml": langYAML, ".toml": langYAML,
+	"Makefile": langShell, "Dockerfile": langShell, "Rakefile": langRuby, "Gemfile": langRuby,
+}
+
+// languageFor returns the language of a file by its name, or nil.
+func languageFor(path string) *language {
+	if lang, ok := languages[filepath.Base(path)]; ok {
+		return lang
+	}
+	return languages[strings.ToLower(filepath.Ext(path))]
+}
+
+// diffPainter colors unified diff lines. It follows the file headers so
+// each hunk's code is highlighted as its file's language, and block
+// comments from line to line, so lines are painted in order.
+type diffPainter struct {
+	syntax bool
+	lang   *language
+
+	// Whether the old and new side of the hunk are inside a block comment
+	// where the last of their lines ended, and whether the hunk has had a
+	// line on that side yet
+	inComment [2]bool
+	started   [2]bool
+}
+
+// diffPainter returns a painter for a diff of file, or for a diff with
+// its own file headers when file is "".
+func (m model) diffPainter(file string) *diffPainter {
+	return &diffPainter{syntax: m.syntax, lang: languageFor(file)}
+}
+
+// render colors one line of the diff.
+func (p *diffPainter) render(line string) string {
+	return p.renderChanged(line, nil)
+}
+
+// renderChanged colors one line of the diff, emphasizing the bytes of its
+// code (after the +/- marker) that changed marks as edited.
+func (p *diffPainter) renderChanged(line string, changed []bool) string {
+	return p.renderCut(line, changed, len(line))
+}
+
+// renderCut is renderChanged showing only the first cut bytes of the line.
+// The rest is still read, so a block comment it opens or closes carries
+// on to the next line as it should.
+func (p *diffPainter) renderCut(line string, changed []bool, cut int) string {
+	switch {
+	case strings.HasPrefix(line, "diff --git "):
+		// "diff --git a/x b/x": the new path follows the last " b/"
+		if i := strings.LastIndex(line, " b/"); i >= 0 {
+			p.lang = languageFor(line[i+3:])
+		}
+		p.startHunk()
+	case strings.HasPrefix(line, "+++ "):
+		if path := strings.TrimPrefix(line[4:], "b/"); path != "/dev/null" {
+			p.lang = languageFor(path)
+		}
+		p.startHunk()
+	case strings.HasPrefix(line, "@@"):
+		p.startHunk()
+	}
+
+	style := diffLineStyle(line)
+	highlight := p.syntax && p.lang != nil
+	if (!highlight && changed == nil) || line == "" || strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+		return style.Render(line[:cut])
+	}
+	base := style
+	var emph lipgloss.Style
+	switch line[0] {
+	case '+':
+		if highlight {
+			base = addedLineStyle
+		}
+		emph = base.Background(lipgloss.Color("28"))
+	case '-':
+		if highlight {
+			base = deletedLineStyle
+		}
+		emph = base.Background(lipgloss.Color("88"))
+	case ' ':
+	default:
+		return style.Render(line[:cut])
+	}
+
+	code := line[1:]
+	spans := []span{{from: 0, to: len(code)}}
+	if highlight {
+		spans = p.codeSpans(line[0], code)
+	}
+	var out strings.Builder
+	out.WriteString(base.Render(line[:min(cut, 1)]))
+	edited := func(i int) bool { return i < len(changed) && changed[i] }
+	for _, s := range spans {
+		if s.to = min(s.to, cut-1); s.from >= s.to {
+			break
+		}
+		// Split each span where the emphasis starts or stops
+		for from := s.from; from < s.to; {
+			on := edited(from)
+			to := from + 1
+			for to < s.to && edited(to) == on {
+				to++
+			}
+			under := base
+			if on {
+				under = emph
+			}
+			if s.token {
+				out.WriteString(s.style.Inherit(under).Render(code[from:to]))
+			} else {
+				out.WriteString(under.Render(code[from:to]))
+			}
+			from = to
+		}
+	}
+	return out.String()
+}
+
+// startHunk forgets the block comments of the lines before: a new hunk
+// starts somewhere else in the file.
+func (p *diffPainter) startHunk() {
+	p.inComment = [2]bool{}
+	p.started = [2]bool{}
+}
+
+// codeSpans splits the code of a diff line into tokens, following block
+// comments on the side or sides of the hunk the line is on.
+func (p *diffPainter) codeSpans(marker byte, code string) []span {
+	side := 1 // new, and context lines, which are on both
+	if marker == '-' {
+		side = 0
+	}
+	in := p.inComment[side]
+	if !p.started[side] {
+		in = p.lang.continuesComment(code)
+	}
+	spans, in := p.lang.spans(code, in)
+	if marker == ' ' {
+		p.inComment, p.started = [2]bool{in, in}, [2]bool{true, true}
+	} else {
+		p.inComment[side], p.started[side] = in, true
+	}
+	return spans
+}
+
+// continuesComment guesses whether the first line of a hunk is inside a
+// block comment that opened before the hunk, from the leading "*" of a
+// doc comment's lines, e.g. " * text" or " */".
+func (lang *language) continuesComment(code string) bool {
+	if lang.blockComment[0] == "" {
+		return false
+	}
+	trimmed := strings.TrimSpace(code)
+	return trimmed == "*" || strings.HasPrefix(trimmed, "* ") || strings.HasPrefix(trimmed, lang.blockComment[1])
+}
+
+// span is a run of a line of code: a token, or the plain text between
+// tokens.
+type span struct {
+	from, to int
+	token    bool
+	style    lipgloss.Style
+}
+
+// spans splits a line of code into tokens and the text between them. The
+// line starts inside a block comment if inComment is set, and it reports
+// whether the line ends inside one.
+func (lang *language) spans(code string, inComment bool) ([]span, bool) {
+	var spans []span
+	plainFrom := 0
+	token := func(from, to int, style lipgloss.Style) {
+		if from > plainFrom {
+			spans = append(spans, span{from: plainFrom, to: from})
+		}
+		spans = append(spans, span{from: from, to: to, token: true, style: style})
+		plainFrom = to
+	}
+	open, close := lang.blockComment[0], lang.blockComment[1]
+
+	i := 0
+	if inComment && open != "" {
+		i = len(code)
+		if j := strings.Index(code, close); j >= 0 {
+			i, inComment = j+len(close), false
+		}
+		if i > 0 {
+			token(0, i, commentStyle)
+		}
+	}
+	for i < len(code) {
+		rest := code[i:]
+		if lang.startsLineComment(rest) {
+			token(i, len(code), commentStyle)
+			break
+		}
+		if open != "" && strings.HasPrefix(rest, open) {
+			end := len(code)
+			if j := strings.Index(rest[len(open):], close); j >= 0 {
+				end = i + len(open) + j + len(close)
+			} else {
+				inComment = true
+			}
+			token(i, end, commentStyle)
+			i = end
+			continue
+		}
+		c := code[i]
+		switch {
+		case strings.IndexByte(lang.quotes, c) >= 0:
+			end := stringEnd(code, i)
+			token(i, end, stringStyle)
+			i = end
+		case isDigit(c) && (i == 0 || !isWordByte(code[i-1])):
+			end := i + 1
+			for end < len(code) && (isWordByte(code[end]) || code[end] == '.') {
+				end++
+			}
+			token(i, end, numberStyle)
+			i = end
+		case isWordByte(c):
+			end := i + 1
+			for end < len(code) && isWordByte(code[end]) {
+				end++
+			}
+			if lang.keywords[code[i:end]] {
+				token(i, end, keywordStyle)
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	if plainFrom < len(code) {
+		spans = append(spans, span{from: plainFrom, to: len(code)})
+	}
+	return spans, inComment
+}
+
+func (lang *language) startsLineComment(s string) bool {
+	for _, prefix := range lang.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringEnd returns the index just past the string starting at start,
+// or the end of the line for a string that carries on past it.
+func stringEnd(code string, start int) int {
+	quote := code[start]
+	for i := start + 1; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		}
+	}
+	return len(code)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || unicode.IsLetter(rune(c)) || isDigit(c)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// commented returns the parts of code that spans color as comments,
+// joined by "|".
+func commented(code string, spans []span) string {
+	var parts []string
+	for _, s := range spans {
+		if s.token && reflect.DeepEqual(s.style, commentStyle) {
+			parts = append(parts, code[s.from:s.to])
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+func TestSpansBlockComments(t *testing.T) {
+	tests := []struct {
+		code      string
+		in        bool
+		want      string
+		wantAfter bool
+	}{
+		{"x := 1", false, "", false},
+		{"x := 1 /* one */ + 2", false, "/* one */", false},
+		{"x := 1 /* opens", false, "/* opens", true},
+		{"still inside", true, "still inside", true},
+		{"", true, "", true},
+		{"closes */ x := 2", true, "closes */", false},
+		{"closes */ y /* and again", true, "closes */|/* and again", true},
+		{"s := \"/* not a comment\"", false, "", false},
+		{"x := 1 // /* not either", false, "// /* not either", false},
+		{"*p = 1", false, "", false},
+	}
+	for _, tt := range tests {
+		spans, after := langGo.spans(tt.code, tt.in)
+		if got := commented(tt.code, spans); got != tt.want || after != tt.wantAfter {
+			t.Errorf("spans(%q, %v) commented %q, ending in a comment %v; want %q, %v", tt.code, tt.in, got, after, tt.want, tt.wantAfter)
+		}
+	}
+
+	// Languages without block comments never start one
+	if spans, after := langPython.spans("x = 1 /* y", false); commented("x = 1 /* y", spans) != "" || after {
+		t.Error("Python took /* as a block comment")
+	}
+}
+
+// commentLines paints diff line by line, reporting for each whether its
+// code was colored as a comment from end to end.
+func commentLines(p *diffPainter, diff []string) []bool {
+	got := make([]bool, len(diff))
+	for i, line := range diff {
+		if line != "" && strings.IndexByte("+- ", line[0]) >= 0 {
+			before := *p
+			code := line[1:]
+			got[i] = code != "" && commented(code, p.codeSpans(line[0], code)) == code
+			*p = before
+		}
+		p.render(line)
+	}
+	return got
+}
+
+func TestDiffPainterFollowsBlockComments(t *testing.T) {
+	tests := []struct {
+		line    string
+		comment bool
+	}{
+		{"diff --git a/x.go b/x.go", false},
+		{"+++ b/x.go", false},
+		{"@@ -1,6 +1,7 @@", false},
+		{" /*", true},
+		{"-old text", true},
+		{"+new text", true},
+		{"+*p = 1 is inside too", true},
+		{" */", true},
+		{" x := 1", false},
+		{"-/* a comment only on the old side", true},
+		{"+y := 2", false},
+		{"-still on the old side */", true},
+		{"+z := 3", false},
+		{"@@ -20,3 +21,3 @@", false},
+		{" * carries on from before the hunk", true},
+		{" */", true},
+		{" *p = 2", false},
+		{"@@ -40,2 +41,2 @@", false},
+		{" *p = 3 starts a hunk outside a comment", false},
+	}
+	var diff []string
+	for _, tt := range tests {
+		diff = append(diff, tt.line)
+	}
+	got := commentLines(&diffPainter{syntax: true}, diff)
+	for i, tt := range tests {
+		if got[i] != tt.comment {
+			t.Errorf("%q: comment %v, want %v", tt.line, got[i], tt.comment)
+		}
+	}
+}
+
+func TestSplitPaintsContextOnce(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Cleanup(func() { lipgloss.SetColorProfile(profile) })
+
+	m := model{syntax: true}
+	diff := []string{
+		"diff --git a/x.go b/x.go",
+		"+++ b/x.go",
+		"@@ -1,3 +1,3 @@",
+		" x := 1 /* opens",
+		"-x",
+		"+y",
+		" closes */ y := 2",
+	}
+	divider := helpStyle.Render(" " + glyphs.Divider + " ")
+	rows := renderSplit(diff, m.diffPainter("x.go"), 61)
+	if len(rows) != 6 {
+		t.Fatalf("renderSplit drew %d rows, want 6", len(rows))
+	}
+	for _, row := range []string{rows[3], rows[5]} {
+		if left, right, _ := strings.Cut(row, divider); left != right {
+			t.Errorf("context row painted differently on each side:\n%q\n%q", left, right)
+		}
+	}
+}
+
+func TestDiffViewSteps(t *testing.T) {
+	diff := []string{"@@ -1,3 +1,3 @@", "-a", "-b", "+c", "+d", " e"}
+	j := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")}
+	for _, tt := range []struct {
+		split bool
+		lines []int // selected after each j
+		row   int   // row showing the last
+	}{
+		{false, []int{1, 2, 3, 4, 5, 5}, 5},
+		{true, []int{1, 2, 5, 5}, 3}, // a|c, b|d, then e
+	} {
+		d := diffView{lines: diff, selectable: true, split: tt.split}
+		for i, want := range tt.lines {
+			d, _ = d.Update(j)
+			if d.line != want {
+				t.Fatalf("split %v: after %d steps line %d selected, want %d", tt.split, i+1, d.line, want)
+			}
+		}
+		if d.row() != tt.row {
+			t.Errorf("split %v: line %d shown on row %d, want %d", tt.split, d.line, d.row(), tt.row)
+		}
+	}
+
+	d := diffView{lines: diff}
+	if d, _ = d.Update(j); d.line != 0 {
+		t.Errorf("a diff that isn't selectable moved its line to %d", d.line)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// historyState holds the log of one file
+type historyState struct {
+	file    string
+	commits []FileCommit
+
+	// Commit whose change to the file is shown; -1 while listing commits
+	shown int
+	diff  diffView
+}
+
+// openHistory switches to the log of the selected file.
+func (m *model) openHistory() {
+	file, ok := m.selectedFile()
+	if !ok {
+		return
+	}
+	file = treePath(file)
+	commits, err := GetFileHistory(file)
+	if err != nil {
+		m.notifyErr(err)
+		return
+	}
+	if len(commits) == 0 {
+		m.notify(file + " has no history yet")
+		return
+	}
+	m.history = historyState{file: file, commits: commits, shown: -1}
+	m.view = viewHistory
+	m.cursor = 0
+	m.viewport.GotoTop()
+	m.resize()
+}
+
+// updateHistory handles key input in the file history view: picking a
+// commit, or scrolling through what it changed in the file.
+func (m model) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	h := &m.history
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "backspace":
+		if h.shown >= 0 {
+			// Back to the commit list
+			h.shown, h.diff = -1, diffView{}
+			m.viewport.GotoTop()
+			break
+		}
+		if msg.String() == "esc" {
+			m.view = viewFiles
+			m.resize()
+			return m, tea.ClearScreen
+		}
+	case "up", "k":
+		if h.shown >= 0 {
+			m.viewport.LineUp(1)
+			return m, nil
+		}
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		if h.shown >= 0 {
+			m.viewport.LineDown(1)
+			return m, nil
+		}
+		m.cursor = min(m.cursor+1, max(len(h.commits)-1, 0))
+	case "pgup":
+		if h.shown >= 0 {
+			m.viewport.HalfViewUp()
+			return m, nil
+		}
+	case "pgdown":
+		if h.shown >= 0 {
+			m.viewport.HalfViewDown()
+			return m, nil
+		}
+	case "v":
+		if h.shown >= 0 {
+			m.toggleSplit()
+		}
+	case "enter":
+		if h.shown >= 0 || m.cursor >= len(h.commits) {
+			return m, nil
+		}
+		c := h.commits[m.cursor]
+		paths := []string{c.Path}
+		if m.cursor+1 < len(h.commits) && h.commits[m.cursor+1].Path != c.Path {
+			paths = append(paths, h.commits[m.cursor+1].Path) // renamed here
+		}
+		diff, err := GetCommitFileDiff(c.Hash, paths...)
+		if err != nil {
+			m.notifyErr(err)
+			return m, nil
+		}
+		h.shown, h.diff = m.cursor, diffView{file: h.file, lines: diff}
+		m.viewport.GotoTop()
+		m.resize()
+		return m, tea.ClearScreen
+	}
+
+	m.resize()
+	if h.shown < 0 {
+		m.scrollTo(m.cursor + 1)
+	}
+	return m, nil
+}
+
+func (m model) renderHistory() string {
+	h := m.history
+	var body strings.Builder
+
+	if h.shown >= 0 {
+		c := h.commits[h.shown]
+		body.WriteString(fmt.Sprintf("%s %s  %s\n", commitHashStyle.Render(c.Hash), c.Subject, fileStyle.Render(c.Path)))
+		if m.renderPaged(&body, h.diff.lines, m.width) {
+			return body.String()
+		}
+		body.WriteString(m.fitDiff(h.diff).View())
+		return body.String()
+	}
+
+	body.WriteString(fmt.Sprintf("History of %s", fileStyle.Render(h.file)))
+	body.WriteString(helpStyle.Render(" ("+plural(len(h.commits), "commit")+")") + "\n")
+	for i, c := range h.commits {
+		line := fmt.Sprintf("%s %s %s", commitHashStyle.Render(c.Hash), c.Subject, helpStyle.Render("("+c.Author+", "+m.timeAgo(c.Time)+")"))
+		if c.Path != h.file {
+			line += " " + pathStyle.Render(c.Path) // before a rename
+		}
+		if m.narrow() {
+			line = truncate(fmt.Sprintf("%s %s", commitHashStyle.Render(c.Hash), c.Subject), m.width-1)
+		}
+		body.WriteString(m.cursorColumn(i == m.cursor) + line + "\n")
+	}
+	return body.String()
+}
@@ -0,0 +1,301 @@
+// Package fixture builds throwaway git repositories in known states, for
+// exercising vigil's parsing and rendering end to end without a real
+// project: every porcelain status code, renames, each kind of merge
+// conflict, stashes, notes, tags, an upstream to be ahead of and behind,
+// detached HEAD, a repository with no commits, stacked branches and a
+// branch with code owners, generated files and TODO comments.
+package fixture
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Paths in the repository built by New, by the status git reports for them
+const (
+	Modified       = "modified.txt"        // " M"
+	Staged         = "staged.txt"          // "M "
+	StagedModified = "staged-modified.txt" // "MM"
+	Added          = "added.txt"           // "A "
+	AddedModified  = "added-modified.txt"  // "AM"
+	Deleted        = "deleted.txt"         // " D"
+	StagedDeleted  = "staged-deleted.txt"  // "D "
+	RenamedFrom    = "renamed-from.txt"    // "R  renamed-from.txt -> renamed-to.txt"
+	RenamedTo      = "renamed-to.txt"
+	TypeChanged    = "type-changed.txt"    // " T", replaced by a symlink
+	Untracked      = "untracked.txt"       // "??"
+	Ignored        = "build/output.log"    // "!!"
+	BothModified   = "conflict.txt"        // "UU"
+	BothAdded      = "both-added.txt"      // "AA"
+	DeletedByUs    = "deleted-by-us.txt"   // "DU"
+	DeletedByThem  = "deleted-by-them.txt" // "UD"
+	BranchAdded    = "feature/feature.go"  // added on the branch, vs main
+	BranchModified = "README.md"           // modified on the branch, vs main
+	Branch         = "feature"             // branch checked out in New's repository
+	Base           = "main"                // the branch it forked from
+	Tag            = "v1.0.0"              // tag on main before the fork
+	UpstreamRemote = "origin"              // bare repository next to the working one
+)
+
+// Branches in the repository built by Stacked
+const (
+	StackBase = "stack-base" // two commits on main, then a third
+	StackTop  = "stack-top"  // a commit on StackBase before its third: one behind
+	Amended   = "amended"    // a commit on main, amended after OnAmended was built on it
+	OnAmended = "on-amended" // a commit on Amended's first version: one behind
+	Sibling   = "sibling"    // a commit on main, sharing none with the others
+)
+
+// Files and annotations in the repository built by Annotated
+const (
+	Owner     = "@fixture/reviewers"    // owns everything, in CODEOWNERS
+	Generated = "gen/types.go"          // marked generated in .gitattributes
+	Todo      = "handle the empty case" // a TODO comment BranchAdded gains
+)
+
+// New builds a repository under dir, in dir/repo with its upstream in
+// dir/origin.git, and returns the repository's path. Branch is checked
+// out mid-merge with Base, so it has every kind of conflict alongside
+// every other status, a stash, a note on HEAD, and is one commit ahead of
+// and one behind its upstream.
+func New(dir string) (string, error) {
+	repo := filepath.Join(dir, "repo")
+	origin := filepath.Join(dir, "origin.git")
+	g := &builder{dir: repo}
+
+	g.git("init", "--quiet", "--bare", "--initial-branch", Base, origin)
+	g.git("init", "--quiet", "--initial-branch", Base, repo)
+	g.config()
+	g.write(".gitignore", "build/\n")
+	g.write(BranchModified, "# fixture\n")
+	for _, f := range []string{Modified, Staged, StagedModified, Deleted, StagedDeleted, RenamedFrom, TypeChanged, BothModified, DeletedByUs, DeletedByThem} {
+		g.write(f, "original "+f+"\n")
+	}
+	g.commit("Initial commit")
+	g.git("tag", "--annotate", "--message", "First release", Tag)
+	g.git("remote", "add", UpstreamRemote, origin)
+	g.git("push", "--quiet", UpstreamRemote, Base)
+
+	// The branch's side of the conflicts, and its own changes
+	g.git("switch", "--quiet", "--create", Branch)
+	g.write(BranchAdded, "package feature\n")
+	g.write(BranchModified, "# fixture\n\nWith a feature.\n")
+	g.write(BothModified, "branch side\n")
+	g.write(BothAdded, "added on the branch\n")
+	g.remove(DeletedByUs)
+	g.write(DeletedByThem, "changed on the branch\n")
+	g.commit("Add feature")
+	g.git("push", "--quiet", "--set-upstream", UpstreamRemote, Branch)
+
+	// Upstream gains a commit that isn't local, and local one that isn't
+	// pushed: one ahead, one behind
+	g.git("switch", "--quiet", "--create", "elsewhere")
+	g.write("upstream.txt", "pushed from elsewhere\n")
+	g.commit("Commit from another clone")
+	g.git("push", "--quiet", UpstreamRemote, "elsewhere:"+Branch)
+	g.git("switch", "--quiet", Branch)
+	g.git("branch", "--quiet", "--delete", "--force", "elsewhere")
+	g.write("local.txt", "not pushed\n")
+	g.commit("Local commit")
+	g.git("notes", "add", "--message", "Needs review", "HEAD")
+
+	// A stash, made before the merge leaves the index unmerged
+	g.write(Modified, "stashed change\n")
+	g.git("stash", "--quiet")
+
+	// The base's side of the conflicts
+	g.git("switch", "--quiet", Base)
+	g.write(BothModified, "base side\n")
+	g.write(BothAdded, "added on the base\n")
+	g.write(DeletedByUs, "changed on the base\n")
+	g.remove(DeletedByThem)
+	g.commit("Change the base")
+	g.git("switch", "--quiet", Branch)
+	g.mayFail("merge", "--quiet", "--no-edit", Base) // stops on the conflicts
+
+	// Everything else, around the conflicts
+	g.write(Modified, "changed\n")
+	g.write(Staged, "changed\n")
+	g.git("add", Staged)
+	g.write(StagedModified, "changed\n")
+	g.git("add", StagedModified)
+	g.write(StagedModified, "changed again\n")
+	g.write(Added, "new\n")
+	g.git("add", Added)
+	g.write(AddedModified, "new\n")
+	g.git("add", AddedModified)
+	g.write(AddedModified, "new, then changed\n")
+	g.remove(Deleted)
+	g.git("rm", "--quiet", StagedDeleted)
+	g.git("mv", RenamedFrom, RenamedTo)
+	g.remove(TypeChanged)
+	g.symlink(RenamedTo, TypeChanged)
+	g.write(Untracked, "untracked\n")
+	g.write(Ignored, "ignored\n")
+	return repo, g.err
+}
+
+// Detached builds a repository under dir with HEAD detached one commit
+// behind main and a modified file, and returns its path.
+func Detached(dir string) (string, error) {
+	repo := filepath.Join(dir, "detached")
+	g := &builder{dir: repo}
+	g.git("init", "--quiet", "--initial-branch", Base, repo)
+	g.config()
+	g.write(Modified, "first\n")
+	g.commit("First commit")
+	g.git("tag", Tag)
+	g.write(Modified, "second\n")
+	g.commit("Second commit")
+	g.git("switch", "--quiet", "--detach", "HEAD~1")
+	g.write(Modified, "changed while detached\n")
+	return repo, g.err
+}
+
+// Empty builds a repository under dir with no commits yet, one staged and
+// one untracked file, and returns its path.
+func Empty(dir string) (string, error) {
+	repo := filepath.Join(dir, "empty")
+	g := &builder{dir: repo}
+	g.git("init", "--quiet", "--initial-branch", Base, repo)
+	g.config()
+	g.write(Added, "new\n")
+	g.git("add", Added)
+	g.write(Untracked, "untracked\n")
+	return repo, g.err
+}
+
+// Stacked builds a repository under dir with branches stacked on each
+// other, one of them on a parent that has since been amended, and returns
+// its path.
+func Stacked(dir string) (string, error) {
+	repo := filepath.Join(dir, "stacked")
+	g := &builder{dir: repo}
+	g.git("init", "--quiet", "--initial-branch", Base, repo)
+	g.config()
+	g.write(Modified, "first\n")
+	g.commit("First commit")
+
+	g.git("switch", "--quiet", "--create", StackBase)
+	g.write("base.txt", "one\n")
+	g.commit("Start the base")
+	g.write("base.txt", "two\n")
+	g.commit("Continue the base")
+	g.git("switch", "--quiet", "--create", StackTop)
+	g.write("top.txt", "top\n")
+	g.commit("Build on the base")
+	g.git("switch", "--quiet", StackBase)
+	g.write("base.txt", "three\n")
+	g.commit("Finish the base")
+
+	g.git("switch", "--quiet", "--create", Amended, Base)
+	g.write("amended.txt", "draft\n")
+	g.commit("Draft")
+	g.git("switch", "--quiet", "--create", OnAmended)
+	g.write("on-amended.txt", "on the draft\n")
+	g.commit("Build on the draft")
+	g.git("switch", "--quiet", Amended)
+	g.write("amended.txt", "final\n")
+	g.git("add", "--all")
+	g.git("commit", "--quiet", "--amend", "--message", "Final")
+	g.commits++
+
+	g.git("switch", "--quiet", "--create", Sibling, Base)
+	g.write("sibling.txt", "sibling\n")
+	g.commit("Work beside the stacks")
+	g.git("switch", "--quiet", Base)
+	return repo, g.err
+}
+
+// Annotated builds a repository under dir with Branch checked out, which
+// adds BranchAdded with a TODO comment and Generated to Base, whose
+// CODEOWNERS gives everything to Owner, and returns its path.
+func Annotated(dir string) (string, error) {
+	repo := filepath.Join(dir, "annotated")
+	g := &builder{dir: repo}
+	g.git("init", "--quiet", "--initial-branch", Base, repo)
+	g.config()
+	g.write("CODEOWNERS", "* "+Owner+"\n")
+	g.write(".gitattributes", "gen/** linguist-generated\n")
+	g.commit("Initial commit")
+
+	g.git("switch", "--quiet", "--create", Branch)
+	g.write(BranchAdded, "package feature\n\n// TODO: "+Todo+"\n")
+	g.write(Generated, "package gen\n")
+	g.commit("Add feature")
+	return repo, g.err
+}
+
+// builder runs the steps that build a repository, stopping at the first
+// error
+type builder struct {
+	dir     string
+	commits int
+	err     error
+}
+
+// config keeps the repository independent of the user's git settings.
+func (g *builder) config() {
+	g.git("config", "user.name", "Fixture")
+	g.git("config", "user.email", "fixture@example.com")
+	g.git("config", "commit.gpgsign", "false")
+	g.git("config", "tag.gpgsign", "false")
+	g.git("config", "core.autocrlf", "false")
+}
+
+func (g *builder) run(mayFail bool, args ...string) {
+	if g.err != nil {
+		return
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.dir
+	if _, err := os.Stat(g.dir); err != nil {
+		cmd.Dir = "" // init creates it
+	}
+	// Fixed, advancing dates so every build makes the same commits
+	date := fmt.Sprintf("2024-01-01T12:%02d:00Z", g.commits)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date, "GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull)
+	output, err := cmd.CombinedOutput()
+	if err != nil && !mayFail {
+		g.err = fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+}
+
+func (g *builder) git(args ...string) {
+	g.run(false, args...)
+}
+
+func (g *builder) mayFail(args ...string) {
+	g.run(true, args...)
+}
+
+func (g *builder) commit(message string) {
+	g.git("add", "--all")
+	g.git("commit", "--quiet", "--message", message)
+	g.commits++
+}
+
+func (g *builder) write(name, content string) {
+	if g.err != nil {
+		return
+	}
+	path := filepath.Join(g.dir, name)
+	if g.err = os.MkdirAll(filepath.Dir(path), 0o755); g.err == nil {
+		g.err = os.WriteFile(path, []byte(content), 0o644)
+	}
+}
+
+func (g *builder) remove(name string) {
+	if g.err == nil {
+		g.err = os.Remove(filepath.Join(g.dir, name))
+	}
+}
+
+func (g *builder) symlink(target, name string) {
+	if g.err == nil {
+		g.err = os.Symlink(target, filepath.Join(g.dir, name))
+	}
+}
@@ -0,0 +1,274 @@
+// Package porcelain parses git's machine-readable output: git status
+// --porcelain=v2 -z (or v1, from git older than 2.11), and git diff
+// --name-status -z and --numstat -z.
+//
+// The -z forms are used throughout because they leave paths unquoted and
+// unescaped, so names containing spaces, tabs, newlines, quotes or
+// non-UTF-8 bytes come through as they are on disk. Parsers never panic
+// and never fail as a whole: an entry that can't be understood is skipped
+// and parsing resumes at the next one.
+package porcelain
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Kind is the type of a status entry, from its first character
+type Kind byte
+
+const (
+	Ordinary  Kind = '1' // changed tracked file
+	Renamed   Kind = '2' // renamed or copied file
+	Unmerged  Kind = 'u' // merge conflict
+	Untracked Kind = '?'
+	Ignored   Kind = '!'
+)
+
+// Entry is one file in git status
+type Entry struct {
+	Kind Kind
+
+	// X and Y are the index and working tree status: one of
+	// ".MTADRCU" for tracked files, "??" and "!!" otherwise
+	X, Y byte
+
+	// Submodule is "N..." for a plain file, or "S" followed by whether
+	// the submodule's commit changed, it has tracked changes and it has
+	// untracked files ("C", "M", "U" or ".")
+	Submodule string
+
+	Path     string
+	OrigPath string // the source of a rename or copy
+	Score    string // similarity of a rename or copy, e.g. "R100"
+}
+
+// IsSubmodule reports whether the entry is a submodule rather than a file
+func (e Entry) IsSubmodule() bool {
+	return strings.HasPrefix(e.Submodule, "S")
+}
+
+// Status is parsed git status output
+type Status struct {
+	Entries []Entry
+
+	// Headers, present with --branch and --show-stash
+	Branch   string // "(detached)" for a detached HEAD
+	OID      string // "(initial)" before the first commit
+	Upstream string
+	Ahead    int
+	Behind   int
+	Stashes  int
+}
+
+// ParseStatus parses the output of git status --porcelain=v2 -z.
+func ParseStatus(data []byte) Status {
+	var s Status
+	fields := strings.Split(string(data), "\x00")
+	for i := 0; i < len(fields); i++ {
+		line := fields[i]
+		if line == "" {
+			continue
+		}
+		if header, ok := strings.CutPrefix(line, "# "); ok {
+			s.header(header)
+			continue
+		}
+
+		e, ok := parseEntry(line)
+		if !ok {
+			continue
+		}
+		if e.Kind == Renamed {
+			// The source path is the next field, empty if the output was
+			// cut off after the entry
+			if i+1 >= len(fields) || fields[i+1] == "" {
+				continue
+			}
+			i++
+			e.OrigPath = fields[i]
+		}
+		s.Entries = append(s.Entries, e)
+	}
+	return s
+}
+
+func (s *Status) header(line string) {
+	name, value, _ := strings.Cut(line, " ")
+	switch name {
+	case "branch.oid":
+		s.OID = value
+	case "branch.head":
+		s.Branch = value
+	case "branch.upstream":
+		s.Upstream = value
+	case "branch.ab":
+		// "+<ahead> -<behind>"
+		ahead, behind, _ := strings.Cut(value, " ")
+		s.Ahead = atoi(strings.TrimPrefix(ahead, "+"))
+		s.Behind = atoi(strings.TrimPrefix(behind, "-"))
+	case "stash":
+		s.Stashes = atoi(value)
+	}
+}
+
+// entryFields is the number of space-separated fields before the path,
+// by kind
+var entryFields = map[Kind]int{
+	Ordinary: 8,  // 1 XY sub mH mI mW hH hI path
+	Renamed:  9,  // 2 XY sub mH mI mW hH hI Xscore path
+	Unmerged: 10, // u XY sub m1 m2 m3 mW h1 h2 h3 path
+}
+
+func parseEntry(line string) (Entry, bool) {
+	if len(line) < 3 || line[1] != ' ' {
+		return Entry{}, false
+	}
+	kind := Kind(line[0])
+	switch kind {
+	case Untracked, Ignored:
+		return Entry{Kind: kind, X: line[0], Y: line[0], Path: line[2:]}, true
+	}
+
+	n, ok := entryFields[kind]
+	if !ok {
+		return Entry{}, false
+	}
+	// Paths may contain spaces, so only split off the fields before it
+	parts := strings.SplitN(line, " ", n+1)
+	if len(parts) != n+1 || len(parts[1]) != 2 || parts[n] == "" {
+		return Entry{}, false
+	}
+	e := Entry{Kind: kind, X: parts[1][0], Y: parts[1][1], Submodule: parts[2], Path: parts[n]}
+	if kind == Renamed {
+		e.Score = parts[8]
+	}
+	return e, true
+}
+
+// ParseStatusV1 parses the output of git status --porcelain -z, for git
+// versions without v2. Entries come out as ParseStatus gives them, with
+// '.' for unchanged columns; v1 has no headers and no submodule state.
+func ParseStatusV1(data []byte) Status {
+	var s Status
+	fields := strings.Split(string(data), "\x00")
+	// Entries are "XY path\0", or "XY new\0old\0" for renames and copies
+	for i := 0; i < len(fields); i++ {
+		line := fields[i]
+		if len(line) < 4 || line[2] != ' ' {
+			continue
+		}
+		x, y := line[0], line[1]
+		e := Entry{Kind: Ordinary, X: x, Y: y, Path: line[3:]}
+		switch {
+		case x == '?' && y == '?':
+			e.Kind = Untracked
+		case x == '!' && y == '!':
+			e.Kind = Ignored
+		case isUnmerged(x, y):
+			e.Kind = Unmerged
+		case x == 'R' || x == 'C' || y == 'R' || y == 'C':
+			if i+1 >= len(fields) || fields[i+1] == "" {
+				continue
+			}
+			i++
+			e.Kind, e.OrigPath = Renamed, fields[i]
+		}
+		if e.Kind != Untracked && e.Kind != Ignored {
+			if e.X == ' ' {
+				e.X = '.'
+			}
+			if e.Y == ' ' {
+				e.Y = '.'
+			}
+		}
+		s.Entries = append(s.Entries, e)
+	}
+	return s
+}
+
+// isUnmerged reports whether XY is one of the merge conflict states
+func isUnmerged(x, y byte) bool {
+	switch string([]byte{x, y}) {
+	case "DD", "AU", "UD", "UA", "DU", "AA", "UU":
+		return true
+	}
+	return false
+}
+
+// FileStatus is one file in git diff --name-status output
+type FileStatus struct {
+	Status   string // e.g. "M", or "R100" with a similarity score
+	Path     string
+	OrigPath string // the source of a rename or copy
+}
+
+// ParseNameStatus parses the output of git diff --name-status -z.
+func ParseNameStatus(data []byte) []FileStatus {
+	var files []FileStatus
+	fields := strings.Split(string(data), "\x00")
+	// Entries are "status\0path\0", or "R100\0old\0new\0" for renames
+	// and copies
+	for i := 0; i+1 < len(fields); i += 2 {
+		status := fields[i]
+		if status == "" {
+			continue
+		}
+		f := FileStatus{Status: status, Path: fields[i+1]}
+		if status[0] == 'R' || status[0] == 'C' {
+			if i+2 >= len(fields) || fields[i+1] == "" {
+				break
+			}
+			f.OrigPath, f.Path = fields[i+1], fields[i+2]
+			i++
+		}
+		if f.Path == "" {
+			continue
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// NumStat is one file in git diff --numstat output
+type NumStat struct {
+	Path     string
+	OrigPath string // the source of a rename or copy
+	Added    int
+	Removed  int
+	Binary   bool // git doesn't count lines in binary files
+}
+
+// ParseNumstat parses the output of git diff --numstat -z.
+func ParseNumstat(data []byte) []NumStat {
+	var stats []NumStat
+	fields := strings.Split(string(data), "\x00")
+	// Entries are "added<tab>removed<tab>path\0", or for renames
+	// "added<tab>removed<tab>\0old\0new\0". Binary files are "-<tab>-"
+	for i := 0; i < len(fields); i++ {
+		parts := strings.SplitN(fields[i], "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		s := NumStat{Path: parts[2], Binary: parts[0] == "-"}
+		if s.Path == "" {
+			if i+2 >= len(fields) {
+				break
+			}
+			s.OrigPath, s.Path = fields[i+1], fields[i+2]
+			i += 2
+			if s.OrigPath == "" || s.Path == "" {
+				continue // cut off
+			}
+		}
+		s.Added, s.Removed = atoi(parts[0]), atoi(parts[1])
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// atoi returns 0 for anything that isn't a number
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,507 @@
+package porcelain
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// z joins records the way git's -z output does, each ending in a NUL
+func z(records ...string) []byte {
+	var b strings.Builder
+	for _, r := range records {
+		b.WriteString(r)
+		b.WriteByte(0)
+	}
+	return []byte(b.String())
+}
+
+const (
+	modes  = "100644 100644 100644"
+	hashes = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391 e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Status
+	}{
+		{
+			name: "empty",
+			data: nil,
+			want: Status{},
+		},
+		{
+			name: "headers",
+			data: z("# branch.oid 1234abcd", "# branch.head main", "# branch.upstream origin/main", "# branch.ab +2 -1", "# stash 3"),
+			want: Status{OID: "1234abcd", Branch: "main", Upstream: "origin/main", Ahead: 2, Behind: 1, Stashes: 3},
+		},
+		{
+			name: "detached before the first commit",
+			data: z("# branch.oid (initial)", "# branch.head (detached)"),
+			want: Status{OID: "(initial)", Branch: "(detached)"},
+		},
+		{
+			name: "modified in the working tree and staged",
+			data: z("1 .M N... "+modes+" "+hashes+" a.txt", "1 M. N... "+modes+" "+hashes+" dir/b.txt"),
+			want: Status{Entries: []Entry{
+				{Kind: Ordinary, X: '.', Y: 'M', Submodule: "N...", Path: "a.txt"},
+				{Kind: Ordinary, X: 'M', Y: '.', Submodule: "N...", Path: "dir/b.txt"},
+			}},
+		},
+		{
+			name: "spaces, tabs, newlines and quotes in paths",
+			data: z("1 A. N... 000000 100644 100644 "+hashes+" with space\tand tab\nand \"newline\".txt", "? new\nline.txt"),
+			want: Status{Entries: []Entry{
+				{Kind: Ordinary, X: 'A', Y: '.', Submodule: "N...", Path: "with space\tand tab\nand \"newline\".txt"},
+				{Kind: Untracked, X: '?', Y: '?', Path: "new\nline.txt"},
+			}},
+		},
+		{
+			name: "rename with tabs and newlines",
+			data: z("2 R. N... "+modes+" "+hashes+" R100 new\tname.txt", "old\nname.txt"),
+			want: Status{Entries: []Entry{
+				{Kind: Renamed, X: 'R', Y: '.', Submodule: "N...", Score: "R100", Path: "new\tname.txt", OrigPath: "old\nname.txt"},
+			}},
+		},
+		{
+			name: "copy",
+			data: z("2 C. N... "+modes+" "+hashes+" C75 copy of a.txt", "a.txt"),
+			want: Status{Entries: []Entry{
+				{Kind: Renamed, X: 'C', Y: '.', Submodule: "N...", Score: "C75", Path: "copy of a.txt", OrigPath: "a.txt"},
+			}},
+		},
+		{
+			name: "rename in the working tree with intent-to-add",
+			data: z("2 .R N... "+modes+" "+hashes+" R90 b.txt", "a.txt"),
+			want: Status{Entries: []Entry{
+				{Kind: Renamed, X: '.', Y: 'R', Submodule: "N...", Score: "R90", Path: "b.txt", OrigPath: "a.txt"},
+			}},
+		},
+		{
+			name: "typechange",
+			data: z("1 .T N... 120000 120000 100644 " + hashes + " link"),
+			want: Status{Entries: []Entry{
+				{Kind: Ordinary, X: '.', Y: 'T', Submodule: "N...", Path: "link"},
+			}},
+		},
+		{
+			name: "submodule states",
+			data: z(
+				"1 .M SC.. 160000 160000 160000 "+hashes+" sub/new-commit",
+				"1 .M S.M. 160000 160000 160000 "+hashes+" sub/modified",
+				"1 .M S..U 160000 160000 160000 "+hashes+" sub/untracked",
+				"1 AM SCMU 000000 160000 160000 "+hashes+" sub/all",
+			),
+			want: Status{Entries: []Entry{
+				{Kind: Ordinary, X: '.', Y: 'M', Submodule: "SC..", Path: "sub/new-commit"},
+				{Kind: Ordinary, X: '.', Y: 'M', Submodule: "S.M.", Path: "sub/modified"},
+				{Kind: Ordinary, X: '.', Y: 'M', Submodule: "S..U", Path: "sub/untracked"},
+				{Kind: Ordinary, X: 'A', Y: 'M', Submodule: "SCMU", Path: "sub/all"},
+			}},
+		},
+		{
+			name: "untracked and ignored",
+			data: z("? notes.txt", "! build/"),
+			want: Status{Entries: []Entry{
+				{Kind: Untracked, X: '?', Y: '?', Path: "notes.txt"},
+				{Kind: Ignored, X: '!', Y: '!', Path: "build/"},
+			}},
+		},
+		{
+			name: "truncated entry is skipped",
+			data: z("1 .M N... 100644", "? after.txt"),
+			want: Status{Entries: []Entry{
+				{Kind: Untracked, X: '?', Y: '?', Path: "after.txt"},
+			}},
+		},
+		{
+			name: "rename cut off before its source",
+			data: []byte("? before.txt\x002 R. N... " + modes + " " + hashes + " R100 new.txt"),
+			want: Status{Entries: []Entry{
+				{Kind: Untracked, X: '?', Y: '?', Path: "before.txt"},
+			}},
+		},
+		{
+			name: "rename cut off after its NUL",
+			data: z("2 R. N... " + modes + " " + hashes + " R100 new.txt"),
+			want: Status{},
+		},
+		{
+			name: "last record without its NUL",
+			data: []byte("? a.txt\x00? b.txt"),
+			want: Status{Entries: []Entry{
+				{Kind: Untracked, X: '?', Y: '?', Path: "a.txt"},
+				{Kind: Untracked, X: '?', Y: '?', Path: "b.txt"},
+			}},
+		},
+		{
+			name: "unknown kinds and garbage are skipped",
+			data: z("x nonsense", "1", "?", "1 .M N... "+modes+" "+hashes+" "),
+			want: Status{},
+		},
+	}
+	for _, pair := range []string{"DD", "AU", "UD", "UA", "DU", "AA", "UU"} {
+		tests = append(tests, struct {
+			name string
+			data []byte
+			want Status
+		}{
+			name: "unmerged " + pair,
+			data: z("u " + pair + " N... 100644 100644 100644 100644 " + hashes + " " + hashes[:40] + " conflict file.txt"),
+			want: Status{Entries: []Entry{
+				{Kind: Unmerged, X: pair[0], Y: pair[1], Submodule: "N...", Path: "conflict file.txt"},
+			}},
+		})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseStatus(tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseStatus(%q)\n got %+v\nwant %+v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStatusV1(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []Entry
+	}{
+		{
+			name: "modified, added and deleted",
+			data: z(" M a.txt", "A  b.txt", "D  c.txt", "MM d.txt"),
+			want: []Entry{
+				{Kind: Ordinary, X: '.', Y: 'M', Path: "a.txt"},
+				{Kind: Ordinary, X: 'A', Y: '.', Path: "b.txt"},
+				{Kind: Ordinary, X: 'D', Y: '.', Path: "c.txt"},
+				{Kind: Ordinary, X: 'M', Y: 'M', Path: "d.txt"},
+			},
+		},
+		{
+			name: "rename and copy with tabs and newlines",
+			data: z("R  new\tname.txt", "old\nname.txt", "C  copy.txt", "a.txt"),
+			want: []Entry{
+				{Kind: Renamed, X: 'R', Y: '.', Path: "new\tname.txt", OrigPath: "old\nname.txt"},
+				{Kind: Renamed, X: 'C', Y: '.', Path: "copy.txt", OrigPath: "a.txt"},
+			},
+		},
+		{
+			name: "typechange",
+			data: z(" T link"),
+			want: []Entry{{Kind: Ordinary, X: '.', Y: 'T', Path: "link"}},
+		},
+		{
+			name: "untracked and ignored",
+			data: z("?? new file.txt", "!! build/"),
+			want: []Entry{
+				{Kind: Untracked, X: '?', Y: '?', Path: "new file.txt"},
+				{Kind: Ignored, X: '!', Y: '!', Path: "build/"},
+			},
+		},
+		{
+			name: "rename cut off before its source",
+			data: []byte(" M a.txt\x00R  new.txt"),
+			want: []Entry{{Kind: Ordinary, X: '.', Y: 'M', Path: "a.txt"}},
+		},
+		{
+			name: "rename cut off after its NUL",
+			data: z("R  new.txt"),
+			want: nil,
+		},
+		{
+			name: "truncated and garbage records are skipped",
+			data: z("M", "MM", "MMx", "?? ok.txt"),
+			want: []Entry{{Kind: Untracked, X: '?', Y: '?', Path: "ok.txt"}},
+		},
+	}
+	for _, pair := range []string{"DD", "AU", "UD", "UA", "DU", "AA", "UU"} {
+		tests = append(tests, struct {
+			name string
+			data []byte
+			want []Entry
+		}{
+			name: "unmerged " + pair,
+			data: z(pair + " conflict.txt"),
+			want: []Entry{{Kind: Unmerged, X: pair[0], Y: pair[1], Path: "conflict.txt"}},
+		})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseStatusV1(tt.data); !reflect.DeepEqual(got.Entries, tt.want) {
+				t.Errorf("ParseStatusV1(%q)\n got %+v\nwant %+v", tt.data, got.Entries, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNameStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []FileStatus
+	}{
+		{
+			name: "plain changes",
+			data: z("M", "a.txt", "A", "new file.txt", "D", "gone.txt", "T", "link"),
+			want: []FileStatus{
+				{Status: "M", Path: "a.txt"},
+				{Status: "A", Path: "new file.txt"},
+				{Status: "D", Path: "gone.txt"},
+				{Status: "T", Path: "link"},
+			},
+		},
+		{
+			name: "rename and copy with tabs and newlines",
+			data: z("R100", "old\tname.txt", "new\nname.txt", "C080", "a.txt", "copy.txt", "M", "after.txt"),
+			want: []FileStatus{
+				{Status: "R100", Path: "new\nname.txt", OrigPath: "old\tname.txt"},
+				{Status: "C080", Path: "copy.txt", OrigPath: "a.txt"},
+				{Status: "M", Path: "after.txt"},
+			},
+		},
+		{
+			name: "unmerged",
+			data: z("U", "conflict.txt"),
+			want: []FileStatus{{Status: "U", Path: "conflict.txt"}},
+		},
+		{
+			name: "rename cut off before its destination",
+			data: z("M", "a.txt", "R100", "old.txt"),
+			want: []FileStatus{{Status: "M", Path: "a.txt"}},
+		},
+		{
+			name: "rename cut off after its NUL",
+			data: z("R100", "old.txt"),
+			want: nil,
+		},
+		{
+			name: "status without a path",
+			data: []byte("M"),
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseNameStatus(tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNameStatus(%q)\n got %+v\nwant %+v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNumstat(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want []NumStat
+	}{
+		{
+			name: "text and binary files",
+			data: z("3\t1\ta.txt", "-\t-\timage.png", "0\t12\tpath with\ttab.txt"),
+			want: []NumStat{
+				{Path: "a.txt", Added: 3, Removed: 1},
+				{Path: "image.png", Binary: true},
+				{Path: "path with\ttab.txt", Removed: 12},
+			},
+		},
+		{
+			name: "rename with tabs and newlines",
+			data: z("1\t1\t", "old\tname.txt", "new\nname.txt", "2\t0\tafter.txt"),
+			want: []NumStat{
+				{Path: "new\nname.txt", OrigPath: "old\tname.txt", Added: 1, Removed: 1},
+				{Path: "after.txt", Added: 2},
+			},
+		},
+		{
+			name: "rename cut off before its destination",
+			data: z("1\t0\ta.txt", "1\t1\t", "old.txt"),
+			want: []NumStat{{Path: "a.txt", Added: 1}},
+		},
+		{
+			name: "garbage is skipped",
+			data: z("nonsense", "1\t2", "4\t5\tok.txt"),
+			want: []NumStat{{Path: "ok.txt", Added: 4, Removed: 5}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseNumstat(tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNumstat(%q)\n got %+v\nwant %+v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+// formatStatus writes s back out as git status --porcelain=v2 -z would,
+// with placeholder modes and hashes, which the parser doesn't keep
+func formatStatus(s Status) []byte {
+	var records []string
+	header := func(name, value string) {
+		if value != "" {
+			records = append(records, "# "+name+" "+value)
+		}
+	}
+	header("branch.oid", s.OID)
+	header("branch.head", s.Branch)
+	header("branch.upstream", s.Upstream)
+	if s.Ahead != 0 || s.Behind != 0 {
+		header("branch.ab", fmt.Sprintf("+%d -%d", s.Ahead, s.Behind))
+	}
+	if s.Stashes != 0 {
+		header("stash", fmt.Sprint(s.Stashes))
+	}
+	for _, e := range s.Entries {
+		xy := string([]byte{e.X, e.Y})
+		switch e.Kind {
+		case Untracked, Ignored:
+			records = append(records, string(e.Kind)+" "+e.Path)
+		case Ordinary:
+			records = append(records, "1 "+xy+" "+e.Submodule+" "+modes+" "+hashes+" "+e.Path)
+		case Renamed:
+			records = append(records, "2 "+xy+" "+e.Submodule+" "+modes+" "+hashes+" "+e.Score+" "+e.Path, e.OrigPath)
+		case Unmerged:
+			records = append(records, "u "+xy+" "+e.Submodule+" 100644 "+modes+" "+hashes+" "+hashes[:40]+" "+e.Path)
+		}
+	}
+	return z(records...)
+}
+
+// formatStatusV1 writes entries back out as git status --porcelain -z would
+func formatStatusV1(s Status) []byte {
+	var records []string
+	for _, e := range s.Entries {
+		records = append(records, string([]byte{e.X, e.Y})+" "+e.Path)
+		if e.Kind == Renamed {
+			records = append(records, e.OrigPath)
+		}
+	}
+	return z(records...)
+}
+
+// formatNameStatus writes files back out as git diff --name-status -z would
+func formatNameStatus(files []FileStatus) []byte {
+	var records []string
+	for _, f := range files {
+		if f.Status[0] == 'R' || f.Status[0] == 'C' {
+			records = append(records, f.Status, f.OrigPath, f.Path)
+		} else {
+			records = append(records, f.Status, f.Path)
+		}
+	}
+	return z(records...)
+}
+
+// formatNumstat writes stats back out as git diff --numstat -z would
+func formatNumstat(stats []NumStat) []byte {
+	var records []string
+	for _, s := range stats {
+		counts := fmt.Sprintf("%d\t%d\t", s.Added, s.Removed)
+		if s.Binary {
+			counts = fmt.Sprintf("-\t%d\t", s.Removed)
+		}
+		if s.OrigPath != "" {
+			records = append(records, counts, s.OrigPath, s.Path)
+		} else {
+			records = append(records, counts+s.Path)
+		}
+	}
+	return z(records...)
+}
+
+// seeds are real and broken outputs for the fuzzers to start from
+var seeds = [][]byte{
+	z("# branch.oid 1234abcd", "# branch.head main", "# branch.ab +2 -1", "# stash 1"),
+	z("1 .M N... "+modes+" "+hashes+" a b.txt", "2 R. N... "+modes+" "+hashes+" R100 new\tname", "old\nname"),
+	z("u UU N... 100644 "+modes+" "+hashes+" "+hashes[:40]+" c.txt", "? d.txt", "! e/"),
+	z(" M a.txt", "R  new.txt", "old.txt", "?? f.txt", "UU g.txt"),
+	z("R100", "old", "new", "M", "a.txt"),
+	z("1\t2\ta.txt", "-\t-\tb.png", "0\t0\t", "old", "new"),
+	[]byte("2 R. N... " + modes + " " + hashes + " R100 cut"),
+	[]byte("\x00\x00\t\t\x00"),
+}
+
+// FuzzParseStatus checks ParseStatus never panics, and that writing what
+// it parsed back out and parsing that gives the same result.
+func FuzzParseStatus(f *testing.F) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s := ParseStatus(data)
+		for _, e := range s.Entries {
+			if e.Path == "" || (e.Kind == Renamed) != (e.OrigPath != "") {
+				t.Fatalf("entry with no path, or no source for a rename: %+v", e)
+			}
+		}
+		if again := ParseStatus(formatStatus(s)); !reflect.DeepEqual(again, s) {
+			t.Fatalf("round trip changed\n%+v\nto\n%+v", s, again)
+		}
+	})
+}
+
+// FuzzParseStatusV1 checks ParseStatusV1 never panics, only gives the
+// kinds v2 does with '.' for unchanged columns, and round-trips.
+func FuzzParseStatusV1(f *testing.F) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s := ParseStatusV1(data)
+		for _, e := range s.Entries {
+			if e.Path == "" {
+				t.Fatalf("entry with no path: %+v", e)
+			}
+			if e.Kind != Untracked && e.Kind != Ignored && (e.X == ' ' || e.Y == ' ') {
+				t.Fatalf("blank status column: %+v", e)
+			}
+		}
+		if again := ParseStatusV1(formatStatusV1(s)); !reflect.DeepEqual(again, s) {
+			t.Fatalf("round trip changed\n%+v\nto\n%+v", s, again)
+		}
+	})
+}
+
+// FuzzParseNameStatus checks ParseNameStatus never panics, gives every
+// file a status and path, and round-trips.
+func FuzzParseNameStatus(f *testing.F) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		files := ParseNameStatus(data)
+		for _, file := range files {
+			if file.Status == "" || file.Path == "" {
+				t.Fatalf("file with no status or path: %+v", file)
+			}
+		}
+		if again := ParseNameStatus(formatNameStatus(files)); !reflect.DeepEqual(again, files) {
+			t.Fatalf("round trip changed\n%+v\nto\n%+v", files, again)
+		}
+	})
+}
+
+// FuzzParseNumstat checks ParseNumstat never panics, never counts lines
+// in binary files, and round-trips.
+func FuzzParseNumstat(f *testing.F) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		stats := ParseNumstat(data)
+		for _, s := range stats {
+			if s.Path == "" {
+				t.Fatalf("file with no path: %+v", s)
+			}
+			if s.Binary && s.Added != 0 {
+				t.Fatalf("binary file with added lines: %+v", s)
+			}
+		}
+		if again := ParseNumstat(formatNumstat(stats)); !reflect.DeepEqual(again, stats) {
+			t.Fatalf("round trip changed\n%+v\nto\n%+v", stats, again)
+		}
+	})
+}
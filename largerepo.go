@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// builtinFSMonitor reports whether the installed git has its own file
+// system monitor, which core.fsmonitor=true starts on first use. Builds
+// without it (older git, and Linux before it was supported there) would
+// warn on every command if it were turned on.
+var builtinFSMonitor = sync.OnceValue(func() bool {
+	output, err := gitCommand("version", "--build-options").Output()
+	return err == nil && strings.Contains(string(output), "fsmonitor--daemon")
+})
+
+// largeRepoOverrides returns the settings large-repo mode adds: git's
+// file system monitor, so status asks it what changed rather than
+// checking every file, and the untracked cache, so it only rereads
+// directories that changed. Settings already made in git config, such as
+// a Watchman hook in core.fsmonitor, are kept.
+func largeRepoOverrides() [][2]string {
+	var overrides [][2]string
+	if builtinFSMonitor() && GitConfig("core.fsmonitor") == "" {
+		overrides = append(overrides, [2]string{"core.fsmonitor", "true"})
+	}
+	if gitAtLeast(2, 8) && GitConfig("core.untrackedCache") == "" {
+		overrides = append(overrides, [2]string{"core.untrackedCache", "true"})
+	}
+	return overrides
+}
+
+// noOptionalLocks runs the git commands vigil runs with
+// --no-optional-locks, so polling status never rewrites the index, or
+// contends for its lock with the commands you run.
+var noOptionalLocks bool
+
+// startLargeRepo runs one status that's allowed to write the index, which
+// adds the untracked cache and the monitor's token to it and starts the
+// monitor, then runs the rest with --no-optional-locks. A read-only
+// repository is left as it is.
+func startLargeRepo() {
+	if !IsReadOnly() {
+		gitCommand("status", "--porcelain", "-uall").Run()
+	}
+	noOptionalLocks = gitAtLeast(2, 15)
+}
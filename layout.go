@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Panel names usable in a layout
+const (
+	panelChanges = "changes" // uncommitted changes
+	panelBranch  = "branch"  // files changed on the branch vs its base
+	panelCommits = "commits" // commits on the branch since its merge base
+)
+
+// Header segment names usable in a layout
+const (
+	segmentBanner  = "banner"
+	segmentPath    = "path"
+	segmentBranch  = "branch"
+	segmentCommit  = "commit"  // last commit, under the branch line
+	segmentRelease = "release" // git describe distance from the last tag
+	segmentWatch   = "watch"   // state of the watch command, when configured
+
+	// Split out of the branch line, which leaves them out when these are
+	// in the header too
+	segmentUpstream = "upstream" // ahead/behind and when it was last fetched
+	segmentStashes  = "stashes"
+
+	segmentPR  = "pr"  // the branch's pull request, from the gh CLI
+	segmentCI  = "ci"  // check results on the pull request
+	segmentAge = "age" // how long ago the last commit was made
+
+	segmentOwners = "owners" // CODEOWNERS of the branch files, as reviewers
+
+	segmentDebug = "debug" // average timings of refreshes, fetches and polls
+)
+
+var knownPanels = []string{panelChanges, panelBranch, panelCommits}
+var knownSegments = []string{
+	segmentBanner, segmentPath, segmentBranch, segmentCommit, segmentRelease, segmentWatch,
+	segmentUpstream, segmentStashes, segmentPR, segmentCI, segmentAge, segmentOwners, segmentDebug,
+}
+
+// blockSegments are rendered as consecutive lines of one header block
+var blockSegments = []string{
+	segmentBranch, segmentCommit, segmentRelease, segmentWatch,
+	segmentUpstream, segmentStashes, segmentPR, segmentCI, segmentAge, segmentOwners, segmentDebug,
+}
+
+// Layout is a named arrangement of panels and header segments
+type Layout struct {
+	Panels []string       `json:"panels"` // in display order
+	Sizes  map[string]int `json:"sizes"`  // max rows per panel; 0 or missing means unlimited
+	Header []string       `json:"header"` // in display order
+}
+
+// builtinLayouts are always available and can be overridden by config
+var builtinLayouts = map[string]Layout{
+	"monitor": {
+		Panels: []string{panelChanges, panelBranch, panelCommits},
+		Header: []string{segmentBanner, segmentPath, segmentBranch, segmentCommit, segmentRelease, segmentWatch},
+	},
+	"review": {
+		Panels: []string{panelBranch, panelCommits, panelChanges},
+		Sizes:  map[string]int{panelChanges: 5},
+		Header: []string{segmentBranch, segmentCommit, segmentRelease, segmentWatch, segmentOwners},
+	},
+	"commit": {
+		Panels: []string{panelChanges},
+		Header: []string{segmentPath, segmentBranch, segmentCommit, segmentWatch},
+	},
+}
+
+// validateHeader checks segment names, shared by layouts and the
+// top-level header setting.
+func validateHeader(segments []string) error {
+	for _, s := range segments {
+		if !slices.Contains(knownSegments, s) {
+			return fmt.Errorf("unknown header segment %q", s)
+		}
+	}
+	return nil
+}
+
+func (l Layout) validate() error {
+	for _, p := range l.Panels {
+		if !slices.Contains(knownPanels, p) {
+			return fmt.Errorf("unknown panel %q", p)
+		}
+	}
+	for p := range l.Sizes {
+		if !slices.Contains(knownPanels, p) {
+			return fmt.Errorf("unknown panel %q in sizes", p)
+		}
+	}
+	return validateHeader(l.Header)
+}
+
+func (l Layout) hasSegment(name string) bool {
+	return slices.Contains(l.Header, name)
+}
+
+// mergeLayouts returns the built-in presets with user presets layered on
+// top. A non-nil header replaces every preset's header segments.
+func mergeLayouts(user map[string]Layout, header []string) map[string]Layout {
+	layouts := make(map[string]Layout, len(builtinLayouts)+len(user))
+	for name, l := range builtinLayouts {
+		layouts[name] = l
+	}
+	for name, l := range user {
+		layouts[name] = l
+	}
+	if header != nil {
+		for name, l := range layouts {
+			l.Header = header
+			layouts[name] = l
+		}
+	}
+	return layouts
+}
+
+// withSegment adds segment to the end of every layout's header that
+// doesn't already have it.
+func withSegment(layouts map[string]Layout, segment string) {
+	for name, l := range layouts {
+		if !l.hasSegment(segment) {
+			l.Header = append(slices.Clone(l.Header), segment)
+			layouts[name] = l
+		}
+	}
+}
+
+// layoutNames returns preset names in switching order: built-ins first,
+// then user presets alphabetically.
+func layoutNames(layouts map[string]Layout) []string {
+	names := []string{"monitor", "review", "commit"}
+	var extra []string
+	for name := range layouts {
+		if _, ok := builtinLayouts[name]; !ok {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	return append(names, extra...)
+}
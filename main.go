@@ -1,20 +1,26 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-)
+	"github.com/charmbracelet/x/ansi"
 
-const asciiArt = `
- █░█ █ █▀▀ █ █░░
- ▀▄▀ █ █▄█ █ █▄▄
-`
+	"vigil/internal/fixture"
+)
 
 // Styles
 var (
@@ -44,63 +50,516 @@ var (
 	statusRenamed = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("39"))
 
+	statusConflict = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("196"))
+
+	commitHashStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214"))
+
+	reviewedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("241")).
+			Strikethrough(true)
+
+	generatedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("241"))
+
+	ownerStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("110"))
+
+	confirmStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("214"))
+
+	pinStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("205"))
+
+	markStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("39"))
+
+	tagStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("141"))
+
+	noteStyle = lipgloss.NewStyle().
+			Italic(true).
+			Foreground(lipgloss.Color("109"))
+
+	dirStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("39"))
+
+	focusStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("205"))
+
 	fileStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("252"))
 
 	helpStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("241"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("196"))
+)
+
+// narrowWidth is the terminal width below which vigil switches to the
+// condensed layout, e.g. in a tmux sidebar pane.
+const narrowWidth = 60
+
+// viewMode selects what the body shows
+type viewMode int
+
+const (
+	viewFiles viewMode = iota
+	viewWorktrees
+	viewHelp
+	viewChangelog
+	viewCompare
+	viewStack
+	viewPick
+	viewOutput
+	viewToolLog
+	viewHistory
+	viewReflog
+	viewBookmarks
+	viewMenu
+	viewUpstream
+	viewHealth
 )
 
 // Messages
 type tickMsg struct{}
-type fetchTickMsg struct {
-	ahead  int
-	behind int
-	err    error
-}
 
 // Model
 type model struct {
 	dir         string
 	branch      string
 	changes     []FileChange
+	summary     StatusSummary
+	statusOpts  StatusOptions // untracked and ignored files to include in changes
+	tool        vcsTool       // jj or git-branchless, if either manages the repository
+	lastCommit  Commit
+	hasCommit   bool
+	release     Release
+	hasRelease  bool
 	branchFiles []BranchFile
+	rebase      RebaseState // the rebase stopped partway, when rebasing
+	rebasing    bool
+	picking     bool     // a cherry-pick stopped partway
+	commits     []Commit // on the branch since its merge base, newest first
+	base        string   // comparison base for branch files; empty means default branch
+	upstream    string
 	ahead       int
 	behind      int
 	upstreamErr error
-	viewport viewport.Model
-	ready    bool
-	width    int
-	height   int
+	remotes     []RemoteDivergence // other remotes' main branches, for forks
+	readOnly    bool               // the repository is on a read-only filesystem
+	health      healthProblem      // the failed startup check, on the health screen
+	metrics     metrics            // timings for the debug header segment
+	now         func() time.Time   // the clock relative times are shown against
+	counted     bool               // ahead and behind have been counted at least once
+	viewport    viewport.Model
+	body        string // the viewport's content, as last rendered
+	ready       bool
+	width       int
+	height      int
+
+	// Current view and list selection
+	view           viewMode
+	files          fileList // the files view's panels and cursor
+	cursor         int      // selected row in list views
+	worktrees      []Worktree
+	changelog      changelogState
+	compare        compareState
+	stack          stackState
+	pick           pickState
+	output         outputState
+	toolLog        toolLogState
+	history        historyState
+	reflog         []ReflogEntry
+	palette        paletteState
+	upstreamPicker upstreamState
+	menu           menuState
+
+	state      RepoState // persisted per repository
+	flash      string    // one-off message shown in the footer until the next key
+	flashIsErr bool
+
+	// File list order
+	sort     sortOrder
+	sortKeys sortKeys
+
+	// Changes marked with space for a batch stage, unstage, discard or stash
+	marked map[string]bool
+
+	// How often each changed file has been edited recently
+	heat map[string]fileHeat
+
+	// Tree rendering of file lists
+	tree      bool
+	collapsed map[string]bool // panel:dir -> collapsed
+
+	// Last author of each file, shown after its name
+	showAuthors bool
+	authors     *fileAuthors
+
+	// Files whose changes are all whitespace, badged or hidden
+	whitespace whitespaceOnly
+	hideSpace  bool
+
+	// Cruft found in uncommitted changes, by file
+	cruft       map[string][]string
+	cruftChecks []cruftPattern
+
+	// Possible credentials in the staged changes, when scanning is on
+	secrets    []secretFinding
+	secretScan SecretsConfig
+
+	// Branch commit subjects must match this, when set
+	subjects *regexp.Regexp
+
+	// Branch files .gitattributes marks as generated, grouped last
+	generated map[string]bool
+	showGen   bool // the group is expanded
+
+	// Refs fingerprint the branch files and commits were diffed at, and
+	// whether the last refresh found it changed
+	branchKey   string
+	branchMoved bool
+
+	// Counts refreshes, so a background one is dropped if another ran
+	// while it did; stopRefresh cancels the one in flight
+	refreshGen  int
+	stopRefresh context.CancelFunc
+
+	// The header above the viewport, and whether it shows diagnostics
+	header header
+
+	// Owners of the branch files from CODEOWNERS, by path; nil without it
+	owners map[string][]string
+
+	// TODO comments added on the branch, listed under Branch Files
+	todos     branchTodos
+	showTodos bool // the group is expanded
+
+	// Binary and large files, labeled and not diffed
+	sizes     fileSizes
+	largeSize int64 // bytes from which a file is large; 0 for no limit
+	warnLarge bool  // ask before staging large files
+
+	syntax bool       // highlight code in diffs
+	split  bool       // show diffs side by side
+	pager  string     // diff_pager command, if any
+	paged  *pagedDiff // its last output
+
+	// Layout presets
+	layouts    map[string]Layout
+	layoutName string
+	layout     Layout
+
+	// Focus-follows-activity
+	follow bool
+	focus  string // panel that changed most recently
+
+	autoFetch   bool        // fetch in the background every couple of minutes
+	daemon      *daemonLink // set while a vigil daemon serves the repository
+	lastFetched time.Time   // the last fetch that worked
+	fetchFails  int         // fetches failed in a row
+	fetchErr    error       // why the last one failed
+
+	// Branches with no commits for this long are marked stale in the stack
+	// view; 0 turns the mark off
+	staleAfter time.Duration
+
+	// Background push/pull
+	pullMode string // rebase, merge, or empty for git's default
+	jobs     jobs   // what runs in the background, and its spinner
+
+	// User-defined commands bound to keys
+	commands []Command
+
+	// Editor setting for opening files; empty means $VISUAL or $EDITOR
+	editor string
+
+	// Repositories to switch between
+	bookmarks []Bookmark
+
+	// Repositories left to open a nested repository or submodule, the
+	// latest last; esc goes back
+	outer []string
+
+	// The branch's pull request, for the pr and ci header segments
+	pr    PullRequest
+	hasPR bool
+
+	// Command run on working tree changes
+	watch watchState
+
+	// Terminal title and notifications
+	setTitle   bool
+	title      string // last title set
+	notifyMode string
+
+	// URLs posted to on repository events, and chat alerts
+	webhooks    []Webhook
+	webhookSent []map[string]time.Time // per webhook, when each event was last posted
+	webhookErrs chan error
+	protected   []string // branch patterns for the dirty-protected event, kept when deleting merged branches
+	alerts      []ChatAlert
+	alertStates []alertState
+
+	// --status-file output
+	statusPath string
+	lastStatus []byte // last status written, without its timestamp
+
+	// --record output, or the recording being replayed instead of git
+	recorder *recorder
+	replay   *replayer
+
+	// Pending yes/no confirmation, or pick from a few options
+	confirm *confirmation
+	choice  *choice
+
+	// Single-line text prompt shown in the footer
+	prompting bool
+	input     textinput.Model
+	inputErr  string
+	onSubmit  submitFunc
+
+	// Fuzzy filter narrowing the file lists
+	filter      string
+	filtering   bool // the filter is being typed
+	filterInput textinput.Model
 }
 
-func initialModel() model {
+func initialModel(cfg Config, state RepoState) model {
+	input := textinput.New()
+	input.CharLimit = 256
+
+	layouts := mergeLayouts(cfg.Layouts, cfg.Header)
+	if cfg.Debug {
+		withSegment(layouts, segmentDebug)
+	}
+	webhookSent := make([]map[string]time.Time, len(cfg.Webhooks))
+	for i := range webhookSent {
+		webhookSent[i] = make(map[string]time.Time)
+	}
+	statusOpts := cfg.statusOptions()
+	changes, summary := GetGitStatus(statusOpts)
+	readOnly := IsReadOnly()
+	cruftChecks, _ := cruftPatterns(cfg.Cruft) // validated on load
+	subjects, _ := subjectPattern(cfg.CommitSubjects)
+	lastCommit, hasCommit := GetLastCommit()
+	release, hasRelease := GetRelease()
+	rebase, rebasing := GetRebaseState()
+
 	return model{
+		now:         time.Now,
 		branch:      GetCurrentBranch(),
-		changes:     GetGitStatus(),
-		branchFiles: GetBranchDiffFiles(),
+		changes:     changes,
+		summary:     summary,
+		statusOpts:  statusOpts,
+		tool:        detectTool(),
+		lastCommit:  lastCommit,
+		hasCommit:   hasCommit,
+		release:     release,
+		hasRelease:  hasRelease,
+		branchFiles: GetBranchDiffFiles(cfg.Base),
+		branchMoved: true, // for the loaders run before the first refresh
+		rebase:      rebase,
+		rebasing:    rebasing,
+		picking:     CherryPickInProgress(),
+		commits:     GetBranchCommits(cfg.Base),
+		base:        cfg.Base,
+		layouts:     layouts,
+		layoutName:  cfg.Layout,
+		layout:      layouts[cfg.Layout],
+		follow:      cfg.FollowActivity,
+		pullMode:    cfg.Pull,
+		staleAfter:  time.Duration(cfg.StaleDays) * 24 * time.Hour,
+		autoFetch:   cfg.AutoFetch && !readOnly, // fetching writes refs
+		readOnly:    readOnly,
+		jobs:        newJobs(cfg.AutoFetch && !readOnly), // Init starts the first fetch
+		state:       state,
+		tree:        cfg.Tree,
+		showAuthors: cfg.Authors,
+		syntax:      cfg.Syntax,
+		pager:       cfg.DiffPager,
+		paged:       &pagedDiff{},
+		largeSize:   int64(cfg.LargeFileKB) * 1024,
+		warnLarge:   cfg.WarnLargeFiles,
+		cruftChecks: cruftChecks,
+		secretScan:  cfg.Secrets,
+		subjects:    subjects,
+		authors:     &fileAuthors{},
+		collapsed:   make(map[string]bool),
+		marked:      make(map[string]bool),
+		input:       input,
+		filterInput: newFilterInput(),
+		commands:    cfg.Commands,
+		editor:      cfg.Editor,
+		bookmarks:   cfg.Bookmarks,
+		watch:       newWatchState(cfg.Watch),
+		setTitle:    cfg.Title,
+		notifyMode:  cfg.Notify,
+		webhooks:    cfg.Webhooks,
+		webhookSent: webhookSent,
+		protected:   cfg.Protected,
+		webhookErrs: make(chan error, 1),
+		alerts:      cfg.Alerts,
+		alertStates: make([]alertState, len(cfg.Alerts)),
 	}
 }
 
-func tick() tea.Cmd {
-	return tea.Tick(3*time.Second, func(t time.Time) tea.Msg {
-		return tickMsg{}
-	})
+// baseName returns the ref branch files are compared against.
+func (m model) baseName() string {
+	if m.base != "" {
+		return m.base
+	}
+	return GetDefaultBranch()
 }
 
-func fetchUpstream() tea.Msg {
-	ahead, behind, err := GetCommitsAheadBehind()
-	return fetchTickMsg{ahead: ahead, behind: behind, err: err}
+// cycleLayout switches to the next (or previous) layout preset.
+func (m *model) cycleLayout(step int) {
+	names := layoutNames(m.layouts)
+	i := slices.Index(names, m.layoutName)
+	i = (i + step + len(names)) % len(names)
+	m.layoutName = names[i]
+	m.layout = m.layouts[m.layoutName]
+	m.viewport.GotoTop()
+	m.resize()
 }
 
-func scheduleFetch() tea.Cmd {
-	return tea.Tick(2*time.Minute, func(t time.Time) tea.Msg {
-		return fetchUpstream()
+// refreshWith runs load, which replaces what the model knows of the
+// repository, then brings everything that depends on it up to date.
+func (m *model) refreshWith(load func()) {
+	prevChanges, prevBranchFiles, prevSummary := m.changes, m.branchFiles, m.summary
+	prevBranch, prevCommit, hadCommit := m.branch, m.lastCommit, m.hasCommit
+	anchor := m.anchorSelection()
+
+	load()
+	if !slices.Equal(m.changes, prevChanges) || m.summary != prevSummary {
+		m.metrics.changed(m.now())
+	}
+	m.pruneMarked()
+	m.trackHeat()
+	m.restoreSelection(anchor)
+
+	if m.follow {
+		m.followActivity(prevChanges, prevBranchFiles)
+	}
+	if prevSummary.Conflicts == 0 && m.summary.Conflicts > 0 {
+		msg := plural(m.summary.Conflicts, "conflicted file") + " on " + m.branch
+		m.alert("Merge conflicts", msg)
+		m.emit(eventConflicts, msg)
+	}
+	if m.hasCommit && m.branch == prevBranch && (!hadCommit || m.lastCommit.Hash != prevCommit.Hash) {
+		// One new commit on the same branch; pulls and resets move HEAD
+		// further or sideways
+		if n, err := CountCommits(prevCommit.Hash, "HEAD"); !hadCommit || err == nil && n == 1 {
+			m.emit(eventCommit, m.lastCommit.Hash+" "+m.lastCommit.Subject)
+		}
+	}
+	wasDirty := prevSummary.Dirty() && protected(prevBranch, m.protected)
+	if !wasDirty && m.summary.Dirty() && protected(m.branch, m.protected) {
+		m.emit(eventDirty, "uncommitted changes on "+m.branch)
+	}
+	m.publish()
+}
+
+// publish passes a status update on to everything outside vigil that
+// follows it.
+func (m *model) publish() {
+	m.checkAlerts()
+	m.updateTitle()
+	if err := m.writeStatusFile(); err != nil {
+		m.notifyErr(err)
+	}
+	if err := m.record(); err != nil {
+		m.notifyErr(err)
+	}
+	if err := m.webhookErr(); err != nil {
+		m.notifyErr(err)
+	}
+}
+
+// narrow reports whether the condensed layout should be used.
+func (m model) narrow() bool {
+	return m.width > 0 && m.width < narrowWidth
+}
+
+// resize fits the viewport between the header and footer and re-renders
+// the body, leaving the viewport alone when the body hasn't changed.
+func (m *model) resize() {
+	headerHeight := strings.Count(m.fillHeader().View(), "\n")
+	footerHeight := 2 // Help text
+	verticalMargin := headerHeight + footerHeight
+
+	m.viewport.Width = m.width
+	m.viewport.Height = max(m.height-verticalMargin, 0)
+	m.syncFiles()
+	if body := m.renderBody(); body != m.body {
+		m.body = body
+		m.viewport.SetContent(body)
+	}
+}
+
+// notify shows a one-off message in the footer.
+func (m *model) notify(msg string) {
+	m.flash = msg
+	m.flashIsErr = false
+}
+
+// notifyErr shows an error in the footer.
+func (m *model) notifyErr(err error) {
+	m.flash = "Error: " + err.Error()
+	m.flashIsErr = true
+}
+
+// scrollTo scrolls the viewport just enough to show the given body line.
+func (m *model) scrollTo(line int) {
+	if line < m.viewport.YOffset {
+		m.viewport.SetYOffset(line)
+	} else if line >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(line - m.viewport.Height + 1)
+	}
+}
+
+// redraw runs a periodic refresh. Repainting the whole screen every time
+// flickers on some terminals and drops any mouse selection, so it only
+// does when something changed.
+func (m *model) redraw(refresh func()) tea.Cmd {
+	branch, before := m.branch, m.View()
+	refresh()
+	var cmds []tea.Cmd
+	if m.View() != before {
+		cmds = append(cmds, tea.ClearScreen)
+	}
+	if m.branch != branch {
+		cmds = append(cmds, m.loadPR())
+	}
+	return tea.Batch(cmds...)
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(3*time.Second, func(t time.Time) tea.Msg {
+		return tickMsg{}
 	})
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(tick(), tea.EnterAltScreen, fetchUpstream)
+	if m.replay != nil {
+		return tea.Batch(tick(), tea.EnterAltScreen, m.replay.next())
+	}
+	var watch tea.Cmd
+	if m.watch.enabled() {
+		watch = m.watch.scan()
+	}
+	if m.jobs.fetching {
+		return tea.Batch(tick(), tea.EnterAltScreen, fetchUpstream, m.jobs.spinner.Tick, watch, m.loadPR())
+	}
+	return tea.Batch(tick(), tea.EnterAltScreen, countUpstream, watch, m.loadPR())
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -109,58 +568,152 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "q", "ctrl+c", "esc":
-			return m, tea.Quit
-		case "up", "k":
-			m.viewport.LineUp(1)
-		case "down", "j":
-			m.viewport.LineDown(1)
-		case "pgup":
-			m.viewport.HalfViewUp()
-		case "pgdown":
-			m.viewport.HalfViewDown()
-		case "r":
-			m.branch = GetCurrentBranch()
-			m.changes = GetGitStatus()
-			m.branchFiles = GetBranchDiffFiles()
-			m.viewport.SetContent(m.renderBody())
-			return m, tea.ClearScreen
+		if m.prompting {
+			return m.updatePrompt(msg)
+		}
+		if m.filtering {
+			return m.updateFilter(msg)
+		}
+		m.flash = ""
+		if m.confirm != nil {
+			return m.updateConfirm(msg)
+		}
+		if m.choice != nil {
+			return m.updateChoice(msg)
+		}
+		if msg.String() == diagnosticsKey {
+			var cmd tea.Cmd
+			m.header, cmd = m.header.Update(msg)
+			m.resize()
+			return m, cmd
 		}
+		if m.replay != nil && !replayKeys[msg.String()] {
+			m.notify("Not available while replaying")
+			return m, nil
+		}
+		if m.view == viewFiles || m.view == viewStack {
+			if c, ok := m.customCommand(msg.String()); ok {
+				return m, m.runCommand(c)
+			}
+		}
+		switch m.view {
+		case viewWorktrees:
+			return m.updateWorktrees(msg)
+		case viewHelp:
+			return m.updateHelp(msg)
+		case viewChangelog:
+			return m.updateChangelog(msg)
+		case viewCompare:
+			return m.updateCompare(msg)
+		case viewStack:
+			return m.updateStack(msg)
+		case viewPick:
+			return m.updatePick(msg)
+		case viewOutput:
+			return m.updateOutput(msg)
+		case viewToolLog:
+			return m.updateToolLog(msg)
+		case viewHistory:
+			return m.updateHistory(msg)
+		case viewReflog:
+			return m.updateReflog(msg)
+		case viewBookmarks:
+			return m.updateBookmarks(msg)
+		case viewUpstream:
+			return m.updateUpstream(msg)
+		case viewHealth:
+			return m.updateHealth(msg)
+		case viewMenu:
+			return m.updateMenu(msg)
+		}
+		return m.updateFiles(msg)
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 
-		headerHeight := 8 // ASCII art + path + branch + spacing
-		footerHeight := 2 // Help text
-		verticalMargin := headerHeight + footerHeight
-
 		if !m.ready {
-			m.viewport = viewport.New(msg.Width, msg.Height-verticalMargin)
-			m.viewport.SetContent(m.renderBody())
+			m.viewport = viewport.New(msg.Width, msg.Height)
+			m.body = "" // not yet in this viewport
 			m.ready = true
-		} else {
-			m.viewport.Width = msg.Width
-			m.viewport.Height = msg.Height - verticalMargin
-			m.viewport.SetContent(m.renderBody())
 		}
+		m.resize()
 
 	case tickMsg:
-		m.branch = GetCurrentBranch()
-		m.changes = GetGitStatus()
-		m.branchFiles = GetBranchDiffFiles()
-		m.viewport.SetContent(m.renderBody())
-		cmds = append(cmds, tick(), tea.ClearScreen)
+		if m.view == viewHealth {
+			m.recheckHealth()
+		}
+		if m.replay == nil {
+			// The next tick waits for this refresh to finish, so on a slow
+			// disk refreshes don't pile up
+			if m.daemon != nil {
+				return m, queryDaemonStatus
+			}
+			return m, m.refreshInBackground()
+		}
+		cmds = append(cmds, m.redraw(m.refresh), tick())
+
+	case refreshedMsg:
+		cmds = append(cmds, tick())
+		if msg.ok && msg.gen == m.refreshGen {
+			cmds = append(cmds, m.redraw(func() { m.refreshWith(func() { m.setRepoState(msg.state) }) }))
+		} else {
+			debugLog.Debug("refresh superseded", "queries", msg.state.took)
+		}
+
+	case daemonStatusMsg:
+		return m, m.daemonStatus(msg)
+
+	case prLoadedMsg:
+		m.setPR(msg)
+		m.resize()
+		return m, nil
+
+	case replayMsg:
+		m.replay.advance()
+		m.refresh()
+		return m, m.replay.next()
+
+	case fetchDueMsg, fetchTickMsg, upstreamMsg:
+		m, cmd = m.updateFetch(msg)
+		cmds = append(cmds, cmd)
 
-	case fetchTickMsg:
-		m.ahead = msg.ahead
-		m.behind = msg.behind
-		m.upstreamErr = msg.err
-		cmds = append(cmds, scheduleFetch())
+	case stackLoadedMsg:
+		m.stack = stackState{branches: msg}
+		m.cursor = min(m.cursor, max(len(msg)-1, 0))
+		m.resize()
+		return m, nil
+
+	case watchScanMsg:
+		return m, m.updateWatch(msg)
+
+	case watchDoneMsg:
+		m.watchDone(msg)
+		m.resize()
+		return m, nil
+
+	case commandDoneMsg:
+		m.commandDone(msg)
+		return m, tea.ClearScreen
+
+	case editorDoneMsg:
+		m.editorDone(msg)
+		return m, nil
+
+	case sequenceDoneMsg:
+		return m, m.sequenceDone(msg)
+
+	case bundleVerifiedMsg:
+		m.confirmImportBundle(msg)
+		return m, nil
+
+	case spinner.TickMsg, opDoneMsg:
+		m, cmd = m.updateOps(msg)
+		return m, cmd
 	}
 
-	if m.ready {
+	// Keys are handled above; the viewport only needs mouse scrolling
+	if _, isKey := msg.(tea.KeyMsg); m.ready && !isKey {
 		m.viewport, cmd = m.viewport.Update(msg)
 		cmds = append(cmds, cmd)
 	}
@@ -168,117 +721,306 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
-func (m model) View() string {
-	if !m.ready {
-		return "Initializing..."
+// setBase changes the comparison base for branch files.
+func (m *model) setBase(base string) (tea.Cmd, error) {
+	if base != "" && !RefExists(base) {
+		return nil, fmt.Errorf("unknown ref %q", base)
 	}
+	m.base = base
+	return nil, nil
+}
 
-	// Header (rendered outside viewport)
-	var header strings.Builder
-	header.WriteString(asciiStyle.Render(asciiArt))
-	header.WriteString("\n")
-	header.WriteString(pathStyle.Render(m.dir))
-	header.WriteString("\n\n")
-	header.WriteString("Branch: ")
-	header.WriteString(branchStyle.Render(m.branch))
-	if m.upstreamErr != nil {
-		header.WriteString(helpStyle.Render(" (no upstream)"))
-	} else if m.ahead == 0 && m.behind == 0 {
-		header.WriteString(helpStyle.Render(" (up to date)"))
+// setNote replaces, or with empty text removes, the note on the last commit.
+func (m *model) setNote(text string) (tea.Cmd, error) {
+	if err := SetNote(m.lastCommit.Hash, text); err != nil {
+		return nil, err
+	}
+	if text == "" {
+		m.notify("Removed note from " + m.lastCommit.Hash)
 	} else {
-		var parts []string
-		if m.behind > 0 {
-			parts = append(parts, fmt.Sprintf("%d behind", m.behind))
-		}
-		if m.ahead > 0 {
-			parts = append(parts, fmt.Sprintf("%d ahead", m.ahead))
-		}
-		header.WriteString(helpStyle.Render(" (" + strings.Join(parts, ", ") + ")"))
+		m.notify("Saved note on " + m.lastCommit.Hash)
 	}
-	header.WriteString("\n\n")
+	return nil, nil
+}
 
-	// Footer
-	footer := helpStyle.Render("\nScroll: ↑/↓/j/k  r: refresh  q: quit")
+// confirmation is a pending yes/no question; action runs on yes
+type confirmation struct {
+	prompt string
+	action func(m *model) tea.Cmd
+}
 
-	return header.String() + m.viewport.View() + footer
+// updateConfirm answers the pending confirmation. Anything but y cancels.
+func (m model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.confirm
+	m.confirm = nil
+	switch msg.String() {
+	case "y", "Y":
+		return m, c.action(&m)
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	m.notify("Cancelled")
+	return m, nil
 }
 
-func (m model) renderBody() string {
-	var body strings.Builder
-	if len(m.changes) == 0 && len(m.branchFiles) == 0 {
-		body.WriteString(helpStyle.Render("No changes detected"))
-	} else {
-		if len(m.changes) > 0 {
-			body.WriteString("Changed Files:\n")
-			for _, change := range m.changes {
-				label := formatLabel(change)
-				file := fileStyle.Render(change.File)
-				body.WriteString(fmt.Sprintf("  %s  %s\n", label, file))
-			}
+// choice is a pending pick from a few options, answered by number
+type choice struct {
+	prompt  string
+	options []string
+	action  func(m *model, option string) tea.Cmd
+}
+
+// updateChoice answers the pending choice. Anything but an option's
+// number cancels.
+func (m model) updateChoice(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.choice
+	m.choice = nil
+	if msg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+	if n, err := strconv.Atoi(msg.String()); err == nil && n >= 1 && n <= len(c.options) {
+		return m, c.action(&m, c.options[n-1])
+	}
+	m.notify("Cancelled")
+	return m, nil
+}
+
+// submitFunc handles text entered at a prompt. A non-nil error keeps the
+// prompt open; it may also open a follow-up prompt.
+type submitFunc func(m *model, value string) (tea.Cmd, error)
+
+// openPrompt shows a text prompt in the footer, prefilled with value.
+// submit is called with the entered text on enter.
+func (m *model) openPrompt(label, value string, submit submitFunc) tea.Cmd {
+	m.prompting = true
+	m.inputErr = ""
+	m.onSubmit = submit
+	m.input.Prompt = label
+	m.input.SetValue(value)
+	m.input.CursorEnd()
+	return m.input.Focus()
+}
+
+// updatePrompt handles key input while a prompt is open.
+func (m model) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.prompting = false
+		m.input.Blur()
+		return m, nil
+	case "enter":
+		submit := m.onSubmit
+		m.prompting = false
+		cmd, err := submit(&m, strings.TrimSpace(m.input.Value()))
+		if err != nil {
+			m.prompting = true
+			m.inputErr = err.Error()
+			return m, nil
 		}
-		if len(m.branchFiles) > 0 {
-			if len(m.changes) > 0 {
-				body.WriteString("\n")
-			}
-			body.WriteString("Branch Files:\n")
-			for _, bf := range m.branchFiles {
-				label := fmt.Sprintf("%-12s", branchFileLabel(bf.Status))
-				styled := statusModified.Render(label)
-				if bf.Status == "A" {
-					styled = statusAdded.Render(label)
-				} else if bf.Status == "D" {
-					styled = statusDeleted.Render(label)
-				} else if strings.HasPrefix(bf.Status, "R") {
-					styled = statusRenamed.Render(label)
-				}
-				body.WriteString(fmt.Sprintf("  %s  %s\n", styled, fileStyle.Render(bf.File)))
-			}
+		if m.prompting {
+			return m, cmd // a follow-up prompt was opened
 		}
+		m.input.Blur()
+		m.refresh()
+		return m, tea.Batch(cmd, tea.ClearScreen)
 	}
-	return body.String()
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
 }
 
-func formatLabel(c FileChange) string {
-	padded := fmt.Sprintf("%-12s", c.Label)
+func (m model) View() string {
+	if !m.ready {
+		return "Initializing..."
+	}
 
-	if c.Staged == '?' {
-		return statusUntracked.Render(padded)
+	// Footer
+	footer := helpStyle.Render("\n" + m.keyHints())
+	if m.jobs.busy != "" {
+		footer = "\n" + m.jobs.View()
+	}
+	if m.flash != "" {
+		style := helpStyle
+		if m.flashIsErr {
+			style = errorStyle
+		}
+		footer = "\n" + style.Render(m.flash)
+	}
+	if m.confirm != nil {
+		footer = "\n" + confirmStyle.Render(m.confirm.prompt+" (y/N)")
 	}
-	if c.Staged == 'D' || c.Unstaged == 'D' {
-		return statusDeleted.Render(padded)
+	if m.choice != nil {
+		options := m.choice.prompt
+		for i, o := range m.choice.options {
+			options += fmt.Sprintf("  %d: %s", i+1, o)
+		}
+		footer = "\n" + confirmStyle.Render(options+"  (esc: cancel)")
 	}
-	if c.Staged == 'A' {
-		return statusAdded.Render(padded)
+	if m.filtering {
+		footer = "\n" + m.filterInput.View()
 	}
-	if c.Staged == 'R' {
-		return statusRenamed.Render(padded)
+	if m.prompting {
+		footer = "\n" + m.input.View()
+		if m.inputErr != "" {
+			footer += "  " + errorStyle.Render(m.inputErr)
+		}
 	}
-	if c.Staged != ' ' && c.Staged != 0 {
-		return statusAdded.Render(padded) // staged changes in green
+
+	return m.fillHeader().View() + m.viewport.View() + footer
+}
+
+// renderBody renders the current view into the viewport.
+func (m model) renderBody() string {
+	switch m.view {
+	case viewWorktrees:
+		return m.renderWorktrees()
+	case viewHelp:
+		return m.renderHelp()
+	case viewChangelog:
+		return m.renderChangelog()
+	case viewCompare:
+		return m.renderCompare()
+	case viewStack:
+		return m.renderStack()
+	case viewPick:
+		return m.renderPick()
+	case viewOutput:
+		return m.renderOutput()
+	case viewToolLog:
+		return m.renderToolLog()
+	case viewHistory:
+		return m.renderHistory()
+	case viewReflog:
+		return m.renderReflog()
+	case viewBookmarks:
+		return m.renderBookmarks()
+	case viewMenu:
+		return m.renderMenu()
+	case viewUpstream:
+		return m.renderUpstreamPicker()
+	case viewHealth:
+		return m.renderHealth()
 	}
-	return statusModified.Render(padded)
+
+	return m.renderFiles()
 }
 
-func branchFileLabel(status string) string {
+// diffLineStyle colors a line of unified diff output.
+func diffLineStyle(line string) lipgloss.Style {
 	switch {
-	case status == "A":
-		return "added"
-	case status == "D":
-		return "deleted"
-	case status == "M":
-		return "modified"
-	case strings.HasPrefix(status, "R"):
-		return "renamed"
-	case strings.HasPrefix(status, "C"):
-		return "copied"
+	case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		return fileStyle
+	case strings.HasPrefix(line, "+"):
+		return statusAdded
+	case strings.HasPrefix(line, "-"):
+		return statusDeleted
+	case strings.HasPrefix(line, "@@"):
+		return statusRenamed
+	case strings.HasPrefix(line, " "):
+		return lipgloss.NewStyle()
 	default:
-		return "changed"
+		return helpStyle
 	}
 }
 
 func main() {
+	enableANSI()
+	absGitEnv()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "prompt":
+			os.Exit(runPrompt(os.Args[2:]))
+		case "daemon":
+			os.Exit(runDaemon(os.Args[2:]))
+		case "serve":
+			os.Exit(runServe(os.Args[2:]))
+		case "replay":
+			os.Exit(runReplay(os.Args[2:]))
+		case "export":
+			os.Exit(runExport(os.Args[2:]))
+		}
+	}
+
+	base := flag.String("base", "", "ref to compare branch files against (default: the default branch)")
+	layout := flag.String("layout", "", "layout preset to start in, e.g. monitor, review or commit")
+	follow := flag.Bool("follow", false, "scroll to and highlight the panel that changed most recently")
+	var debug debugFlag
+	flag.Var(&debug, "debug", "show average timings in the header and log git commands and timings; --debug=file logs to file rather than debug.log in the user cache directory")
+	noFetch := flag.Bool("no-fetch", false, "don't run git fetch in the background")
+	largeRepo := flag.Bool("large-repo", false, "use git's fsmonitor and untracked cache to keep status fast in huge repositories")
+	colorMode := flag.String("color", "auto", "color mode: auto, truecolor, 256, 16 or none")
+	ascii := flag.Bool("ascii", false, "draw with plain ASCII instead of unicode glyphs")
+	exitDirtyFlag := flag.Bool("exit-dirty", false, "print the status line and exit 1 if there are uncommitted changes")
+	exitBehindFlag := flag.Bool("exit-behind", false, "print the status line and exit 2 if the branch is behind its upstream")
+	fetch := flag.Bool("fetch", false, "with --exit-behind, fetch first")
+	quiet := flag.Bool("q", false, "with --exit-dirty or --exit-behind, print nothing")
+	statusPath := flag.String("status-file", "", "keep a JSON summary of the status in `path`, rewritten whenever it changes")
+	recordPath := flag.String("record", "", "record the session to `file` for \"vigil replay\"")
+	demo := flag.Bool("demo", false, "try vigil in a throwaway repository with every kind of change")
+	flag.Usage = usage
+	flag.Parse()
+
+	var debugPath string
+	if debug.on {
+		path, f, err := openDebugLog(debug.file)
+		if err != nil {
+			fmt.Printf("Error opening debug log: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		debugPath = path
+	}
+
+	if *exitDirtyFlag || *exitBehindFlag {
+		if *ascii || !SupportsUnicode() {
+			glyphs = asciiGlyphs
+		}
+		o := oneShot{style: "none", fetch: *fetch, exitDirty: *exitDirtyFlag, exitBehind: *exitBehindFlag, quiet: *quiet}
+		os.Exit(o.run())
+	}
+
+	if err := setupTerminal(*colorMode, *ascii); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *demo {
+		tmp, err := os.MkdirTemp("", "vigil-demo-")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(tmp)
+		repo, err := fixture.New(tmp)
+		if err == nil {
+			err = os.Chdir(repo)
+		}
+		if err != nil {
+			os.RemoveAll(tmp)
+			fmt.Printf("Error creating the demo repository: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Check if we're in a git repo
+	if worktree, ok := InsideGitDir(); ok {
+		if worktree != "" {
+			fmt.Println("Error: Inside a repository's .git directory")
+			fmt.Printf("Please run vigil from its working tree, %s.\n", worktree)
+		} else {
+			fmt.Println("Error: Inside a git directory with no working tree")
+			fmt.Println("Please run vigil from within a working tree.")
+		}
+		os.Exit(1)
+	}
 	if !IsGitRepo() {
+		if p, ok := DiagnoseNotRepo(); ok {
+			fmt.Print(p)
+			os.Exit(1)
+		}
 		fmt.Println("Error: Not a git repository")
 		fmt.Println("Please run vigil from within a git repository.")
 		os.Exit(1)
@@ -291,9 +1033,81 @@ func main() {
 		os.Exit(1)
 	}
 
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Printf("Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if *base != "" {
+		cfg.Base = *base
+	}
+	if *layout != "" {
+		cfg.Layout = *layout
+	}
+	if *follow {
+		cfg.FollowActivity = true
+	}
+	if debug.on {
+		cfg.Debug = true
+	}
+	if *noFetch {
+		cfg.AutoFetch = false
+	}
+	if *largeRepo {
+		cfg.LargeRepo = true
+	}
+	cfg.applyGitOverrides()
+	daemon := linkDaemon(&cfg)
+
+	if cfg.Base != "" && !RefExists(cfg.Base) {
+		fmt.Printf("Error: unknown base ref %q\n", cfg.Base)
+		os.Exit(1)
+	}
+	if _, ok := mergeLayouts(cfg.Layouts, cfg.Header)[cfg.Layout]; !ok {
+		fmt.Printf("Error: unknown layout %q\n", cfg.Layout)
+		os.Exit(1)
+	}
+
+	state, err := LoadRepoState()
+	if err != nil {
+		fmt.Printf("Error loading repository state: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Create model
-	m := initialModel()
+	m := initialModel(cfg, state)
 	m.dir = dir
+	m.daemon = daemon
+	m.setLoaded(m.newLoadRun().load())
+	if p, failed := CheckHealth(); failed {
+		m.showHealth(p)
+	}
+	if debugPath != "" {
+		m.notify("Logging to " + debugPath)
+	}
+	if limits := GitLimitations(); len(limits) > 0 {
+		m.notify(fmt.Sprintf("git %s is old: %s limited, see ?", gitVersion(), plural(len(limits), "feature")))
+	}
+	if *statusPath != "" {
+		if m.statusPath, err = filepath.Abs(*statusPath); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := m.writeStatusFile(); err != nil {
+			fmt.Printf("Error writing status file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *recordPath != "" {
+		if m.recorder, err = newRecorder(*recordPath); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := m.record(); err != nil {
+			fmt.Printf("Error recording: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	// Run the program
 	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
@@ -302,3 +1116,77 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprint(out, `Usage: vigil [flags]
+       vigil prompt [flags]
+       vigil daemon [flags]
+       vigil serve [flags]
+       vigil replay [flags] <recording>
+       vigil export [flags]
+
+vigil watches the git repository in the current directory.
+
+With --exit-dirty or --exit-behind it instead prints a one-line status and
+exits, for scripts, CI and hooks:
+  0    clean and up to date
+  1    uncommitted changes, including untracked files (--exit-dirty)
+  2    behind upstream (--exit-behind; checked after --exit-dirty)
+  128  not a git repository, or another error
+
+"vigil prompt" prints the same line for shell prompts and tmux status bars;
+see "vigil prompt -h".
+
+"vigil daemon" keeps polling and fetching one repository in the background
+and serves its status over a Unix socket, for editors, prompts and several
+vigil windows to share; see "vigil daemon -h". "vigil serve" shows the
+same status on a live web page; see "vigil serve -h".
+
+"vigil replay" plays back a session recorded with --record, or a built-in
+demo with --demo, without needing the repository.
+
+"vigil export" prints a markdown or HTML summary of the branch, for pull
+requests and tickets; see "vigil export -h".
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+// timeAgo formats t relative to the model's clock, e.g. "5m ago" or
+// "3d ago".
+func (m model) timeAgo(t time.Time) string {
+	return timeAgo(m.now(), t)
+}
+
+func timeAgo(now, t time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo ago", int(d.Hours()/24/30))
+	default:
+		return fmt.Sprintf("%dy ago", int(d.Hours()/24/365))
+	}
+}
+
+// plural formats a count with a noun, e.g. "1 commit" or "3 commits".
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// truncate shortens a possibly styled string to width cells.
+func truncate(s string, width int) string {
+	return ansi.Truncate(s, width, "")
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// menuItem is one action in the file menu, with the key that runs it
+// from the file list, if it has one
+type menuItem struct {
+	label  string
+	key    string
+	action func(m *model) tea.Cmd
+}
+
+// menuState holds the file menu: the actions applicable to one file
+type menuState struct {
+	row   listRow
+	items []menuItem
+}
+
+// openMenu lists what can be done with the selected file, so actions can
+// be found without knowing their keys.
+func (m *model) openMenu() {
+	row, ok := m.selectedRow()
+	if !ok || row.file == "" {
+		return
+	}
+	items := m.menuItems(row)
+	if len(items) == 0 {
+		return
+	}
+	m.menu = menuState{row: row, items: items}
+	m.view = viewMenu
+	m.cursor = 0
+	m.viewport.GotoTop()
+	m.resize()
+}
+
+// menuItems returns the actions that apply to a file row, depending on
+// its panel and status.
+func (m model) menuItems(row listRow) []menuItem {
+	var items []menuItem
+	add := func(label, key string, action func(m *model) tea.Cmd) {
+		items = append(items, menuItem{label: label, key: key, action: action})
+	}
+	run := func(f func(m *model)) func(m *model) tea.Cmd {
+		return func(m *model) tea.Cmd {
+			f(m)
+			return nil
+		}
+	}
+
+	tracked, exists := true, true
+	if row.panel == panelChanges {
+		c, ok := m.change(row.file)
+		if !ok {
+			return nil
+		}
+		changes := []FileChange{c}
+		untracked := c.Staged == '?' || c.Staged == '!'
+		tracked = !untracked && c.Staged != 'A'
+		exists = c.Staged != 'D' && c.Unstaged != 'D'
+
+		if c.Staged == '?' || !untracked && c.Unstaged != ' ' {
+			add("stage", "+", run(func(m *model) { m.stageChanges(changes) }))
+		}
+		if !untracked && c.Staged != ' ' {
+			add("unstage", "-", run(func(m *model) { m.unstageChanges(changes) }))
+		}
+		add("diff", "", run(func(m *model) { m.showDiff(row) }))
+		add("discard", "d", run(func(m *model) { m.confirmDiscard(changes) }))
+		add("stash", "z", run(func(m *model) { m.stashChanges(changes) }))
+		if m.marked[c.File] {
+			add("unselect", "space", run((*model).toggleMarked))
+		} else {
+			add("select", "space", run((*model).toggleMarked))
+		}
+		if c.Staged == '?' {
+			add("ignore", "e", run((*model).ignoreSelected))
+		}
+		if c.Repo != "" {
+			add("open the "+c.Repo, "enter", func(m *model) tea.Cmd { return m.openNestedRepo(c) })
+		}
+	} else {
+		add("diff", "", run(func(m *model) { m.showDiff(row) }))
+		label := "mark reviewed"
+		if m.isReviewed(row.file) {
+			label = "unmark reviewed"
+		}
+		add(label, "x", run((*model).toggleReviewed))
+		for _, bf := range m.branchFiles {
+			if bf.File == row.file {
+				exists = bf.Status != "D"
+			}
+		}
+	}
+	if tracked {
+		add("history", "h", run((*model).openHistory))
+		if exists {
+			add("blame", "", run(func(m *model) { m.showBlame(treePath(row.file)) }))
+		}
+	}
+	if exists {
+		add("open in editor", "o", (*model).openSelectedFile)
+	}
+	add("copy path, absolute path or diff", "y", run((*model).promptCopy))
+	add("open on the code host", "V", run((*model).promptCodeHost))
+	if m.isPinned(row.file) {
+		add("unpin", "*", run((*model).togglePin))
+	} else {
+		add("pin", "*", run((*model).togglePin))
+	}
+	return items
+}
+
+// updateMenu handles key input in the file menu. enter runs the selected
+// action, as does its own key.
+func (m model) updateMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+	switch key {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "m":
+		m.view = viewFiles
+		m.resize()
+		return m, tea.ClearScreen
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, len(m.menu.items)-1)
+	case "enter":
+		return m.runMenuItem(m.menu.items[m.cursor])
+	default:
+		if key == " " {
+			key = "space"
+		}
+		for _, item := range m.menu.items {
+			if item.key == key {
+				return m.runMenuItem(item)
+			}
+		}
+		return m, nil
+	}
+
+	m.resize()
+	m.scrollTo(m.cursor + 1)
+	return m, nil
+}
+
+// runMenuItem goes back to the file list, where the menu's file is still
+// selected, and runs the action there.
+func (m model) runMenuItem(item menuItem) (tea.Model, tea.Cmd) {
+	m.view = viewFiles
+	m.resize()
+	cmd := item.action(&m)
+	if m.view == viewFiles {
+		m.resize()
+	}
+	return m, tea.Batch(cmd, tea.ClearScreen)
+}
+
+func (m model) renderMenu() string {
+	var body strings.Builder
+	body.WriteString("Actions for " + pathStyle.Render(treePath(m.menu.row.file)) + ":\n")
+	width := 0
+	for _, item := range m.menu.items {
+		width = max(width, len(item.label))
+	}
+	for i, item := range m.menu.items {
+		line := fmt.Sprintf("%-*s", width, item.label)
+		if item.key != "" {
+			line += "  " + helpStyle.Render(item.key)
+		}
+		body.WriteString(m.cursorColumn(i == m.cursor) + line + "\n")
+	}
+	return body.String()
+}
+
+// showDiff shows a file row's diff in the output pane.
+func (m *model) showDiff(row listRow) {
+	diff, err := m.rowDiff(row)
+	m.output = outputState{title: "Diff of " + treePath(row.file), lines: diff, err: err, parent: viewFiles, diff: true}
+	m.view = viewOutput
+	m.viewport.GotoTop()
+	m.resize()
+}
+
+// showBlame shows git blame for a file in the output pane.
+func (m *model) showBlame(file string) {
+	lines, err := GetBlame(file)
+	m.output = outputState{title: "$ git blame " + file, lines: lines, err: err, parent: viewFiles}
+	m.view = viewOutput
+	m.viewport.GotoTop()
+	m.resize()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// metricSamples is how many of the latest timings an average covers
+const metricSamples = 20
+
+// rollingAverage averages the latest timings of one operation
+type rollingAverage struct {
+	samples []time.Duration
+}
+
+func (r *rollingAverage) add(d time.Duration) {
+	r.samples = append(r.samples, d)
+	if len(r.samples) > metricSamples {
+		r.samples = r.samples[1:]
+	}
+}
+
+// last returns the latest sample, or 0 if there are none.
+func (r rollingAverage) last() time.Duration {
+	if len(r.samples) == 0 {
+		return 0
+	}
+	return r.samples[len(r.samples)-1]
+}
+
+// average returns the mean of the samples, or false if there are none.
+func (r rollingAverage) average() (time.Duration, bool) {
+	if len(r.samples) == 0 {
+		return 0, false
+	}
+	var total time.Duration
+	for _, d := range r.samples {
+		total += d
+	}
+	return total / time.Duration(len(r.samples)), true
+}
+
+// metrics records where vigil's time goes, for the debug header segment:
+// users of giant repositories can see whether status, the branch diff,
+// fetching or the watch poll is slow, and tune excludes and intervals
+type metrics struct {
+	refresh rollingAverage // a whole refresh, everything below included
+	status  rollingAverage // git status
+	diff    rollingAverage // the branch files diff
+	fetch   rollingAverage
+	scan    rollingAverage // the watch command's working tree poll
+
+	changes []time.Time // refreshes that found the changes different, in the last minute
+
+	// What the watch poll saw: working tree and watch path changes, and
+	// watch command runs
+	treeChanges, pathChanges, watchRuns int
+}
+
+// changed records that a refresh found the working tree changed.
+func (mt *metrics) changed(now time.Time) {
+	mt.changes = append(mt.changes, now)
+	for len(mt.changes) > 0 && now.Sub(mt.changes[0]) > time.Minute {
+		mt.changes = mt.changes[1:]
+	}
+}
+
+// changeRate returns how many changes were seen in the last minute.
+func (mt metrics) changeRate() int {
+	n := 0
+	for _, t := range mt.changes {
+		if time.Since(t) <= time.Minute {
+			n++
+		}
+	}
+	return n
+}
+
+// renderDebugLine renders the average timings, e.g.
+// "Debug: refresh 85ms (status 40ms, diff 12ms) · fetch 1.2s · 4 changes/min".
+func (m model) renderDebugLine() string {
+	mt := m.metrics
+	format := func(label string, r rollingAverage) string {
+		avg, ok := r.average()
+		if !ok {
+			return label + " -"
+		}
+		return label + " " + avg.Round(time.Millisecond).String()
+	}
+	parts := []string{
+		format("refresh", mt.refresh) + " (" + format("status", mt.status) + ", " + format("diff", mt.diff) + ")",
+		format("fetch", mt.fetch),
+	}
+	if m.watch.enabled() {
+		parts = append(parts, format("watch poll", mt.scan))
+	}
+	parts = append(parts, fmt.Sprintf("%d changes/min", mt.changeRate()))
+	return "Debug: " + helpStyle.Render(strings.Join(parts, " "+glyphs.Dot+" "))
+}
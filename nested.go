@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openNestedRepo re-roots vigil in the selected submodule or nested
+// repository, whose files the outer repository's status doesn't list.
+// esc comes back out.
+func (m *model) openNestedRepo(c FileChange) tea.Cmd {
+	root, err := GetRepoRoot()
+	if err != nil {
+		m.notifyErr(err)
+		return nil
+	}
+	outer := m.dir
+	path := strings.TrimSuffix(c.File, "/")
+	if err := m.switchRepo(filepath.Join(root, path)); err != nil {
+		m.notifyErr(fmt.Errorf("%s: %w", path, err))
+		return nil
+	}
+	m.outer = append(m.outer, outer)
+	m.notify(fmt.Sprintf("Opened %s %s; esc goes back to %s", c.Repo, path, filepath.Base(root)))
+	return tea.Batch(checkUpstream, m.loadPR(), tea.ClearScreen)
+}
+
+// leaveNestedRepo goes back to the repository a nested one was opened
+// from, reporting whether there was one.
+func (m *model) leaveNestedRepo() (tea.Cmd, bool) {
+	if len(m.outer) == 0 {
+		return nil, false
+	}
+	outer := m.outer[len(m.outer)-1]
+	if err := m.switchRepo(outer); err != nil {
+		m.notifyErr(err)
+		return nil, true
+	}
+	m.outer = m.outer[:len(m.outer)-1]
+	m.notify("Back in " + filepath.Base(outer))
+	return tea.Batch(checkUpstream, m.loadPR(), tea.ClearScreen), true
+}
+
+// selectedRepo returns the selected change when it's a submodule or
+// nested repository.
+func (m model) selectedRepo() (FileChange, bool) {
+	row, ok := m.selectedRow()
+	if !ok || row.panel != panelChanges || row.file == "" {
+		return FileChange{}, false
+	}
+	c, ok := m.change(row.file)
+	return c, ok && c.Repo != ""
+}
+
+// withoutRepos drops nested repositories, and submodules too if asked,
+// from changes a batch action is about to apply to: staging a nested
+// repository would embed it as a broken submodule, and discarding either
+// would delete work inside it that vigil doesn't show.
+func (m *model) withoutRepos(changes []FileChange, verb string, submodules bool) []FileChange {
+	var kept []FileChange
+	var skipped []string
+	for _, c := range changes {
+		if c.Repo == repoNested || submodules && c.Repo == repoSubmodule {
+			skipped = append(skipped, c.File)
+			continue
+		}
+		kept = append(kept, c)
+	}
+	if len(skipped) > 0 && len(kept) == 0 {
+		what := skipped[0] + ": it's a separate repository"
+		if len(skipped) > 1 {
+			what = plural(len(skipped), "file") + ": they're separate repositories"
+		}
+		m.notify(fmt.Sprintf("Can't %s %s; enter opens one", verb, what))
+	}
+	return kept
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Notification modes for alerts
+const (
+	notifyOSC9   = "osc9"   // iTerm2, kitty, WezTerm, Windows Terminal, Ghostty
+	notifyOSC777 = "osc777" // foot, urxvt, Konsole
+	notifyOff    = "off"
+)
+
+// windowTitle summarizes the repository for the terminal title, e.g.
+// "vigil: feature-x ↑2 ✗3".
+func (m model) windowTitle() string {
+	parts := []string{"vigil: " + m.branch}
+	if m.upstreamErr == nil && m.ahead > 0 {
+		parts = append(parts, fmt.Sprintf("%s%d", glyphs.Up, m.ahead))
+	}
+	if m.upstreamErr == nil && m.behind > 0 {
+		parts = append(parts, fmt.Sprintf("%s%d", glyphs.Down, m.behind))
+	}
+	if n := len(m.changes); n > 0 {
+		parts = append(parts, fmt.Sprintf("%s%d", glyphs.Cross, n))
+	}
+	return strings.Join(parts, " ")
+}
+
+// updateTitle sets the terminal title when the summary changes. Inside
+// tmux this sets the pane title, shown with tmux's set-titles option.
+func (m *model) updateTitle() {
+	if !m.setTitle {
+		return
+	}
+	if title := m.windowTitle(); title != m.title {
+		m.title = title
+		writeTerminal("\x1b]2;" + sanitizeOSC(title) + "\x07")
+	}
+}
+
+// alert raises a desktop notification through the terminal, so events
+// are noticed while vigil's pane is hidden.
+func (m *model) alert(title, body string) {
+	switch m.notifyMode {
+	case notifyOSC9:
+		writeTerminal(passthrough("\x1b]9;" + sanitizeOSC(title+": "+body) + "\x07"))
+	case notifyOSC777:
+		writeTerminal(passthrough("\x1b]777;notify;" + sanitizeOSC(title) + ";" + sanitizeOSC(body) + "\x07"))
+	}
+}
+
+// passthrough wraps an escape sequence so tmux forwards it to the outer
+// terminal (needs tmux's allow-passthrough option).
+func passthrough(seq string) string {
+	if os.Getenv("TMUX") == "" {
+		return seq
+	}
+	return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+}
+
+// sanitizeOSC drops control characters, which would end the sequence early.
+func sanitizeOSC(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// writeTerminal sends an escape sequence straight to the terminal. The
+// sequences used don't move the cursor, so they don't disturb rendering.
+func writeTerminal(seq string) {
+	os.Stdout.WriteString(seq)
+}
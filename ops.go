@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// opDoneMsg reports the result of a background operation
+type opDoneMsg struct {
+	op  string
+	err error
+}
+
+// jobs is the work running in the background: the one slow operation
+// (push, pull, a custom command...) allowed at a time, the fetch and the
+// watch command. It animates the spinner they show while any runs.
+type jobs struct {
+	busy     string // progress text while an operation runs
+	fetching bool
+	watching bool // the watch command is running
+	spinner  spinner.Model
+}
+
+// newJobs returns the jobs with the fetch running when fetching is set.
+func newJobs(fetching bool) jobs {
+	return jobs{fetching: fetching, spinner: spinner.New(spinner.WithSpinner(spinner.MiniDot), spinner.WithStyle(helpStyle))}
+}
+
+// start marks an operation running and starts the spinner.
+func (j *jobs) start(progress string) tea.Cmd {
+	j.busy = progress
+	return j.spinner.Tick
+}
+
+// active reports whether anything is running.
+func (j jobs) active() bool {
+	return j.busy != "" || j.fetching || j.watching
+}
+
+// Update animates the spinner while anything is running, letting it stop
+// once nothing is.
+func (j jobs) Update(msg tea.Msg) (jobs, tea.Cmd) {
+	if _, ok := msg.(spinner.TickMsg); !ok || !j.active() {
+		return j, nil
+	}
+	var cmd tea.Cmd
+	j.spinner, cmd = j.spinner.Update(msg)
+	return j, cmd
+}
+
+// View renders the running operation's progress for the footer, or
+// nothing when there isn't one.
+func (j jobs) View() string {
+	if j.busy == "" {
+		return ""
+	}
+	return j.spinner.View() + " " + helpStyle.Render(j.busy+"...")
+}
+
+// spin renders the spinner on its own, for the fetch and watch states.
+func (j jobs) spin() string {
+	return j.spinner.View()
+}
+
+// startOp runs a slow git operation (push, pull, archive...) in the
+// background, showing progress with a spinner until it completes. Only
+// one runs at a time.
+func (m *model) startOp(op, progress string, run func() error) tea.Cmd {
+	if m.jobs.busy != "" {
+		m.notify(m.jobs.busy + " already in progress")
+		return nil
+	}
+	return tea.Batch(m.jobs.start(progress), func() tea.Msg {
+		return opDoneMsg{op: op, err: run()}
+	})
+}
+
+// updateOps animates the spinner while a fetch or background operation
+// runs, and reports operations as they complete.
+func (m model) updateOps(msg tea.Msg) (model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.jobs, cmd = m.jobs.Update(msg)
+		return m, cmd
+
+	case opDoneMsg:
+		m.jobs.busy = ""
+		if msg.err != nil {
+			m.notifyErr(fmt.Errorf("%s failed: %w", msg.op, msg.err))
+			m.alert(msg.op+" failed", msg.err.Error())
+		} else {
+			m.notify(msg.op + " complete")
+			m.alert(msg.op+" complete", m.branch)
+		}
+		m.refresh()
+		if m.view == viewStack {
+			return m, tea.Batch(checkUpstream, loadStack)
+		}
+		return m, checkUpstream
+	}
+	return m, nil
+}
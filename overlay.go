@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// vcsTool is a tool layered over git that vigil knows to stay out of the
+// way of
+type vcsTool string
+
+const (
+	toolNone       vcsTool = ""
+	toolJJ         vcsTool = "jj"         // jujutsu, colocated with git
+	toolBranchless vcsTool = "branchless" // git-branchless
+)
+
+// detectTool reports which tool, if any, manages the current repository.
+func detectTool() vcsTool {
+	if root, err := GetRepoRoot(); err == nil {
+		if info, err := os.Stat(filepath.Join(root, ".jj")); err == nil && info.IsDir() {
+			return toolJJ
+		}
+	}
+	if dir, err := GetGitCommonDir(); err == nil {
+		if info, err := os.Stat(filepath.Join(dir, "branchless")); err == nil && info.IsDir() {
+			return toolBranchless
+		}
+	}
+	return toolNone
+}
+
+// toolLog is one of the logs the tool view cycles through
+type toolLog struct {
+	name string
+	args []string
+}
+
+// graphLimit is how many commits the graph shows, as laying out a whole
+// history can take a while
+const graphLimit = 500
+
+// graphLog is git's commit graph of every branch, with each branch's line
+// in its own color
+var graphLog = toolLog{"graph", []string{"git", "log", "--graph", "--oneline", "--decorate", "--all", "--color=always", "--max-count=" + strconv.Itoa(graphLimit)}}
+
+// toolLogs returns the logs a tool offers, e.g. jj's log, op log and status,
+// followed by the commit graph, which is all there is without a tool.
+func (t vcsTool) logs() []toolLog {
+	switch t {
+	case toolJJ:
+		return []toolLog{
+			{"log", []string{"jj", "log", "--no-pager", "--color=always"}},
+			{"op log", []string{"jj", "op", "log", "--no-pager", "--color=always", "--limit", "30"}},
+			{"status", []string{"jj", "status", "--no-pager", "--color=always"}},
+			graphLog,
+		}
+	case toolBranchless:
+		return []toolLog{
+			{"smartlog", []string{"git", "branchless", "smartlog"}},
+			graphLog,
+		}
+	}
+	return []toolLog{graphLog}
+}
+
+// name is what the tool log view is titled with.
+func (t vcsTool) name() string {
+	if t == toolNone {
+		return "git"
+	}
+	return string(t)
+}
+
+// toolLogState holds the view of the tool's own logs
+type toolLogState struct {
+	index int // into the tool's logs
+	lines []string
+	err   error
+}
+
+// openToolLog switches to the view of the managing tool's logs, or of the
+// commit graph when there's no tool.
+func (m *model) openToolLog() {
+	m.toolLog = toolLogState{}
+	m.loadToolLog()
+	m.view = viewToolLog
+	m.viewport.GotoTop()
+	m.resize()
+}
+
+// loadToolLog runs the tool for the selected log.
+func (m *model) loadToolLog() {
+	log := m.tool.logs()[m.toolLog.index]
+	output, err := exec.Command(log.args[0], log.args[1:]...).CombinedOutput()
+	m.toolLog.lines = strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	m.toolLog.err = err
+}
+
+// updateToolLog handles key input in the tool log view.
+func (m model) updateToolLog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "J":
+		m.view = viewFiles
+		m.resize()
+		return m, tea.ClearScreen
+	case "tab":
+		m.toolLog.index = (m.toolLog.index + 1) % len(m.tool.logs())
+		m.loadToolLog()
+		m.viewport.GotoTop()
+	case "r":
+		m.loadToolLog()
+	case "up", "k":
+		m.viewport.LineUp(1)
+		return m, nil
+	case "down", "j":
+		m.viewport.LineDown(1)
+		return m, nil
+	case "pgup":
+		m.viewport.HalfViewUp()
+		return m, nil
+	case "pgdown":
+		m.viewport.HalfViewDown()
+		return m, nil
+	case "home", "g":
+		m.viewport.GotoTop()
+		return m, nil
+	case "end", "G":
+		m.viewport.GotoBottom()
+		return m, nil
+	}
+	m.resize()
+	return m, tea.ClearScreen
+}
+
+func (m model) renderToolLog() string {
+	var body strings.Builder
+	logs := m.tool.logs()
+	var names []string
+	for i, log := range logs {
+		if i == m.toolLog.index {
+			names = append(names, branchStyle.Render(log.name))
+		} else {
+			names = append(names, helpStyle.Render(log.name))
+		}
+	}
+	body.WriteString(m.tool.name() + ": " + strings.Join(names, helpStyle.Render(" "+glyphs.Dot+" ")) + "\n")
+	if m.toolLog.err != nil {
+		body.WriteString(errorStyle.Render(m.toolLog.err.Error()) + "\n")
+	}
+	for _, line := range m.toolLog.lines {
+		if m.narrow() {
+			line = truncate(line, m.width)
+		}
+		body.WriteString(line + "\n")
+	}
+	return body.String()
+}
+
+// toolConflict reports, and tells the user, when op would fight the tool
+// managing the repository. jj keeps git in detached HEAD and records its
+// own operation log, so history rewriting and branch switching should go
+// through jj instead. Every such op writes to the repository, so they're
+// all refused on a read-only filesystem too.
+func (m *model) toolConflict(op, instead string) bool {
+	if m.readOnlyBlocked(op) {
+		return true
+	}
+	if m.tool != toolJJ {
+		return false
+	}
+	m.notify("jj manages this repository; " + op + " with " + instead + " instead")
+	return true
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// pagerTimeout bounds how long the diff pager may take over one diff
+const pagerTimeout = 5 * time.Second
+
+// pagedDiff caches a diff as the configured diff pager rendered it, since
+// running the pager on every redraw would be too slow
+type pagedDiff struct {
+	diff  []string // the raw diff the pager was given
+	width int
+	lines []string // its ANSI output
+	err   error
+}
+
+// pageDiff returns diff as rendered by the diff pager at width, running it
+// only when the diff or width has changed, or nil without a pager.
+func (m model) pageDiff(diff []string, width int) *pagedDiff {
+	if m.pager == "" || len(diff) == 0 {
+		return nil
+	}
+	p := m.paged
+	if p.width != width || !slices.Equal(p.diff, diff) {
+		p.diff, p.width = diff, width
+		p.lines, p.err = runPager(m.pager, diff, width)
+	}
+	return p
+}
+
+// runPager pipes diff through the pager command with the shell, telling it the
+// width through $COLUMNS as its output isn't a terminal.
+func runPager(pager string, diff []string, width int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pagerTimeout)
+	defer cancel()
+	cmd := shellCommandContext(ctx, pager)
+	cmd.Stdin = strings.NewReader(strings.Join(diff, "\n") + "\n")
+	cmd.Env = append(os.Environ(), fmt.Sprintf("COLUMNS=%d", width))
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			msg, _, _ = strings.Cut(msg, "\n")
+			err = fmt.Errorf("%s: %s", pager, msg)
+		} else {
+			err = fmt.Errorf("%s: %w", pager, err)
+		}
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = truncate(line, width)
+	}
+	return lines, nil
+}
+
+// renderPaged writes a diff through the diff pager into body, reporting
+// whether it did. If the pager fails, its error heads the built-in diff.
+func (m model) renderPaged(body *strings.Builder, diff []string, width int) bool {
+	p := m.pageDiff(diff, width)
+	if p == nil {
+		return false
+	}
+	if p.err != nil {
+		body.WriteString(errorStyle.Render("diff_pager failed: "+p.err.Error()) + "\n")
+		return false
+	}
+	for _, line := range p.lines {
+		body.WriteString(line + "\n")
+	}
+	return true
+}
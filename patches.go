@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// promptFormatPatch asks where to write the branch's commits as a patch
+// series, ready for git send-email.
+func (m *model) promptFormatPatch() tea.Cmd {
+	mergeBase, err := GetMergeBase(m.base)
+	if err != nil {
+		m.notifyErr(fmt.Errorf("no merge base with %s", m.baseName()))
+		return nil
+	}
+	commits := GetCommitsBetween(mergeBase, "HEAD")
+	if len(commits) == 0 {
+		m.notify("No commits on the branch since " + m.baseName())
+		return nil
+	}
+
+	label := fmt.Sprintf("Write %s as patches to: ", plural(len(commits), "commit"))
+	return m.openPrompt(label, snapshotPath(m.dir, m.branch, "-patches"), func(m *model, dir string) (tea.Cmd, error) {
+		dir, err := outputPath(dir)
+		if err != nil {
+			return nil, err
+		}
+		return m.startOp("Patch series to "+dir, "Formatting patches", func() error {
+			return FormatPatch(mergeBase, dir)
+		}), nil
+	})
+}
+
+// Patch actions offered by promptPatch
+const (
+	patchSaveChanges = "save changes"
+	patchCopyChanges = "copy changes"
+	patchSaveBranch  = "save branch diff"
+	patchCopyBranch  = "copy branch diff"
+	patchApply       = "apply a patch"
+)
+
+// promptPatch offers to export uncommitted changes or the branch's diff
+// against its merge base, to a file or the clipboard, or to apply a patch.
+func (m *model) promptPatch() {
+	options := []string{patchSaveChanges, patchCopyChanges}
+	if len(m.branchFiles) > 0 {
+		options = append(options, patchSaveBranch, patchCopyBranch)
+	}
+	options = append(options, patchApply)
+	m.choice = &choice{
+		prompt:  "Patch:",
+		options: options,
+		action: func(m *model, option string) tea.Cmd {
+			switch option {
+			case patchApply:
+				return m.promptApplyPatch()
+			case patchSaveBranch, patchCopyBranch:
+				mergeBase, err := GetMergeBase(m.base)
+				if err != nil {
+					m.notifyErr(fmt.Errorf("no merge base with %s", m.baseName()))
+					return nil
+				}
+				return m.exportPatch(mergeBase, "HEAD", option == patchCopyBranch, "the branch diff", snapshotPath(m.dir, m.branch, ".patch"))
+			default:
+				return m.exportPatch("HEAD", "", option == patchCopyChanges, "uncommitted changes", snapshotPath(m.dir, m.branch, "-wip.patch"))
+			}
+		},
+	}
+}
+
+// exportPatch writes the diff from..to (to the working tree when to is
+// empty) to the clipboard, or to a file after asking where.
+func (m *model) exportPatch(from, to string, clipboard bool, what, suggested string) tea.Cmd {
+	patch, err := GetPatch(from, to)
+	if err != nil {
+		m.notifyErr(err)
+		return nil
+	}
+	if len(patch) == 0 {
+		m.notify("No changes in " + what)
+		return nil
+	}
+	if clipboard {
+		m.notify(fmt.Sprintf("Copied %s via %s", what, copyToClipboard(string(patch))))
+		return nil
+	}
+	return m.openPrompt("Write "+what+" to: ", suggested, func(m *model, path string) (tea.Cmd, error) {
+		path, err := outputPath(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, patch, 0o644); err != nil {
+			return nil, err
+		}
+		m.notify("Wrote " + what + " to " + path)
+		return nil, nil
+	})
+}
+
+// promptApplyPatch asks for a patch file and applies it, showing git's
+// output if it fails. Hunks that don't apply cleanly are merged three-way
+// and left as conflicts, listed first in Changed Files.
+func (m *model) promptApplyPatch() tea.Cmd {
+	if m.toolConflict("apply patches", "jj's working copy and git apply") {
+		return nil
+	}
+	return m.openPrompt("Apply patch: ", "", func(m *model, path string) (tea.Cmd, error) {
+		path, err := expandPath(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+		output, err := ApplyPatch(path)
+		m.refresh()
+		switch {
+		case err != nil && m.summary.Conflicts > 0:
+			m.notify(fmt.Sprintf("Applied %s with conflicts in %s; resolve and stage them", filepath.Base(path), plural(m.summary.Conflicts, "file")))
+		case err != nil:
+			m.output = outputState{title: "$ git apply --3way " + filepath.Base(path), lines: output, err: err, parent: viewFiles}
+			m.view = viewOutput
+			m.viewport.GotoTop()
+			m.resize()
+			return tea.ClearScreen, nil
+		default:
+			m.notify("Applied " + filepath.Base(path))
+		}
+		return nil, nil
+	})
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// PullRequest is the current branch's pull request, as reported by the
+// GitHub CLI, or its merge request as reported by GitLab's
+type PullRequest struct {
+	Number  int     `json:"number"`
+	State   string  `json:"state"` // OPEN, CLOSED or MERGED
+	IsDraft bool    `json:"isDraft"`
+	Review  string  `json:"reviewDecision"` // APPROVED, CHANGES_REQUESTED, REVIEW_REQUIRED or empty
+	URL     string  `json:"url"`
+	Checks  []Check `json:"statusCheckRollup"`
+	GitLab  bool    `json:"-"` // a merge request, numbered !N
+}
+
+// Check is one CI check run or commit status on a pull request. Check
+// runs report Status and Conclusion; commit statuses report State.
+type Check struct {
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	State      string `json:"state"`
+}
+
+// CheckCounts tallies a pull request's checks
+type CheckCounts struct {
+	Passed, Failed, Pending int
+}
+
+// Counts tallies the checks by outcome. Skipped and neutral checks count
+// as passed.
+func (pr PullRequest) Counts() CheckCounts {
+	var c CheckCounts
+	for _, check := range pr.Checks {
+		result := check.Conclusion
+		if check.State != "" {
+			result = check.State
+		} else if check.Status != "COMPLETED" {
+			c.Pending++
+			continue
+		}
+		switch result {
+		case "SUCCESS", "NEUTRAL", "SKIPPED":
+			c.Passed++
+		case "PENDING", "EXPECTED":
+			c.Pending++
+		default:
+			c.Failed++
+		}
+	}
+	return c
+}
+
+// GetPullRequest asks the installed code host CLIs for the current
+// branch's pull request: gh, then glab, or glab first when origin is on
+// GitLab. They use their own logins, so nothing needs configuring. It
+// returns false when neither is installed and logged in, or the branch
+// has no pull request.
+func GetPullRequest() (PullRequest, bool) {
+	lookups := []func() (PullRequest, bool){ghPullRequest, glabMergeRequest}
+	if host, err := GetCodeHost(); err == nil && host.GitLab {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		if pr, ok := lookup(); ok {
+			return pr, true
+		}
+	}
+	return PullRequest{}, false
+}
+
+// ghPullRequest asks the GitHub CLI for the branch's pull request.
+func ghPullRequest() (PullRequest, bool) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return PullRequest{}, false
+	}
+	output, err := exec.Command("gh", "pr", "view", "--json", "number,state,isDraft,reviewDecision,url,statusCheckRollup").Output()
+	if err != nil {
+		return PullRequest{}, false
+	}
+	var pr PullRequest
+	if err := json.Unmarshal(output, &pr); err != nil {
+		return PullRequest{}, false
+	}
+	return pr, true
+}
+
+// mergeRequest is what vigil reads of glab's JSON for a merge request
+type mergeRequest struct {
+	IID         int    `json:"iid"`
+	State       string `json:"state"` // opened, closed, merged or locked
+	Draft       bool   `json:"draft"`
+	WebURL      string `json:"web_url"`
+	MergeStatus string `json:"detailed_merge_status"`
+	Pipeline    *struct {
+		Status string `json:"status"`
+	} `json:"head_pipeline"`
+}
+
+// glabMergeRequest asks the GitLab CLI for the branch's merge request,
+// translated into a PullRequest. GitLab reports one status for the
+// pipeline rather than one per check, so that's its only check.
+func glabMergeRequest() (PullRequest, bool) {
+	if _, err := exec.LookPath("glab"); err != nil {
+		return PullRequest{}, false
+	}
+	output, err := exec.Command("glab", "mr", "view", "-F", "json").Output()
+	if err != nil {
+		return PullRequest{}, false
+	}
+	var mr mergeRequest
+	if err := json.Unmarshal(output, &mr); err != nil || mr.IID == 0 {
+		return PullRequest{}, false
+	}
+
+	pr := PullRequest{Number: mr.IID, IsDraft: mr.Draft, URL: mr.WebURL, GitLab: true}
+	switch mr.State {
+	case "opened":
+		pr.State = "OPEN"
+	case "merged":
+		pr.State = "MERGED"
+	default:
+		pr.State = "CLOSED"
+	}
+	switch mr.MergeStatus {
+	case "not_approved":
+		pr.Review = "REVIEW_REQUIRED"
+	case "requested_changes":
+		pr.Review = "CHANGES_REQUESTED"
+	}
+	if mr.Pipeline != nil {
+		pr.Checks = []Check{{State: pipelineState(mr.Pipeline.Status)}}
+	}
+	return pr, true
+}
+
+// pipelineState maps a GitLab pipeline status to the check state GitHub
+// would report.
+func pipelineState(status string) string {
+	switch status {
+	case "success":
+		return "SUCCESS"
+	case "skipped", "manual":
+		return "SKIPPED"
+	case "failed", "canceled":
+		return "FAILURE"
+	default: // created, pending, running, scheduled and the like
+		return "PENDING"
+	}
+}
+
+// prLoadedMsg carries a pull request lookup for a branch
+type prLoadedMsg struct {
+	branch string
+	pr     PullRequest
+	ok     bool
+}
+
+// loadPR looks up the branch's pull request in the background, when a
+// layout shows it. gh goes over the network, so this runs at startup,
+// after fetches and on switching branches rather than on every refresh.
+func (m model) loadPR() tea.Cmd {
+	if m.replay != nil || !m.showsPR() {
+		return nil
+	}
+	branch := m.branch
+	return func() tea.Msg {
+		pr, ok := GetPullRequest()
+		return prLoadedMsg{branch: branch, pr: pr, ok: ok}
+	}
+}
+
+// showsPR reports whether any layout has the pr or ci header segment.
+func (m model) showsPR() bool {
+	for _, l := range m.layouts {
+		if l.hasSegment(segmentPR) || l.hasSegment(segmentCI) {
+			return true
+		}
+	}
+	return false
+}
+
+// setPR records a pull request lookup, unless the branch has changed
+// since it started.
+func (m *model) setPR(msg prLoadedMsg) {
+	if msg.branch != m.branch {
+		return
+	}
+	m.pr, m.hasPR = msg.pr, msg.ok
+}
+
+// renderPRLine renders the pull request's number, state and review
+// decision, e.g. "PR: #42 open · approved" or "MR: !42 open".
+func (m model) renderPRLine() string {
+	pr := m.pr
+	state := strings.ToLower(pr.State)
+	style := statusAdded
+	switch {
+	case pr.State == "OPEN" && pr.IsDraft:
+		state, style = "draft", helpStyle
+	case pr.State == "MERGED":
+		style = tagStyle
+	case pr.State == "CLOSED":
+		style = statusDeleted
+	}
+	number, kind := fmt.Sprintf("#%d", pr.Number), "PR"
+	if pr.GitLab {
+		number, kind = fmt.Sprintf("!%d", pr.Number), "MR"
+	}
+	line := number + " " + style.Render(state)
+	if review := strings.ToLower(strings.ReplaceAll(pr.Review, "_", " ")); review != "" && pr.State == "OPEN" {
+		line += helpStyle.Render(" " + glyphs.Dot + " " + review)
+	}
+	if m.narrow() {
+		return line
+	}
+	return kind + ": " + line + " " + helpStyle.Render(pr.URL)
+}
+
+// renderCILine renders the pull request's check results, e.g.
+// "CI: 1 failing · 4 passed".
+func (m model) renderCILine() string {
+	c := m.pr.Counts()
+	var parts []string
+	if c.Failed > 0 {
+		parts = append(parts, statusDeleted.Render(fmt.Sprintf("%d failing", c.Failed)))
+	}
+	if c.Pending > 0 {
+		parts = append(parts, statusModified.Render(fmt.Sprintf("%d pending", c.Pending)))
+	}
+	if c.Passed > 0 {
+		parts = append(parts, statusAdded.Render(fmt.Sprintf("%d passed", c.Passed)))
+	}
+	line := strings.Join(parts, helpStyle.Render(" "+glyphs.Dot+" "))
+	if m.narrow() {
+		return "CI " + line
+	}
+	return "CI: " + line
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Exit codes of the one-shot checks
+const (
+	exitClean  = 0
+	exitDirty  = 1 // uncommitted changes, with --exit-dirty
+	exitBehind = 2 // behind upstream, with --exit-behind
+)
+
+// oneShot configures a single status print, from "vigil prompt" or the
+// main command's --exit-dirty and --exit-behind
+type oneShot struct {
+	style      string
+	fetch      bool
+	exitDirty  bool
+	exitBehind bool
+	quiet      bool
+}
+
+// promptPart is one colored piece of the prompt line
+type promptPart struct {
+	text  string
+	color string // ANSI 256 color number, matching the TUI's styles
+}
+
+// promptStatus is what the prompt line shows
+type promptStatus struct {
+	branch   string
+	upstream bool
+	ahead    int
+	behind   int
+	summary  StatusSummary
+}
+
+// runPrompt implements "vigil prompt": print one compact status line for a
+// tmux status bar or shell prompt, e.g. "main ↑1 +2 !1 ?3".
+func runPrompt(args []string) int {
+	var o oneShot
+	fs := flag.NewFlagSet("prompt", flag.ExitOnError)
+	fs.StringVar(&o.style, "style", "none", "color escapes: none, ansi, bash, zsh or tmux")
+	dir := fs.String("C", "", "run as if started in `dir`, e.g. #{pane_current_path} in tmux")
+	ascii := fs.Bool("ascii", false, "draw with plain ASCII instead of unicode glyphs")
+	fs.BoolVar(&o.fetch, "fetch", false, "fetch before counting commits ahead/behind")
+	fs.BoolVar(&o.exitDirty, "exit-dirty", false, "exit 1 if there are uncommitted changes")
+	fs.BoolVar(&o.exitBehind, "exit-behind", false, "exit 2 if the branch is behind its upstream")
+	fs.BoolVar(&o.quiet, "q", false, "print nothing; only set the exit status")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: vigil prompt [flags]\n\nPrint the branch, ahead/behind and dirty counts on one line.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *ascii || !SupportsUnicode() {
+		glyphs = asciiGlyphs
+	}
+	if *dir != "" {
+		if err := os.Chdir(*dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 128
+		}
+	}
+	if !IsGitRepo() && !o.exitDirty && !o.exitBehind {
+		return exitClean // nothing to show outside a repository
+	}
+	return o.run()
+}
+
+// run prints the status line and returns the exit status. Being dirty
+// takes precedence over being behind.
+func (o oneShot) run() int {
+	colorize, ok := promptStyles[o.style]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown style %q (want none, ansi, bash, zsh or tmux)\n", o.style)
+		return 128
+	}
+	if !IsGitRepo() {
+		fmt.Fprintln(os.Stderr, "Error: Not a git repository")
+		return 128
+	}
+	if o.fetch {
+		if err := Fetch(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: fetch failed: %v\n", err)
+		}
+	}
+
+	s, err := daemonPromptStatus()
+	if o.fetch || err != nil {
+		s = readPromptStatus()
+	}
+	if !o.quiet {
+		var parts []string
+		for _, p := range s.parts() {
+			parts = append(parts, colorize(p))
+		}
+		fmt.Println(strings.Join(parts, " "))
+	}
+
+	switch {
+	case o.exitDirty && s.dirty():
+		return exitDirty
+	case o.exitBehind && s.behind > 0:
+		return exitBehind
+	}
+	return exitClean
+}
+
+func readPromptStatus() promptStatus {
+	s := promptStatus{branch: GetCurrentBranch()}
+	var err error
+	s.ahead, s.behind, err = GetCommitsAheadBehind()
+	s.upstream = err == nil
+	_, s.summary = GetGitStatus(Config{}.statusOptions()) // as git sees untracked files
+	return s
+}
+
+// daemonPromptStatus asks a running "vigil daemon" for the status instead
+// of running git, which keeps prompts fast in large repositories.
+func daemonPromptStatus() (promptStatus, error) {
+	f, err := QueryDaemon("status")
+	if err != nil {
+		return promptStatus{}, err
+	}
+	s := promptStatus{branch: f.Branch, upstream: f.Upstream, ahead: f.Ahead, behind: f.Behind}
+	s.summary.Staged = f.Summary.Staged
+	s.summary.Modified = f.Summary.Modified
+	s.summary.Untracked = f.Summary.Untracked
+	s.summary.Stashes = f.Summary.Stashes
+	s.summary.Conflicts = f.Summary.Conflicts
+	return s, nil
+}
+
+// dirty reports uncommitted changes of any kind, untracked files included.
+func (s promptStatus) dirty() bool {
+	return s.summary.Dirty()
+}
+
+// parts returns the prompt line's pieces, leaving out zero counts.
+func (s promptStatus) parts() []promptPart {
+	parts := []promptPart{{s.branch, "42"}}
+	if s.upstream && s.ahead > 0 {
+		parts = append(parts, promptPart{fmt.Sprintf("%s%d", glyphs.Up, s.ahead), "241"})
+	}
+	if s.upstream && s.behind > 0 {
+		parts = append(parts, promptPart{fmt.Sprintf("%s%d", glyphs.Down, s.behind), "241"})
+	}
+
+	counts := []struct {
+		n      int
+		symbol string
+		color  string
+	}{
+		{s.summary.Staged, "+", "42"},
+		{s.summary.Modified, "!", "214"},
+		{s.summary.Untracked, "?", "245"},
+		{s.summary.Stashes, "$", "241"},
+		{s.summary.Conflicts, "=", "196"},
+	}
+	for _, c := range counts {
+		if c.n > 0 {
+			parts = append(parts, promptPart{fmt.Sprintf("%s%d", c.symbol, c.n), c.color})
+		}
+	}
+	return parts
+}
+
+// promptStyles wrap a part in the color escapes each consumer understands
+var promptStyles = map[string]func(promptPart) string{
+	"none": func(p promptPart) string { return p.text },
+	"ansi": func(p promptPart) string { return "\x1b[38;5;" + p.color + "m" + p.text + "\x1b[0m" },
+	// bash needs non-printing sequences marked so it can measure the prompt
+	"bash": func(p promptPart) string {
+		return `\[` + "\x1b[38;5;" + p.color + `m\]` + p.text + `\[` + "\x1b[0m" + `\]`
+	},
+	"zsh":  func(p promptPart) string { return "%F{" + p.color + "}" + p.text + "%f" },
+	"tmux": func(p promptPart) string { return "#[fg=colour" + p.color + "]" + p.text + "#[default]" },
+}
@@ -0,0 +1,30 @@
+package main
+
+import "os"
+
+// IsReadOnly reports whether the repository can't be written to, as with
+// CI artifacts and mounted snapshots. git writes everything it changes
+// (the index, refs, objects) under the git dir, so that's what's tried.
+func IsReadOnly() bool {
+	dir, err := GetGitCommonDir()
+	if err != nil {
+		return false
+	}
+	f, err := os.CreateTemp(dir, ".vigil-write-test-*")
+	if err != nil {
+		return true
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return false
+}
+
+// readOnlyBlocked reports, and tells the user, when op would write to a
+// read-only repository, rather than letting git fail partway through.
+func (m *model) readOnlyBlocked(op string) bool {
+	if !m.readOnly {
+		return false
+	}
+	m.notify("Read-only filesystem; can't " + op)
+	return true
+}
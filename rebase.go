@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Git commands that can stop partway, to be continued or aborted
+const (
+	opRebase     = "rebase"
+	opCherryPick = "cherry-pick"
+)
+
+// Answers to the prompt for a rebase or cherry-pick in progress
+const (
+	sequenceContinue = "continue"
+	sequenceAbort    = "abort"
+)
+
+// sequenceDoneMsg reports that git rebase or cherry-pick gave the
+// terminal back, finished or stopped partway
+type sequenceDoneMsg struct {
+	op  string
+	err error
+}
+
+// startInteractiveRebase runs git rebase -i onto the parent of a commit on
+// the branch, so that commit is the first in the todo list. git takes over
+// the terminal for its editor until the rebase finishes or stops.
+func (m *model) startInteractiveRebase(hash string) tea.Cmd {
+	if m.toolConflict("rebase", "jj rebase or jj squash") {
+		return nil
+	}
+	if m.stoppedOp() != "" {
+		m.promptStopped()
+		return nil
+	}
+	if m.summary.Staged+m.summary.Modified+m.summary.Conflicts > 0 && GitConfig("rebase.autoStash") != "true" {
+		m.notify("Commit or stash your changes before rebasing")
+		return nil
+	}
+	args := []string{"rebase", "--interactive", hash + "^"}
+	if !RefExists(hash + "^") {
+		args = []string{"rebase", "--interactive", "--root"} // the branch's first commit
+	}
+	return tea.ExecProcess(exec.Command("git", args...), func(err error) tea.Msg {
+		return sequenceDoneMsg{op: opRebase, err: err}
+	})
+}
+
+// stoppedOp returns the git command stopped partway, if any.
+func (m model) stoppedOp() string {
+	switch {
+	case m.rebasing:
+		return opRebase
+	case m.picking:
+		return opCherryPick
+	}
+	return ""
+}
+
+// promptStopped offers to continue or abort the rebase or cherry-pick in
+// progress.
+func (m *model) promptStopped() {
+	op := m.stoppedOp()
+	m.choice = &choice{
+		prompt:  m.stoppedAt(op) + ":",
+		options: []string{sequenceContinue, sequenceAbort},
+		action: func(m *model, option string) tea.Cmd {
+			if option == sequenceContinue && m.summary.Conflicts > 0 {
+				m.notify("Resolve and stage the conflicted files first")
+				return nil
+			}
+			// Continuing may open the editor for a commit message
+			return tea.ExecProcess(exec.Command("git", op, "--"+option), func(err error) tea.Msg {
+				return sequenceDoneMsg{op: op, err: err}
+			})
+		},
+	}
+}
+
+// sequenceDone reports where a rebase or cherry-pick got to.
+func (m *model) sequenceDone(msg sequenceDoneMsg) tea.Cmd {
+	m.refresh()
+	switch op := m.stoppedOp(); {
+	case op != "" && m.summary.Conflicts > 0:
+		m.notify(fmt.Sprintf("%s on %s; resolve and stage them, then R to continue", m.stoppedAt(op), plural(m.summary.Conflicts, "conflicted file")))
+	case msg.err != nil:
+		m.notifyErr(fmt.Errorf("git %s: %w", msg.op, msg.err))
+	case op != "":
+		m.notify(m.stoppedAt(op) + "; R continues or aborts it")
+	default:
+		m.notify(capitalize(msg.op) + " finished")
+	}
+	return checkUpstream
+}
+
+// stoppedAt describes where op stopped, e.g. "Rebase stopped at 2/5".
+func (m model) stoppedAt(op string) string {
+	if op == opRebase && m.rebase.Total > 0 {
+		return fmt.Sprintf("Rebase stopped at %d/%d", m.rebase.Step, m.rebase.Total)
+	}
+	return capitalize(op) + " stopped"
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// refWatch follows the refs the branch diff depends on by reading them
+// from the git directory, which is much cheaper than running git on every
+// refresh. Each fingerprint covers HEAD, origin/HEAD, the packed refs and
+// reftable (whichever the repository uses) and the refs it's asked about,
+// so it changes whenever any of them moves.
+type refWatch struct {
+	mu        sync.Mutex
+	cwd       string // working directory the git dirs were found from
+	gitDir    string // HEAD lives here, per worktree
+	commonDir string // branches, remotes and packed refs live here
+}
+
+var refs refWatch
+
+// dirs returns the git directories, finding them again when the working
+// directory changes, as when switching to a bookmark.
+func (w *refWatch) dirs() (gitDir, commonDir string, ok bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", "", false
+	}
+	if cwd != w.cwd || w.gitDir == "" {
+		gitDir, err := GetGitDir()
+		if err != nil {
+			return "", "", false
+		}
+		commonDir, err := GetGitCommonDir()
+		if err != nil {
+			return "", "", false
+		}
+		w.cwd, w.gitDir, w.commonDir = cwd, gitDir, commonDir
+	}
+	return w.gitDir, w.commonDir, true
+}
+
+// fingerprint returns the state of HEAD and of names, which are short or
+// full ref names, or "" if the git directory can't be found.
+func (w *refWatch) fingerprint(names ...string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	gitDir, commonDir, ok := w.dirs()
+	if !ok {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(w.cwd)
+	read := func(path string) {
+		data, _ := os.ReadFile(path)
+		b.WriteString("\x00")
+		b.Write(data)
+	}
+	stat := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&b, "\x00%d.%d", info.ModTime().UnixNano(), info.Size())
+		} else {
+			b.WriteString("\x00-")
+		}
+	}
+
+	head, _ := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	b.Write(head)
+	if target, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: "); ok {
+		read(filepath.Join(commonDir, target))
+	}
+	read(filepath.Join(commonDir, "refs/remotes/origin/HEAD"))
+	stat(filepath.Join(commonDir, "packed-refs"))
+	stat(filepath.Join(commonDir, "reftable", "tables.list"))
+	for _, name := range names {
+		b.WriteString("\x00" + name)
+		// Where git looks up a short name, in its order
+		for _, prefix := range []string{"", "refs/", "refs/tags/", "refs/heads/", "refs/remotes/"} {
+			read(filepath.Join(commonDir, prefix+name))
+		}
+	}
+	return b.String()
+}
+
+// cacheMu guards the caches below, which commands running in the
+// background use too
+var cacheMu sync.Mutex
+
+// defaultBranchKey is the refs fingerprint GetDefaultBranch's cached
+// answer was found at
+var defaultBranchKey string
+
+// mergeBases caches GetMergeBase by base, with the refs fingerprint each
+// was found at
+var mergeBases = make(map[string]cachedMergeBase)
+
+type cachedMergeBase struct {
+	key  string
+	base string
+}
+
+// branchKey returns the refs fingerprint the branch diff against base
+// depends on: HEAD, the base, and the notes shown with its commits.
+func branchKey(base string) string {
+	if base == "" {
+		base = GetDefaultBranch()
+	}
+	return refs.fingerprint(base, "refs/notes/commits")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// reflogLimit is how many HEAD movements the reflog view lists
+const reflogLimit = 100
+
+// openReflog switches to the list of recent HEAD movements.
+func (m *model) openReflog() {
+	entries, err := GetReflog(reflogLimit)
+	if err != nil {
+		m.notifyErr(err)
+		return
+	}
+	m.reflog = entries
+	m.view = viewReflog
+	m.cursor = 0
+	m.viewport.GotoTop()
+	m.resize()
+}
+
+// updateReflog handles key input in the reflog view: checking out or
+// resetting the branch to an earlier position of HEAD.
+func (m model) updateReflog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "H":
+		m.view = viewFiles
+		m.resize()
+		return m, tea.ClearScreen
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(m.reflog)-1, 0))
+	case "r":
+		m.reloadReflog()
+	case "enter":
+		if m.cursor >= len(m.reflog) || m.toolConflict("check out", "jj edit") {
+			return m, nil
+		}
+		e := m.reflog[m.cursor]
+		m.confirm = &confirmation{
+			prompt: fmt.Sprintf("Check out %s (%s) with a detached HEAD?", e.Hash, e.Selector),
+			action: func(m *model) tea.Cmd {
+				if err := CheckoutDetached(e.Hash); err != nil {
+					m.notifyErr(err)
+					return nil
+				}
+				m.notify("Checked out " + e.Hash)
+				m.refresh()
+				m.cursor = 0 // the move just made
+				m.reloadReflog()
+				return checkUpstream
+			},
+		}
+		return m, nil
+	case "R":
+		if m.cursor >= len(m.reflog) || m.toolConflict("reset", "jj op restore") {
+			return m, nil
+		}
+		e := m.reflog[m.cursor]
+		prompt := fmt.Sprintf("Reset %s to %s (%s)?", m.branch, e.Hash, e.Selector)
+		if m.summary.Staged+m.summary.Modified+m.summary.Conflicts > 0 {
+			prompt += " Uncommitted changes will be lost."
+		}
+		m.confirm = &confirmation{
+			prompt: prompt,
+			action: func(m *model) tea.Cmd {
+				if err := ResetHard(e.Hash); err != nil {
+					m.notifyErr(err)
+					return nil
+				}
+				m.notify("Reset to " + e.Hash + "; HEAD@{1} is where it was")
+				m.refresh()
+				m.cursor = 0 // the move just made
+				m.reloadReflog()
+				return checkUpstream
+			},
+		}
+		return m, nil
+	}
+
+	m.resize()
+	m.scrollTo(m.cursor + 1)
+	return m, nil
+}
+
+// reloadReflog rereads the reflog, keeping the selection in range.
+func (m *model) reloadReflog() {
+	entries, err := GetReflog(reflogLimit)
+	if err != nil {
+		m.notifyErr(err)
+		return
+	}
+	m.reflog = entries
+	m.cursor = min(m.cursor, max(len(m.reflog)-1, 0))
+	m.resize()
+}
+
+func (m model) renderReflog() string {
+	var body strings.Builder
+	body.WriteString("Reflog (HEAD):\n")
+	if len(m.reflog) == 0 {
+		body.WriteString(helpStyle.Render("  No HEAD movements recorded"))
+		return body.String()
+	}
+
+	for i, e := range m.reflog {
+		line := fmt.Sprintf("%s %s %s %s", commitHashStyle.Render(e.Hash), helpStyle.Render(fmt.Sprintf("%-10s", e.Selector)), e.Subject, helpStyle.Render("("+m.timeAgo(e.Time)+")"))
+		if m.narrow() {
+			line = truncate(fmt.Sprintf("%s %s", commitHashStyle.Render(e.Hash), e.Subject), m.width-1)
+		}
+		body.WriteString(m.cursorColumn(i == m.cursor) + line + "\n")
+	}
+	return body.String()
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// repoState is what a refresh reads from git
+type repoState struct {
+	branch     string
+	tool       vcsTool
+	lastCommit Commit
+	hasCommit  bool
+	release    Release
+	hasRelease bool
+	rebase     RebaseState
+	rebasing   bool
+	picking    bool
+	changes    []FileChange
+	summary    StatusSummary // stashes included
+
+	// The branch diff, only read again when the refs it depends on moved
+	branchKey   string
+	branchMoved bool
+	branchFiles []BranchFile
+	commits     []Commit
+
+	// What the loaders built on the above
+	loaded loaded
+
+	took, status, diff, loads time.Duration
+}
+
+// refreshedMsg is a background refresh's result, dropped if a newer
+// refresh has run since it started
+type refreshedMsg struct {
+	gen   int
+	state repoState
+	ok    bool // false if it was canceled
+}
+
+// queryRepo reads a refresh's worth of state from git, running the queries
+// that don't depend on each other at once, so a refresh takes as long as
+// its slowest query rather than all of them, then runs the loaders over
+// it the same way. The branch is only diffed against the base when its
+// refs fingerprint differs from lastKey. It reports false if ctx was
+// canceled, killing the slow queries, before it finished.
+func queryRepo(ctx context.Context, set loadSettings, opts StatusOptions, lastKey string) (repoState, bool) {
+	start := time.Now()
+	var s repoState
+	jobs := []func(){
+		func() { s.branch = GetCurrentBranch() },
+		func() { s.tool = detectTool() },
+		func() { s.lastCommit, s.hasCommit = GetLastCommit() },
+		func() { s.release, s.hasRelease = GetRelease() },
+		func() { s.rebase, s.rebasing = GetRebaseState() },
+		func() { s.picking = CherryPickInProgress() },
+		func() {
+			start := time.Now()
+			s.changes, s.summary = GetGitStatusContext(ctx, opts)
+			s.status = time.Since(start)
+		},
+		func() {
+			s.branchKey = branchKey(set.base)
+			s.branchMoved = s.branchKey == "" || s.branchKey != lastKey
+			if !s.branchMoved {
+				// The loaders still need them
+				s.branchFiles, s.commits = set.branchFiles, set.commits
+				return
+			}
+			start := time.Now()
+			s.branchFiles = GetBranchDiffFilesContext(ctx, set.base)
+			s.commits = GetBranchCommitsContext(ctx, set.base)
+			s.diff = time.Since(start)
+		},
+	}
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Go(job)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return s, false
+	}
+
+	loads := time.Now()
+	s.loaded = newLoadRun(ctx, set, &s).load()
+	s.loads = time.Since(loads)
+	s.took = time.Since(start)
+	return s, ctx.Err() == nil
+}
+
+// loadSettings are the model's settings and caches the loaders read,
+// copied when a refresh starts so its jobs share nothing with the UI
+type loadSettings struct {
+	base        string
+	sort        sortOrder
+	showAuthors bool
+	authors     fileAuthors
+	largeSize   int64
+	cruftChecks []cruftPattern
+	secretScan  SecretsConfig
+	branchFiles []BranchFile   // the branch diff, used while it hasn't moved
+	commits     []Commit       // likewise
+	whitespace  whitespaceOnly // the branch's is kept unless it moved
+	sizes       fileSizes      // likewise
+	todos       branchTodos    // kept until the branch's commits change
+}
+
+// loadSettings copies what the loaders read from the model.
+func (m model) loadSettings() loadSettings {
+	return loadSettings{
+		base:        m.base,
+		sort:        m.sort,
+		showAuthors: m.showAuthors,
+		authors:     m.authors.clone(),
+		largeSize:   m.largeSize,
+		cruftChecks: m.cruftChecks,
+		secretScan:  m.secretScan,
+		branchFiles: m.branchFiles,
+		commits:     m.commits,
+		whitespace:  m.whitespace,
+		sizes:       m.sizes,
+		todos:       m.todos,
+	}
+}
+
+// loaded is what the loaders build on a refresh's state: the sort keys,
+// columns and badges of the file panels
+type loaded struct {
+	sortKeys   sortKeys
+	authors    fileAuthors
+	whitespace whitespaceOnly
+	sizes      fileSizes
+	cruft      map[string][]string
+	secrets    []secretFinding
+	generated  map[string]bool
+	todos      branchTodos
+	owners     map[string][]string
+}
+
+// loadRun is one run of the loaders over a refresh's state. The diffs
+// more than one of them needs are read once, by whichever asks first.
+type loadRun struct {
+	ctx context.Context
+	set loadSettings
+	s   *repoState
+
+	root        func() string
+	mergeBase   func() string              // "" without one
+	headStats   func() map[string]DiffStat // uncommitted changes, against HEAD
+	branchStats func() map[string]DiffStat // the branch's, since the merge base
+}
+
+func newLoadRun(ctx context.Context, set loadSettings, s *repoState) *loadRun {
+	r := &loadRun{ctx: ctx, set: set, s: s}
+	r.root = sync.OnceValue(func() string {
+		root, _ := GetRepoRoot()
+		return root
+	})
+	r.mergeBase = sync.OnceValue(func() string {
+		base, _ := GetMergeBase(set.base)
+		return base
+	})
+	r.headStats = sync.OnceValue(func() map[string]DiffStat {
+		if !s.hasCommit {
+			return nil
+		}
+		return GetDiffStatsContext(ctx, "HEAD")
+	})
+	r.branchStats = sync.OnceValue(func() map[string]DiffStat {
+		base := r.mergeBase()
+		if base == "" {
+			return nil
+		}
+		return GetDiffStatsContext(ctx, base, "HEAD")
+	})
+	return r
+}
+
+// newLoadRun starts a run of the loaders over the model's state as it is,
+// for those run again when a setting changes.
+func (m model) newLoadRun() *loadRun {
+	s := repoState{
+		lastCommit:  m.lastCommit,
+		hasCommit:   m.hasCommit,
+		changes:     m.changes,
+		summary:     m.summary,
+		branchMoved: m.branchMoved,
+		branchFiles: m.branchFiles,
+	}
+	return newLoadRun(context.Background(), m.loadSettings(), &s)
+}
+
+// load runs the loaders, at once except where one needs another's
+// result: cruft and TODOs skip files whose diffs are skipped, and TODOs
+// generated files.
+func (r *loadRun) load() loaded {
+	var l loaded
+	var wg sync.WaitGroup
+	wg.Go(func() { l.sortKeys = r.sortKeys() })
+	wg.Go(func() { l.authors = r.authors() })
+	wg.Go(func() { l.whitespace = r.whitespace() })
+	wg.Go(func() { l.secrets = r.secrets() })
+	wg.Go(func() { l.owners = r.owners() })
+	wg.Go(func() {
+		var first, then sync.WaitGroup
+		first.Go(func() { l.sizes = r.sizes() })
+		first.Go(func() { l.generated = r.generated() })
+		first.Wait()
+		then.Go(func() { l.cruft = r.cruft(l.sizes) })
+		then.Go(func() { l.todos = r.todos(l.sizes, l.generated) })
+		then.Wait()
+	})
+	wg.Wait()
+	return l
+}
+
+// refresh reloads everything from git, replacing any refresh running in
+// the background.
+func (m *model) refresh() {
+	m.cancelRefresh()
+	if m.replay != nil {
+		m.refreshWith(func() { m.replay.apply(m) })
+		return
+	}
+	s, _ := queryRepo(context.Background(), m.loadSettings(), m.statusOpts, m.branchKey)
+	m.refreshWith(func() { m.setRepoState(s) })
+}
+
+// refreshInBackground starts a refresh off the UI's goroutine, so keys
+// are still handled while git works; its result arrives as a
+// refreshedMsg. Any refresh already running is canceled.
+func (m *model) refreshInBackground() tea.Cmd {
+	m.cancelRefresh()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.stopRefresh = cancel
+	gen, set, opts, key := m.refreshGen, m.loadSettings(), m.statusOpts, m.branchKey
+	return func() tea.Msg {
+		defer cancel()
+		s, ok := queryRepo(ctx, set, opts, key)
+		return refreshedMsg{gen: gen, state: s, ok: ok}
+	}
+}
+
+// cancelRefresh stops the background refresh in flight, if any: whatever
+// it would find is about to be older than what replaces it.
+func (m *model) cancelRefresh() {
+	m.refreshGen++
+	if m.stopRefresh != nil {
+		m.stopRefresh()
+		m.stopRefresh = nil
+	}
+}
+
+// setRepoState takes in what queryRepo read and the loaders built on it.
+func (m *model) setRepoState(s repoState) {
+	start := time.Now()
+	m.branch, m.tool = s.branch, s.tool
+	m.lastCommit, m.hasCommit = s.lastCommit, s.hasCommit
+	m.release, m.hasRelease = s.release, s.hasRelease
+	m.rebase, m.rebasing = s.rebase, s.rebasing
+	m.picking = s.picking
+	m.changes, m.summary = s.changes, s.summary
+	m.metrics.status.add(s.status)
+	m.branchMoved = s.branchMoved
+	if s.branchMoved {
+		m.branchFiles, m.commits, m.branchKey = s.branchFiles, s.commits, s.branchKey
+		m.metrics.diff.add(s.diff)
+	}
+	m.setLoaded(s.loaded)
+	took := s.took + time.Since(start)
+	m.metrics.refresh.add(took)
+	debugLog.Debug("refresh", "duration", took, "queries", s.took-s.loads, "loads", s.loads, "status", s.status, "diff", s.diff,
+		"branch_moved", s.branchMoved, "changes", len(s.changes))
+}
+
+// setLoaded takes in what the loaders found, alerting when possible
+// secrets are newly staged.
+func (m *model) setLoaded(l loaded) {
+	prevSecrets := len(m.secrets)
+	m.sortKeys = l.sortKeys
+	*m.authors = l.authors
+	m.whitespace = l.whitespace
+	m.sizes = l.sizes
+	m.cruft = l.cruft
+	m.secrets = l.secrets
+	m.generated = l.generated
+	m.todos = l.todos
+	m.owners = l.owners
+	if len(m.secrets) > prevSecrets {
+		m.alert("Possible secrets staged", m.secrets[0].String())
+	}
+}
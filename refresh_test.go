@@ -0,0 +1,40 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"vigil/internal/fixture"
+)
+
+func TestRefreshKeepsBranchLoads(t *testing.T) {
+	repo, err := fixture.Annotated(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+	m := initialModel(Config{Base: fixture.Base}, RepoState{})
+
+	// The first refresh diffs the branch; the others find it hasn't moved
+	for i := range 3 {
+		msg, ok := m.refreshInBackground()().(refreshedMsg)
+		if !ok || !msg.ok {
+			t.Fatalf("refresh %d: got %+v", i+1, msg)
+		}
+		if moved := msg.state.branchMoved; moved != (i == 0) {
+			t.Errorf("refresh %d: branch moved = %v", i+1, moved)
+		}
+		next, _ := m.Update(msg)
+		m = next.(model)
+
+		if owners := m.owners[fixture.BranchAdded]; !slices.Equal(owners, []string{fixture.Owner}) {
+			t.Errorf("refresh %d: owners of %s = %v, want %s", i+1, fixture.BranchAdded, owners, fixture.Owner)
+		}
+		if !m.isGenerated(fixture.Generated) {
+			t.Errorf("refresh %d: %s isn't generated", i+1, fixture.Generated)
+		}
+		if c := m.todos.comments; len(c) != 1 || c[0].file != fixture.BranchAdded || c[0].text != "TODO: "+fixture.Todo {
+			t.Errorf("refresh %d: TODOs = %+v", i+1, c)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func (m *model) push(force bool) tea.Cmd {
+	if m.readOnlyBlocked("push") {
+		return nil
+	}
+	if force {
+		return m.startOp("Force push", "Force pushing", func() error { return Push(true) })
+	}
+	return m.startOp("Push", "Pushing", func() error { return Push(false) })
+}
+
+func (m *model) pull() tea.Cmd {
+	if m.toolConflict("pull", "jj git fetch and jj rebase") {
+		return nil
+	}
+	mode := m.pullMode
+	return m.startOp("Pull", "Pulling", func() error { return Pull(mode) })
+}
+
+// confirmForcePush asks before pushing with --force-with-lease.
+func (m *model) confirmForcePush() {
+	m.confirm = &confirmation{
+		prompt: fmt.Sprintf("Force push %s with lease?", m.branch),
+		action: func(m *model) tea.Cmd { return m.push(true) },
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// frame is one recorded state of the repository: the status file's
+// fields plus what the header shows
+type frame struct {
+	At int64 `json:"at_ms"` // since the recording started
+	statusFile
+	Commit  *Commit  `json:"commit,omitempty"`
+	Release *Release `json:"release,omitempty"`
+}
+
+// recorder appends a frame to the --record file whenever the state changes
+type recorder struct {
+	file  *os.File
+	start time.Time
+	last  []byte // last frame written, without its time
+}
+
+// replayer feeds recorded frames to the model in place of git
+type replayer struct {
+	frames []frame
+	index  int // frame shown
+	speed  float64
+	loop   bool
+}
+
+// replayMsg advances a replay to its next frame
+type replayMsg struct{}
+
+// replayKeys are the files view keys that only change what's shown, and
+// so work while replaying
+var replayKeys = map[string]bool{
+	"q": true, "ctrl+c": true, "esc": true, "?": true, "/": true,
+	"up": true, "k": true, "down": true, "j": true, "pgup": true, "pgdown": true,
+	"home": true, "g": true, "end": true, "G": true,
+	"s": true, "t": true, "enter": true, " ": true, "l": true, "L": true, "Z": true,
+}
+
+func newRecorder(path string) (*recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &recorder{file: file, start: time.Now()}, nil
+}
+
+// frame captures the model's current state.
+func (m model) frame() frame {
+	f := frame{statusFile: m.snapshot()}
+	if m.hasCommit {
+		commit := m.lastCommit
+		f.Commit = &commit
+	}
+	if m.hasRelease {
+		release := m.release
+		f.Release = &release
+	}
+	return f
+}
+
+// record writes the current state to the --record file, if it changed.
+func (m *model) record() error {
+	r := m.recorder
+	if r == nil {
+		return nil
+	}
+	f := m.frame()
+	untimed, err := json.Marshal(f)
+	if err != nil || bytes.Equal(untimed, r.last) {
+		return err
+	}
+	f.At = time.Since(r.start).Milliseconds()
+	f.Updated = time.Now()
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if _, err := r.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	r.last = untimed
+	return nil
+}
+
+// loadFrames reads a recording made with --record.
+func loadFrames(path string) ([]frame, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var frames []frame
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20) // a frame lists every changed file
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var f frame
+		if err := json.Unmarshal(scanner.Bytes(), &f); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		frames = append(frames, f)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("%s: no frames recorded", path)
+	}
+	return frames, nil
+}
+
+// apply sets the model's repository state from the current frame.
+func (r *replayer) apply(m *model) {
+	f := r.frames[r.index]
+	m.dir, m.branch, m.base = f.Dir, f.Branch, f.Base
+	m.lastCommit, m.hasCommit = Commit{}, f.Commit != nil
+	if f.Commit != nil {
+		m.lastCommit = *f.Commit
+	}
+	m.release, m.hasRelease = Release{}, f.Release != nil
+	if f.Release != nil {
+		m.release = *f.Release
+	}
+	m.summary = StatusSummary(f.Summary)
+	m.changes = nil
+	for _, c := range f.Changes {
+		if len(c.Status) == 2 {
+			m.changes = append(m.changes, FileChange{Staged: c.Status[0], Unstaged: c.Status[1], Label: c.Label, File: c.Path})
+		}
+	}
+	m.branchFiles, m.commits = nil, nil
+	for _, bf := range f.BranchFiles {
+		m.branchFiles = append(m.branchFiles, BranchFile{Status: bf.Status, File: bf.Path})
+	}
+	m.ahead, m.behind, m.counted = f.Ahead, f.Behind, true
+	m.upstreamErr = nil
+	if !f.Upstream {
+		m.upstreamErr = errors.New("no upstream")
+	}
+}
+
+// next schedules the move to the following frame, at the pace it was
+// recorded (scaled by the replay speed).
+func (r *replayer) next() tea.Cmd {
+	if r.index+1 >= len(r.frames) {
+		if !r.loop {
+			return nil
+		}
+		return tea.Tick(3*time.Second, func(time.Time) tea.Msg { return replayMsg{} })
+	}
+	wait := time.Duration(float64(r.frames[r.index+1].At-r.frames[r.index].At)/r.speed) * time.Millisecond
+	return tea.Tick(wait, func(time.Time) tea.Msg { return replayMsg{} })
+}
+
+// advance moves to the next frame, back to the first when looping.
+func (r *replayer) advance() {
+	r.index++
+	if r.index >= len(r.frames) {
+		r.index = 0
+	}
+}
+
+// runReplay implements "vigil replay": play back a recording made with
+// --record, or a built-in demo, without a repository.
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := fs.Float64("speed", 1, "playback speed multiplier")
+	loop := fs.Bool("loop", false, "start over after the last frame")
+	demo := fs.Bool("demo", false, "play a built-in demo instead of a recording")
+	colorMode := fs.String("color", "", "color mode: auto, truecolor, 256, 16 or none (default auto, or none with --render)")
+	ascii := fs.Bool("ascii", false, "draw with plain ASCII instead of unicode glyphs")
+	render := fs.String("render", "", "print every frame rendered at these `sizes` (e.g. 100x30,40x20) instead of playing")
+	golden := fs.String("golden", "", "with --render, compare the frames with the files in `dir`")
+	update := fs.Bool("update", false, "with --golden, rewrite the files instead of comparing")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: vigil replay [flags] <recording>\n       vigil replay --demo [flags]\n\nPlay back a recording made with \"vigil --record <file>\".\n\nWith --render and --golden it instead renders each frame at fixed sizes\nand compares them with golden files, exiting 1 if any differ, for\ncatching layout and styling changes in CI.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *colorMode == "" && *render != "" {
+		*colorMode = "none" // the same output whatever the terminal
+	}
+	if err := setupTerminal(*colorMode, *ascii); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *speed <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --speed must be positive")
+		return 1
+	}
+
+	var frames []frame
+	switch {
+	case *demo:
+		frames = demoFrames(time.Now())
+	case fs.NArg() == 1:
+		var err error
+		if frames, err = loadFrames(fs.Arg(0)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	default:
+		fs.Usage()
+		return 1
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+	// Nothing in a replay should reach git or the outside world
+	cfg.AutoFetch = false
+	cfg.Watch = WatchConfig{}
+	cfg.Commands, cfg.Webhooks, cfg.Alerts = nil, nil, nil
+
+	m := initialModel(cfg, RepoState{})
+	m.setTitle = false
+	m.notifyMode = notifyOff
+	m.replay = &replayer{frames: frames, speed: *speed, loop: *loop}
+
+	if *render != "" {
+		sizes, err := parseSizes(*render)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		screens := renderScreens(m, sizes)
+		if *golden == "" {
+			for _, s := range screens {
+				fmt.Printf("--- %s\n%s", s.name, s.view)
+			}
+			return 0
+		}
+		if err := checkGolden(*golden, screens, *update); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	m.refresh()
+
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	if _, err := p.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// demoFrames is a made-up session on a feature branch: editing, staging,
+// committing, falling behind and resolving a conflict, three seconds apart.
+func demoFrames(now time.Time) []frame {
+	type step struct {
+		changes  []statusFileEntry
+		commit   Commit
+		ahead    int
+		behind   int
+		branches []statusFileEntry
+	}
+	modified := func(path string) statusFileEntry { return statusFileEntry{Path: path, Status: " M", Label: "modified"} }
+	staged := func(path string) statusFileEntry {
+		return statusFileEntry{Path: path, Status: "M ", Label: "modified (staged)"}
+	}
+	untracked := func(path string) statusFileEntry {
+		return statusFileEntry{Path: path, Status: "??", Label: "untracked"}
+	}
+	conflict := func(path string) statusFileEntry { return statusFileEntry{Path: path, Status: "UU", Label: "conflict"} }
+	branchFile := func(status, path string) statusFileEntry {
+		return statusFileEntry{Path: path, Status: status, Label: branchFileLabel(status)}
+	}
+
+	first := Commit{Hash: "3f9c2e1", Subject: "Add search index", Author: "Ada Lovelace", Time: now.Add(-2 * time.Hour)}
+	second := Commit{Hash: "a81d47b", Subject: "Parse search queries", Author: "Ada Lovelace", Time: now.Add(12 * time.Second)}
+	merge := Commit{Hash: "c05e9f3", Subject: "Merge branch 'main' into feature/search", Author: "Ada Lovelace", Time: now.Add(21 * time.Second)}
+	before := []statusFileEntry{branchFile("A", "search/index.go"), branchFile("M", "README.md")}
+	after := []statusFileEntry{branchFile("A", "search/index.go"), branchFile("M", "README.md"), branchFile("A", "search/query.go"), branchFile("A", "search/query_test.go")}
+
+	steps := []step{
+		{commit: first, ahead: 1, branches: before},
+		{changes: []statusFileEntry{untracked("search/query.go")}, commit: first, ahead: 1, branches: before},
+		{changes: []statusFileEntry{modified("README.md"), untracked("search/query.go"), untracked("search/query_test.go")}, commit: first, ahead: 1, branches: before},
+		{changes: []statusFileEntry{modified("README.md"), {Path: "search/query.go", Status: "A ", Label: "added (staged)"}, {Path: "search/query_test.go", Status: "A ", Label: "added (staged)"}}, commit: first, ahead: 1, branches: before},
+		{changes: []statusFileEntry{modified("README.md")}, commit: second, ahead: 2, branches: after},
+		{changes: []statusFileEntry{modified("README.md")}, commit: second, ahead: 2, behind: 3, branches: after},
+		{changes: []statusFileEntry{conflict("search/index.go"), staged("go.mod"), modified("README.md")}, commit: second, ahead: 2, behind: 3, branches: after},
+		{changes: []statusFileEntry{modified("README.md")}, commit: merge, ahead: 6, branches: after},
+	}
+
+	var frames []frame
+	for i, s := range steps {
+		f := frame{At: int64(i) * 3000, Commit: &s.commit}
+		f.Updated = now.Add(time.Duration(f.At) * time.Millisecond)
+		f.Release = &Release{Describe: "v1.4.0-" + fmt.Sprint(s.ahead+2) + "-g" + s.commit.Hash, Tag: "v1.4.0", Distance: s.ahead + 2}
+		f.Dir, f.Branch, f.Head, f.Base = "/home/ada/src/catalog", "feature/search", s.commit.Hash, "main"
+		f.Upstream, f.Ahead, f.Behind = true, s.ahead, s.behind
+		f.Changes, f.BranchFiles = s.changes, s.branches
+		for _, c := range s.changes {
+			switch {
+			case c.Status == "??":
+				f.Summary.Untracked++
+			case c.Status == "UU":
+				f.Summary.Conflicts++
+			case c.Status[0] != ' ':
+				f.Summary.Staged++
+			default:
+				f.Summary.Modified++
+			}
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SecretsConfig turns on scanning staged changes for credentials
+type SecretsConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// Allow are path patterns never scanned, such as test fixtures with
+	// fake keys, matched against the whole path or its file name
+	Allow []string `json:"allow"`
+}
+
+// secretRule is a kind of credential and how to spot it in a line
+type secretRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+var secretRules = []secretRule{
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"private key", regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY( BLOCK)?-----`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"GitLab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_\-]{20,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{20,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+}
+
+// secretAssignment finds a long value given to something named like a
+// credential, e.g. api_key = "...", which is a finding if it looks random
+var secretAssignment = regexp.MustCompile(`(?i)(secret|token|passw(or)?d|api_?key|access_?key|auth)[A-Za-z0-9_]*["']?\s*[:=]\s*["']?([A-Za-z0-9/+=_\-.]{20,})`)
+
+// minSecretEntropy is the bits per character from which an assigned value
+// looks random enough to be a credential rather than a placeholder
+const minSecretEntropy = 3.5
+
+// secretFinding is a possible credential in a staged line
+type secretFinding struct {
+	File string
+	Line int
+	Rule string
+}
+
+func (f secretFinding) String() string {
+	return fmt.Sprintf("%s in %s:%d", f.Rule, f.File, f.Line)
+}
+
+// addedLine is a line a diff adds, numbered as in the new version of its
+// file
+type addedLine struct {
+	file string
+	line int
+	text string
+}
+
+// GetStagedLines returns the lines the staged changes add.
+func GetStagedLines(ctx context.Context) []addedLine {
+	return diffAddedLines(ctx, "--cached")
+}
+
+// diffAddedLines returns the lines added by git diff with args.
+func diffAddedLines(ctx context.Context, args ...string) []addedLine {
+	args = append([]string{"diff", "--unified=0", "--no-color", "--no-ext-diff"}, args...)
+	output, err := gitCommandContext(ctx, args...).Output()
+	if err != nil {
+		return nil
+	}
+	var lines []addedLine
+	var file string
+	next := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file = ""
+		case strings.HasPrefix(line, "+++ "):
+			file = strings.TrimPrefix(line[4:], "b/")
+		case strings.HasPrefix(line, "@@ "):
+			// "@@ -a,b +c,d @@": added lines start at c
+			if _, after, ok := strings.Cut(line, " +"); ok {
+				start, _, _ := strings.Cut(after, " ")
+				start, _, _ = strings.Cut(start, ",")
+				next, _ = strconv.Atoi(start)
+			}
+		case strings.HasPrefix(line, "+") && file != "":
+			lines = append(lines, addedLine{file: file, line: next, text: line[1:]})
+			next++
+		}
+	}
+	return lines
+}
+
+// scanSecrets checks lines for credentials, skipping allowed paths.
+func scanSecrets(lines []addedLine, allow []string) []secretFinding {
+	var findings []secretFinding
+	for _, l := range lines {
+		if allowedPath(l.file, allow) {
+			continue
+		}
+		if rule := matchSecret(l.text); rule != "" {
+			findings = append(findings, secretFinding{File: l.file, Line: l.line, Rule: rule})
+		}
+	}
+	return findings
+}
+
+// matchSecret returns the kind of credential a line seems to hold, or "".
+func matchSecret(line string) string {
+	for _, r := range secretRules {
+		if r.re.MatchString(line) {
+			return r.name
+		}
+	}
+	if m := secretAssignment.FindStringSubmatch(line); m != nil && entropy(m[3]) >= minSecretEntropy {
+		return "high-entropy " + strings.ToLower(m[1])
+	}
+	return ""
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var bits float64
+	n := float64(len([]rune(s)))
+	for _, c := range counts {
+		p := float64(c) / n
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
+
+// allowedPath reports whether a path matches one of the allow patterns.
+func allowedPath(path string, allow []string) bool {
+	for _, pattern := range allow {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// secrets scans the staged changes.
+func (r *loadRun) secrets() []secretFinding {
+	if !r.set.secretScan.Enabled || r.s.summary.Staged == 0 {
+		return nil
+	}
+	return scanSecrets(GetStagedLines(r.ctx), r.set.secretScan.Allow)
+}
+
+// secretsInFile counts the findings in a file.
+func (m model) secretsInFile(file string) int {
+	n := 0
+	for _, f := range m.secrets {
+		if f.File == treePath(file) {
+			n++
+		}
+	}
+	return n
+}
+
+// secretsBadge flags a file with possible secrets staged.
+func (m model) secretsBadge(file, text string) string {
+	n := m.secretsInFile(file)
+	if n == 0 {
+		return text
+	}
+	return text + " " + errorStyle.Render(glyphs.Warn+" "+plural(n, "secret"))
+}
+
+// renderSecretsLine warns of possible secrets in the staged changes, so
+// they're seen before the commit.
+func (m model) renderSecretsLine() string {
+	first := m.secrets[0]
+	line := fmt.Sprintf("%s Possible secret staged: %s", glyphs.Warn, first)
+	if len(m.secrets) > 1 {
+		line += fmt.Sprintf(" (and %d more)", len(m.secrets)-1)
+	}
+	if m.narrow() {
+		line = fmt.Sprintf("%s %s staged", glyphs.Warn, plural(len(m.secrets), "secret"))
+	}
+	return errorStyle.Render(truncate(line, m.width))
+}
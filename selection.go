@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// toggleMarked marks or unmarks the selected uncommitted change for a
+// batch action. On a directory row it collapses the directory instead.
+func (m *model) toggleMarked() {
+	row, ok := m.selectedRow()
+	if !ok || row.dir != "" {
+		m.toggleCollapsed()
+		return
+	}
+	if row.panel != panelChanges {
+		return
+	}
+	if m.marked[row.file] {
+		delete(m.marked, row.file)
+	} else {
+		m.marked[row.file] = true
+	}
+	m.moveSelection(1)
+}
+
+// toggleMarkedAll marks every listed change, or unmarks them all when they
+// already are. With a filter, only the matching files are listed.
+func (m *model) toggleMarkedAll() {
+	var files []string
+	for _, r := range m.visibleRows() {
+		if r.panel == panelChanges && r.file != "" {
+			files = append(files, r.file)
+		}
+	}
+	all := true
+	for _, f := range files {
+		all = all && m.marked[f]
+	}
+	for _, f := range files {
+		if all {
+			delete(m.marked, f)
+		} else {
+			m.marked[f] = true
+		}
+	}
+	m.resize()
+}
+
+// pruneMarked drops marks on files that no longer have changes.
+func (m *model) pruneMarked() {
+	for file := range m.marked {
+		if _, ok := m.change(file); !ok {
+			delete(m.marked, file)
+		}
+	}
+}
+
+// targetChanges returns the changes a batch action applies to: the marked
+// ones, or else the selected one.
+func (m model) targetChanges() []FileChange {
+	var changes []FileChange
+	if len(m.marked) > 0 {
+		for _, c := range m.changes {
+			if m.marked[c.File] {
+				changes = append(changes, c)
+			}
+		}
+		return changes
+	}
+	if row, ok := m.selectedRow(); ok && row.panel == panelChanges && row.file != "" {
+		if c, found := m.change(row.file); found {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// changePaths returns the paths git knows a change by: both sides of a
+// rename, otherwise just the file.
+func changePaths(c FileChange) []string {
+	if from, to, ok := strings.Cut(c.File, " -> "); ok {
+		return []string{from, to}
+	}
+	return []string{c.File}
+}
+
+// applyToChanges runs a git operation on the changes' paths, unmarking
+// them once it succeeds.
+func (m *model) applyToChanges(changes []FileChange, verb string, op func(paths ...string) error) {
+	if len(changes) == 0 {
+		return
+	}
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, changePaths(c)...)
+	}
+	if err := op(paths...); err != nil {
+		m.notifyErr(err)
+		m.refresh()
+		return
+	}
+	m.unmark(changes)
+	m.notify(verb + " " + plural(len(changes), "file"))
+	m.refresh()
+}
+
+// stageChanges stages changes.
+func (m *model) stageChanges(changes []FileChange) {
+	if m.toolConflict("stage", "jj split or jj squash") {
+		return
+	}
+	changes = m.withoutRepos(changes, "stage", false)
+	var large []string
+	for _, c := range changes {
+		if f := m.fileSize(panelChanges, c.File); m.warnLarge && f.bytes > 0 {
+			large = append(large, fmt.Sprintf("%s (%s)", treePath(c.File), formatSize(f.bytes)))
+		}
+	}
+	if len(large) > 0 {
+		it := "it"
+		if len(large) > 1 {
+			it = "them"
+		}
+		// Once committed, a large file stays in the history for good
+		m.confirm = &confirmation{
+			prompt: fmt.Sprintf("Stage %s? Committing %s keeps %s in the history for good.", strings.Join(large, ", "), it, it),
+			action: func(m *model) tea.Cmd {
+				m.applyToChanges(changes, "Staged", Stage)
+				return nil
+			},
+		}
+		return
+	}
+	m.applyToChanges(changes, "Staged", Stage)
+}
+
+// unstageChanges unstages changes.
+func (m *model) unstageChanges(changes []FileChange) {
+	if m.toolConflict("unstage", "jj split or jj squash") {
+		return
+	}
+	m.applyToChanges(changes, "Unstaged", Unstage)
+}
+
+// stashChanges stashes changes.
+func (m *model) stashChanges(changes []FileChange) {
+	if m.toolConflict("stash", "jj new") {
+		return
+	}
+	if !gitAtLeast(2, 13) {
+		m.notify("Stashing selected files needs git 2.13")
+		return
+	}
+	m.applyToChanges(m.withoutRepos(changes, "stash", false), "Stashed", StashPaths)
+}
+
+// confirmDiscard asks before throwing away changes. Files HEAD doesn't
+// have, untracked or newly added, are deleted.
+func (m *model) confirmDiscard(changes []FileChange) {
+	if m.toolConflict("discard changes", "jj restore") {
+		return
+	}
+	changes = m.withoutRepos(changes, "discard", true)
+	if len(changes) == 0 {
+		return
+	}
+	var restore, remove []string
+	for _, c := range changes {
+		paths := changePaths(c)
+		switch {
+		case len(paths) == 2:
+			restore = append(restore, paths[0])
+			remove = append(remove, paths[1])
+		case c.Staged == '?' || c.Staged == 'A':
+			remove = append(remove, c.File)
+		default:
+			restore = append(restore, c.File)
+		}
+	}
+
+	what := plural(len(changes), "file")
+	if len(changes) == 1 {
+		what = changes[0].File
+	}
+	prompt := fmt.Sprintf("Discard changes to %s? This can't be undone.", what)
+	if len(remove) > 0 {
+		prompt = fmt.Sprintf("Discard changes to %s, deleting %s? This can't be undone.", what, plural(len(remove), "new file"))
+	}
+	m.confirm = &confirmation{
+		prompt: prompt,
+		action: func(m *model) tea.Cmd {
+			if err := Discard(restore, remove); err != nil {
+				m.notifyErr(err)
+				m.refresh()
+				return nil
+			}
+			m.unmark(changes)
+			m.notify("Discarded changes to " + what)
+			m.refresh()
+			return nil
+		},
+	}
+}
+
+// unmark drops the marks on changes.
+func (m *model) unmark(changes []FileChange) {
+	for _, c := range changes {
+		delete(m.marked, c.File)
+	}
+}
+
+// clearMarked unmarks everything, reporting whether anything was marked.
+func (m *model) clearMarked() bool {
+	if len(m.marked) == 0 {
+		return false
+	}
+	m.marked = make(map[string]bool)
+	m.resize()
+	return true
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// runServe implements "vigil serve": a web dashboard showing the
+// repository's status, updated live over server-sent events.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on; use :8080 to listen on all interfaces")
+	dir := fs.String("C", "", "serve the repository in `dir`")
+	noFetch := fs.Bool("no-fetch", false, "don't run git fetch in the background")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: vigil serve [flags]\n\nServe a live web dashboard of the repository's status. /status returns\nthe status as JSON and /events streams it as server-sent events.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *dir != "" {
+		if err := os.Chdir(*dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	if !IsGitRepo() {
+		fmt.Fprintln(os.Stderr, "Error: Not a git repository")
+		return 1
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+	if *noFetch {
+		cfg.AutoFetch = false
+	}
+	cfg.applyGitOverrides()
+	if DaemonRunning() {
+		cfg.AutoFetch = false // as in the TUI, leave fetching and webhooks to the daemon
+		cfg.Webhooks = nil
+		cfg.Alerts = nil
+	}
+
+	d := startDaemon(cfg)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardPage)
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(d.status())
+	})
+	mux.HandleFunc("GET /events", d.streamStatus)
+
+	fmt.Fprintf(os.Stderr, "vigil serving http://%s\n", *addr)
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// streamStatus sends the status as a server-sent event when a client
+// connects and again whenever it changes.
+func (d *daemon) streamStatus(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	var last []byte // last status sent, without its timestamp
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		s := d.status()
+		updated := s.Updated
+		s.Updated = time.Time{}
+		current, _ := json.Marshal(s)
+		if string(current) != string(last) {
+			last = current
+			s.Updated = updated
+			data, _ := json.Marshal(s)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
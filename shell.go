@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// shell is the shell custom commands, the watch command, the editor and
+// the diff pager run under, as the program and its flag before the
+// script: sh -c. Windows has no sh of its own, so there it's the one
+// Git for Windows installs, or cmd.exe without it.
+var shell = sync.OnceValue(func() []string {
+	if runtime.GOOS != "windows" {
+		return []string{"sh", "-c"}
+	}
+	if path, err := exec.LookPath("sh"); err == nil {
+		return []string{path, "-c"}
+	}
+	// git's exec path is e.g. C:/Program Files/Git/mingw64/libexec/git-core,
+	// three levels below the install that has sh in usr\bin
+	if output, err := gitCommand("--exec-path").Output(); err == nil {
+		root := filepath.Join(filepath.FromSlash(strings.TrimSpace(string(output))), "..", "..", "..")
+		for _, sh := range []string{`usr\bin\sh.exe`, `bin\sh.exe`} {
+			if _, err := os.Stat(filepath.Join(root, sh)); err == nil {
+				return []string{filepath.Join(root, sh), "-c"}
+			}
+		}
+	}
+	return []string{"cmd", "/C"}
+})
+
+// shellCommand is exec.Command for a shell script.
+func shellCommand(script string) *exec.Cmd {
+	sh := shell()
+	cmd := exec.Command(sh[0], append(sh[1:], script)...)
+	if sh[0] == "cmd" {
+		cmdLine(cmd, script)
+	}
+	return cmd
+}
+
+// shellCommandContext is exec.CommandContext for a shell script.
+func shellCommandContext(ctx context.Context, script string) *exec.Cmd {
+	sh := shell()
+	cmd := exec.CommandContext(ctx, sh[0], append(sh[1:], script)...)
+	if sh[0] == "cmd" {
+		cmdLine(cmd, script)
+	}
+	return cmd
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package main
+
+import "os/exec"
+
+// cmdLine does nothing: cmd.exe is only run on Windows.
+func cmdLine(cmd *exec.Cmd, script string) {}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+// awkward are file names that need quoting in one shell or another
+var awkward = []string{
+	"plain.txt",
+	"with space.txt",
+	"it's.txt",
+	`say "hi".txt`,
+	"100%done%.txt",
+	"%PATH%.txt",
+	"a&b|c<d>e^f.txt",
+	"$HOME `date`.txt",
+}
+
+func TestShQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain.txt", "'plain.txt'"},
+		{"with space.txt", "'with space.txt'"},
+		{"it's.txt", `'it'\''s.txt'`},
+		{"$HOME.txt", "'$HOME.txt'"},
+		{"", "''"},
+	}
+	for _, tt := range tests {
+		if got := shQuote(tt.in); got != tt.want {
+			t.Errorf("shQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCmdQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain.txt", `"plain.txt"`},
+		{`say "hi".txt`, `"say ""hi"".txt"`},
+		{"100%done%.txt", `"100"^%"done"^%".txt"`},
+		{"%PATH%", `""^%"PATH"^%""`},
+		{"a&b.txt", `"a&b.txt"`},
+	}
+	for _, tt := range tests {
+		if got := cmdQuote(tt.in); got != tt.want {
+			t.Errorf("cmdQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestShellQuoteRoundTrip runs each quoted name through the shell and
+// checks it comes back as it went in.
+func TestShellQuoteRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" && shell()[0] == "cmd" {
+		t.Skip("covered by TestCmdQuoteRoundTrip")
+	}
+	for _, name := range awkward {
+		output, err := shellCommand("printf %s " + shellQuote(name)).Output()
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if string(output) != name {
+			t.Errorf("%q came back as %q", name, output)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// cmdLine hands script to cmd.exe as written. Go quotes arguments the way
+// most Windows programs unquote them, with backslashes before quotes,
+// which cmd.exe doesn't understand; with /S it runs everything between
+// the outer quotes as the command.
+func cmdLine(cmd *exec.Cmd, script string) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd /S /C "` + script + `"`}
+}
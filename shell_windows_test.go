@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCmdQuoteRoundTrip echoes each quoted name through cmd.exe, as a
+// custom command would be run without Git for Windows' sh, and checks
+// nothing in it was expanded or split.
+func TestCmdQuoteRoundTrip(t *testing.T) {
+	for _, name := range awkward {
+		script := "echo " + cmdQuote(name)
+		cmd := exec.Command("cmd", "/C", script)
+		cmdLine(cmd, script)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		// echo keeps the quotes and the carets' effect, not the carets
+		got := strings.TrimSpace(string(output))
+		want := `"` + strings.ReplaceAll(strings.ReplaceAll(name, `"`, `""`), "%", `"%"`) + `"`
+		if got != want {
+			t.Errorf("%q echoed as %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestCutHomeWindows(t *testing.T) {
+	tests := []struct {
+		in, rest string
+		ok       bool
+	}{
+		{`~\src\app`, `src\app`, true},
+		{"~/src/app", "src/app", true},
+		{`C:\src\app`, "", false},
+		{`~user\src`, "", false},
+	}
+	for _, tt := range tests {
+		rest, ok := cutHome(tt.in)
+		if rest != tt.rest || ok != tt.ok {
+			t.Errorf("cutHome(%q) = %q, %v, want %q, %v", tt.in, rest, ok, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestWatchPathsExpandHomeWindows(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	c := WatchConfig{Paths: []string{`~\generated`, `C:\out`}}
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, "generated"); c.Paths[0] != want {
+		t.Errorf("got %q, want %q", c.Paths[0], want)
+	}
+	if c.Paths[1] != `C:\out` {
+		t.Errorf("absolute path changed to %q", c.Paths[1])
+	}
+}
+
+func TestSamePathIgnoresCaseWindows(t *testing.T) {
+	dir := t.TempDir()
+	if !samePath(dir, strings.ToUpper(dir)) {
+		t.Errorf("%s and its upper case should be the same directory", dir)
+	}
+	if samePath(dir, filepath.Join(dir, "other")) {
+		t.Errorf("%s and a subdirectory should differ", dir)
+	}
+}
+
+// TestPathsFingerprintSkipsGitDirWindows checks changes inside a git dir
+// under a watch path don't count as changes, with Windows separators.
+func TestPathsFingerprintSkipsGitDirWindows(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".git"), 0o755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644)
+	before := PathsFingerprint([]string{dir})
+	os.WriteFile(filepath.Join(dir, ".git", "index"), []byte("changed"), 0o644)
+	if PathsFingerprint([]string{dir}) != before {
+		t.Error("a change under .git changed the fingerprint")
+	}
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("changed"), 0o644)
+	if PathsFingerprint([]string{dir}) == before {
+		t.Error("a change to a.txt didn't change the fingerprint")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sortOrder is how the file panels are ordered
+type sortOrder int
+
+const (
+	sortPath     sortOrder = iota // as git lists them
+	sortStatus                    // grouped by status label
+	sortModified                  // most recently modified on disk first
+	sortSize                      // most lines changed first
+)
+
+var sortNames = []string{"path", "status", "mtime", "size"}
+
+func (s sortOrder) String() string {
+	return sortNames[s]
+}
+
+// sortKeys holds what the mtime and size orders compare, gathered on
+// refresh only while one of those orders is active
+type sortKeys struct {
+	modTimes    map[string]time.Time // by path relative to the repo root
+	changeSizes map[string]int       // lines changed in uncommitted changes
+	branchSizes map[string]int       // lines changed on the branch
+}
+
+// cycleSort switches the file panels to the next sort order.
+func (m *model) cycleSort() {
+	m.sort = (m.sort + 1) % sortOrder(len(sortNames))
+	m.loadSortKeys()
+	m.notify("Sorting by " + m.sort.String())
+	m.moveSelection(0)
+}
+
+// loadSortKeys gathers the keys for the active sort order, dropping any
+// refresh in flight, which gathers them for the order it started with.
+func (m *model) loadSortKeys() {
+	m.cancelRefresh()
+	m.sortKeys = m.newLoadRun().sortKeys()
+}
+
+// sortKeys gathers modification times or diff sizes for the active sort
+// order.
+func (r *loadRun) sortKeys() sortKeys {
+	var keys sortKeys
+	switch r.set.sort {
+	case sortModified:
+		root := r.root()
+		if root == "" {
+			return keys
+		}
+		keys.modTimes = make(map[string]time.Time)
+		for _, file := range r.s.changedPaths() {
+			if info, err := os.Stat(filepath.Join(root, file)); err == nil {
+				keys.modTimes[file] = info.ModTime()
+			}
+		}
+
+	case sortSize:
+		keys.changeSizes = diffSizes(r.headStats())
+		for _, c := range r.s.changes {
+			if c.Staged == '?' {
+				keys.changeSizes[c.File] = countLines(filepath.Join(r.root(), c.File))
+			}
+		}
+		if stats := r.branchStats(); stats != nil {
+			keys.branchSizes = diffSizes(stats)
+		}
+	}
+	return keys
+}
+
+// changedPaths returns the current path of every file in either panel.
+func (s repoState) changedPaths() []string {
+	var paths []string
+	for _, c := range s.changes {
+		paths = append(paths, treePath(c.File))
+	}
+	for _, bf := range s.branchFiles {
+		paths = append(paths, treePath(bf.File))
+	}
+	return paths
+}
+
+// compareFiles orders two files of a panel by the active sort. Files that
+// compare equal keep git's (path) order. status is the file's label.
+func (m model) compareFiles(panel, a, b, statusA, statusB string) int {
+	switch m.sort {
+	case sortStatus:
+		return strings.Compare(statusA, statusB)
+	case sortModified:
+		return m.sortKeys.modTimes[treePath(b)].Compare(m.sortKeys.modTimes[treePath(a)])
+	case sortSize:
+		sizes := m.sortKeys.changeSizes
+		if panel == panelBranch {
+			sizes = m.sortKeys.branchSizes
+		}
+		return sizes[treePath(b)] - sizes[treePath(a)]
+	}
+	return 0
+}
+
+// sortTitle describes the active sort for a section title. The default
+// path order isn't worth the space in narrow terminals.
+func (m model) sortTitle() string {
+	if m.narrow() {
+		if m.sort == sortPath {
+			return ""
+		}
+		return " " + m.sort.String()
+	}
+	return " " + glyphs.Dot + " by " + m.sort.String()
+}
+
+// countLines counts the lines of an untracked text file, as git diff would
+// count them once it's added. Binary files count as zero.
+func countLines(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(data, 0) >= 0 {
+		return 0
+	}
+	n := bytes.Count(data, []byte("\n"))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		n++
+	}
+	return n
+}
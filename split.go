@@ -0,0 +1,231 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// splitRow is a row of a side-by-side diff, holding indexes into the
+// unified diff's lines. A removed line is paired with the added line that
+// replaced it; a line on only one side leaves the other at -1. Headers
+// and hunk lines span both sides, with old and new both set to them.
+type splitRow struct {
+	old, new int
+	span     bool
+}
+
+// splitDiff pairs up the lines of a unified diff into side-by-side rows.
+func splitDiff(diff []string) []splitRow {
+	var rows []splitRow
+	var removed, added []int
+	flush := func() {
+		for i := range max(len(removed), len(added)) {
+			row := splitRow{old: -1, new: -1}
+			if i < len(removed) {
+				row.old = removed[i]
+			}
+			if i < len(added) {
+				row.new = added[i]
+			}
+			rows = append(rows, row)
+		}
+		removed, added = nil, nil
+	}
+
+	for i, line := range diff {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			flush()
+			rows = append(rows, splitRow{old: i, new: i, span: true})
+		case strings.HasPrefix(line, "+"):
+			added = append(added, i)
+		case strings.HasPrefix(line, "-"):
+			if len(added) > 0 {
+				flush() // a new change after some additions
+			}
+			removed = append(removed, i)
+		case strings.HasPrefix(line, " "):
+			flush()
+			rows = append(rows, splitRow{old: i, new: i})
+		default:
+			flush()
+			rows = append(rows, splitRow{old: i, new: i, span: true})
+		}
+	}
+	flush()
+	return rows
+}
+
+// splitRowOf returns the row showing a line of the unified diff.
+func splitRowOf(rows []splitRow, line int) int {
+	for i, row := range rows {
+		if row.old == line || row.new == line {
+			return i
+		}
+	}
+	return 0
+}
+
+// first returns the first unified line the row shows.
+func (row splitRow) first() int {
+	if row.old >= 0 {
+		return row.old
+	}
+	return row.new
+}
+
+// showSplit reports whether diffs are drawn side by side, which needs
+// room for two columns of code.
+func (m model) showSplit() bool {
+	return m.split && !m.narrow()
+}
+
+// toggleSplit switches diffs between unified and side by side.
+func (m *model) toggleSplit() {
+	if m.pager != "" {
+		m.notify("Diffs are shown by diff_pager; use its own side-by-side option")
+		return
+	}
+	m.split = !m.split
+	switch {
+	case m.split && m.narrow():
+		m.notify("Side by side needs a wider terminal; showing unified")
+	case m.split:
+		m.notify("Side-by-side diff")
+	default:
+		m.notify("Unified diff")
+	}
+}
+
+// renderSplit draws diff side by side in width columns, one string per
+// row. Changed words in a removed line and the line that replaced it are
+// emphasized.
+func renderSplit(diff []string, painter *diffPainter, width int) []string {
+	side := max((width-3)/2, 1)
+	divider := helpStyle.Render(" " + glyphs.Divider + " ")
+
+	var out []string
+	for _, row := range splitDiff(diff) {
+		if row.span {
+			line := strings.ReplaceAll(diff[row.old], "\t", "    ")
+			out = append(out, painter.render(line))
+			continue
+		}
+		var old, new string
+		var oldChanged, newChanged []bool
+		if row.old >= 0 {
+			old = strings.ReplaceAll(diff[row.old], "\t", "    ")
+		}
+		if row.new >= 0 {
+			new = strings.ReplaceAll(diff[row.new], "\t", "    ")
+		}
+		if row.old == row.new {
+			// A context line, shown on both sides but painted once, as the
+			// painter follows each line in turn
+			line := paintSide(painter, old, nil, side)
+			out = append(out, line+divider+line)
+			continue
+		}
+		if row.old >= 0 && row.new >= 0 {
+			oldChanged, newChanged = wordDiff(old[1:], new[1:])
+		}
+		out = append(out, paintSide(painter, old, oldChanged, side)+divider+paintSide(painter, new, newChanged, side))
+	}
+	return out
+}
+
+// paintSide fits one side's line to width columns and colors it.
+func paintSide(painter *diffPainter, line string, changed []bool, width int) string {
+	if line == "" {
+		return strings.Repeat(" ", width)
+	}
+	line += strings.Repeat(" ", max(width-lipgloss.Width(line), 0))
+	return painter.renderCut(line, changed, len(truncate(line, width)))
+}
+
+// wordDiff compares two versions of a line word by word, marking the
+// bytes of each that the other doesn't share. It returns nil for lines
+// too long to compare or with no words in common, where emphasis would
+// only be noise.
+func wordDiff(a, b string) (aChanged, bChanged []bool) {
+	at, bt := diffWords(a), diffWords(b)
+	if len(at)*len(bt) > 200*200 {
+		return nil, nil
+	}
+
+	// Longest common subsequence of the words, filled in from the end
+	lcs := make([][]int, len(at)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bt)+1)
+	}
+	for i := len(at) - 1; i >= 0; i-- {
+		for j := len(bt) - 1; j >= 0; j-- {
+			if at[i] == bt[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	aChanged, bChanged = make([]bool, len(a)), make([]bool, len(b))
+	mark := func(changed []bool, from int, word string) {
+		for k := range len(word) {
+			changed[from+k] = true
+		}
+	}
+	shared := false
+	ai, bi := 0, 0 // byte offsets of words i and j
+	i, j := 0, 0
+	for i < len(at) || j < len(bt) {
+		switch {
+		case i < len(at) && j < len(bt) && at[i] == bt[j]:
+			shared = shared || strings.TrimSpace(at[i]) != ""
+			ai, bi = ai+len(at[i]), bi+len(bt[j])
+			i, j = i+1, j+1
+		case j == len(bt) || i < len(at) && lcs[i+1][j] >= lcs[i][j+1]:
+			mark(aChanged, ai, at[i])
+			ai += len(at[i])
+			i++
+		default:
+			mark(bChanged, bi, bt[j])
+			bi += len(bt[j])
+			j++
+		}
+	}
+	if !shared {
+		return nil, nil
+	}
+	return aChanged, bChanged
+}
+
+// diffWords splits a line into words, runs of spaces, and single
+// punctuation characters.
+func diffWords(s string) []string {
+	var words []string
+	for i := 0; i < len(s); {
+		end := i + 1
+		switch {
+		case isWordByte(s[i]):
+			for end < len(s) && isWordByte(s[end]) {
+				end++
+			}
+		case s[i] == ' ':
+			for end < len(s) && s[end] == ' ' {
+				end++
+			}
+		}
+		words = append(words, s[i:end])
+		i = end
+	}
+	return words
+}
+
+// splitHint names what v switches the diff to.
+func (m model) splitHint() string {
+	if m.split {
+		return "unified"
+	}
+	return "side by side"
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// stackBranch is a local branch placed in its stack
+type stackBranch struct {
+	name   string
+	parent string // empty for roots
+	ahead  int    // commits since forking from parent
+	behind int    // commits parent has gained since; non-zero means it needs a restack
+	depth  int
+	tip    time.Time // when the branch was last committed to
+	merged bool      // all its commits are in the default branch
+}
+
+// stackState holds the branch stack view
+type stackState struct {
+	loading  bool
+	branches []stackBranch // in display order: each parent followed by its children
+}
+
+// stackLoadedMsg carries the detected stacks
+type stackLoadedMsg []stackBranch
+
+// openStack switches to the stack view and detects stacks in the background.
+func (m *model) openStack() tea.Cmd {
+	m.stack = stackState{loading: true}
+	m.view = viewStack
+	m.cursor = 0
+	m.viewport.GotoTop()
+	m.resize()
+	return loadStack
+}
+
+func loadStack() tea.Msg {
+	branches := detectStacks(GetLocalBranches(), GetDefaultBranch())
+	merged := GetMergedBranches(GetDefaultBranch())
+	for i := range branches {
+		branches[i].merged = merged[branches[i].name]
+	}
+	return stackLoadedMsg(branches)
+}
+
+// detectStacks works out each branch's parent from the commit graph. A
+// parent has fewer commits beyond the default branch than its child, or as
+// many but a newer tip (it gained commits after the child was built on
+// it), so parents always sit closer to the root. Of the possible parents,
+// the branch's is the one it has the fewest commits beyond.
+//
+// The graph beyond the default branch, every branch's reflog and its
+// counts against the default branch are read once up front, so the pairs
+// are compared in memory rather than with git processes per pair.
+func detectStacks(local []LocalBranch, defaultBranch string) []stackBranch {
+	var names, tips []string
+	for _, b := range local {
+		names = append(names, b.Name)
+		tips = append(tips, b.Hash)
+	}
+	return placeBranches(local, defaultBranch, GetBranchCounts(defaultBranch, local),
+		GetCommitGraph(defaultBranch, tips), GetBranchReflogs(names))
+}
+
+// placeBranches is detectStacks given what it reads from git: each
+// branch's counts against the default branch, the parents of the commits
+// beyond it, and each branch's reflog.
+//
+// A branch forked from another where its commits meet those the other has
+// pointed at, as merge-base --fork-point finds them, so a branch is still
+// placed after its parent was amended or rebased. Branches that only meet
+// in the default branch's history aren't stacked on each other.
+func placeBranches(local []LocalBranch, defaultBranch string, counts map[string]BranchCounts, parents map[string][]string, reflogs map[string][]string) []stackBranch {
+	own := make(map[string]map[string]bool, len(local))
+	forked := make(map[string]map[string]bool, len(local))
+	for _, b := range local {
+		own[b.Name] = reachable(parents, b.Hash)
+		forked[b.Name] = reachable(parents, append([]string{b.Hash}, reflogs[b.Name]...)...)
+	}
+	below := func(p, b LocalBranch) bool {
+		if p.Name == defaultBranch {
+			return true
+		}
+		hp, hb := counts[p.Name].Ahead, counts[b.Name].Ahead
+		return hp < hb || hp == hb && p.Time.After(b.Time)
+	}
+
+	var names []string
+	branches := make(map[string]*stackBranch, len(local))
+	for _, b := range local {
+		sb := &stackBranch{name: b.Name, tip: b.Time}
+		branches[b.Name] = sb
+		names = append(names, b.Name)
+		if b.Name == defaultBranch {
+			continue
+		}
+		for _, p := range local {
+			if p.Name == b.Name || !below(p, b) {
+				continue
+			}
+			ahead, behind := counts[b.Name].Ahead, counts[b.Name].Behind
+			if p.Name != defaultBranch {
+				ahead, behind = 0, 0
+				for c := range own[b.Name] {
+					if !forked[p.Name][c] {
+						ahead++
+					}
+				}
+				if ahead == len(own[b.Name]) {
+					continue
+				}
+				for c := range own[p.Name] {
+					if !own[b.Name][c] {
+						behind++
+					}
+				}
+			}
+			better := sb.parent == "" || ahead < sb.ahead ||
+				ahead == sb.ahead && p.Name == defaultBranch ||
+				ahead == sb.ahead && sb.parent != defaultBranch && behind < sb.behind
+			if better {
+				sb.parent, sb.ahead, sb.behind = p.Name, ahead, behind
+			}
+		}
+	}
+
+	children := make(map[string][]string)
+	var roots []string
+	for _, name := range names {
+		if p := branches[name].parent; p != "" {
+			children[p] = append(children[p], name)
+		} else {
+			roots = append(roots, name)
+		}
+	}
+	// The default branch leads
+	slices.SortStableFunc(roots, func(a, b string) int {
+		return boolRank(a == defaultBranch) - boolRank(b == defaultBranch)
+	})
+
+	var ordered []stackBranch
+	var walk func(name string, depth int)
+	walk = func(name string, depth int) {
+		b := branches[name]
+		b.depth = depth
+		ordered = append(ordered, *b)
+		for _, child := range children[name] {
+			walk(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+	return ordered
+}
+
+// reachable returns the commits in parents reachable from tips.
+func reachable(parents map[string][]string, tips ...string) map[string]bool {
+	seen := make(map[string]bool)
+	for len(tips) > 0 {
+		c := tips[len(tips)-1]
+		tips = tips[:len(tips)-1]
+		if _, ok := parents[c]; !ok || seen[c] {
+			continue
+		}
+		seen[c] = true
+		tips = append(tips, parents[c]...)
+	}
+	return seen
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 0
+	}
+	return 1
+}
+
+// updateStack handles key input in the stack view.
+func (m model) updateStack(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	branches := m.stack.branches
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "S":
+		m.view = viewFiles
+		m.resize()
+		return m, tea.ClearScreen
+	case "up", "k":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "j":
+		m.cursor = min(m.cursor+1, max(len(branches)-1, 0))
+	case "r":
+		m.stack.loading = true
+		m.resize()
+		return m, loadStack
+	case "enter":
+		if m.cursor >= len(branches) || m.toolConflict("switch branches", "jj edit or jj new") {
+			return m, nil
+		}
+		if err := Checkout(branches[m.cursor].name); err != nil {
+			m.notifyErr(err)
+			return m, nil
+		}
+		m.notify("Switched to " + branches[m.cursor].name)
+		m.refresh()
+		return m, checkUpstream
+	case "n":
+		if m.toolConflict("create branches", "jj bookmark create") {
+			return m, nil
+		}
+		return m, m.openPrompt("New branch from HEAD: ", "", (*model).createBranch)
+	case "m":
+		if m.cursor >= len(branches) || m.toolConflict("rename branches", "jj bookmark rename") {
+			return m, nil
+		}
+		from := branches[m.cursor].name
+		return m, m.openPrompt("Rename "+from+" to: ", from, func(m *model, to string) (tea.Cmd, error) {
+			return m.renameBranch(from, to)
+		})
+	case "d":
+		if m.cursor >= len(branches) || m.toolConflict("delete branches", "jj bookmark delete") {
+			return m, nil
+		}
+		m.confirmDeleteBranch(branches[m.cursor].name)
+		return m, nil
+	case "D":
+		if m.toolConflict("delete branches", "jj bookmark delete") {
+			return m, nil
+		}
+		m.confirmDeleteMerged()
+		return m, nil
+	case "c":
+		if m.cursor >= len(branches) || m.toolConflict("cherry-pick", "jj duplicate") {
+			return m, nil
+		}
+		m.openPick(branches[m.cursor].name)
+		return m, nil
+	case "R":
+		if m.cursor >= len(branches) || branches[m.cursor].parent == "" || m.toolConflict("restack", "jj rebase") {
+			return m, nil
+		}
+		b := branches[m.cursor]
+		m.confirm = &confirmation{
+			prompt: fmt.Sprintf("Rebase %s onto %s?", b.name, b.parent),
+			action: func(m *model) tea.Cmd {
+				return m.startOp("Restack "+b.name, "Restacking "+b.name, func() error {
+					return Restack(b.name, b.parent)
+				})
+			},
+		}
+		return m, nil
+	}
+
+	m.resize()
+	m.scrollTo(m.cursor + 1)
+	return m, nil
+}
+
+// createBranch creates a branch at HEAD, switches to it and reloads the
+// stacks.
+func (m *model) createBranch(name string) (tea.Cmd, error) {
+	if err := ValidBranchName(name); err != nil {
+		return nil, err
+	}
+	if err := CreateBranch(name); err != nil {
+		return nil, err
+	}
+	m.notify("Created and switched to " + name)
+	return m.branchesChanged(), nil
+}
+
+// renameBranch renames a branch and reloads the stacks.
+func (m *model) renameBranch(from, to string) (tea.Cmd, error) {
+	if to == from {
+		return nil, nil
+	}
+	if err := ValidBranchName(to); err != nil {
+		return nil, err
+	}
+	if err := RenameBranch(from, to); err != nil {
+		return nil, err
+	}
+	m.notify("Renamed " + from + " to " + to)
+	return m.branchesChanged(), nil
+}
+
+// confirmDeleteBranch asks before deleting a branch, and again, as a
+// force delete, if it has commits that aren't merged.
+func (m *model) confirmDeleteBranch(name string) {
+	if name == m.branch {
+		m.notify("Can't delete the current branch; switch to another first")
+		return
+	}
+	m.confirm = &confirmation{
+		prompt: "Delete branch " + name + "?",
+		action: func(m *model) tea.Cmd {
+			err := DeleteBranch(name, false)
+			if errors.Is(err, errUnmerged) {
+				m.confirmForceDeleteBranch(name)
+				return nil
+			}
+			if err != nil {
+				m.notifyErr(err)
+				return nil
+			}
+			m.notify("Deleted " + name)
+			return m.branchesChanged()
+		},
+	}
+}
+
+// confirmForceDeleteBranch asks before deleting a branch whose commits
+// would be lost, saying how many.
+func (m *model) confirmForceDeleteBranch(name string) {
+	unmerged := "has unmerged commits"
+	if n, err := CountCommits(GetDefaultBranch(), name); err == nil && n > 0 {
+		unmerged = fmt.Sprintf("has %s not in %s", plural(n, "commit"), GetDefaultBranch())
+	}
+	m.confirm = &confirmation{
+		prompt: fmt.Sprintf("%s %s. Delete it anyway?", name, unmerged),
+		action: func(m *model) tea.Cmd {
+			if err := DeleteBranch(name, true); err != nil {
+				m.notifyErr(err)
+				return nil
+			}
+			m.notify("Deleted " + name + "; it's still in the reflog for a while")
+			return m.branchesChanged()
+		},
+	}
+}
+
+// confirmDeleteMerged asks before deleting every branch merged into the
+// default branch, other than the current one and protected ones.
+func (m *model) confirmDeleteMerged() {
+	def := GetDefaultBranch()
+	var names []string
+	for _, b := range m.stack.branches {
+		if b.merged && b.name != m.branch && !protected(b.name, m.protected) {
+			names = append(names, b.name)
+		}
+	}
+	if len(names) == 0 {
+		m.notify("No merged branches to delete")
+		return
+	}
+	list := strings.Join(names, ", ")
+	if len(names) > 3 {
+		list = strings.Join(names[:3], ", ") + ", ..."
+	}
+	m.confirm = &confirmation{
+		prompt: fmt.Sprintf("Delete %s merged into %s (%s)?", branchCount(len(names)), def, list),
+		action: func(m *model) tea.Cmd {
+			// A branch may have gained commits since the list was loaded;
+			// the rest are safe to force delete, as their commits are all
+			// in the default branch whatever HEAD is
+			merged := GetMergedBranches(def)
+			deleted := 0
+			for _, name := range names {
+				if !merged[name] {
+					continue
+				}
+				if err := DeleteBranch(name, true); err != nil {
+					m.notifyErr(err)
+					return m.branchesChanged()
+				}
+				deleted++
+			}
+			m.notify(fmt.Sprintf("Deleted %s merged into %s", branchCount(deleted), def))
+			return m.branchesChanged()
+		},
+	}
+}
+
+// branchCount formats a number of branches, e.g. "1 branch" or "3 branches".
+func branchCount(n int) string {
+	if n == 1 {
+		return "1 branch"
+	}
+	return fmt.Sprintf("%d branches", n)
+}
+
+// branchesChanged refreshes the header, which may be showing a branch that
+// was just created or renamed, and reloads the stacks.
+func (m *model) branchesChanged() tea.Cmd {
+	m.refresh()
+	m.stack.loading = true
+	m.resize()
+	return tea.Batch(loadStack, checkUpstream)
+}
+
+func (m model) renderStack() string {
+	var body strings.Builder
+	body.WriteString("Branch stacks:\n")
+	if m.stack.loading {
+		body.WriteString(helpStyle.Render("  Detecting stacks..."))
+		return body.String()
+	}
+
+	current := GetCurrentBranch()
+	for i, b := range m.stack.branches {
+		marker := "  "
+		if b.name == current {
+			marker = "* "
+		}
+		indent := ""
+		if b.depth > 0 {
+			indent = strings.Repeat("  ", b.depth-1) + helpStyle.Render(glyphs.Child) + " "
+		}
+		line := m.cursorColumn(i == m.cursor) + marker + indent + branchStyle.Render(b.name)
+		if b.merged {
+			line += "  " + statusAdded.Render("merged")
+		}
+		if m.staleAfter > 0 && m.now().Sub(b.tip) > m.staleAfter {
+			stale := "stale"
+			if !m.narrow() {
+				stale += ", last commit " + m.timeAgo(b.tip)
+			}
+			line += "  " + helpStyle.Render(stale)
+		}
+		if b.parent != "" {
+			line += "  " + helpStyle.Render(fmt.Sprintf("%s%d", glyphs.Up, b.ahead))
+			if b.behind > 0 && !b.merged { // nothing left to restack
+				line += " " + statusModified.Render(fmt.Sprintf("%s%d", glyphs.Down, b.behind))
+				if !m.narrow() {
+					line += "  " + statusModified.Render("needs restack")
+				}
+			}
+		}
+		body.WriteString(line + "\n")
+	}
+	return body.String()
+}
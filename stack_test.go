@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"vigil/internal/fixture"
+)
+
+func TestDetectStacks(t *testing.T) {
+	repo, err := fixture.Stacked(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(repo)
+
+	type placed struct {
+		name, parent  string
+		ahead, behind int
+		depth         int
+	}
+	want := []placed{
+		{fixture.Base, "", 0, 0, 0},
+		{fixture.Amended, fixture.Base, 1, 0, 1},
+		{fixture.OnAmended, fixture.Amended, 1, 1, 2},
+		{fixture.Sibling, fixture.Base, 1, 0, 1},
+		{fixture.StackBase, fixture.Base, 3, 0, 1},
+		{fixture.StackTop, fixture.StackBase, 1, 1, 2},
+	}
+	branches := detectStacks(GetLocalBranches(), fixture.Base)
+	if len(branches) != len(want) {
+		t.Fatalf("detectStacks found %d branches, want %d: %+v", len(branches), len(want), branches)
+	}
+	for i, b := range branches {
+		if got := (placed{b.name, b.parent, b.ahead, b.behind, b.depth}); got != want[i] {
+			t.Errorf("branch %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// RepoState is per-repository state vigil persists between runs, stored
+// as vigil.json in the repository's common git dir so all worktrees share it.
+type RepoState struct {
+	Pinned   []string            `json:"pinned,omitempty"`   // files kept at the top of Changed Files
+	Reviewed map[string][]string `json:"reviewed,omitempty"` // branch -> branch files checked off during self-review
+}
+
+// repoStatePath returns where the current repository's state is stored.
+func repoStatePath() (string, error) {
+	dir, err := GetGitCommonDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "vigil.json"), nil
+}
+
+// LoadRepoState reads the current repository's state. A missing file
+// yields empty state.
+func LoadRepoState() (RepoState, error) {
+	var state RepoState
+	path, err := repoStatePath()
+	if err != nil {
+		return state, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// Save writes the state back to the current repository.
+func (s RepoState) Save() error {
+	path, err := repoStatePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// saveState persists the repository's state after a change to what. On a
+// read-only filesystem the change only lasts for the session.
+func (m *model) saveState(what string) {
+	if m.readOnly {
+		return
+	}
+	if err := m.state.Save(); err != nil {
+		m.notifyErr(fmt.Errorf("saving %s: %w", what, err))
+	}
+}
+
+func (m model) isPinned(file string) bool {
+	return slices.Contains(m.state.Pinned, file)
+}
+
+// togglePin pins or unpins the selected file and persists the change.
+func (m *model) togglePin() {
+	file, ok := m.selectedFile()
+	if !ok {
+		return
+	}
+	if i := slices.Index(m.state.Pinned, file); i >= 0 {
+		m.state.Pinned = slices.Delete(m.state.Pinned, i, i+1)
+		m.notify("Unpinned " + file)
+	} else {
+		m.state.Pinned = append(m.state.Pinned, file)
+		m.notify("Pinned " + file)
+	}
+	m.saveState("pins")
+	m.selectFile(file)
+}
+
+func (m model) isReviewed(file string) bool {
+	return slices.Contains(m.state.Reviewed[m.branch], file)
+}
+
+// reviewProgress returns how many of the branch files are marked reviewed.
+func (m model) reviewProgress() int {
+	n := 0
+	for _, bf := range m.branchFiles {
+		if m.isReviewed(bf.File) {
+			n++
+		}
+	}
+	return n
+}
+
+// toggleReviewed checks off or un-checks the selected branch file and
+// persists the change.
+func (m *model) toggleReviewed() {
+	row, ok := m.selectedRow()
+	if !ok || row.panel != panelBranch {
+		m.notify("Select a branch file to mark it reviewed")
+		return
+	}
+	if m.state.Reviewed == nil {
+		m.state.Reviewed = make(map[string][]string)
+	}
+	reviewed := m.state.Reviewed[m.branch]
+	if i := slices.Index(reviewed, row.file); i >= 0 {
+		reviewed = slices.Delete(reviewed, i, i+1)
+	} else {
+		reviewed = append(reviewed, row.file)
+	}
+	if len(reviewed) == 0 {
+		delete(m.state.Reviewed, m.branch)
+	} else {
+		m.state.Reviewed[m.branch] = reviewed
+	}
+	m.saveState("review state")
+	m.resize()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// statusFile is the JSON written by --status-file for editors and status
+// bars to watch
+type statusFile struct {
+	Dir      string `json:"dir"`
+	Branch   string `json:"branch"`
+	Head     string `json:"head,omitempty"` // abbreviated hash; empty before the first commit
+	Upstream bool   `json:"upstream"`       // whether ahead and behind are known
+	Ahead    int    `json:"ahead"`
+	Behind   int    `json:"behind"`
+
+	Summary struct {
+		Staged    int `json:"staged"`
+		Modified  int `json:"modified"`
+		Untracked int `json:"untracked"`
+		Stashes   int `json:"stashes"`
+		Conflicts int `json:"conflicts"`
+		Ignored   int `json:"ignored"`
+	} `json:"summary"`
+
+	Changes     []statusFileEntry `json:"changes"`
+	Base        string            `json:"base"`
+	BranchFiles []statusFileEntry `json:"branch_files"`
+
+	Updated time.Time `json:"updated"`
+}
+
+type statusFileEntry struct {
+	Path   string `json:"path"`
+	Status string `json:"status"` // porcelain status letters, e.g. "M " or "??"
+	Label  string `json:"label"`
+}
+
+// snapshot captures the current status, leaving Updated unset.
+func (m model) snapshot() statusFile {
+	var s statusFile
+	s.Dir, s.Branch = m.dir, m.branch
+	if m.hasCommit {
+		s.Head = m.lastCommit.Hash
+	}
+	s.Upstream = m.upstreamErr == nil
+	s.Ahead, s.Behind = m.ahead, m.behind
+	s.Summary.Staged = m.summary.Staged
+	s.Summary.Modified = m.summary.Modified
+	s.Summary.Untracked = m.summary.Untracked
+	s.Summary.Stashes = m.summary.Stashes
+	s.Summary.Conflicts = m.summary.Conflicts
+	s.Summary.Ignored = m.summary.Ignored
+	s.Changes = []statusFileEntry{}
+	for _, c := range m.changes {
+		s.Changes = append(s.Changes, statusFileEntry{Path: c.File, Status: string([]byte{c.Staged, c.Unstaged}), Label: c.Label})
+	}
+	s.Base = m.baseName()
+	s.BranchFiles = []statusFileEntry{}
+	for _, bf := range m.branchFiles {
+		s.BranchFiles = append(s.BranchFiles, statusFileEntry{Path: treePath(bf.File), Status: bf.Status, Label: branchFileLabel(bf.Status)})
+	}
+	return s
+}
+
+// writeStatusFile replaces the status file when the status has changed
+// since it was last written. The file is renamed into place, so readers
+// never see it half written.
+func (m *model) writeStatusFile() error {
+	if m.statusPath == "" {
+		return nil
+	}
+
+	// Compare without the timestamp so an unchanged status isn't rewritten
+	// (and doesn't wake up watchers) on every refresh
+	s := m.snapshot()
+	unstamped, err := json.Marshal(s)
+	if err != nil || bytes.Equal(unstamped, m.lastStatus) {
+		return err
+	}
+	s.Updated = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(m.statusPath, append(data, '\n')); err != nil {
+		return err
+	}
+	m.lastStatus = unstamped
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
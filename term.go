@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Glyphs holds the characters vigil draws that need a unicode-capable terminal
+type Glyphs struct {
+	Art    string // header banner
+	Up     string
+	Down   string
+	Cursor string // marks the selected row in list views
+	Dot    string // separates inline items
+	Pin    string // marks pinned files
+	Check  string // marks reviewed files
+	Mark   string // marks files selected for a batch action
+	Cross  string // marks failures
+	Warn   string // marks files with leftover cruft
+	Note   string // prefixes git notes
+
+	HeatLow string // a file edited once recently
+	Heat    string // repeated for files edited more often
+
+	TreeOpen   string // expanded directory in tree mode
+	TreeClosed string // collapsed directory in tree mode
+
+	Child string // links a branch to its parent in the stack view
+
+	Divider string // between the old and new sides of a split diff
+}
+
+var unicodeGlyphs = Glyphs{
+	Art: `
+ █░█ █ █▀▀ █ █░░
+ ▀▄▀ █ █▄█ █ █▄▄
+`,
+	Up:     "↑",
+	Down:   "↓",
+	Cursor: "▸",
+	Dot:    "·",
+	Pin:    "◆",
+	Check:  "✓",
+	Mark:   "●",
+	Cross:  "✗",
+	Warn:   "⚠",
+	Note:   "✎",
+
+	HeatLow: "·",
+	Heat:    "•",
+
+	TreeOpen:   "▼",
+	TreeClosed: "▶",
+
+	Child: "└",
+
+	Divider: "│",
+}
+
+var asciiGlyphs = Glyphs{
+	Art: `
+ \  / | /~~ | |
+  \/  | \_/ | |__
+`,
+	Up:     "up",
+	Down:   "down",
+	Cursor: ">",
+	Dot:    "|",
+	Pin:    "*",
+	Check:  "x",
+	Mark:   "#",
+	Cross:  "!",
+	Warn:   "!",
+	Note:   "note:",
+
+	HeatLow: ".",
+	Heat:    "o",
+
+	TreeOpen:   "-",
+	TreeClosed: "+",
+
+	Child: "`-",
+
+	Divider: "|",
+}
+
+// glyphs is the active glyph set, chosen by setupTerminal
+var glyphs = unicodeGlyphs
+
+// enableANSI turns on escape sequence handling in the Windows console,
+// which older versions leave off, for output written outside the TUI
+// (the prompt line, one-shot checks, the export); elsewhere it does
+// nothing.
+func enableANSI() {
+	termenv.EnableVirtualTerminalProcessing(termenv.DefaultOutput())
+}
+
+// setupTerminal picks a color profile and glyph set for the terminal.
+// colorMode is one of auto, truecolor, 256, 16 or none; auto keeps
+// lipgloss's detection from TERM, COLORTERM and NO_COLOR.
+func setupTerminal(colorMode string, ascii bool) error {
+	switch colorMode {
+	case "", "auto":
+	case "truecolor":
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	case "256":
+		lipgloss.SetColorProfile(termenv.ANSI256)
+	case "16":
+		lipgloss.SetColorProfile(termenv.ANSI)
+	case "none":
+		lipgloss.SetColorProfile(termenv.Ascii)
+	default:
+		return fmt.Errorf("unknown color mode %q (want auto, truecolor, 256, 16 or none)", colorMode)
+	}
+
+	if ascii || !SupportsUnicode() {
+		glyphs = asciiGlyphs
+	}
+	return nil
+}
+
+// SupportsUnicode guesses whether the terminal can draw unicode glyphs,
+// based on the locale and a few terminals known to lack them.
+func SupportsUnicode() bool {
+	switch term := os.Getenv("TERM"); {
+	case term == "dumb", term == "linux", strings.HasPrefix(term, "vt"):
+		return false
+	}
+
+	// The first locale variable that is set wins, as with setlocale(3)
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	// Windows doesn't use them, and its console takes unicode whatever the
+	// code page
+	return runtime.GOOS == "windows"
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// todoMarker finds a TODO, FIXME or XXX after something that opens a
+// comment, so the words in strings and identifiers aren't counted
+var todoMarker = regexp.MustCompile(`(//|#|--|/\*|^\s*\*|<!--|;).*?\b(TODO|FIXME|XXX)\b`)
+
+// todoComment is a TODO comment added on the branch
+type todoComment struct {
+	file string
+	line int
+	text string // from the marker on, e.g. "TODO: retry"
+}
+
+// branchTodos are the TODO comments the branch's commits add to Branch
+// Files, found again only when the branch's commits change
+type branchTodos struct {
+	key      string // merge base and HEAD they were found between
+	comments []todoComment
+}
+
+// todos scans the lines the branch adds for TODO comments. Generated
+// files and those whose diffs are skipped are left out.
+func (r *loadRun) todos(sizes fileSizes, generated map[string]bool) branchTodos {
+	if len(r.s.branchFiles) == 0 || !r.s.hasCommit {
+		return branchTodos{}
+	}
+	base := r.mergeBase()
+	if base == "" {
+		return branchTodos{}
+	}
+	key := base + ".." + r.s.lastCommit.Hash
+	if key == r.set.todos.key {
+		return r.set.todos
+	}
+
+	scan := make(map[string]bool)
+	for _, bf := range r.s.branchFiles {
+		path := treePath(bf.File)
+		if !generated[path] && !sizes.of(panelBranch, bf.File).skipsDiff() {
+			scan[path] = true
+		}
+	}
+	var comments []todoComment
+	for _, l := range diffAddedLines(r.ctx, base, "HEAD") {
+		if !scan[l.file] {
+			continue
+		}
+		if match := todoMarker.FindStringSubmatchIndex(l.text); match != nil {
+			comments = append(comments, todoComment{file: l.file, line: l.line, text: todoText(l.text[match[4]:])})
+		}
+	}
+	return branchTodos{key: key, comments: comments}
+}
+
+// todoText trims a comment's closing delimiter and surrounding space.
+func todoText(text string) string {
+	text = strings.TrimSpace(text)
+	for _, close := range []string{"*/", "-->"} {
+		text = strings.TrimSpace(strings.TrimSuffix(text, close))
+	}
+	return text
+}
+
+// todoRows are the rows Branch Files lists the TODO comments under:
+// a group row, collapsed until enter expands it, then one per comment.
+func (m model) todoRows() []listRow {
+	comments := m.todos.comments
+	if len(comments) == 0 || m.filter != "" {
+		return nil
+	}
+	glyph := glyphs.TreeClosed
+	if m.showTodos {
+		glyph = glyphs.TreeOpen
+	}
+	text := fmt.Sprintf("%s %s added on this branch", glyph, plural(len(comments), "TODO"))
+	if m.narrow() {
+		text = fmt.Sprintf("%s %s", glyph, plural(len(comments), "TODO"))
+	}
+	rows := []listRow{{todos: true, text: statusModified.Render(text)}}
+	if !m.showTodos {
+		return rows
+	}
+	for _, c := range comments {
+		where := fmt.Sprintf("%s:%d", c.file, c.line)
+		text := "  " + fileStyle.Render(where) + " " + c.text
+		if m.narrow() {
+			text = "  " + fileStyle.Render(where)
+		}
+		rows = append(rows, listRow{file: c.file, line: c.line, text: truncate(text, m.width-1)})
+	}
+	return rows
+}
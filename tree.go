@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// fileEntry is a file to list in a panel, before flat or tree layout
+type fileEntry struct {
+	file   string                       // as reported by git, used for selection
+	status string                       // rendered status column
+	render func(name styledName) string // renders the name with the entry's decorations
+}
+
+// styledName renders a file's name in a style, with filter matches highlighted
+type styledName func(style lipgloss.Style) string
+
+// treeNode is a directory in the tree rendering
+type treeNode struct {
+	name  string // may span several path segments once compressed
+	path  string
+	dirs  map[string]*treeNode
+	files []fileEntry
+	count int // files at or below this directory
+}
+
+// fileRows lays out a panel's entries as a flat list or, in tree mode, as
+// collapsible directories. Entries not matching the filter are left out.
+func (m model) fileRows(panel string, entries []fileEntry) []listRow {
+	sep := "  "
+	if m.narrow() {
+		sep = " "
+	}
+
+	matches := make(map[string][]int)
+	if m.filter != "" {
+		var shown []fileEntry
+		for _, e := range entries {
+			if positions, ok := fuzzyMatch(m.filter, e.file); ok {
+				shown = append(shown, e)
+				matches[e.file] = positions
+			}
+		}
+		entries = shown
+	}
+
+	if !m.tree {
+		rows := make([]listRow, len(entries))
+		for i, e := range entries {
+			name := func(style lipgloss.Style) string { return highlight(e.file, matches[e.file], style) }
+			rows[i] = listRow{file: e.file, text: e.status + sep + e.render(name)}
+		}
+		return rows
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+	root := &treeNode{dirs: map[string]*treeNode{}}
+	for _, e := range entries {
+		node := root
+		node.count++
+		dir := path.Dir(treePath(e.file))
+		if dir != "." {
+			for _, segment := range strings.Split(dir, "/") {
+				child, ok := node.dirs[segment]
+				if !ok {
+					child = &treeNode{name: segment, path: path.Join(node.path, segment), dirs: map[string]*treeNode{}}
+					node.dirs[segment] = child
+				}
+				node = child
+				node.count++
+			}
+		}
+		node.files = append(node.files, e)
+	}
+	compress(root)
+
+	blank := strings.Repeat(" ", lipgloss.Width(entries[0].status))
+	var rows []listRow
+	var walk func(node *treeNode, depth int)
+	walk = func(node *treeNode, depth int) {
+		indent := strings.Repeat("  ", depth)
+		for _, name := range sortedDirs(node) {
+			child := node.dirs[name]
+			key := panel + ":" + child.path
+			glyph := glyphs.TreeOpen
+			if m.collapsed[key] {
+				glyph = glyphs.TreeClosed
+			}
+			text := blank + sep + indent + helpStyle.Render(glyph) + " " + dirStyle.Render(child.name+"/") + helpStyle.Render(fmt.Sprintf(" (%d)", child.count))
+			rows = append(rows, listRow{dir: key, text: text})
+			if !m.collapsed[key] {
+				walk(child, depth+1)
+			}
+		}
+		files := node.files
+		if m.sort == sortPath {
+			sort.SliceStable(files, func(i, j int) bool { return treePath(files[i].file) < treePath(files[j].file) })
+		}
+		for _, e := range files {
+			name := func(style lipgloss.Style) string { return highlightTreeName(e.file, matches[e.file], style) }
+			rows = append(rows, listRow{file: e.file, text: e.status + sep + indent + e.render(name)})
+		}
+	}
+	walk(root, 0)
+	return rows
+}
+
+// compress merges directories that hold nothing but a single subdirectory,
+// so deep paths like src/main/java don't take a row per segment.
+func compress(node *treeNode) {
+	for key, child := range node.dirs {
+		for len(child.files) == 0 && len(child.dirs) == 1 {
+			for _, grandchild := range child.dirs {
+				grandchild.name = child.name + "/" + grandchild.name
+				child = grandchild
+			}
+		}
+		node.dirs[key] = child
+		compress(child)
+	}
+}
+
+func sortedDirs(node *treeNode) []string {
+	names := make([]string, 0, len(node.dirs))
+	for name := range node.dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// treePath returns where a file sits in the tree: the new path for renames,
+// which git reports as "old -> new" (status) or "old<tab>new" (diff).
+func treePath(file string) string {
+	if _, to, ok := strings.Cut(file, " -> "); ok {
+		return to
+	}
+	if _, to, ok := strings.Cut(file, "\t"); ok {
+		return to
+	}
+	return file
+}
+
+// treeName is the name a file is shown with under its directory.
+func treeName(file string) string {
+	name := path.Base(treePath(file))
+	if from, _, ok := strings.Cut(file, " -> "); ok {
+		return name + " (from " + from + ")"
+	}
+	if from, _, ok := strings.Cut(file, "\t"); ok {
+		return name + " (from " + from + ")"
+	}
+	return name
+}
+
+// highlightTreeName renders treeName(file), carrying over the filter
+// matches that fall in the file's base name. The base name is always at
+// the end of file, after any rename source.
+func highlightTreeName(file string, positions []int, style lipgloss.Style) string {
+	base := path.Base(treePath(file))
+	offset := len([]rune(file)) - len([]rune(base))
+	var shifted []int
+	for _, p := range positions {
+		if p >= offset {
+			shifted = append(shifted, p-offset)
+		}
+	}
+	name := treeName(file)
+	return highlight(base, shifted, style) + style.Render(name[len(base):])
+}
+
+// toggleCollapsed collapses or expands the selected directory in tree mode.
+func (m *model) toggleCollapsed() {
+	row, ok := m.selectedRow()
+	if ok && row.generated {
+		m.showGen = !m.showGen
+		m.resize()
+		return
+	}
+	if ok && row.todos {
+		m.showTodos = !m.showTodos
+		m.resize()
+		return
+	}
+	if !ok || row.dir == "" {
+		return
+	}
+	m.collapsed[row.dir] = !m.collapsed[row.dir]
+	m.resize()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// confirmUndoCommit asks before moving the last commit's changes back into
+// the index with git reset --soft HEAD~1.
+func (m *model) confirmUndoCommit() {
+	if !m.hasCommit || m.toolConflict("undo commits", "jj undo") {
+		return
+	}
+	if !RefExists("HEAD~1") {
+		m.notify("The first commit can't be undone")
+		return
+	}
+	c := m.lastCommit
+	prompt := fmt.Sprintf("Undo commit %s %q, keeping its changes staged?", c.Hash, c.Subject)
+	if m.upstreamErr == nil && m.counted && m.ahead == 0 {
+		prompt += " It's already pushed."
+	}
+	m.confirm = &confirmation{
+		prompt: prompt,
+		action: func(m *model) tea.Cmd {
+			if err := UndoCommit(); err != nil {
+				m.notifyErr(err)
+				return nil
+			}
+			m.notify("Undid " + c.Hash + "; its changes are staged")
+			m.refresh()
+			return checkUpstream
+		},
+	}
+}
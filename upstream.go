@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// upstreamOption is one entry in the upstream picker: a remote branch to
+// track, a remote to push the branch to first, no upstream at all, or for
+// a branch whose upstream is gone, cleaning the branch up
+type upstreamOption struct {
+	ref     string // e.g. origin/main; empty to stop tracking
+	remote  string // set when the branch isn't on this remote yet and is pushed there
+	cleanUp bool
+}
+
+func (o upstreamOption) label(branch string) string {
+	switch {
+	case o.cleanUp:
+		return "switch to " + GetDefaultBranch() + " and delete " + branch
+	case o.remote != "":
+		return "push to " + o.remote + "/" + branch
+	case o.ref == "":
+		return "stop tracking"
+	}
+	return o.ref
+}
+
+// upstreamState holds the upstream picker: a query narrowing the options
+// as it's typed
+type upstreamState struct {
+	input   textinput.Model
+	branch  string
+	options []upstreamOption
+	matches []int // indexes into options, in display order
+}
+
+// openUpstream switches to the upstream picker, for a branch with no
+// upstream (so ahead/behind can be counted), one tracking the wrong one,
+// or one whose upstream is gone.
+func (m *model) openUpstream() tea.Cmd {
+	if m.toolConflict("set the upstream", "jj bookmark track") {
+		return nil
+	}
+	branch, err := currentBranch()
+	if err != nil || branch == "" {
+		m.notify("Not on a branch")
+		return nil
+	}
+	remotes, err := GetRemotes()
+	if err == nil && len(remotes) == 0 {
+		err = fmt.Errorf("no remotes configured")
+	}
+	if err != nil {
+		m.notifyErr(err)
+		return nil
+	}
+	refs, err := GetRemoteBranches()
+	if err != nil {
+		m.notifyErr(err)
+		return nil
+	}
+
+	// Same-named branches first, as they're what's usually wanted, then
+	// pushing to remotes that don't have the branch yet, then the rest.
+	// When the upstream is gone, the branch has usually been merged, and
+	// what's wanted is to get rid of it.
+	var options, others []upstreamOption
+	gone := errors.Is(m.upstreamErr, errUpstreamGone)
+	if gone && branch != GetDefaultBranch() {
+		options = append(options, upstreamOption{cleanUp: true})
+	}
+	for _, r := range remotes {
+		if ref := r + "/" + branch; slices.Contains(refs, ref) {
+			options = append(options, upstreamOption{ref: ref})
+		} else {
+			others = append(others, upstreamOption{remote: r})
+		}
+	}
+	options = append(options, others...)
+	for _, ref := range refs {
+		if !slices.ContainsFunc(options, func(o upstreamOption) bool { return o.ref == ref }) {
+			options = append(options, upstreamOption{ref: ref})
+		}
+	}
+	if m.upstreamErr == nil || gone {
+		options = append(options, upstreamOption{})
+	}
+
+	input := textinput.New()
+	input.Prompt = "Upstream for " + branch + ": "
+	input.CharLimit = 256
+	m.upstreamPicker = upstreamState{input: input, branch: branch, options: options}
+	m.matchUpstreams()
+	m.view = viewUpstream
+	m.viewport.GotoTop()
+	m.resize()
+	return m.upstreamPicker.input.Focus()
+}
+
+// matchUpstreams narrows the picker to options matching the query.
+func (m *model) matchUpstreams() {
+	p := &m.upstreamPicker
+	query := strings.TrimSpace(p.input.Value())
+	p.matches = p.matches[:0]
+	for i, o := range p.options {
+		if _, ok := fuzzyMatch(query, o.label(p.branch)); ok {
+			p.matches = append(p.matches, i)
+		}
+	}
+	m.cursor = 0
+}
+
+// updateUpstream handles key input in the upstream picker. Typing narrows
+// the list, and enter sets the selected upstream.
+func (m model) updateUpstream(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := &m.upstreamPicker
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		p.input.Blur()
+		m.view = viewFiles
+		m.resize()
+		return m, tea.ClearScreen
+	case "up", "ctrl+p":
+		m.cursor = max(m.cursor-1, 0)
+	case "down", "ctrl+n":
+		m.cursor = min(m.cursor+1, max(len(p.matches)-1, 0))
+	case "enter":
+		if m.cursor >= len(p.matches) {
+			return m, nil
+		}
+		o := p.options[p.matches[m.cursor]]
+		p.input.Blur()
+		m.view = viewFiles
+		m.resize()
+		return m, tea.Batch(m.applyUpstream(o), tea.ClearScreen)
+	default:
+		var cmd tea.Cmd
+		query := p.input.Value()
+		p.input, cmd = p.input.Update(msg)
+		if p.input.Value() != query {
+			m.matchUpstreams()
+		}
+		m.resize()
+		return m, cmd
+	}
+
+	m.resize()
+	m.scrollTo(m.cursor + 1)
+	return m, nil
+}
+
+// applyUpstream applies a picked option. Pushing runs in the background;
+// the ahead/behind counts are rechecked once the upstream is set.
+func (m *model) applyUpstream(o upstreamOption) tea.Cmd {
+	branch := m.upstreamPicker.branch
+	if o.cleanUp {
+		m.confirmCleanUpBranch(branch)
+		return nil
+	}
+	if o.remote != "" {
+		return m.startOp("Push", "Pushing", func() error { return PushTo(o.remote, branch) })
+	}
+	if o.ref == "" {
+		if err := UnsetUpstream(); err != nil {
+			m.notifyErr(err)
+			return nil
+		}
+		m.notify(branch + " no longer tracks " + m.upstream)
+		return checkUpstream
+	}
+	if err := SetUpstream(o.ref); err != nil {
+		m.notifyErr(err)
+		return nil
+	}
+	m.notify(branch + " now tracks " + o.ref)
+	return checkUpstream
+}
+
+// confirmCleanUpBranch asks before switching from a branch whose upstream
+// is gone to the default branch and deleting it. Squash and rebase merges
+// leave the branch looking unmerged, in which case deleting it asks again.
+func (m *model) confirmCleanUpBranch(branch string) {
+	if m.toolConflict("switch branches", "jj edit or jj new") {
+		return
+	}
+	def := GetDefaultBranch()
+	m.confirm = &confirmation{
+		prompt: fmt.Sprintf("Switch to %s and delete %s?", def, branch),
+		action: func(m *model) tea.Cmd {
+			if err := Checkout(def); err != nil {
+				m.notifyErr(err)
+				return nil
+			}
+			m.refresh()
+			err := DeleteBranch(branch, false)
+			if errors.Is(err, errUnmerged) {
+				m.confirmForceDeleteBranch(branch)
+				return checkUpstream
+			}
+			if err != nil {
+				m.notifyErr(err)
+				return checkUpstream
+			}
+			m.notify(fmt.Sprintf("Switched to %s and deleted %s", def, branch))
+			return m.branchesChanged()
+		},
+	}
+}
+
+func (m model) renderUpstreamPicker() string {
+	p := m.upstreamPicker
+	var body strings.Builder
+	body.WriteString(p.input.View() + "\n")
+	if len(p.matches) == 0 {
+		body.WriteString(helpStyle.Render("  No remote branches match"))
+		return body.String()
+	}
+	for row, i := range p.matches {
+		o := p.options[i]
+		marker := "  "
+		if o.ref != "" && o.ref == m.upstream && m.upstreamErr == nil {
+			marker = "* "
+		}
+		label := o.label(p.branch)
+		switch {
+		case o.cleanUp:
+			label = statusDeleted.Render(label)
+		case o.ref != "":
+			label = branchStyle.Render(label)
+		case o.remote == "":
+			label = helpStyle.Render(label) // stop tracking
+		}
+		body.WriteString(m.cursorColumn(row == m.cursor) + marker + label + "\n")
+	}
+	return body.String()
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// WatchConfig configures the command run whenever the working tree changes
+type WatchConfig struct {
+	Cmd      string `json:"cmd"`         // shell command, run from the repository root
+	Debounce int    `json:"debounce_ms"` // quiet time before running; default 1000
+
+	// Paths are files or directories outside the working tree, e.g. a
+	// sibling repository or a generated output directory, whose changes
+	// also refresh vigil and run the command. Relative paths are relative
+	// to the repository root
+	Paths []string `json:"paths"`
+}
+
+// watchPathFiles caps how many files are looked at under the watch paths
+// on each poll, so pointing one at a huge directory can't stall vigil
+const watchPathFiles = 20000
+
+func (c *WatchConfig) validate() error {
+	for i, p := range c.Paths {
+		if p == "" {
+			return fmt.Errorf("path %d is empty", i+1)
+		}
+		if rest, ok := cutHome(p); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+			c.Paths[i] = filepath.Join(home, rest)
+		}
+	}
+	return nil
+}
+
+// watchState tracks the watch command: which working tree state it last
+// ran for, and its latest result
+type watchState struct {
+	cmd      string
+	debounce time.Duration
+	paths    []string
+
+	seen      string    // latest working tree fingerprint
+	pathsSeen string    // latest fingerprint of the watch paths
+	changedAt time.Time // when seen last changed
+	ranFor    string    // fingerprint the latest run started on
+
+	output  string
+	err     error
+	elapsed time.Duration
+	done    time.Time // zero until the first run finishes
+}
+
+// watchScanMsg carries the working tree and watch path fingerprints from
+// a poll
+type watchScanMsg struct {
+	tree    string
+	paths   string
+	elapsed time.Duration
+}
+
+// watchDoneMsg reports a finished watch run
+type watchDoneMsg struct {
+	output  string
+	err     error
+	elapsed time.Duration
+}
+
+func newWatchState(cfg WatchConfig) watchState {
+	debounce := time.Duration(cfg.Debounce) * time.Millisecond
+	if debounce <= 0 {
+		debounce = time.Second
+	}
+	return watchState{cmd: cfg.Cmd, debounce: debounce, paths: cfg.Paths}
+}
+
+// enabled reports whether anything needs the working tree polled.
+func (w watchState) enabled() bool {
+	return w.cmd != "" || len(w.paths) > 0
+}
+
+// scan polls the working tree, and the watch paths if there are any, for
+// changes every second.
+func (w watchState) scan() tea.Cmd {
+	paths := w.paths
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		start := time.Now()
+		msg := watchScanMsg{paths: PathsFingerprint(paths)}
+		if w.cmd != "" {
+			msg.tree = WorkingTreeFingerprint()
+		}
+		msg.elapsed = time.Since(start)
+		return msg
+	})
+}
+
+// PathsFingerprint returns a value that changes whenever a file under
+// paths is added, removed or modified. .git directories are skipped, since
+// git updates them just by being asked for status.
+func PathsFingerprint(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	root, _ := GetRepoRoot()
+	gitDirs := gitDirs()
+	h := fnv.New64a()
+	files := 0
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(root, p)
+		}
+		filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && (d.Name() == ".git" || slices.Contains(gitDirs, path)) {
+				return filepath.SkipDir
+			}
+			if files++; files > watchPathFiles {
+				return filepath.SkipAll
+			}
+			if info, err := d.Info(); err == nil {
+				fmt.Fprintf(h, "%s %d %d\x00", path, info.ModTime().UnixNano(), info.Size())
+			}
+			return nil
+		})
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// updateWatch refreshes when the watch paths change, and starts the watch
+// command once the working tree or watch paths have changed since its
+// last run and then stayed unchanged for the debounce time.
+func (m *model) updateWatch(msg watchScanMsg) tea.Cmd {
+	w := &m.watch
+	m.metrics.scan.add(msg.elapsed)
+	if msg.paths != w.pathsSeen {
+		if w.pathsSeen != "" {
+			m.metrics.pathChanges++
+			debugLog.Debug("watch paths changed", "scan", msg.elapsed)
+			m.refresh()
+		}
+		w.pathsSeen = msg.paths
+	}
+	if w.cmd == "" {
+		return w.scan()
+	}
+	if fingerprint := msg.tree + msg.paths; fingerprint != w.seen {
+		w.seen = fingerprint
+		w.changedAt = m.now()
+		m.metrics.treeChanges++
+		debugLog.Debug("watch change", "scan", msg.elapsed)
+	}
+	if m.jobs.watching || w.seen == w.ranFor || m.now().Sub(w.changedAt) < w.debounce {
+		return w.scan()
+	}
+
+	m.jobs.watching = true
+	w.ranFor = w.seen
+	script := w.cmd
+	m.metrics.watchRuns++
+	debugLog.Debug("watch run", "cmd", script)
+	return tea.Batch(w.scan(), m.jobs.spinner.Tick, func() tea.Msg {
+		root, err := GetRepoRoot()
+		if err != nil {
+			return watchDoneMsg{err: err}
+		}
+		start := time.Now()
+		cmd := shellCommand(script)
+		cmd.Dir = root
+		output, err := cmd.CombinedOutput()
+		return watchDoneMsg{output: string(output), err: err, elapsed: time.Since(start)}
+	})
+}
+
+// watchDone records a finished run, refreshing the output pane if it's
+// showing the watch output.
+func (m *model) watchDone(msg watchDoneMsg) {
+	w := &m.watch
+	failed := msg.err != nil
+	switch {
+	case failed && (w.done.IsZero() || w.err == nil):
+		m.alert("Watch failed", w.cmd)
+	case !failed && w.err != nil:
+		m.alert("Watch passing again", w.cmd)
+	}
+	debugLog.Debug("watch done", "duration", msg.elapsed, "error", msg.err)
+	m.jobs.watching = false
+	w.output, w.err, w.elapsed = msg.output, msg.err, msg.elapsed
+	w.done = m.now()
+	if m.view == viewOutput && m.output.watch {
+		m.showWatchOutput()
+	}
+}
+
+// showWatchOutput opens the latest watch output in the output pane.
+func (m *model) showWatchOutput() {
+	if m.watch.cmd == "" {
+		m.notify("No watch command configured")
+		return
+	}
+	parent := m.view
+	if m.view == viewOutput {
+		parent = m.output.parent
+	}
+	m.output = outputState{
+		title:  fmt.Sprintf("$ %s (%s)", m.watch.cmd, m.watch.elapsed.Round(time.Millisecond*100)),
+		err:    m.watch.err,
+		parent: parent,
+		watch:  true,
+	}
+	if output := strings.TrimRight(m.watch.output, "\n"); output != "" {
+		m.output.lines = strings.Split(output, "\n")
+	}
+	m.view = viewOutput
+	m.resize()
+}
+
+// renderWatchLine renders the watch command's state, e.g.
+// "Watch: go test ./... ✓ passed 1m ago (2.1s)".
+func (m model) renderWatchLine() string {
+	w := m.watch
+	var state string
+	switch {
+	case m.jobs.watching:
+		state = m.jobs.spin() + helpStyle.Render(" running")
+	case w.done.IsZero():
+		state = helpStyle.Render("waiting")
+	case w.err != nil:
+		state = errorStyle.Render(glyphs.Cross + " failed")
+	default:
+		state = statusAdded.Render(glyphs.Check + " passed")
+	}
+	if m.narrow() {
+		return "watch " + state
+	}
+	if !m.jobs.watching && !w.done.IsZero() {
+		state += helpStyle.Render(fmt.Sprintf(" %s (%s)", m.timeAgo(w.done), w.elapsed.Round(time.Millisecond*100)))
+	}
+	return "Watch: " + truncate(w.cmd, 40) + " " + state
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Events that can be sent to webhooks
+const (
+	eventBehind    = "became-behind"      // a fetch or recount found new upstream commits
+	eventConflicts = "conflicts-appeared" // the working tree went from no conflicts to some
+	eventCommit    = "commit-created"     // a commit was made on the current branch
+	eventDirty     = "dirty-protected"    // uncommitted changes appeared on a protected branch
+)
+
+var knownEvents = []string{eventBehind, eventConflicts, eventCommit, eventDirty}
+
+// defaultProtected are the protected branches when the config names none
+var defaultProtected = []string{"main", "master"}
+
+// Webhook is a URL that receives a JSON POST on repository events
+type Webhook struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // empty means all events
+
+	// Interval is the fewest minutes between two posts of the same event;
+	// 0 posts every time
+	Interval int `json:"interval_minutes"`
+}
+
+func (w Webhook) validate() error {
+	// The URL is left out of the errors: chat webhook URLs are secrets
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("url: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url must be http or https, got %q", u.Scheme+":")
+	}
+	for _, e := range w.Events {
+		if !slices.Contains(knownEvents, e) {
+			return fmt.Errorf("unknown event %q (want %s)", e, strings.Join(knownEvents, ", "))
+		}
+	}
+	return nil
+}
+
+func (w Webhook) wants(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// webhookPayload is the JSON body posted to webhooks
+type webhookPayload struct {
+	Event   string     `json:"event"`
+	Message string     `json:"message"`
+	Status  statusFile `json:"status"`
+}
+
+// emit posts an event to the webhooks that want it, unless they posted
+// it too recently. Posting happens in the background; failures are
+// collected in webhookErrs and shown on the next refresh.
+func (m *model) emit(event, message string) {
+	payload := webhookPayload{Event: event, Message: message, Status: m.snapshot()}
+	payload.Status.Updated = time.Now()
+	for i, w := range m.webhooks {
+		if !w.wants(event) {
+			continue
+		}
+		sent := m.webhookSent[i]
+		if last, ok := sent[event]; ok && time.Since(last) < time.Duration(w.Interval)*time.Minute {
+			continue
+		}
+		sent[event] = time.Now()
+		go postWebhook(w, payload, m.webhookErrs)
+	}
+}
+
+func postWebhook(w Webhook, payload webhookPayload, errs chan<- error) {
+	data, err := json.Marshal(payload)
+	if err == nil {
+		err = post(w.URL, data)
+	}
+	if err != nil {
+		select {
+		case errs <- fmt.Errorf("webhook %s: %v", payload.Event, err):
+		default: // don't pile up errors nobody has seen yet
+		}
+	}
+}
+
+// post sends data to a webhook. Its errors name only the URL's host, as
+// Slack and Discord webhook URLs carry their token in the path.
+func post(target string, data []byte) error {
+	host := "webhook"
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(target, "application/json", bytes.NewReader(data))
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err // its message quotes the whole URL
+		}
+		return fmt.Errorf("%s: %v", host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", host, resp.Status)
+	}
+	return nil
+}
+
+// webhookErr returns a webhook failure that hasn't been reported yet.
+func (m *model) webhookErr() error {
+	select {
+	case err := <-m.webhookErrs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// protected reports whether branch matches one of the protected patterns.
+func protected(branch string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, branch); ok {
+			return true
+		}
+	}
+	return false
+}
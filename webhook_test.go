@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookErrorsHideURL(t *testing.T) {
+	const token = "T0000/B0000/s3cr3t"
+	forbidden := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer forbidden.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for name, err := range map[string]error{
+		"status":      post(forbidden.URL+"/services/"+token, nil),
+		"unreachable": post(closed.URL+"/services/"+token, nil),
+		"scheme":      Webhook{URL: "ftp://hooks.example.com/services/" + token}.validate(),
+		"unparsable":  Webhook{URL: "https://hooks.example.com/services/" + token + "%zz"}.validate(),
+	} {
+		if err == nil {
+			t.Errorf("%s: no error", name)
+			continue
+		}
+		if strings.Contains(err.Error(), token) {
+			t.Errorf("%s: error shows the webhook's token: %v", name, err)
+		}
+	}
+	if err := post(forbidden.URL+"/services/"+token, nil); !strings.Contains(err.Error(), "403") {
+		t.Errorf("status error %q doesn't say what the webhook returned", err)
+	}
+}
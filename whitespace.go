@@ -0,0 +1,74 @@
+package main
+
+import "fmt"
+
+// whitespaceOnly holds the files in each panel whose changes are all
+// whitespace, such as reindentation, by path
+type whitespaceOnly struct {
+	changes map[string]bool // uncommitted, against HEAD
+	branch  map[string]bool // since the merge base
+}
+
+// whitespace finds which changed and branch files differ only in
+// whitespace, keeping the branch's unless it has moved.
+func (r *loadRun) whitespace() whitespaceOnly {
+	var w whitespaceOnly
+	if r.s.hasCommit && len(r.s.changes) > 0 {
+		w.changes = r.whitespaceOnly(r.headStats(), "HEAD")
+	}
+	if !r.s.branchMoved {
+		w.branch = r.set.whitespace.branch
+		return w
+	}
+	if len(r.s.branchFiles) > 0 {
+		if base := r.mergeBase(); base != "" {
+			w.branch = r.whitespaceOnly(r.branchStats(), base, "HEAD")
+		}
+	}
+	return w
+}
+
+// whitespaceOnly returns the files in stats, git diff with revs, whose
+// changes are all whitespace, diffing again under -w if any changed.
+func (r *loadRun) whitespaceOnly(stats map[string]DiffStat, revs ...string) map[string]bool {
+	if len(stats) == 0 {
+		return nil
+	}
+	return whitespaceOnlyFiles(stats, GetDiffStatsContext(r.ctx, append([]string{"--ignore-all-space"}, revs...)...))
+}
+
+// isWhitespaceOnly reports whether a file's changes in a panel are all
+// whitespace.
+func (m model) isWhitespaceOnly(panel, file string) bool {
+	if panel == panelBranch {
+		return m.whitespace.branch[treePath(file)]
+	}
+	return m.whitespace.changes[treePath(file)]
+}
+
+// hidesWhitespace reports whether a file is left out of a panel for
+// having only whitespace changes.
+func (m model) hidesWhitespace(panel, file string) bool {
+	return m.hideSpace && m.isWhitespaceOnly(panel, file)
+}
+
+// toggleHideWhitespace shows or hides files with only whitespace changes.
+func (m *model) toggleHideWhitespace() {
+	m.hideSpace = !m.hideSpace
+	m.notify("Whitespace-only files " + shownHidden(!m.hideSpace))
+	m.moveSelection(0)
+}
+
+// whitespaceBadge marks a file whose changes are all whitespace.
+func whitespaceBadge(text string) string {
+	return text + helpStyle.Render(" whitespace")
+}
+
+// whitespaceHidden notes in a panel title how many whitespace-only files
+// it leaves out.
+func whitespaceHidden(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d whitespace-only hidden)", n)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// openWorktrees switches to the worktree list view.
+func (m *model) openWorktrees() {
+	if !gitAtLeast(2, 7) {
+		m.notify("The worktree list needs git 2.7 or newer")
+		return
+	}
+	m.worktrees = GetWorktrees()
+	m.view = viewWorktrees
+	m.cursor = 0
+	for i, wt := range m.worktrees {
+		if samePath(wt.Path, m.dir) {
+			m.cursor = i
+		}
+	}
+	m.viewport.GotoTop()
+	m.resize()
+	m.scrollTo(m.cursor + 1)
+}
+
+// updateWorktrees handles key input in the worktree list view.
+func (m model) updateWorktrees(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "esc", "w":
+		m.view = viewFiles
+		m.resize()
+		return m, tea.ClearScreen
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.worktrees)-1 {
+			m.cursor++
+		}
+	case "r":
+		m.worktrees = GetWorktrees()
+		m.cursor = min(m.cursor, max(len(m.worktrees)-1, 0))
+	case "enter":
+		if m.cursor >= len(m.worktrees) || m.worktrees[m.cursor].Bare {
+			return m, nil
+		}
+		path := m.worktrees[m.cursor].Path
+		restore := clearGitEnv()
+		if err := os.Chdir(path); err != nil {
+			restore()
+			return m, nil
+		}
+		m.dir = path
+		m.view = viewFiles
+		m.viewport.GotoTop()
+		m.refresh()
+		return m, tea.Batch(checkUpstream, tea.ClearScreen)
+	}
+
+	m.resize()
+	m.scrollTo(m.cursor + 1)
+	return m, nil
+}
+
+func (m model) renderWorktrees() string {
+	var body strings.Builder
+	body.WriteString("Worktrees:\n")
+	if len(m.worktrees) == 0 {
+		body.WriteString(helpStyle.Render("  No worktrees found"))
+		return body.String()
+	}
+
+	for i, wt := range m.worktrees {
+		branch := wt.Branch
+		switch {
+		case wt.Bare:
+			branch = "(bare)"
+		case wt.Detached && len(wt.Head) >= 7:
+			branch = "(detached) " + wt.Head[:7]
+		}
+
+		state := statusAdded.Render("clean ")
+		if wt.Bare {
+			state = helpStyle.Render("-     ")
+		} else if wt.Dirty {
+			state = statusModified.Render("dirty ")
+		}
+
+		marker := " "
+		if i == m.cursor {
+			marker = glyphs.Cursor
+		}
+		if samePath(wt.Path, m.dir) {
+			marker += "* "
+		} else {
+			marker += "  "
+		}
+
+		line := fmt.Sprintf("%s%s  %s  %s", marker, state, branchStyle.Render(branch), pathStyle.Render(wt.Path))
+		if m.narrow() {
+			line = fmt.Sprintf("%s%s %s", marker, branchStyle.Render(branch), pathStyle.Render(filepath.Base(wt.Path)))
+		}
+		body.WriteString(line + "\n")
+	}
+	return body.String()
+}
+
+// samePath reports whether a and b name the same directory, resolving symlinks.
+func samePath(a, b string) bool {
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	if errA != nil || errB != nil {
+		ra, rb = filepath.Clean(a), filepath.Clean(b)
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(ra, rb) // C:\Repo and c:\repo are the same
+	}
+	return ra == rb
+}